@@ -2,9 +2,10 @@ package db
 
 import (
 	"context"
-	"fmt"
-	"os"
+	"log"
+	"notebook-service/internal/config"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -14,17 +15,21 @@ var db *redis.Client
 func Setup(c context.Context) *redis.Client {
 	// Connect to redis
 	db = redis.NewClient(&redis.Options{
-		Addr:     os.Getenv("REDIS_URL"),
-		Password: os.Getenv("REDIS_PASSWORD"),
+		Addr:     config.Get().Redis.URL,
+		Password: config.Get().Redis.Password,
 		DB:       0,
 	})
 
+	// Trace every redis command
+	if err := redisotel.InstrumentTracing(db); err != nil {
+		log.Printf("Failed to instrument redis tracing: %v", err)
+	}
+
 	// Check connectivity
 	err := db.Ping(c).Err()
 
 	if err != nil {
-		fmt.Printf("Could not connect to redis: " + err.Error())
-		return nil
+		log.Fatalf("Could not connect to redis: %v", err)
 	}
 
 	return db
@@ -5,10 +5,13 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
+	"notebook-service/internal/config"
+	"notebook-service/internal/metrics"
 
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
 var client *mongo.Client
@@ -16,11 +19,9 @@ var client *mongo.Client
 // Method to setup mongodb connection
 func SetupMongoDB() *mongo.Database {
 	// Set the client option
-	url := os.Getenv("DB_URL")
-	username := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASS")
+	cfg := config.Get().MongoDB
 
-	opt := options.Client().ApplyURI(fmt.Sprintf("mongodb://%s:%s@%s", username, password, url))
+	opt := options.Client().ApplyURI(fmt.Sprintf("mongodb://%s:%s@%s", cfg.User, cfg.Password, cfg.URL)).SetMonitor(combineMonitors(metrics.MongoMonitor(), otelmongo.NewMonitor()))
 
 	// Connect to mongodb
 	client, err := mongo.Connect(context.TODO(), opt)
@@ -35,9 +36,7 @@ func SetupMongoDB() *mongo.Database {
 	}
 
 	// Get the database instance
-	dbName := os.Getenv("DB_NAME")
-
-	return client.Database(dbName)
+	return client.Database(cfg.Database)
 }
 
 func Close() {
@@ -45,3 +44,30 @@ func Close() {
 		log.Fatal(err)
 	}
 }
+
+// Combines command monitors, since the client only accepts one. Commands are both measured and traced this way.
+func combineMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, monitor := range monitors {
+				if monitor.Started != nil {
+					monitor.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, monitor := range monitors {
+				if monitor.Succeeded != nil {
+					monitor.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, monitor := range monitors {
+				if monitor.Failed != nil {
+					monitor.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}
@@ -1,40 +1,50 @@
 package grpc
 
 import (
+	"context"
 	"log"
 	"net"
 	"notebook-service/api/controller"
 	"notebook-service/internal/auth"
-	"os"
+	"notebook-service/internal/config"
+	"notebook-service/internal/health"
+	"notebook-service/internal/metrics"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 )
 
-func SetupGRPCServer(tokenService controller.NotebookServiceServer) {
+func SetupGRPCServer(ctx context.Context, tokenService controller.NotebookServiceServer, operationsService controller.OperationsServer, deadLettersService controller.DeadLettersServer, webhooksService controller.WebhooksServer, checker *health.Checker) {
 	server, lis, url := CreateGRPCServer()
-	// Register the service
+	// Register the services
 	controller.RegisterNotebookServiceServer(server, tokenService)
+	controller.RegisterOperationsServer(server, operationsService)
+	controller.RegisterDeadLettersServer(server, deadLettersService)
+	controller.RegisterWebhooksServer(server, webhooksService)
+	checker.Register(server)
 
 	// Run the server
 	log.Printf("gRPC server running on %s", url)
-	if err := server.Serve(lis); err != nil {
+	if err := serve(ctx, server, lis, checker); err != nil {
 		log.Fatalf("Unable to run gRPC server on %s", url)
 	}
-
+	log.Println("gRPC server stopped")
 }
 
 func CreateGRPCServer() (*grpc.Server, net.Listener, string) {
 	// Listen the tcp port for grpc server
-	url := os.Getenv("SERVER_URL")
+	url := config.Get().Server.URL
 
 	lis, err := net.Listen("tcp", url)
 	if err != nil {
 		log.Fatalf("Failed listening to tcp %s: %v", url, err)
 	}
 
-	// Create a new gRPC server
-	interceptor := grpc.UnaryInterceptor(auth.AuthInterceptor)
-	server := grpc.NewServer(interceptor)
+	// Create a new gRPC server, counting calls before they are authenticated
+	interceptor := grpc.ChainUnaryInterceptor(metrics.UnaryServerInterceptor, auth.AuthInterceptor)
+	streamInterceptor := grpc.ChainStreamInterceptor(metrics.StreamServerInterceptor, auth.AuthStreamInterceptor)
+	tracing := grpc.StatsHandler(otelgrpc.NewServerHandler())
+	server := grpc.NewServer(interceptor, streamInterceptor, tracing)
 
 	return server, lis, url
 }
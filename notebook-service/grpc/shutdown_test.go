@@ -0,0 +1,36 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"notebook-service/internal/health"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestServeStopsWhenContextIsDone(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	server := grpc.NewServer()
+	checker := health.NewChecker()
+	checker.Register(server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() {
+		served <- serve(ctx, server, lis, checker)
+	}()
+
+	cancel()
+
+	select {
+	case err := <-served:
+		assert.Nil(t, err)
+	case <-time.After(SHUTDOWN_TIMEOUT):
+		t.Fatal("server did not stop")
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
+	"notebook-service/internal/config"
+	"strings"
 
 	"github.com/dgrijalva/jwt-go"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
@@ -22,15 +24,18 @@ const (
 type key string
 
 const CtxKey key = "username"
+const RoleCtxKey key = "role"
+const TeamCtxKey key = "team"
 
 type JWTClaims struct {
 	Username string `json:"iss"`
 	Role     string `json:"role"`
+	Team     string `json:"team,omitempty"` // Optional, used to charge usage to a team
 	jwt.StandardClaims
 }
 
 func ValidateToken(tokenString string) (*JWTClaims, error) {
-	jwtSecret := os.Getenv("SECRET_KEY")
+	jwtSecret := config.Get().Auth.SecretKey
 
 	claims := &JWTClaims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
@@ -48,6 +53,54 @@ func AuthInterceptor(
 	info *grpc.UnaryServerInfo,
 	handler grpc.UnaryHandler,
 ) (resp interface{}, err error) {
+	if isPublicMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+
+	ctx, err = authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// AuthStreamInterceptor authenticates streaming calls the same way AuthInterceptor does for unary calls
+func AuthStreamInterceptor(
+	srv interface{},
+	stream grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	if isPublicMethod(info.FullMethod) {
+		return handler(srv, stream)
+	}
+
+	ctx, err := authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticatedStream overrides the stream context so handlers can read the username
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// Health checks are answered without a token, so probes and the gateway can call them
+func isPublicMethod(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/"+healthpb.Health_ServiceDesc.ServiceName+"/")
+}
+
+// Validates the bearer token in the metadata and stores the username in the context
+func authenticate(ctx context.Context) (context.Context, error) {
 	// Extract the token from the metadata
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
@@ -67,8 +120,23 @@ func AuthInterceptor(
 		return nil, status.Error(codes.Unauthenticated, "invalid token")
 	}
 
-	// Set the username in the context for later use
+	// Set the username, role and team in the context for later use
 	ctx = context.WithValue(ctx, CtxKey, claims.Username)
+	ctx = context.WithValue(ctx, TeamCtxKey, claims.Team)
+	return context.WithValue(ctx, RoleCtxKey, claims.Role), nil
+}
 
-	return handler(ctx, req)
+// Returns the role stored in the context, or UNKNOWN if there is none
+func GetRole(ctx context.Context) string {
+	role, ok := ctx.Value(RoleCtxKey).(string)
+	if !ok || role == "" {
+		return UNKNOWN
+	}
+	return role
+}
+
+// Returns the team stored in the context, or an empty string if the user has no team
+func GetTeam(ctx context.Context) string {
+	team, _ := ctx.Value(TeamCtxKey).(string)
+	return team
 }
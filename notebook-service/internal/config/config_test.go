@@ -0,0 +1,156 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Sets every required variable, so tests only change what they check
+func setRequiredEnvironment(t *testing.T) {
+	for name, value := range map[string]string{
+		"SECRET_KEY":         "jwt-secret",
+		"REDIS_URL":          "localhost:6379",
+		"DB_URL":             "localhost:27017",
+		"DB_USER":            "user",
+		"DB_PASS":            "mongo-secret",
+		"DB_NAME":            "notebooks",
+		"RABBIT_MQ_URL":      "localhost:5672",
+		"RABBIT_MQ_USERNAME": "guest",
+		"RABBIT_MQ_PASSWORD": "rabbit-secret",
+		"URLBASE":            "http://kubeflow.local",
+		"NAMESPACE":          "kubeflow-user-example-com",
+	} {
+		t.Setenv(name, value)
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadDefaultsAndEnvironment(t *testing.T) {
+	setRequiredEnvironment(t)
+	t.Setenv("ALLOWED_STORAGE_CLASSES", "standard, nfs,")
+	t.Setenv("IDEMPOTENCY_TTL", "1h")
+
+	config, err := Load("")
+
+	assert.Nil(t, err)
+	assert.Same(t, config, Get())
+	assert.Equal(t, ":50053", config.Server.URL)
+	assert.Equal(t, ":9090", config.Metrics.URL)
+	assert.Equal(t, "ReadWriteOnce", config.Service.DefaultAccessMode)
+	assert.Equal(t, []string{"standard", "nfs"}, config.Service.AllowedStorageClasses)
+	assert.Equal(t, time.Hour, config.Service.IdempotencyTTL)
+	assert.Equal(t, VOLUME_MISSING_FLAG, config.Service.VolumeMissingPolicy)
+	assert.Equal(t, 5, config.RabbitMQ.MaxRetries)
+	assert.Equal(t, time.Second, config.RabbitMQ.RetryDelay)
+	assert.Equal(t, 1, config.RabbitMQ.Workers)
+	assert.Equal(t, 30*time.Second, config.RabbitMQ.DedupLease)
+	assert.Equal(t, 5*time.Second, config.Outbox.PollInterval)
+	assert.Equal(t, 100, config.Outbox.BatchSize)
+	assert.Equal(t, 8, config.Webhooks.MaxAttempts)
+	assert.Equal(t, 30*time.Second, config.Webhooks.RetryDelay)
+	assert.Equal(t, "mongo-secret", config.MongoDB.Password)
+}
+
+func TestLoadEnvironmentOverridesFile(t *testing.T) {
+	setRequiredEnvironment(t)
+	t.Setenv("NAMESPACE", "from-environment")
+
+	path := writeFile(t, `
+server:
+  url: ":6000"
+service:
+  namespace: from-file
+  idempotencyTTL: 30m
+`)
+
+	config, err := Load(path)
+
+	assert.Nil(t, err)
+	assert.Equal(t, ":6000", config.Server.URL)
+	assert.Equal(t, "from-environment", config.Service.Namespace)
+	assert.Equal(t, 30*time.Minute, config.Service.IdempotencyTTL)
+}
+
+func TestLoadReportsEveryError(t *testing.T) {
+	setRequiredEnvironment(t)
+	t.Setenv("SECRET_KEY", "")
+	t.Setenv("IDEMPOTENCY_TTL", "soon")
+	t.Setenv("TRACING_EXPORTER", "jaeger")
+	t.Setenv("DEFAULT_STORAGE_CLASS", "gp2")
+	t.Setenv("ALLOWED_STORAGE_CLASSES", "standard")
+	t.Setenv("RABBIT_MQ_MAX_RETRIES", "-1")
+	t.Setenv("RABBIT_MQ_WORKERS", "20")
+	t.Setenv("RABBIT_MQ_DEDUP_LEASE", "48h")
+	t.Setenv("VOLUME_MISSING_POLICY", "delete")
+	t.Setenv("WEBHOOKS_MAX_ATTEMPTS", "0")
+	t.Setenv("WEBHOOKS_ALLOWED_HOSTS", "hooks.svc, 10.0.0.0/33")
+
+	previous := Get()
+	_, err := Load("")
+
+	assert.NotNil(t, err)
+	assert.ErrorContains(t, err, "auth.secretKey is required, set it in the config file or with SECRET_KEY")
+	assert.ErrorContains(t, err, `IDEMPOTENCY_TTL: expected a duration, got "soon"`)
+	assert.ErrorContains(t, err, `tracing.exporter must be otlp or stdout, got "jaeger"`)
+	assert.ErrorContains(t, err, "service.defaultStorageClass gp2 is not in service.allowedStorageClasses")
+	assert.ErrorContains(t, err, "rabbitmq.maxRetries can't be negative, got -1")
+	assert.ErrorContains(t, err, "rabbitmq.workers must be between 1 and rabbitmq.prefetch, got 20")
+	assert.ErrorContains(t, err, "rabbitmq.dedupLease must be positive and at most rabbitmq.dedupTTL, got 48h0m0s")
+	assert.ErrorContains(t, err, `service.volumeMissingPolicy must be flag or stop, got "delete"`)
+	assert.ErrorContains(t, err, "webhooks.maxAttempts must be positive, got 0")
+	assert.ErrorContains(t, err, `webhooks.allowedHosts has an invalid CIDR range "10.0.0.0/33"`)
+	assert.Same(t, previous, Get())
+}
+
+func TestLoadRabbitMQDrivers(t *testing.T) {
+	setRequiredEnvironment(t)
+	t.Setenv("RABBIT_MQ_URL", "")
+
+	_, err := Load("")
+	assert.ErrorContains(t, err, "rabbitmq.url is required by the amqp driver, set it in the config file or with RABBIT_MQ_URL")
+
+	// The in-memory bus doesn't connect to RabbitMQ
+	t.Setenv("RABBIT_MQ_DRIVER", "memory")
+	config, err := Load("")
+	assert.Nil(t, err)
+	assert.Equal(t, MEMORY_DRIVER, config.RabbitMQ.Driver)
+
+	t.Setenv("RABBIT_MQ_DRIVER", "kafka")
+	_, err = Load("")
+	assert.ErrorContains(t, err, `rabbitmq.driver must be amqp or memory, got "kafka"`)
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	setRequiredEnvironment(t)
+	path := writeFile(t, "service:\n  namespaces: typo\n")
+
+	_, err := Load(path)
+
+	assert.ErrorContains(t, err, "field namespaces not found")
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	setRequiredEnvironment(t)
+	config, err := Load("")
+	assert.Nil(t, err)
+
+	var out bytes.Buffer
+	assert.Nil(t, Print(&out, config))
+
+	assert.Contains(t, out.String(), "namespace: kubeflow-user-example-com")
+	assert.Contains(t, out.String(), "secretKey: "+REDACTED)
+	assert.NotContains(t, out.String(), "jwt-secret")
+	assert.NotContains(t, out.String(), "mongo-secret")
+	assert.NotContains(t, out.String(), "rabbit-secret")
+	assert.Equal(t, "jwt-secret", config.Auth.SecretKey)
+}
@@ -0,0 +1,48 @@
+package health
+
+import (
+	"context"
+	"notebook-service/internal/rabbitmq"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Names of the dependencies, under which their status is served
+const (
+	REDIS      = "redis"
+	MONGODB    = "mongodb"
+	RABBITMQ   = "rabbitmq"
+	KUBERNETES = "kubernetes"
+)
+
+// Checks that redis answers a ping
+func Redis(client *redis.Client) Check {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}
+}
+
+// Checks that the primary of mongodb answers a ping
+func MongoDB(client *mongo.Client) Check {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx, readpref.Primary())
+	}
+}
+
+// Checks that the handler is connected to RabbitMQ, reporting the state of the connection otherwise
+func RabbitMQ(rbmq rabbitmq.RabbitMQHandler) Check {
+	return func(ctx context.Context) error {
+		return rbmq.CheckConnection()
+	}
+}
+
+// Checks that the Kubernetes API server can be reached by the clients of the service
+func Kubernetes(clientset kubernetes.Interface) Check {
+	return func(ctx context.Context) error {
+		// The version is readable without any permission
+		return clientset.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Error()
+	}
+}
@@ -0,0 +1,68 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Returns the status served for the service name
+func servedStatus(t *testing.T, checker *Checker, service string) healthpb.HealthCheckResponse_ServingStatus {
+	res, err := checker.server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	assert.Nil(t, err)
+	return res.Status
+}
+
+func TestCheckerNotReadyBeforeFirstCheck(t *testing.T) {
+	checker := NewChecker()
+	checker.AddCheck(REDIS, func(ctx context.Context) error { return nil })
+
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servedStatus(t, checker, ""))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servedStatus(t, checker, LIVENESS))
+}
+
+func TestCheckerFollowsDependencies(t *testing.T) {
+	var redisErr error
+	checker := NewChecker()
+	checker.AddCheck(REDIS, func(ctx context.Context) error { return redisErr })
+	checker.AddCheck(MONGODB, func(ctx context.Context) error { return nil })
+
+	server := grpc.NewServer()
+	server.RegisterService(&grpc.ServiceDesc{ServiceName: "notebookservice.NotebookService", HandlerType: (*interface{})(nil)}, nil)
+	checker.Register(server)
+
+	// Every dependency is up
+	assert.True(t, checker.CheckAll(context.Background()))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servedStatus(t, checker, ""))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servedStatus(t, checker, REDIS))
+
+	// A critical dependency goes down
+	redisErr = errors.New("connection refused")
+	assert.False(t, checker.CheckAll(context.Background()))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servedStatus(t, checker, ""))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servedStatus(t, checker, "notebookservice.NotebookService"))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servedStatus(t, checker, REDIS))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servedStatus(t, checker, MONGODB))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servedStatus(t, checker, LIVENESS))
+
+	// The dependency recovers
+	redisErr = nil
+	assert.True(t, checker.CheckAll(context.Background()))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servedStatus(t, checker, ""))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servedStatus(t, checker, REDIS))
+}
+
+func TestCheckerTimesOutSlowChecks(t *testing.T) {
+	checker := NewChecker()
+	checker.AddCheck(KUBERNETES, func(ctx context.Context) error {
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline)
+		return nil
+	})
+
+	assert.True(t, checker.CheckAll(context.Background()))
+}
@@ -1,33 +1,68 @@
 package internal
 
 import (
-	"flag"
-	"path/filepath"
-	"sync"
+	"errors"
+	"fmt"
+	"log"
+	"notebook-service/internal/config"
+	"notebook-service/internal/tracing"
 
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
 )
 
-var kubeconfig *string
-var kubeconfigOnce sync.Once // Ensures the flag is defined only once
+// Clients of the Kubernetes API, built once and shared by the whole process
+type KubeClients struct {
+	Dynamic   dynamic.Interface
+	Clientset kubernetes.Interface
+}
+
+// Builds the clients from the config of the cluster the service runs in
+func NewKubeClients(cfg config.KubernetesConfig) (*KubeClients, error) {
+	restConfig, err := GetKubeConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tracing.InstrumentKubernetes(restConfig)
 
-var GetKubeConfig = func() (*rest.Config, error) {
-	// Use sync.Once to ensure flag is only defined once
-	kubeconfigOnce.Do(func() {
-		if home := homedir.HomeDir(); home != "" {
-			kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
-		} else {
-			kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating dynamic client: %v", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating client set: %v", err)
+	}
+
+	return &KubeClients{Dynamic: dynamicClient, Clientset: clientset}, nil
+}
+
+// GetKubeConfig uses the service account of the pod when running inside the cluster. Otherwise, or when
+// a kubeconfig or a context is set explicitly, the kubeconfig is loaded the same way kubectl does.
+func GetKubeConfig(cfg config.KubernetesConfig) (*rest.Config, error) {
+	if cfg.Kubeconfig == "" && cfg.Context == "" {
+		restConfig, err := rest.InClusterConfig()
+		if err == nil {
+			log.Println("Using in-cluster kubernetes config")
+			return restConfig, nil
 		}
-		flag.Parse() // Parse flags only once
-	})
+		if !errors.Is(err, rest.ErrNotInCluster) {
+			return nil, fmt.Errorf("failed loading in-cluster config: %v", err)
+		}
+	}
 
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	// Follows KUBECONFIG and falls back to ~/.kube/config, unless a path is given
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = cfg.Kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: cfg.Context}
+
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed loading kubeconfig: %v", err)
 	}
 
-	return config, nil
+	return restConfig, nil
 }
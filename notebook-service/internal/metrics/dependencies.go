@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.mongodb.org/mongo-driver/event"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+)
+
+const (
+	SUCCESS = "success"
+	FAILURE = "failure"
+
+	HIT  = "hit"
+	MISS = "miss"
+
+	PROCESSED = "processed"
+	DUPLICATE = "duplicate"
+)
+
+var rabbitMQPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "rabbitmq_published_messages_total",
+	Help: "Number of messages published to RabbitMQ, by routing key and result.",
+}, []string{"routing_key", "result"})
+
+var rabbitMQConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "rabbitmq_consumed_messages_total",
+	Help: "Number of messages consumed from RabbitMQ, by routing key and result.",
+}, []string{"routing_key", "result"})
+
+var rabbitMQDeduplicated = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "rabbitmq_deduplicated_events_total",
+	Help: "Number of consumed events checked for duplicates, by routing key and whether they were processed or skipped as duplicates.",
+}, []string{"routing_key", "result"})
+
+var webhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "webhook_delivery_attempts_total",
+	Help: "Number of attempts to deliver an event to the endpoint of a webhook, by event type and result.",
+}, []string{"event_type", "result"})
+
+var cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "redis_cache_lookups_total",
+	Help: "Number of lookups in a Redis cache, by cache and whether the entry was cached.",
+}, []string{"cache", "result"})
+
+var mongoCommandSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "mongodb_command_duration_seconds",
+	Help:    "Time MongoDB took to run a command, by command and result.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"command", "result"})
+
+var kubernetesRequestSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "kubernetes_api_request_duration_seconds",
+	Help:    "Time the Kubernetes API took to answer a request, by verb.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"verb"})
+
+var kubernetesRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "kubernetes_api_requests_total",
+	Help: "Number of requests sent to the Kubernetes API, by method and status code.",
+}, []string{"method", "code"})
+
+var kubernetesRequestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "kubernetes_api_request_errors_total",
+	Help: "Number of requests to the Kubernetes API that failed or returned an error status, by method and status code.",
+}, []string{"method", "code"})
+
+func result(err error) string {
+	if err != nil {
+		return FAILURE
+	}
+	return SUCCESS
+}
+
+// Method to count a message published to RabbitMQ
+func RecordPublished(routingKey string, err error) {
+	rabbitMQPublished.WithLabelValues(routingKey, result(err)).Inc()
+}
+
+// Method to count a message consumed from RabbitMQ
+func RecordConsumed(routingKey string, err error) {
+	rabbitMQConsumed.WithLabelValues(routingKey, result(err)).Inc()
+}
+
+// Method to count a consumed event checked for duplicates
+func RecordDeduplicated(routingKey string, duplicate bool) {
+	if duplicate {
+		rabbitMQDeduplicated.WithLabelValues(routingKey, DUPLICATE).Inc()
+	} else {
+		rabbitMQDeduplicated.WithLabelValues(routingKey, PROCESSED).Inc()
+	}
+}
+
+// Method to count an attempt to deliver an event to a webhook
+func RecordWebhookDelivery(eventType string, err error) {
+	webhookDeliveries.WithLabelValues(eventType, result(err)).Inc()
+}
+
+// Method to count a lookup in a Redis cache
+func RecordCacheLookup(cache string, hit bool) {
+	if hit {
+		cacheLookups.WithLabelValues(cache, HIT).Inc()
+	} else {
+		cacheLookups.WithLabelValues(cache, MISS).Inc()
+	}
+}
+
+// MongoMonitor times every command sent to MongoDB
+func MongoMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			mongoCommandSeconds.WithLabelValues(e.CommandName, SUCCESS).Observe(e.Duration.Seconds())
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			mongoCommandSeconds.WithLabelValues(e.CommandName, FAILURE).Observe(e.Duration.Seconds())
+		},
+	}
+}
+
+var registerKubernetesOnce sync.Once
+
+// Method to time the requests of every Kubernetes client created from a rest config
+func RegisterKubernetesMetrics() {
+	registerKubernetesOnce.Do(func() {
+		clientmetrics.Register(clientmetrics.RegisterOpts{
+			RequestLatency: kubernetesLatency{},
+			RequestResult:  kubernetesResult{},
+		})
+	})
+}
+
+type kubernetesLatency struct{}
+
+func (kubernetesLatency) Observe(_ context.Context, verb string, _ url.URL, latency time.Duration) {
+	kubernetesRequestSeconds.WithLabelValues(verb).Observe(latency.Seconds())
+}
+
+type kubernetesResult struct{}
+
+func (kubernetesResult) Increment(_ context.Context, code string, method string, _ string) {
+	kubernetesRequests.WithLabelValues(method, code).Inc()
+
+	// Requests that never got an answer are reported with a non numeric code
+	if status, err := strconv.Atoi(code); err != nil || status >= 400 {
+		kubernetesRequestErrors.WithLabelValues(method, code).Inc()
+	}
+}
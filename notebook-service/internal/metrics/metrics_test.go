@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptorCountsByCode(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/notebook.NotebookService/TestMethod"}
+	failing := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	succeeding := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+
+	UnaryServerInterceptor(context.Background(), nil, info, failing)
+	UnaryServerInterceptor(context.Background(), nil, info, succeeding)
+	resp, err := UnaryServerInterceptor(context.Background(), nil, info, succeeding)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", resp)
+	assert.Equal(t, float64(1), testutil.ToFloat64(grpcHandled.WithLabelValues(info.FullMethod, codes.NotFound.String())))
+	assert.Equal(t, float64(2), testutil.ToFloat64(grpcHandled.WithLabelValues(info.FullMethod, codes.OK.String())))
+}
+
+func TestKubernetesResultCountsErrors(t *testing.T) {
+	result := kubernetesResult{}
+	result.Increment(context.Background(), "200", "GET", "")
+	result.Increment(context.Background(), "404", "GET", "")
+	result.Increment(context.Background(), "<error>", "GET", "")
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(kubernetesRequests.WithLabelValues("GET", "200")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(kubernetesRequestErrors.WithLabelValues("GET", "200")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(kubernetesRequestErrors.WithLabelValues("GET", "404")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(kubernetesRequestErrors.WithLabelValues("GET", "<error>")))
+}
+
+func TestCountCollector(t *testing.T) {
+	collector := &countCollector{
+		desc: prometheus.NewDesc("test_notebooks", "Test gauge.", []string{"state"}, nil),
+		count: func(ctx context.Context) (map[string]float64, error) {
+			return map[string]float64{"running": 2, "stopped": 1}, nil
+		},
+	}
+
+	expected := `
+# HELP test_notebooks Test gauge.
+# TYPE test_notebooks gauge
+test_notebooks{state="running"} 2
+test_notebooks{state="stopped"} 1
+`
+	assert.Nil(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+}
+
+func TestCountCollectorSkipsFailedCount(t *testing.T) {
+	collector := &countCollector{
+		desc: prometheus.NewDesc("test_notebooks", "Test gauge.", []string{"state"}, nil),
+		count: func(ctx context.Context) (map[string]float64, error) {
+			return nil, errors.New("kubernetes unavailable")
+		},
+	}
+
+	assert.Equal(t, 0, testutil.CollectAndCount(collector))
+}
+
+func TestRecordDeduplicatedCountsByResult(t *testing.T) {
+	RecordDeduplicated("PVC.DELETE", false)
+	RecordDeduplicated("PVC.DELETE", true)
+	RecordDeduplicated("PVC.DELETE", true)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(rabbitMQDeduplicated.WithLabelValues("PVC.DELETE", PROCESSED)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(rabbitMQDeduplicated.WithLabelValues("PVC.DELETE", DUPLICATE)))
+}
+
+func TestRecordWebhookDeliveryCountsByResult(t *testing.T) {
+	RecordWebhookDelivery("NOTEBOOK.STOP", nil)
+	RecordWebhookDelivery("NOTEBOOK.STOP", errors.New("endpoint answered 500"))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(webhookDeliveries.WithLabelValues("NOTEBOOK.STOP", SUCCESS)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(webhookDeliveries.WithLabelValues("NOTEBOOK.STOP", FAILURE)))
+}
@@ -0,0 +1,48 @@
+package model
+
+// Version and kind of the portable notebook manifest
+const (
+	NOTEBOOK_MANIFEST_API_VERSION = "suedataplatform/v1"
+	NOTEBOOK_MANIFEST_KIND        = "NotebookManifest"
+)
+
+// NotebookManifest is a platform level description of a notebook that can be stored in git
+// and imported into another cluster
+type NotebookManifest struct {
+	ApiVersion string                   `json:"apiVersion"`
+	Kind       string                   `json:"kind"`
+	Metadata   NotebookManifestMetadata `json:"metadata"`
+	Spec       NotebookManifestSpec     `json:"spec"`
+}
+
+type NotebookManifestMetadata struct {
+	Name string `json:"name"`
+}
+
+type NotebookManifestSpec struct {
+	Type              string                    `json:"type"`
+	Resources         NotebookManifestResources `json:"resources"`
+	Volume            NotebookManifestVolume    `json:"volume"`
+	EnvFrom           []NotebookManifestEnvFrom `json:"envFrom,omitempty"`
+	SchedulingProfile string                    `json:"schedulingProfile,omitempty"`
+}
+
+type NotebookManifestResources struct {
+	MinCpu    string `json:"minCpu,omitempty"`
+	MaxCpu    string `json:"maxCpu,omitempty"`
+	MinMemory string `json:"minMemory,omitempty"`
+	MaxMemory string `json:"maxMemory,omitempty"`
+}
+
+// Either the size and storage of a workspace volume created with the notebook, or an existing claim to mount
+type NotebookManifestVolume struct {
+	Size          string `json:"size,omitempty"`
+	ExistingClaim string `json:"existingClaim,omitempty"`
+	StorageClass  string `json:"storageClass,omitempty"`
+	AccessMode    string `json:"accessMode,omitempty"`
+}
+
+type NotebookManifestEnvFrom struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
@@ -21,8 +21,14 @@ type NotebookTemplateSpec struct {
 	Spec v1.PodSpec `json:"spec"`
 }
 
+// States of a notebook that can't run, unset while it can
+const (
+	NOTEBOOK_VOLUME_MISSING = "volume-missing" // A volume mounted by the notebook was deleted
+)
+
 type NotebookEntity struct {
 	ID           primitive.ObjectID `bson:"_id,omitempty"`
 	NotebookName string             `bson:"notebookName"`
 	Username     string             `bson:"username"`
+	State        string             `bson:"state,omitempty"`
 }
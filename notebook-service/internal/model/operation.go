@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+// States of an operation and its steps, named after the OperationState enum of the API
+const (
+	OPERATION_PENDING   = "PENDING"
+	OPERATION_RUNNING   = "RUNNING"
+	OPERATION_SUCCEEDED = "SUCCEEDED"
+	OPERATION_FAILED    = "FAILED"
+	OPERATION_CANCELLED = "CANCELLED"
+)
+
+type Operation struct {
+	ID              string          `bson:"_id"`
+	Kind            string          `bson:"kind"`
+	Target          string          `bson:"target"`
+	Username        string          `bson:"username"`
+	State           string          `bson:"state"`
+	Steps           []OperationStep `bson:"steps"`
+	Error           *OperationError `bson:"error,omitempty"`
+	CancelRequested bool            `bson:"cancelRequested"`
+	CreatedAt       time.Time       `bson:"createdAt"`
+	UpdatedAt       time.Time       `bson:"updatedAt"`
+	// Instance running the steps, which renews the lease until the operation is done
+	Owner       string    `bson:"owner"`
+	LeaseExpiry time.Time `bson:"leaseExpiry"`
+}
+
+type OperationStep struct {
+	Name       string     `bson:"name"`
+	State      string     `bson:"state"`
+	StartedAt  *time.Time `bson:"startedAt,omitempty"`
+	FinishedAt *time.Time `bson:"finishedAt,omitempty"`
+}
+
+type OperationError struct {
+	Code    uint32 `bson:"code"`
+	Message string `bson:"message"`
+}
+
+// Checks if the operation reached a final state
+func (o *Operation) Done() bool {
+	return o.State == OPERATION_SUCCEEDED || o.State == OPERATION_FAILED || o.State == OPERATION_CANCELLED
+}
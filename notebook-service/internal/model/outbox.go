@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// An event recorded with the state change it announces, kept until the relay published it
+type OutboxEvent struct {
+	ID        string     `bson:"_id"` // Id of the event envelope
+	Type      string     `bson:"type"`
+	Envelope  []byte     `bson:"envelope"` // Serialized event envelope, published as is
+	CreatedAt time.Time  `bson:"createdAt"`
+	SentAt    *time.Time `bson:"sentAt,omitempty"` // Unset until RabbitMQ confirmed the event
+	Attempts  int        `bson:"attempts"`         // Failed publications
+	LastError string     `bson:"lastError,omitempty"`
+	FailedAt  *time.Time `bson:"failedAt,omitempty"` // Set when the event can never be published, the relay skips it from then on
+}
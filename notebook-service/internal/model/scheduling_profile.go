@@ -0,0 +1,25 @@
+package model
+
+import (
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	v1 "k8s.io/api/core/v1"
+)
+
+type SchedulingProfile struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty"`
+	Name              string             `bson:"name"`
+	Description       string             `bson:"description"`
+	NodeSelector      map[string]string  `bson:"nodeSelector,omitempty"`
+	Tolerations       []v1.Toleration    `bson:"tolerations,omitempty"`
+	Affinity          *v1.Affinity       `bson:"affinity,omitempty"`
+	PriorityClassName string             `bson:"priorityClassName,omitempty"`
+	AllowedRoles      []string           `bson:"allowedRoles,omitempty"`
+	DefaultForTypes   []string           `bson:"defaultForTypes,omitempty"`
+}
+
+// Checks if users with the given role may select the profile. An empty role list permits everyone.
+func (p *SchedulingProfile) PermitsRole(role string) bool {
+	return len(p.AllowedRoles) == 0 || slices.Contains(p.AllowedRoles, role)
+}
@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// Lifecycle transitions recorded for metering
+const (
+	USAGE_CREATE = "CREATE"
+	USAGE_START  = "START"
+	USAGE_STOP   = "STOP"
+	USAGE_DELETE = "DELETE"
+	USAGE_RESIZE = "RESIZE"
+)
+
+// Kinds of usage intervals. Compute is charged while the notebook runs, storage until the notebook is deleted.
+const (
+	USAGE_COMPUTE = "COMPUTE"
+	USAGE_STORAGE = "STORAGE"
+)
+
+// A lifecycle transition of a notebook with the resources requested at that moment
+type UsageEvent struct {
+	NotebookName string    `bson:"notebookName"`
+	Username     string    `bson:"username"`
+	Team         string    `bson:"team,omitempty"`
+	Transition   string    `bson:"transition"`
+	Cpu          float64   `bson:"cpu"`       // Requested cores
+	MemoryGB     float64   `bson:"memoryGB"`  // Requested memory
+	StorageGB    float64   `bson:"storageGB"` // Size of the workspace volume created with the notebook
+	Timestamp    time.Time `bson:"timestamp"`
+}
+
+// A period in which a notebook held constant resources, open while End is unset
+type UsageInterval struct {
+	NotebookName string     `bson:"notebookName"`
+	Username     string     `bson:"username"`
+	Team         string     `bson:"team,omitempty"`
+	Kind         string     `bson:"kind"`
+	Cpu          float64    `bson:"cpu"`
+	MemoryGB     float64    `bson:"memoryGB"`
+	StorageGB    float64    `bson:"storageGB"`
+	Start        time.Time  `bson:"start"`
+	End          *time.Time `bson:"end,omitempty"`
+}
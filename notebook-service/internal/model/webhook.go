@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// States of a webhook delivery, the values of the WebhookDeliveryState enum of the API without their prefix
+const (
+	DELIVERY_PENDING   = "PENDING"
+	DELIVERY_SUCCEEDED = "SUCCEEDED"
+	DELIVERY_FAILED    = "FAILED"
+)
+
+// An HTTP endpoint the events are delivered to
+type Webhook struct {
+	ID         string    `bson:"_id"`
+	Owner      string    `bson:"owner"` // User who registered the webhook
+	URL        string    `bson:"url"`
+	EventTypes []string  `bson:"eventTypes,omitempty"` // Routing keys delivered, empty delivers every event
+	Secret     string    `bson:"secret"`               // Key signing the deliveries, never returned by the API
+	Global     bool      `bson:"global"`               // Receives the events of every user, only registered by administrators
+	CreatedAt  time.Time `bson:"createdAt"`
+}
+
+// An event to deliver to a webhook, kept as the delivery log once finished
+type WebhookDelivery struct {
+	ID             string     `bson:"_id"` // Id of the event and of the webhook, so an event is only queued once per webhook
+	WebhookID      string     `bson:"webhookId"`
+	EventID        string     `bson:"eventId"`
+	EventType      string     `bson:"eventType"`
+	Envelope       []byte     `bson:"envelope"` // Serialized event envelope
+	State          string     `bson:"state"`
+	Attempts       int        `bson:"attempts"`
+	ResponseStatus int        `bson:"responseStatus,omitempty"` // HTTP status of the last attempt, 0 when the endpoint did not answer
+	LastError      string     `bson:"lastError,omitempty"`
+	CreatedAt      time.Time  `bson:"createdAt"`
+	NextAttemptAt  time.Time  `bson:"nextAttemptAt"`
+	FinishedAt     *time.Time `bson:"finishedAt,omitempty"`  // Unset while pending
+	LeaseOwner     string     `bson:"leaseOwner,omitempty"`  // Instance making the current attempt, unset between attempts
+	LeaseExpiry    *time.Time `bson:"leaseExpiry,omitempty"` // Time the other instances may claim the delivery again
+}
@@ -1,12 +1,14 @@
 package mongo_repository
 
 import (
+	"context"
 	"notebook-service/internal/model"
 )
 
 type NotebookRepository interface {
-	AuthorizedUser(string, string) (bool, error)
-	CreateNotebook(notebook *model.NotebookEntity) error
-	DeleteNotebook(notebookName string) error
-	ListNotebooks(string) ([]string, error)
+	AuthorizedUser(context.Context, string, string) (bool, error)
+	CreateNotebook(ctx context.Context, notebook *model.NotebookEntity) error
+	DeleteNotebook(ctx context.Context, notebookName string) error
+	ListNotebooks(context.Context, string) ([]model.NotebookEntity, error)
+	SetNotebookState(ctx context.Context, notebookName string, state string) (*model.NotebookEntity, error)
 }
@@ -34,14 +34,14 @@ func CreateNotebookRepository(db *mongo.Database) NotebookRepository {
 }
 
 // Checks if user is authorized to modify the notebook
-func (r *notebookRepository) AuthorizedUser(username, notebookName string) (bool, error) {
+func (r *notebookRepository) AuthorizedUser(ctx context.Context, username, notebookName string) (bool, error) {
 	// Create filter to find the notebook
 	filter := bson.M{"notebookName": notebookName, "username": username}
 
 	// Check if document exists
 	var notebook model.NotebookEntity
 
-	err := r.coll.FindOne(context.Background(), filter).Decode(&notebook)
+	err := r.coll.FindOne(ctx, filter).Decode(&notebook)
 
 	if err == mongo.ErrNoDocuments {
 		return false, nil
@@ -54,8 +54,8 @@ func (r *notebookRepository) AuthorizedUser(username, notebookName string) (bool
 }
 
 // Create Notebook
-func (r *notebookRepository) CreateNotebook(notebook *model.NotebookEntity) error {
-	_, err := r.coll.InsertOne(context.TODO(), notebook)
+func (r *notebookRepository) CreateNotebook(ctx context.Context, notebook *model.NotebookEntity) error {
+	_, err := r.coll.InsertOne(ctx, notebook)
 	if err != nil {
 		return fmt.Errorf("failed inserting the notebook: %v", err)
 	}
@@ -64,12 +64,12 @@ func (r *notebookRepository) CreateNotebook(notebook *model.NotebookEntity) erro
 }
 
 // Delete notebook from MongoDB
-func (r *notebookRepository) DeleteNotebook(notebookName string) error {
+func (r *notebookRepository) DeleteNotebook(ctx context.Context, notebookName string) error {
 	// Create the filter for notebook deletion
 	filter := bson.M{"notebookName": notebookName}
 
 	// Delete the notebook
-	_, err := r.coll.DeleteOne(context.TODO(), filter)
+	_, err := r.coll.DeleteOne(ctx, filter)
 	if err != nil {
 		return fmt.Errorf("failed removing notebook %s: %v", notebookName, err)
 	}
@@ -77,31 +77,49 @@ func (r *notebookRepository) DeleteNotebook(notebookName string) error {
 	return nil
 }
 
+// Set the state of a notebook, returning the updated notebook or nil if it does not exist
+func (r *notebookRepository) SetNotebookState(ctx context.Context, notebookName string, state string) (*model.NotebookEntity, error) {
+	filter := bson.M{"notebookName": notebookName}
+	update := bson.M{"$set": bson.M{"state": state}}
+	if state == "" {
+		update = bson.M{"$unset": bson.M{"state": ""}}
+	}
+
+	var notebook model.NotebookEntity
+	err := r.coll.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&notebook)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed setting state of notebook %s: %v", notebookName, err)
+	}
+
+	return &notebook, nil
+}
+
 // Get list of notebook from MongoDB
-func (r *notebookRepository) ListNotebooks(username string) ([]string, error) {
+func (r *notebookRepository) ListNotebooks(ctx context.Context, username string) ([]model.NotebookEntity, error) {
 	// Create filter for the list of retrieved notebooks
 	filter := bson.M{"username": username}
 
-	// Create projection to limit the query result to notebookName field
-	projection := options.Find().SetProjection(bson.M{"notebookName": 1, "_id": 0})
+	// Create projection to limit the query result to the name and state of the notebooks
+	projection := options.Find().SetProjection(bson.M{"notebookName": 1, "state": 1, "_id": 0})
 
 	// Find matching documents
-	cursor, err := r.coll.Find(context.TODO(), filter, projection)
+	cursor, err := r.coll.Find(ctx, filter, projection)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(context.TODO())
+	defer cursor.Close(ctx)
 
-	// Parse results into a list of notebook names
-	var results []string
-	for cursor.Next(context.TODO()) {
-		var notebook struct {
-			NotebookName string `bson:"notebookName"`
-		}
+	// Parse results into a list of notebooks
+	var results []model.NotebookEntity
+	for cursor.Next(ctx) {
+		var notebook model.NotebookEntity
 		if err := cursor.Decode(&notebook); err != nil {
 			return nil, err
 		}
-		results = append(results, notebook.NotebookName)
+		results = append(results, notebook)
 	}
 
 	if err := cursor.Err(); err != nil {
@@ -0,0 +1,18 @@
+package mongo_repository
+
+import (
+	"context"
+	"notebook-service/internal/model"
+	"time"
+)
+
+type OperationRepository interface {
+	CreateOperation(ctx context.Context, operation *model.Operation) error
+	UpdateOperation(ctx context.Context, operation *model.Operation) error
+	GetOperation(ctx context.Context, id string) (*model.Operation, error)
+	ListOperations(ctx context.Context, username string, kind *string, done *bool, offset int64, limit int64) ([]model.Operation, error)
+	RequestCancel(ctx context.Context, id string) error
+	CancelRequested(ctx context.Context, id string) (bool, error)
+	RenewLeases(ctx context.Context, owner string, expiry time.Time) error
+	FailInterruptedOperations(ctx context.Context, reason model.OperationError) (int64, error)
+}
@@ -0,0 +1,184 @@
+package mongo_repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"notebook-service/internal/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type operationRepository struct {
+	coll *mongo.Collection
+}
+
+var unfinishedStates = bson.A{model.OPERATION_PENDING, model.OPERATION_RUNNING}
+var finishedStates = bson.A{model.OPERATION_SUCCEEDED, model.OPERATION_FAILED, model.OPERATION_CANCELLED}
+
+// Method to create an operation repository
+func CreateOperationRepository(db *mongo.Database) OperationRepository {
+	coll := db.Collection("operations")
+
+	idxModels := []mongo.IndexModel{
+		// Operations are listed per user, newest first
+		{Keys: bson.D{{Key: "username", Value: 1}, {Key: "createdAt", Value: -1}}},
+		// Unfinished operations are renewed per owner and failed once their lease expired
+		{Keys: bson.D{{Key: "state", Value: 1}, {Key: "owner", Value: 1}}},
+		{Keys: bson.D{{Key: "state", Value: 1}, {Key: "leaseExpiry", Value: 1}}},
+	}
+
+	_, err := coll.Indexes().CreateMany(context.Background(), idxModels)
+	if err != nil {
+		log.Fatalf("failed creating indexes for operations: %v", err)
+	}
+
+	return &operationRepository{coll: coll}
+}
+
+func (r *operationRepository) CreateOperation(ctx context.Context, operation *model.Operation) error {
+	_, err := r.coll.InsertOne(ctx, operation)
+	if err != nil {
+		return fmt.Errorf("failed storing operation %s: %v", operation.ID, err)
+	}
+
+	return nil
+}
+
+// Store the progress of the operation. The cancel flag is left untouched, since it is set by CancelOperation
+// while the operation is running.
+func (r *operationRepository) UpdateOperation(ctx context.Context, operation *model.Operation) error {
+	filter := bson.M{"_id": operation.ID}
+	update := bson.M{"$set": bson.M{
+		"state":     operation.State,
+		"steps":     operation.Steps,
+		"error":     operation.Error,
+		"updatedAt": operation.UpdatedAt,
+	}}
+
+	_, err := r.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("failed updating operation %s: %v", operation.ID, err)
+	}
+
+	return nil
+}
+
+// Get the operation with the given id, or nil if it does not exist
+func (r *operationRepository) GetOperation(ctx context.Context, id string) (*model.Operation, error) {
+	var operation model.Operation
+
+	err := r.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&operation)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed getting operation %s: %v", id, err)
+	}
+
+	return &operation, nil
+}
+
+// Get a page of the operations of a user, newest first
+func (r *operationRepository) ListOperations(ctx context.Context, username string, kind *string, done *bool, offset int64, limit int64) ([]model.Operation, error) {
+	filter := bson.M{"username": username}
+	if kind != nil {
+		filter["kind"] = *kind
+	}
+	if done != nil {
+		if *done {
+			filter["state"] = bson.M{"$in": finishedStates}
+		} else {
+			filter["state"] = bson.M{"$in": unfinishedStates}
+		}
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+
+	cursor, err := r.coll.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing operations: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var operations []model.Operation
+	if err := cursor.All(ctx, &operations); err != nil {
+		return nil, fmt.Errorf("failed decoding operations: %v", err)
+	}
+
+	return operations, nil
+}
+
+// Flag the operation as cancelled by the user
+func (r *operationRepository) RequestCancel(ctx context.Context, id string) error {
+	update := bson.M{"$set": bson.M{"cancelRequested": true, "updatedAt": time.Now()}}
+
+	_, err := r.coll.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return fmt.Errorf("failed requesting cancellation of operation %s: %v", id, err)
+	}
+
+	return nil
+}
+
+// Check if the cancellation of the operation was requested, an operation that does not exist is not cancelled
+func (r *operationRepository) CancelRequested(ctx context.Context, id string) (bool, error) {
+	var operation struct {
+		CancelRequested bool `bson:"cancelRequested"`
+	}
+
+	findOptions := options.FindOne().SetProjection(bson.M{"cancelRequested": 1})
+	err := r.coll.FindOne(ctx, bson.M{"_id": id}, findOptions).Decode(&operation)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed getting cancellation of operation %s: %v", id, err)
+	}
+
+	return operation.CancelRequested, nil
+}
+
+// Extend the lease of the unfinished operations run by the owner
+func (r *operationRepository) RenewLeases(ctx context.Context, owner string, expiry time.Time) error {
+	filter := bson.M{"owner": owner, "state": bson.M{"$in": unfinishedStates}}
+	update := bson.M{"$set": bson.M{"leaseExpiry": expiry}}
+
+	_, err := r.coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("failed renewing leases of operations run by %s: %v", owner, err)
+	}
+
+	return nil
+}
+
+// Fail the unfinished operations whose owner stopped renewing their lease, returning how many were failed.
+// Operations stored before the leases were introduced have none and are failed as well.
+func (r *operationRepository) FailInterruptedOperations(ctx context.Context, reason model.OperationError) (int64, error) {
+	now := time.Now()
+	filter := bson.M{
+		"state": bson.M{"$in": unfinishedStates},
+		"$or": bson.A{
+			bson.M{"leaseExpiry": bson.M{"$lt": now}},
+			bson.M{"leaseExpiry": bson.M{"$exists": false}},
+		},
+	}
+	update := bson.M{"$set": bson.M{
+		"state":     model.OPERATION_FAILED,
+		"error":     reason,
+		"updatedAt": now,
+	}}
+
+	result, err := r.coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, fmt.Errorf("failed updating interrupted operations: %v", err)
+	}
+
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,15 @@
+package mongo_repository
+
+import (
+	"context"
+	"notebook-service/internal/model"
+	"time"
+)
+
+type OutboxRepository interface {
+	AddEvent(ctx context.Context, event *model.OutboxEvent) error
+	FindPendingEvents(ctx context.Context, limit int64) ([]model.OutboxEvent, error)
+	MarkEventSent(ctx context.Context, id string, sentAt time.Time) error
+	MarkEventFailed(ctx context.Context, id string, cause string) error
+	MarkEventDiscarded(ctx context.Context, id string, cause string, failedAt time.Time) error
+}
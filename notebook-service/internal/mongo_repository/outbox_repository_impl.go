@@ -0,0 +1,109 @@
+package mongo_repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"notebook-service/internal/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type outboxRepository struct {
+	coll *mongo.Collection
+}
+
+// Method to create an outbox repository, removing the sent events after the retention
+func CreateOutboxRepository(db *mongo.Database, retention time.Duration) OutboxRepository {
+	coll := db.Collection("outbox")
+
+	idxModels := []mongo.IndexModel{
+		// Unsent events are read oldest first
+		{Keys: bson.D{{Key: "sentAt", Value: 1}, {Key: "failedAt", Value: 1}, {Key: "createdAt", Value: 1}}},
+		// Events without sentAt never expire
+		{Keys: bson.D{{Key: "sentAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(retention.Seconds())).SetName("sentAt_ttl")},
+	}
+
+	_, err := coll.Indexes().CreateMany(context.Background(), idxModels)
+	if err != nil {
+		log.Fatalf("failed creating indexes for the outbox: %v", err)
+	}
+
+	return &outboxRepository{coll: coll}
+}
+
+func (r *outboxRepository) AddEvent(ctx context.Context, event *model.OutboxEvent) error {
+	_, err := r.coll.InsertOne(ctx, event)
+	if mongo.IsDuplicateKeyError(err) {
+		// Already recorded by an earlier attempt of the handler
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed storing event %s in the outbox: %v", event.ID, err)
+	}
+
+	return nil
+}
+
+// Get the events not sent yet, oldest first. The discarded events are left out.
+func (r *outboxRepository) FindPendingEvents(ctx context.Context, limit int64) ([]model.OutboxEvent, error) {
+	filter := bson.M{"sentAt": bson.M{"$exists": false}, "failedAt": bson.M{"$exists": false}}
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(limit)
+
+	cursor, err := r.coll.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing pending events: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var events []model.OutboxEvent
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, fmt.Errorf("failed decoding pending events: %v", err)
+	}
+
+	return events, nil
+}
+
+func (r *outboxRepository) MarkEventSent(ctx context.Context, id string, sentAt time.Time) error {
+	_, err := r.coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"sentAt": sentAt}})
+	if err != nil {
+		return fmt.Errorf("failed marking event %s sent: %v", id, err)
+	}
+
+	return nil
+}
+
+// Count a failed publication, the event stays pending
+func (r *outboxRepository) MarkEventFailed(ctx context.Context, id string, cause string) error {
+	update := bson.M{
+		"$inc": bson.M{"attempts": 1},
+		"$set": bson.M{"lastError": cause},
+	}
+
+	_, err := r.coll.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return fmt.Errorf("failed recording the failure of event %s: %v", id, err)
+	}
+
+	return nil
+}
+
+// Mark an event that can never be published, it is kept for inspection but no longer pending
+func (r *outboxRepository) MarkEventDiscarded(ctx context.Context, id string, cause string, failedAt time.Time) error {
+	update := bson.M{
+		"$inc": bson.M{"attempts": 1},
+		"$set": bson.M{"lastError": cause, "failedAt": failedAt},
+	}
+
+	_, err := r.coll.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return fmt.Errorf("failed discarding event %s: %v", id, err)
+	}
+
+	return nil
+}
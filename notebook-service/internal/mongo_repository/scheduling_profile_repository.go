@@ -0,0 +1,14 @@
+package mongo_repository
+
+import (
+	"context"
+	"notebook-service/internal/model"
+)
+
+type SchedulingProfileRepository interface {
+	PutSchedulingProfile(ctx context.Context, profile *model.SchedulingProfile) error
+	DeleteSchedulingProfile(ctx context.Context, name string) (bool, error)
+	GetSchedulingProfile(ctx context.Context, name string) (*model.SchedulingProfile, error)
+	FindDefaultSchedulingProfile(ctx context.Context, notebookType string) (*model.SchedulingProfile, error)
+	ListSchedulingProfiles(ctx context.Context) ([]model.SchedulingProfile, error)
+}
@@ -0,0 +1,98 @@
+package mongo_repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"notebook-service/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type schedulingProfileRepository struct {
+	coll *mongo.Collection
+}
+
+// Method to create a scheduling profile repository
+func CreateSchedulingProfileRepository(db *mongo.Database) SchedulingProfileRepository {
+	coll := db.Collection("schedulingProfiles")
+
+	// Enable unique profile name
+	idxModel := mongo.IndexModel{
+		Keys:    "name",
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := coll.Indexes().CreateOne(context.Background(), idxModel)
+	if err != nil {
+		log.Fatalf("failed creating unique index for scheduling profile name: %v", err)
+	}
+
+	return &schedulingProfileRepository{coll: coll}
+}
+
+// Create the profile or replace the profile with the same name
+func (r *schedulingProfileRepository) PutSchedulingProfile(ctx context.Context, profile *model.SchedulingProfile) error {
+	filter := bson.M{"name": profile.Name}
+
+	_, err := r.coll.ReplaceOne(ctx, filter, profile, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed storing scheduling profile %s: %v", profile.Name, err)
+	}
+
+	return nil
+}
+
+// Delete the profile, reporting whether it existed
+func (r *schedulingProfileRepository) DeleteSchedulingProfile(ctx context.Context, name string) (bool, error) {
+	filter := bson.M{"name": name}
+
+	result, err := r.coll.DeleteOne(ctx, filter)
+	if err != nil {
+		return false, fmt.Errorf("failed removing scheduling profile %s: %v", name, err)
+	}
+
+	return result.DeletedCount > 0, nil
+}
+
+// Get the profile with the given name, or nil if it does not exist
+func (r *schedulingProfileRepository) GetSchedulingProfile(ctx context.Context, name string) (*model.SchedulingProfile, error) {
+	return r.findOne(ctx, bson.M{"name": name})
+}
+
+// Get the profile applied by default to notebooks of the given type, or nil if there is none
+func (r *schedulingProfileRepository) FindDefaultSchedulingProfile(ctx context.Context, notebookType string) (*model.SchedulingProfile, error) {
+	return r.findOne(ctx, bson.M{"defaultForTypes": notebookType})
+}
+
+// Get every scheduling profile sorted by name
+func (r *schedulingProfileRepository) ListSchedulingProfiles(ctx context.Context) ([]model.SchedulingProfile, error) {
+	cursor, err := r.coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed listing scheduling profiles: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var profiles []model.SchedulingProfile
+	if err := cursor.All(ctx, &profiles); err != nil {
+		return nil, fmt.Errorf("failed decoding scheduling profiles: %v", err)
+	}
+
+	return profiles, nil
+}
+
+func (r *schedulingProfileRepository) findOne(ctx context.Context, filter bson.M) (*model.SchedulingProfile, error) {
+	var profile model.SchedulingProfile
+
+	err := r.coll.FindOne(ctx, filter).Decode(&profile)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed getting scheduling profile: %v", err)
+	}
+
+	return &profile, nil
+}
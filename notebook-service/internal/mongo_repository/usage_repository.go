@@ -0,0 +1,14 @@
+package mongo_repository
+
+import (
+	"context"
+	"notebook-service/internal/model"
+	"time"
+)
+
+type UsageRepository interface {
+	InsertUsageEvent(ctx context.Context, event *model.UsageEvent) error
+	OpenUsageInterval(ctx context.Context, interval *model.UsageInterval) error
+	CloseUsageIntervals(ctx context.Context, notebookName string, kind string, end time.Time) (int64, error)
+	FindUsageIntervals(ctx context.Context, start time.Time, end time.Time, username *string) ([]model.UsageInterval, error)
+}
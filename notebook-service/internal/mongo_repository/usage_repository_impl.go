@@ -0,0 +1,96 @@
+package mongo_repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"notebook-service/internal/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type usageRepository struct {
+	events    *mongo.Collection
+	intervals *mongo.Collection
+}
+
+// Method to create a usage repository
+func CreateUsageRepository(db *mongo.Database) UsageRepository {
+	events := db.Collection("usageEvents")
+	intervals := db.Collection("usageIntervals")
+
+	// Intervals are closed per notebook and queried by time range
+	idxModels := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "notebookName", Value: 1}, {Key: "end", Value: 1}}},
+		{Keys: bson.D{{Key: "start", Value: 1}}},
+	}
+
+	_, err := intervals.Indexes().CreateMany(context.Background(), idxModels)
+	if err != nil {
+		log.Fatalf("failed creating indexes for usage intervals: %v", err)
+	}
+
+	return &usageRepository{events: events, intervals: intervals}
+}
+
+func (r *usageRepository) InsertUsageEvent(ctx context.Context, event *model.UsageEvent) error {
+	_, err := r.events.InsertOne(ctx, event)
+	if err != nil {
+		return fmt.Errorf("failed storing %s usage event of notebook %s: %v", event.Transition, event.NotebookName, err)
+	}
+
+	return nil
+}
+
+func (r *usageRepository) OpenUsageInterval(ctx context.Context, interval *model.UsageInterval) error {
+	_, err := r.intervals.InsertOne(ctx, interval)
+	if err != nil {
+		return fmt.Errorf("failed opening usage interval of notebook %s: %v", interval.NotebookName, err)
+	}
+
+	return nil
+}
+
+// Close the open intervals of a notebook, returning how many were closed. An empty kind closes every kind.
+func (r *usageRepository) CloseUsageIntervals(ctx context.Context, notebookName string, kind string, end time.Time) (int64, error) {
+	filter := bson.M{"notebookName": notebookName, "end": bson.M{"$exists": false}}
+	if kind != "" {
+		filter["kind"] = kind
+	}
+
+	result, err := r.intervals.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"end": end}})
+	if err != nil {
+		return 0, fmt.Errorf("failed closing usage intervals of notebook %s: %v", notebookName, err)
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// Get the intervals overlapping [start, end), optionally only those of one user
+func (r *usageRepository) FindUsageIntervals(ctx context.Context, start time.Time, end time.Time, username *string) ([]model.UsageInterval, error) {
+	filter := bson.M{
+		"start": bson.M{"$lt": end},
+		"$or": bson.A{
+			bson.M{"end": bson.M{"$exists": false}},
+			bson.M{"end": bson.M{"$gt": start}},
+		},
+	}
+	if username != nil {
+		filter["username"] = *username
+	}
+
+	cursor, err := r.intervals.Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed finding usage intervals: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var intervals []model.UsageInterval
+	if err := cursor.All(ctx, &intervals); err != nil {
+		return nil, fmt.Errorf("failed decoding usage intervals: %v", err)
+	}
+
+	return intervals, nil
+}
@@ -0,0 +1,20 @@
+package mongo_repository
+
+import (
+	"context"
+	"notebook-service/internal/model"
+	"time"
+)
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook *model.Webhook) error
+	GetWebhook(ctx context.Context, id string) (*model.Webhook, error)
+	ListWebhooks(ctx context.Context, owner string) ([]model.Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) (bool, error)
+	FindSubscribedWebhooks(ctx context.Context, eventType string) ([]model.Webhook, error)
+	AddDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error
+	FindDueDeliveries(ctx context.Context, now time.Time, limit int64) ([]model.WebhookDelivery, error)
+	ClaimDelivery(ctx context.Context, id, owner string, now, leaseExpiry time.Time) (bool, error)
+	UpdateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	ListDeliveries(ctx context.Context, webhookID string, limit int64) ([]model.WebhookDelivery, error)
+}
@@ -0,0 +1,225 @@
+package mongo_repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"notebook-service/internal/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type webhookRepository struct {
+	webhooks   *mongo.Collection
+	deliveries *mongo.Collection
+}
+
+// Method to create a webhook repository, removing the finished deliveries after the retention
+func CreateWebhookRepository(db *mongo.Database, retention time.Duration) WebhookRepository {
+	webhooks := db.Collection("webhooks")
+	deliveries := db.Collection("webhookDeliveries")
+
+	_, err := webhooks.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "owner", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "eventTypes", Value: 1}}},
+	})
+	if err != nil {
+		log.Fatalf("failed creating indexes for the webhooks: %v", err)
+	}
+
+	_, err = deliveries.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		// Pending deliveries are read by their next attempt
+		{Keys: bson.D{{Key: "state", Value: 1}, {Key: "nextAttemptAt", Value: 1}}},
+		// The log of a webhook is read newest first
+		{Keys: bson.D{{Key: "webhookId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		// Deliveries without finishedAt never expire
+		{Keys: bson.D{{Key: "finishedAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(retention.Seconds())).SetName("finishedAt_ttl")},
+	})
+	if err != nil {
+		log.Fatalf("failed creating indexes for the webhook deliveries: %v", err)
+	}
+
+	return &webhookRepository{webhooks: webhooks, deliveries: deliveries}
+}
+
+func (r *webhookRepository) CreateWebhook(ctx context.Context, webhook *model.Webhook) error {
+	_, err := r.webhooks.InsertOne(ctx, webhook)
+	if err != nil {
+		return fmt.Errorf("failed storing webhook: %v", err)
+	}
+
+	return nil
+}
+
+// Get the webhook with the given id, or nil if it does not exist
+func (r *webhookRepository) GetWebhook(ctx context.Context, id string) (*model.Webhook, error) {
+	var webhook model.Webhook
+	err := r.webhooks.FindOne(ctx, bson.M{"_id": id}).Decode(&webhook)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed getting webhook %s: %v", id, err)
+	}
+
+	return &webhook, nil
+}
+
+// Get the webhooks registered by the owner oldest first, every webhook when the owner is empty
+func (r *webhookRepository) ListWebhooks(ctx context.Context, owner string) ([]model.Webhook, error) {
+	filter := bson.M{}
+	if owner != "" {
+		filter["owner"] = owner
+	}
+
+	return r.find(ctx, filter)
+}
+
+// Delete the webhook, reporting whether it existed. Its deliveries are kept in the log until they expire.
+func (r *webhookRepository) DeleteWebhook(ctx context.Context, id string) (bool, error) {
+	result, err := r.webhooks.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return false, fmt.Errorf("failed removing webhook %s: %v", id, err)
+	}
+
+	return result.DeletedCount > 0, nil
+}
+
+// Get the webhooks receiving the events of the given type
+func (r *webhookRepository) FindSubscribedWebhooks(ctx context.Context, eventType string) ([]model.Webhook, error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"eventTypes": eventType},
+		bson.M{"eventTypes": bson.M{"$exists": false}},
+	}}
+
+	return r.find(ctx, filter)
+}
+
+func (r *webhookRepository) find(ctx context.Context, filter bson.M) ([]model.Webhook, error) {
+	cursor, err := r.webhooks.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed listing webhooks: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var webhooks []model.Webhook
+	if err := cursor.All(ctx, &webhooks); err != nil {
+		return nil, fmt.Errorf("failed decoding webhooks: %v", err)
+	}
+
+	return webhooks, nil
+}
+
+// Queue the deliveries, the ones already queued by a previous consumption of the event are left as is
+func (r *webhookRepository) AddDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error {
+	documents := make([]interface{}, len(deliveries))
+	for i := range deliveries {
+		documents[i] = deliveries[i]
+	}
+
+	_, err := r.deliveries.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	if err != nil && !onlyDuplicateKeys(err) {
+		return fmt.Errorf("failed queueing webhook deliveries: %v", err)
+	}
+
+	return nil
+}
+
+// Reports whether every write of a bulk insert failed on an existing id
+func onlyDuplicateKeys(err error) bool {
+	bulkErr, ok := err.(mongo.BulkWriteException)
+	if !ok || bulkErr.WriteConcernError != nil {
+		return false
+	}
+
+	for _, writeErr := range bulkErr.WriteErrors {
+		if !mongo.IsDuplicateKeyError(writeErr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Get the pending deliveries whose next attempt is due and no instance is making, oldest first
+func (r *webhookRepository) FindDueDeliveries(ctx context.Context, now time.Time, limit int64) ([]model.WebhookDelivery, error) {
+	filter := dueDeliveryFilter(now)
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(limit)
+
+	return r.findDeliveries(ctx, filter, findOptions)
+}
+
+// Claim a due delivery for an attempt of the owner until the lease expires. Only one instance claims it, the
+// others getting false until the lease expired.
+func (r *webhookRepository) ClaimDelivery(ctx context.Context, id, owner string, now, leaseExpiry time.Time) (bool, error) {
+	filter := dueDeliveryFilter(now)
+	filter["_id"] = id
+	update := bson.M{"$set": bson.M{"leaseOwner": owner, "leaseExpiry": leaseExpiry}}
+
+	result, err := r.deliveries.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, fmt.Errorf("failed claiming webhook delivery %s: %v", id, err)
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+// Pending deliveries whose next attempt is due, unclaimed or whose lease expired
+func dueDeliveryFilter(now time.Time) bson.M {
+	return bson.M{
+		"state":         model.DELIVERY_PENDING,
+		"nextAttemptAt": bson.M{"$lte": now},
+		"$or": bson.A{
+			bson.M{"leaseExpiry": bson.M{"$exists": false}},
+			bson.M{"leaseExpiry": bson.M{"$lte": now}},
+		},
+	}
+}
+
+// Store the outcome of an attempt and release the delivery. The outcome is dropped when the lease of the
+// owner expired and another instance claimed the delivery meanwhile.
+func (r *webhookRepository) UpdateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	filter := bson.M{"_id": delivery.ID, "leaseOwner": delivery.LeaseOwner}
+	released := *delivery
+	released.LeaseOwner = ""
+	released.LeaseExpiry = nil
+
+	result, err := r.deliveries.ReplaceOne(ctx, filter, released)
+	if err != nil {
+		return fmt.Errorf("failed storing webhook delivery %s: %v", delivery.ID, err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("webhook delivery %s was claimed by another instance", delivery.ID)
+	}
+
+	return nil
+}
+
+// Get the deliveries of a webhook, newest first
+func (r *webhookRepository) ListDeliveries(ctx context.Context, webhookID string, limit int64) ([]model.WebhookDelivery, error) {
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: 1}}).
+		SetLimit(limit)
+
+	return r.findDeliveries(ctx, bson.M{"webhookId": webhookID}, findOptions)
+}
+
+func (r *webhookRepository) findDeliveries(ctx context.Context, filter bson.M, findOptions *options.FindOptions) ([]model.WebhookDelivery, error) {
+	cursor, err := r.deliveries.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing webhook deliveries: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var deliveries []model.WebhookDelivery
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, fmt.Errorf("failed decoding webhook deliveries: %v", err)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,210 @@
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// State of the connection to RabbitMQ, reported by the health checks
+type ConnectionState string
+
+const (
+	CONNECTING ConnectionState = "connecting" // Waiting for the first connection, or for a reconnection
+	CONNECTED  ConnectionState = "connected"
+	CLOSED     ConnectionState = "closed" // Closed by the service, never reconnected
+)
+
+// Upper bound of the delay between two connection attempts
+const MAX_RECONNECT_DELAY = 30 * time.Second
+
+// Returned by the publications while disconnected, once the buffer is full or when it is disabled
+var ErrNotConnected = errors.New("not connected to RabbitMQ")
+
+// Message published while disconnected, sent once reconnected
+type pendingMessage struct {
+	key string
+	msg amqp.Publishing
+}
+
+// Keeps the handler connected, reconnecting with backoff whenever the connection is lost until the handler is closed
+func (r *rabbitMQHandler) maintainConnection() {
+	attempt := 0
+	for {
+		lost, err := r.connect()
+		if err != nil {
+			delay := reconnectDelay(r.cfg.ReconnectDelay, attempt)
+			attempt++
+			log.Printf("Failed to connect to RabbitMQ, retrying in %v: %v", delay, err)
+
+			select {
+			case <-time.After(delay):
+				continue
+			case <-r.closed:
+				return
+			}
+		}
+		attempt = 0
+
+		select {
+		case err := <-lost:
+			r.disconnected(err)
+		case <-r.closed:
+			return
+		}
+	}
+}
+
+// Opens a connection, a channel and a channel in confirm mode, declares the exchange, resumes the consumer and
+// sends the messages published meanwhile. The returned channel reports the loss of the connection or of the channel.
+func (r *rabbitMQHandler) connect() (<-chan *amqp.Error, error) {
+	conn, err := amqp.Dial(r.url)
+	if err != nil {
+		return nil, err
+	}
+	connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed opening channel: %w", err)
+	}
+	chanClosed := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+	// The confirmed publications use a channel of their own, replaced along with the connection
+	confirmCh, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed opening channel: %w", err)
+	}
+	confirms, err := confirmMode(confirmCh)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	r.confirmMu.Lock()
+	defer r.confirmMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.state == CLOSED {
+		conn.Close()
+		return nil, errors.New("handler is closed")
+	}
+
+	if err := declareExchange(ch, EXCHANGE_NAME); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if r.consuming {
+		if err := r.startConsumer(ch); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	r.conn = conn
+	r.channel = ch
+	if r.confirmCh != nil {
+		r.closeConfirmChannel()
+	}
+	r.confirmCh = confirmCh
+	r.confirms = confirms
+	r.state = CONNECTED
+	r.flushPending()
+	log.Println("Connected to RabbitMQ")
+
+	// A channel closed by the broker leaves the connection open, it is closed to reconnect from scratch
+	lost := make(chan *amqp.Error, 1)
+	go func() {
+		select {
+		case err := <-connClosed:
+			lost <- err
+		case err := <-chanClosed:
+			conn.Close()
+			lost <- err
+		}
+	}()
+
+	return lost, nil
+}
+
+// Marks the connection lost, unless the handler was closed meanwhile
+func (r *rabbitMQHandler) disconnected(cause *amqp.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.state == CLOSED {
+		return
+	}
+	r.state = CONNECTING
+	log.Printf("Lost connection to RabbitMQ, reconnecting: %v", cause)
+}
+
+// Sends the messages published while disconnected, keeping the ones that failed for the next connection.
+// Called with the lock held.
+func (r *rabbitMQHandler) flushPending() {
+	for i, pending := range r.pending {
+		if err := r.channel.Publish(EXCHANGE_NAME, pending.key, false, false, pending.msg); err != nil {
+			log.Printf("Failed to send the messages published while disconnected: %v", err)
+			r.pending = r.pending[i:]
+			return
+		}
+	}
+	if len(r.pending) > 0 {
+		log.Printf("Sent %d messages published while disconnected", len(r.pending))
+	}
+	r.pending = nil
+}
+
+// Publishes a message on the current channel. While disconnected the message is buffered until the
+// reconnection, or rejected when the buffer is full or disabled.
+func (r *rabbitMQHandler) send(key string, msg amqp.Publishing) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch r.state {
+	case CLOSED:
+		return amqp.ErrClosed
+	case CONNECTED:
+		err := r.channel.Publish(EXCHANGE_NAME, key, false, false, msg)
+		if !errors.Is(err, amqp.ErrClosed) {
+			return err
+		}
+		// The connection was lost before the handler noticed it
+	}
+
+	if len(r.pending) >= r.cfg.PublishBuffer {
+		return ErrNotConnected
+	}
+	r.pending = append(r.pending, pendingMessage{key: key, msg: msg})
+	return nil
+}
+
+// Opens a channel of its own on the current connection
+func (r *rabbitMQHandler) openChannel() (*amqp.Channel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.state != CONNECTED {
+		return nil, ErrNotConnected
+	}
+	ch, err := r.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed opening channel: %w", err)
+	}
+	return ch, nil
+}
+
+// Returns the delay before the given connection attempt, starting at base and doubled on each attempt
+func reconnectDelay(base time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 0; i < attempt && delay < MAX_RECONNECT_DELAY; i++ {
+		delay *= 2
+	}
+	return min(delay, MAX_RECONNECT_DELAY)
+}
@@ -0,0 +1,131 @@
+package rabbitmq
+
+import (
+	"context"
+	"notebook-service/api/events"
+	"notebook-service/internal/config"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// Handler of a broker that refuses the connections
+func newDisconnectedHandler(publishBuffer int) *rabbitMQHandler {
+	return newRabbitMQHandler(config.RabbitMQConfig{
+		URL:            "127.0.0.1:1",
+		Username:       "guest",
+		Password:       "guest",
+		ReconnectDelay: 10 * time.Millisecond,
+		PublishBuffer:  publishBuffer,
+	})
+}
+
+func TestReconnectDelay(t *testing.T) {
+	assert.Equal(t, time.Second, reconnectDelay(time.Second, 0))
+	assert.Equal(t, 4*time.Second, reconnectDelay(time.Second, 2))
+
+	// Capped, however many attempts
+	assert.Equal(t, MAX_RECONNECT_DELAY, reconnectDelay(time.Second, 100))
+}
+
+func TestReconnectsUntilClosed(t *testing.T) {
+	rbmq := newDisconnectedHandler(0)
+
+	stopped := make(chan struct{})
+	go func() {
+		rbmq.maintainConnection()
+		close(stopped)
+	}()
+
+	// Keeps trying without failing the service
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, CONNECTING, rbmq.State())
+	assert.EqualError(t, rbmq.CheckConnection(), "connection to RabbitMQ is connecting")
+
+	rbmq.Close()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("reconnections not stopped by Close")
+	}
+	assert.Equal(t, CLOSED, rbmq.State())
+	assert.EqualError(t, rbmq.CheckConnection(), "connection to RabbitMQ is closed")
+}
+
+func TestPublishFailsFastWhileDisconnected(t *testing.T) {
+	rbmq := newDisconnectedHandler(0)
+
+	err := rbmq.publish(context.Background(), "PVC.DELETE", amqp.Publishing{})
+
+	assert.ErrorIs(t, err, ErrNotConnected)
+	assert.Empty(t, rbmq.pending)
+}
+
+func TestPublishBuffersWhileDisconnected(t *testing.T) {
+	rbmq := newDisconnectedHandler(1)
+
+	// Kept until the reconnection
+	assert.Nil(t, rbmq.publish(context.Background(), "PVC.DELETE", amqp.Publishing{MessageId: "event-1"}))
+	assert.Len(t, rbmq.pending, 1)
+	assert.Equal(t, "PVC.DELETE", rbmq.pending[0].key)
+	assert.Equal(t, "event-1", rbmq.pending[0].msg.MessageId)
+
+	// Rejected once the buffer is full
+	err := rbmq.publish(context.Background(), "PVC.DELETE", amqp.Publishing{MessageId: "event-2"})
+	assert.ErrorIs(t, err, ErrNotConnected)
+	assert.Len(t, rbmq.pending, 1)
+
+	// Rejected once closed
+	rbmq.Close()
+	err = rbmq.publish(context.Background(), "PVC.DELETE", amqp.Publishing{})
+	assert.ErrorIs(t, err, amqp.ErrClosed)
+}
+
+func TestDeadLettersWhileDisconnected(t *testing.T) {
+	rbmq := newDisconnectedHandler(0)
+
+	_, err := rbmq.ListDeadLetters(10)
+	assert.ErrorIs(t, err, ErrNotConnected)
+
+	_, err = rbmq.ReplayDeadLetters(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrNotConnected)
+}
+
+func TestConsumeMessagesWaitsForConnection(t *testing.T) {
+	rbmq := newDisconnectedHandler(0)
+
+	rbmq.ConsumeMessages(NewRouter())
+	assert.True(t, rbmq.consuming)
+
+	// Nothing to wait for, the consumer never started
+	rbmq.StopConsuming()
+	assert.False(t, rbmq.consuming)
+}
+
+func TestPublishConfirmedAfterReconnecting(t *testing.T) {
+	broker := startFakeBroker(t)
+	rbmq := newRabbitMQHandler(config.RabbitMQConfig{
+		URL:            broker.addr(),
+		Username:       "guest",
+		Password:       "guest",
+		ReconnectDelay: 10 * time.Millisecond,
+	})
+	go rbmq.maintainConnection()
+	defer rbmq.Close()
+
+	connected := func() bool { return rbmq.State() == CONNECTED }
+	assert.Eventually(t, connected, 5*time.Second, 5*time.Millisecond)
+	event, err := NewEvent("PVC.DELETE", "alice", &events.PvcDeleted{PvcName: "pvc-a"})
+	assert.Nil(t, err)
+	assert.Nil(t, rbmq.PublishConfirmed(context.Background(), event))
+
+	// The broker restarts, the channel in confirm mode is opened again with the connection
+	broker.dropConnections()
+	assert.Eventually(t, func() bool { return broker.connections() == 2 && connected() }, 5*time.Second, 5*time.Millisecond)
+
+	// So the first publication after the reconnection is confirmed
+	assert.Nil(t, rbmq.PublishConfirmed(context.Background(), event))
+	assert.Equal(t, []brokerPublication{{key: "PVC.DELETE", confirmed: true}, {key: "PVC.DELETE", confirmed: true}}, broker.published())
+}
@@ -1,14 +1,96 @@
 package rabbitmq
 
 import (
+	"fmt"
 	"log"
+	"notebook-service/internal/metrics"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+)
+
+// Durable queue of the service, keeping the events published while the service is down
+const QUEUE_NAME = CONSUMER_TAG
+
+// Exchange and queue receiving the messages the consumer gave up on
+const DEAD_LETTER_EXCHANGE = EXCHANGE_NAME + ".dead-letter"
+const DEAD_LETTER_QUEUE = QUEUE_NAME + ".dead-letter"
+
+// Routing keys of the events of the other services consumed by the service, the ones without a handler are only
+// delivered to the webhooks. The events of the service reach the webhooks from its outbox.
+var BOUND_KEYS = []string{PVC + "." + DELETE, PVC + "." + CREATE, PVC + "." + EXPIRE}
+
+// Upper bound of the delay between two retries
+const MAX_RETRY_DELAY = time.Hour
+
+// Headers following a message through its retries
+const (
+	ROUTING_KEY_HEADER = "x-original-routing-key" // Routing key the message was published with
+	RETRIES_HEADER     = "x-retries"              // Retries already done
+	ERROR_HEADER       = "x-last-error"           // Error of the last failed delivery
 )
 
-// Method to setup the queue
-func (rbmq *rabbitMQHandler) setupQueue() string {
-	// Declare the exchange
-	err := rbmq.channel.ExchangeDeclare(
-		EXCHANGE_NAME,
+// Method to setup the queues on a new channel
+func (rbmq *rabbitMQHandler) setupQueue(ch *amqp.Channel) error {
+	// Declare the exchanges
+	if err := declareExchange(ch, EXCHANGE_NAME); err != nil {
+		return err
+	}
+	if err := declareExchange(ch, DEAD_LETTER_EXCHANGE); err != nil {
+		return err
+	}
+
+	// Declare the dead letter queue, holding the messages until they are replayed
+	if err := declareQueue(ch, DEAD_LETTER_QUEUE, nil); err != nil {
+		return err
+	}
+	if err := bindQueue(ch, DEAD_LETTER_QUEUE, QUEUE_NAME, DEAD_LETTER_EXCHANGE); err != nil {
+		return err
+	}
+
+	// Declare the queue of the service. Messages rejected without a retry go to the dead letter queue.
+	err := declareQueue(ch, QUEUE_NAME, amqp.Table{
+		"x-dead-letter-exchange":    DEAD_LETTER_EXCHANGE,
+		"x-dead-letter-routing-key": QUEUE_NAME,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Bind the queue
+	for _, key := range BOUND_KEYS {
+		if err := bindQueue(ch, QUEUE_NAME, key, EXCHANGE_NAME); err != nil {
+			return err
+		}
+	}
+
+	// Declare a delay queue per retry, sending the messages back to the queue of the service once expired.
+	// The queues are named after their delay, as the delay of an existing queue can't be changed.
+	for retry := 1; retry <= rbmq.cfg.MaxRetries; retry++ {
+		delay := retryDelay(rbmq.cfg.RetryDelay, retry)
+		err := declareQueue(ch, retryQueueName(delay), amqp.Table{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": QUEUE_NAME,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Limit the messages waiting for their ack
+	if err := ch.Qos(rbmq.cfg.Prefetch, 0, false); err != nil {
+		return fmt.Errorf("failed setting prefetch: %v", err)
+	}
+
+	return nil
+}
+
+// method to declare a durable direct exchange
+func declareExchange(ch *amqp.Channel, name string) error {
+	err := ch.ExchangeDeclare(
+		name,
 		"direct",
 		true,
 		false,
@@ -17,69 +99,252 @@ func (rbmq *rabbitMQHandler) setupQueue() string {
 		nil,
 	)
 	if err != nil {
-		log.Fatalf("failed declaring exchange: %v", err)
+		return fmt.Errorf("failed declaring exchange %s: %v", name, err)
 	}
+	return nil
+}
 
-	// Declare the queues
-	q, err := rbmq.channel.QueueDeclare(
-		"",
+// method to declare a durable queue
+func declareQueue(ch *amqp.Channel, name string, args amqp.Table) error {
+	_, err := ch.QueueDeclare(
+		name,
+		true,
 		false,
 		false,
-		true,
 		false,
-		nil,
+		args,
 	)
 	if err != nil {
-		log.Fatalf("failed declaring queue: %v", err)
+		return fmt.Errorf("failed declaring queue %s: %v", name, err)
 	}
-
-	// Bind the queue
-	rbmq.bindQueue(q.Name, PVC+"."+DELETE)
-	rbmq.bindQueue(q.Name, PVC+"."+CREATE)
-
-	return q.Name
+	return nil
 }
 
 // method to bind the queue with routing key
-func (rbmq *rabbitMQHandler) bindQueue(queue, key string) {
-	err := rbmq.channel.QueueBind(
+func bindQueue(ch *amqp.Channel, queue, key, exchange string) error {
+	err := ch.QueueBind(
 		queue,
 		key,
-		EXCHANGE_NAME,
+		exchange,
 		false,
 		nil,
 	)
 	if err != nil {
-		log.Fatalf("failed binding queue to routing key %s: %v", key, err)
+		return fmt.Errorf("failed binding queue to routing key %s: %v", key, err)
 	}
+	return nil
 }
 
-// Method to consume messages
-func (rbmq *rabbitMQHandler) ConsumeMessages(handlers map[string]func([]byte)) {
-	// Setup the queue
-	queue := rbmq.setupQueue()
+// Method to consume messages, dispatched by the router. The consumer starts once connected and is resumed
+// after every reconnection.
+func (rbmq *rabbitMQHandler) ConsumeMessages(router *Router) {
+	rbmq.mu.Lock()
+	defer rbmq.mu.Unlock()
 
-	// Consume messages
-	msgs, err := rbmq.channel.Consume(
-		queue,
-		"",
-		true,
+	rbmq.router = router
+	rbmq.consuming = true
+	if rbmq.state != CONNECTED {
+		log.Println("Not connected to RabbitMQ, consuming once connected")
+		return
+	}
+
+	// A failure closes the channel, the consumer is started again on the reconnection
+	if err := rbmq.startConsumer(rbmq.channel); err != nil {
+		log.Printf("Failed to start consuming, retrying on the reconnection: %v", err)
+	}
+}
+
+// Sets up the queues and runs the handlers of the messages delivered on the channel in a pool of workers,
+// until the channel is closed or the consumer cancelled. Called with the lock held.
+func (rbmq *rabbitMQHandler) startConsumer(ch *amqp.Channel) error {
+	if err := rbmq.setupQueue(ch); err != nil {
+		return err
+	}
+
+	// Consume messages, acknowledging them once handled
+	msgs, err := ch.Consume(
+		QUEUE_NAME,
+		CONSUMER_TAG,
+		false,
 		false,
 		false,
 		false,
 		nil,
 	)
 	if err != nil {
-		log.Fatalf("failed consuming messages: %v", err)
+		return fmt.Errorf("failed consuming messages: %v", err)
+	}
+
+	// The workers share the prefetched messages, events are no longer handled in their publication order
+	// with more than one worker
+	router := rbmq.router
+	for i := 0; i < rbmq.cfg.Workers; i++ {
+		rbmq.consumersWg.Add(1)
+		go func() {
+			defer rbmq.consumersWg.Done()
+
+			for d := range msgs {
+				rbmq.handle(ch, router, d)
+			}
+		}()
+	}
+
+	return nil
+}
+
+// Dispatches a delivery to the router, then acknowledges it, schedules its retry or dead-letters it
+func (rbmq *rabbitMQHandler) handle(ch *amqp.Channel, router *Router, d amqp.Delivery) {
+	// Retried messages come back from their delay queue under another routing key
+	d.RoutingKey = originalRoutingKey(d)
+
+	// Messages that can't be decoded won't succeed on a retry
+	event, err := DecodeEvent(d)
+	if err != nil {
+		metrics.RecordConsumed(d.RoutingKey, err)
+		log.Printf("Dead-lettering message %s: %v", d.RoutingKey, err)
+		deadLetter(ch, d, err)
+		return
+	}
+
+	// Run the handler within the trace of the publisher
+	err = router.Dispatch(extractTraceContext(d.Headers), event)
+	if err != nil {
+		rbmq.retry(ch, d, err)
+	} else {
+		ack(d)
+	}
+}
+
+// Sends a failed message to the delay queue of its next retry, or to the dead letter queue once the
+// retries are exhausted
+func (rbmq *rabbitMQHandler) retry(ch *amqp.Channel, d amqp.Delivery, cause error) {
+	retries := retriesOf(d)
+	if retries >= rbmq.cfg.MaxRetries {
+		log.Printf("Dead-lettering message %s after %d retries: %v", d.RoutingKey, retries, cause)
+		deadLetter(ch, d, cause)
+		return
 	}
 
-	go func() {
-		for d := range msgs {
-			// Get the appropriate method
-			handler := handlers[d.RoutingKey]
+	delay := retryDelay(rbmq.cfg.RetryDelay, retries+1)
+	log.Printf("Retrying message %s in %v: %v", d.RoutingKey, delay, cause)
+	if err := forward(ch, d, "", retryQueueName(delay), retries+1, cause); err != nil {
+		// Delivered again right away rather than lost
+		log.Printf("Failed scheduling the retry of message %s: %v", d.RoutingKey, err)
+		nack(d, true)
+		return
+	}
+	ack(d)
+}
+
+// Moves a message to the dead letter queue, keeping the error for the inspection
+func deadLetter(ch *amqp.Channel, d amqp.Delivery, cause error) {
+	if err := forward(ch, d, DEAD_LETTER_EXCHANGE, QUEUE_NAME, retriesOf(d), cause); err != nil {
+		// The queue of the service dead-letters rejected messages, only the error is lost
+		log.Printf("Failed dead-lettering message %s: %v", d.RoutingKey, err)
+		nack(d, false)
+		return
+	}
+	ack(d)
+}
+
+// Publishes a copy of the delivery, recording its routing key, retries and last error in its headers
+func forward(ch *amqp.Channel, d amqp.Delivery, exchange, key string, retries int, cause error) error {
+	headers := amqp.Table{}
+	for name, value := range d.Headers {
+		headers[name] = value
+	}
+	headers[ROUTING_KEY_HEADER] = d.RoutingKey
+	headers[RETRIES_HEADER] = int32(retries)
+	headers[ERROR_HEADER] = cause.Error()
+
+	msg := publishingOf(d)
+	msg.Headers = headers
+	return ch.Publish(exchange, key, false, false, msg)
+}
+
+func ack(d amqp.Delivery) {
+	if err := d.Ack(false); err != nil {
+		log.Printf("Failed to ack message %s: %v", d.RoutingKey, err)
+	}
+}
 
-			// Run the handler
-			handler(d.Body)
+func nack(d amqp.Delivery, requeue bool) {
+	if err := d.Nack(false, requeue); err != nil {
+		log.Printf("Failed to nack message %s: %v", d.RoutingKey, err)
+	}
+}
+
+// Returns the properties of a delivery to publish it again. Messages get an id, so they can be replayed.
+func publishingOf(d amqp.Delivery) amqp.Publishing {
+	id := d.MessageId
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	return amqp.Publishing{
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		DeliveryMode:    amqp.Persistent,
+		MessageId:       id,
+		Timestamp:       d.Timestamp,
+		Type:            d.Type,
+		AppId:           d.AppId,
+		Body:            d.Body,
+	}
+}
+
+// Returns the routing key the message was published with
+func originalRoutingKey(d amqp.Delivery) string {
+	if key, ok := d.Headers[ROUTING_KEY_HEADER].(string); ok && key != "" {
+		return key
+	}
+	return d.RoutingKey
+}
+
+// Returns the number of retries already done for the message
+func retriesOf(d amqp.Delivery) int {
+	switch retries := d.Headers[RETRIES_HEADER].(type) {
+	case int32:
+		return int(retries)
+	case int64:
+		return int(retries)
+	}
+	return 0
+}
+
+// Returns the delay before the given retry, starting at base and doubled on each retry
+func retryDelay(base time.Duration, retry int) time.Duration {
+	delay := base
+	for i := 1; i < retry && delay < MAX_RETRY_DELAY; i++ {
+		delay *= 2
+	}
+	return min(delay, MAX_RETRY_DELAY)
+}
+
+// Returns the name of the delay queue holding the messages for the given delay
+func retryQueueName(delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%s", QUEUE_NAME, delay)
+}
+
+// StopConsuming cancels the deliveries and waits for the handlers of the messages already delivered
+func (rbmq *rabbitMQHandler) StopConsuming() {
+	rbmq.mu.Lock()
+	if !rbmq.consuming {
+		rbmq.mu.Unlock()
+		return
+	}
+	rbmq.consuming = false
+
+	// The deliveries channel is closed once the broker confirms the cancellation, or with the connection
+	if rbmq.state == CONNECTED {
+		if err := rbmq.channel.Cancel(CONSUMER_TAG, false); err != nil {
+			log.Printf("Failed to cancel the RabbitMQ consumer: %v", err)
+			rbmq.mu.Unlock()
+			return
 		}
-	}()
+	}
+	rbmq.mu.Unlock()
+
+	// Without the lock, as the handlers may publish
+	rbmq.consumersWg.Wait()
 }
@@ -0,0 +1,95 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"notebook-service/api/events"
+	"slices"
+
+	"github.com/streadway/amqp"
+)
+
+// DeadLetter is a message the consumer gave up on, kept in the dead letter queue until it is replayed
+type DeadLetter struct {
+	ID         string
+	RoutingKey string
+	Retries    int
+	Error      string
+	Event      *events.Envelope // Nil when the message can't be decoded
+}
+
+// ListDeadLetters returns up to limit dead-lettered messages, oldest first, leaving them in the queue
+func (r *rabbitMQHandler) ListDeadLetters(limit int) ([]DeadLetter, error) {
+	// A channel of its own, closing it returns the fetched messages to the queue
+	ch, err := r.openChannel()
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+
+	var deadLetters []DeadLetter
+	for len(deadLetters) < limit {
+		d, ok, err := ch.Get(DEAD_LETTER_QUEUE, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed reading dead letters: %w", err)
+		}
+		if !ok {
+			break
+		}
+		deadLetters = append(deadLetters, toDeadLetter(d))
+	}
+
+	return deadLetters, nil
+}
+
+// ReplayDeadLetters publishes the dead-lettered messages with the given ids again under their routing key,
+// with their retries reset. Every message is replayed when no id is given. Returns the number of messages replayed.
+func (r *rabbitMQHandler) ReplayDeadLetters(ctx context.Context, ids []string) (int, error) {
+	// A channel of its own, closing it returns the skipped messages to the queue
+	ch, err := r.openChannel()
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+
+	replayed := 0
+	for {
+		// Fetched messages stay unacked until the channel is closed, so each one is read once
+		d, ok, err := ch.Get(DEAD_LETTER_QUEUE, false)
+		if err != nil {
+			return replayed, fmt.Errorf("failed reading dead letters: %w", err)
+		}
+		if !ok {
+			return replayed, nil
+		}
+		if len(ids) > 0 && !slices.Contains(ids, d.MessageId) {
+			continue
+		}
+
+		if err := r.publish(ctx, originalRoutingKey(d), publishingOf(d)); err != nil {
+			return replayed, err
+		}
+		if err := d.Ack(false); err != nil {
+			return replayed, fmt.Errorf("failed removing replayed message %s: %w", d.MessageId, err)
+		}
+		replayed++
+	}
+}
+
+// Describes a dead-lettered message from its headers and body
+func toDeadLetter(d amqp.Delivery) DeadLetter {
+	d.RoutingKey = originalRoutingKey(d)
+	cause, _ := d.Headers[ERROR_HEADER].(string)
+
+	deadLetter := DeadLetter{
+		ID:         d.MessageId,
+		RoutingKey: d.RoutingKey,
+		Retries:    retriesOf(d),
+		Error:      cause,
+	}
+	if event, err := DecodeEvent(d); err == nil {
+		deadLetter.Event = event
+	}
+
+	return deadLetter
+}
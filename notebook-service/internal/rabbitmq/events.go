@@ -0,0 +1,160 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"notebook-service/api/events"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Version of the event schemas, raised on every incompatible change of the data of an event
+const SCHEMA_VERSION = 1
+
+// Content types telling the events apart from the JSON payloads published before the envelope
+const (
+	EVENT_CONTENT_TYPE  = "application/cloudevents+protobuf"
+	LEGACY_CONTENT_TYPE = "application/json"
+)
+
+// Creates the data message of each event type, used to decode the legacy JSON payloads
+var eventData = map[string]func() proto.Message{
+	GenerateRoutingKey(NOTEBOOK, CREATE): func() proto.Message { return &events.NotebookCreated{} },
+	GenerateRoutingKey(NOTEBOOK, DELETE): func() proto.Message { return &events.NotebookDeleted{} },
+	GenerateRoutingKey(NOTEBOOK, START):  func() proto.Message { return &events.NotebookStarted{} },
+	GenerateRoutingKey(NOTEBOOK, STOP):   func() proto.Message { return &events.NotebookStopped{} },
+	GenerateRoutingKey(NOTEBOOK, FAIL):   func() proto.Message { return &events.NotebookFailed{} },
+	GenerateRoutingKey(PVC, CREATE):      func() proto.Message { return &events.PvcCreated{} },
+	GenerateRoutingKey(PVC, DELETE):      func() proto.Message { return &events.PvcDeleted{} },
+	GenerateRoutingKey(PVC, EXPIRE):      func() proto.Message { return &events.PvcExpiring{} },
+}
+
+// EventTypes returns the routing keys of the events published on the exchange, sorted
+func EventTypes() []string {
+	types := make([]string, 0, len(eventData))
+	for eventType := range eventData {
+		types = append(types, eventType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NewEvent wraps the data of an event in its envelope, published by this service
+func NewEvent(eventType, actor string, data proto.Message) (*events.Envelope, error) {
+	payload, err := anypb.New(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding data of event %s: %v", eventType, err)
+	}
+
+	return &events.Envelope{
+		Id:            uuid.NewString(),
+		Type:          eventType,
+		Source:        CONSUMER_TAG,
+		Time:          timestamppb.Now(),
+		Subject:       subjectOf(data),
+		Actor:         actor,
+		SchemaVersion: SCHEMA_VERSION,
+		Data:          payload,
+	}, nil
+}
+
+// DecodeEvent reads the envelope of a delivery. The JSON payloads published before the envelope are wrapped
+// in one with schema version 0, so handlers only deal with envelopes.
+func DecodeEvent(d amqp.Delivery) (*events.Envelope, error) {
+	if d.ContentType != EVENT_CONTENT_TYPE {
+		return decodeLegacyEvent(d)
+	}
+
+	event := &events.Envelope{}
+	if err := proto.Unmarshal(d.Body, event); err != nil {
+		return nil, fmt.Errorf("failed decoding event: %v", err)
+	}
+
+	return event, nil
+}
+
+func decodeLegacyEvent(d amqp.Delivery) (*events.Envelope, error) {
+	newData, exists := eventData[d.RoutingKey]
+	if !exists {
+		return nil, fmt.Errorf("no schema for routing key %s", d.RoutingKey)
+	}
+
+	// The field names of the legacy payloads are the ones of the schemas
+	data := newData()
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(d.Body, data); err != nil {
+		return nil, fmt.Errorf("failed decoding legacy payload: %v", err)
+	}
+
+	payload, err := anypb.New(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding legacy payload: %v", err)
+	}
+
+	id := d.MessageId
+	if id == "" {
+		id = uuid.NewString()
+	}
+	published := d.Timestamp
+	if published.IsZero() {
+		published = time.Now()
+	}
+
+	return &events.Envelope{
+		Id:      id,
+		Type:    d.RoutingKey,
+		Source:  d.AppId,
+		Time:    timestamppb.New(published),
+		Subject: subjectOf(data),
+		Data:    payload,
+	}, nil
+}
+
+// OnEvent returns a handler decoding the data of the event before calling handle.
+// Events whose data is not a T fail, as the schema of the routing key was broken.
+func OnEvent[T proto.Message](handle func(context.Context, *events.Envelope, T) error) MessageHandler {
+	return func(ctx context.Context, event *events.Envelope) error {
+		data, err := event.Data.UnmarshalNew()
+		if err != nil {
+			return fmt.Errorf("failed decoding data of event %s %s: %v", event.Type, event.Id, err)
+		}
+
+		typed, ok := data.(T)
+		if !ok {
+			return fmt.Errorf("unexpected data %s in event %s %s", event.Data.TypeUrl, event.Type, event.Id)
+		}
+
+		return handle(ctx, event, typed)
+	}
+}
+
+// Returns the name of the resource the data of an event is about
+func subjectOf(data proto.Message) string {
+	switch data := data.(type) {
+	case *events.NotebookCreated:
+		return data.NotebookName
+	case *events.NotebookDeleted:
+		return data.NotebookName
+	case *events.NotebookStarted:
+		return data.NotebookName
+	case *events.NotebookStopped:
+		return data.NotebookName
+	case *events.NotebookFailed:
+		return data.NotebookName
+	case *events.PvcCreated:
+		return data.PvcName
+	case *events.PvcDeleted:
+		return data.PvcName
+	case *events.PvcExpiring:
+		return data.PvcName
+	case *events.WebhookTest:
+		return data.WebhookId
+	}
+	return ""
+}
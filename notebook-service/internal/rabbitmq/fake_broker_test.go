@@ -0,0 +1,198 @@
+package rabbitmq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+)
+
+// AMQP 0-9-1 frame types
+const (
+	methodFrameType    = 1
+	headerFrameType    = 2
+	frameEnd           = 0xCE
+	protocolHeaderSize = 8
+)
+
+// Message published to the fake broker
+type brokerPublication struct {
+	key       string
+	confirmed bool // Published on a channel in confirm mode
+}
+
+// fakeBroker speaks just enough AMQP to connect, open channels, declare exchanges and publish with
+// confirmations. Every accepted connection can be dropped to make the clients reconnect.
+type fakeBroker struct {
+	listener     net.Listener
+	mu           sync.Mutex
+	conns        []net.Conn
+	accepted     int
+	publications []brokerPublication
+}
+
+// Starts a broker accepting connections until the end of the test
+func startFakeBroker(t *testing.T) *fakeBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := &fakeBroker{listener: listener}
+	t.Cleanup(func() {
+		listener.Close()
+		broker.dropConnections()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			broker.mu.Lock()
+			broker.conns = append(broker.conns, conn)
+			broker.accepted++
+			broker.mu.Unlock()
+			go broker.serve(conn)
+		}
+	}()
+
+	return broker
+}
+
+func (b *fakeBroker) addr() string {
+	return b.listener.Addr().String()
+}
+
+// Closes the open connections, as a broker restart would
+func (b *fakeBroker) dropConnections() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, conn := range b.conns {
+		conn.Close()
+	}
+	b.conns = nil
+}
+
+// Returns the connections accepted since the start
+func (b *fakeBroker) connections() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.accepted
+}
+
+func (b *fakeBroker) published() []brokerPublication {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]brokerPublication(nil), b.publications...)
+}
+
+// Answers the frames of a connection until it is closed
+func (b *fakeBroker) serve(conn net.Conn) {
+	defer conn.Close()
+
+	if _, err := io.ReadFull(conn, make([]byte, protocolHeaderSize)); err != nil {
+		return
+	}
+	// connection.start: version 0-9, no server properties, PLAIN authentication
+	start := []byte{0, 9}
+	start = binary.BigEndian.AppendUint32(start, 0)
+	start = appendLongString(start, "PLAIN")
+	start = appendLongString(start, "en_US")
+	if writeMethod(conn, 0, 10, 10, start) != nil {
+		return
+	}
+
+	confirming := map[uint16]bool{}
+	published := map[uint16]uint64{}
+	var publishing *brokerPublication
+	for {
+		frameType, channel, payload, err := readFrame(conn)
+		if err != nil {
+			return
+		}
+
+		switch frameType {
+		case methodFrameType:
+			class, method := binary.BigEndian.Uint16(payload), binary.BigEndian.Uint16(payload[2:])
+			switch {
+			case class == 10 && method == 11: // connection.start-ok, answered with connection.tune
+				tune := binary.BigEndian.AppendUint16(nil, 0)
+				tune = binary.BigEndian.AppendUint32(tune, 131072)
+				tune = binary.BigEndian.AppendUint16(tune, 0)
+				err = writeMethod(conn, 0, 10, 30, tune)
+			case class == 10 && method == 40: // connection.open
+				err = writeMethod(conn, 0, 10, 41, []byte{0})
+			case class == 10 && method == 50: // connection.close
+				writeMethod(conn, 0, 10, 51, nil)
+				return
+			case class == 20 && method == 10: // channel.open
+				err = writeMethod(conn, channel, 20, 11, []byte{0, 0, 0, 0})
+			case class == 20 && method == 40: // channel.close
+				err = writeMethod(conn, channel, 20, 41, nil)
+			case class == 40 && method == 10: // exchange.declare
+				err = writeMethod(conn, channel, 40, 11, nil)
+			case class == 85 && method == 10: // confirm.select
+				confirming[channel] = true
+				err = writeMethod(conn, channel, 85, 11, nil)
+			case class == 60 && method == 40: // basic.publish, followed by its content
+				exchange := payload[6:]
+				key := exchange[1+exchange[0]:]
+				publishing = &brokerPublication{key: string(key[1 : 1+key[0]]), confirmed: confirming[channel]}
+			}
+		case headerFrameType:
+			// The body frames are skipped, the content being complete with its header for these tests
+			if publishing == nil {
+				continue
+			}
+			b.mu.Lock()
+			b.publications = append(b.publications, *publishing)
+			b.mu.Unlock()
+
+			if publishing.confirmed {
+				published[channel]++
+				// basic.ack of the delivery tag, not multiple
+				err = writeMethod(conn, channel, 60, 80, append(binary.BigEndian.AppendUint64(nil, published[channel]), 0))
+			}
+			publishing = nil
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func readFrame(conn net.Conn) (byte, uint16, []byte, error) {
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return 0, 0, nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header[3:])+1)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return 0, 0, nil, err
+	}
+	return header[0], binary.BigEndian.Uint16(header[1:]), payload[:len(payload)-1], nil
+}
+
+func writeMethod(conn net.Conn, channel, class, method uint16, args []byte) error {
+	payload := binary.BigEndian.AppendUint16(nil, class)
+	payload = binary.BigEndian.AppendUint16(payload, method)
+	payload = append(payload, args...)
+
+	var frame bytes.Buffer
+	frame.WriteByte(methodFrameType)
+	frame.Write(binary.BigEndian.AppendUint16(nil, channel))
+	frame.Write(binary.BigEndian.AppendUint32(nil, uint32(len(payload))))
+	frame.Write(payload)
+	frame.WriteByte(frameEnd)
+
+	_, err := conn.Write(frame.Bytes())
+	return err
+}
+
+func appendLongString(b []byte, s string) []byte {
+	return append(binary.BigEndian.AppendUint32(b, uint32(len(s))), s...)
+}
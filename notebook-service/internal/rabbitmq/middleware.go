@@ -0,0 +1,180 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"notebook-service/api/events"
+	"notebook-service/internal/metrics"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Recovery turns a panic of the handler into an error, so the event is retried rather than crashing the service
+func Recovery() Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, event *events.Envelope) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("handler panicked: %v", r)
+				}
+			}()
+			return next(ctx, event)
+		}
+	}
+}
+
+// Logging logs the outcome and the duration of every handled event
+func Logging() Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, event *events.Envelope) error {
+			start := time.Now()
+			err := next(ctx, event)
+			if err != nil {
+				log.Printf("Failed handling event %s %s after %v: %v", event.Type, event.Id, time.Since(start), err)
+			} else {
+				log.Printf("Handled event %s %s in %v", event.Type, event.Id, time.Since(start))
+			}
+			return err
+		}
+	}
+}
+
+// Metrics counts the handled events by type and result
+func Metrics() Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, event *events.Envelope) error {
+			err := next(ctx, event)
+			metrics.RecordConsumed(event.Type, err)
+			return err
+		}
+	}
+}
+
+// Tracing runs the handler in a span, child of the trace of the publisher carried by the context
+func Tracing() Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, event *events.Envelope) error {
+			ctx, span := startConsumeSpan(ctx, event.Type)
+			defer span.End()
+
+			err := next(ctx, event)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return err
+		}
+	}
+}
+
+// DedupStore remembers the events already handled
+type DedupStore interface {
+	// Claim leases the event to the handler. When it is taken, processed tells whether it was handled
+	// or is still leased to another handler.
+	Claim(ctx context.Context, id string) (claimed bool, processed bool, err error)
+	// Complete marks the event handled, so its duplicates are skipped
+	Complete(ctx context.Context, id string) error
+	// Release forgets the event, so it is handled again on its retry
+	Release(ctx context.Context, id string) error
+}
+
+// Deduplicate runs the handler at most once per event id, as events are delivered at least once, and counts
+// the processed and duplicate events. The event is leased while it is handled and only marked handled once the
+// handler succeeded, so a redelivery after a crash is handled again when the lease expired and retried until then.
+// Events are handled when the store fails, a duplicate being better than a lost event.
+func Deduplicate(store DedupStore) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, event *events.Envelope) error {
+			claimed, processed, err := store.Claim(ctx, event.Id)
+			if err != nil {
+				log.Printf("Failed checking event %s for duplicates, handling it: %v", event.Id, err)
+				return next(ctx, event)
+			}
+			if processed {
+				metrics.RecordDeduplicated(event.Type, true)
+				log.Printf("Skipping duplicate event %s %s", event.Type, event.Id)
+				return nil
+			}
+			if !claimed {
+				return fmt.Errorf("event %s is being handled, retrying it later", event.Id)
+			}
+			metrics.RecordDeduplicated(event.Type, false)
+
+			err = next(ctx, event)
+			if err != nil {
+				if releaseErr := store.Release(ctx, event.Id); releaseErr != nil {
+					log.Printf("Failed releasing event %s, its retry waits for the lease to expire: %v", event.Id, releaseErr)
+				}
+				return err
+			}
+			if completeErr := store.Complete(ctx, event.Id); completeErr != nil {
+				log.Printf("Failed marking event %s handled, its duplicates are handled again: %v", event.Id, completeErr)
+			}
+			return nil
+		}
+	}
+}
+
+// Interval between two removals of the expired events of a memory store
+const DEDUP_PURGE_INTERVAL = time.Minute
+
+// Event remembered by a memory store
+type dedupEntry struct {
+	processed bool
+	expiry    time.Time
+}
+
+// memoryDedupStore keeps the handled events in memory, so duplicates are only caught within one replica
+type memoryDedupStore struct {
+	lease     time.Duration
+	ttl       time.Duration
+	mu        sync.Mutex
+	entries   map[string]dedupEntry
+	lastPurge time.Time
+}
+
+// NewMemoryDedupStore returns a store leasing the events for the lease while they are handled, then remembering
+// the handled events for the ttl
+func NewMemoryDedupStore(lease time.Duration, ttl time.Duration) DedupStore {
+	return &memoryDedupStore{lease: lease, ttl: ttl, entries: map[string]dedupEntry{}, lastPurge: time.Now()}
+}
+
+func (s *memoryDedupStore) Claim(ctx context.Context, id string) (bool, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastPurge) >= DEDUP_PURGE_INTERVAL {
+		for key, entry := range s.entries {
+			if !now.Before(entry.expiry) {
+				delete(s.entries, key)
+			}
+		}
+		s.lastPurge = now
+	}
+
+	if entry, exists := s.entries[id]; exists && now.Before(entry.expiry) {
+		return false, entry.processed, nil
+	}
+	s.entries[id] = dedupEntry{expiry: now.Add(s.lease)}
+	return true, false, nil
+}
+
+func (s *memoryDedupStore) Complete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[id] = dedupEntry{processed: true, expiry: time.Now().Add(s.ttl)}
+	return nil
+}
+
+func (s *memoryDedupStore) Release(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, id)
+	return nil
+}
@@ -1,96 +1,263 @@
 package rabbitmq
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"notebook-service/api/events"
+	"notebook-service/internal/config"
+	"notebook-service/internal/metrics"
+	"sync"
 
 	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/codes"
+	"google.golang.org/protobuf/proto"
 )
 
 type RabbitMQHandler interface {
-	Publish(string, string) error
-	ConsumeMessages(map[string]func([]byte))
+	PublishEvent(ctx context.Context, eventType, actor string, data proto.Message) error
+	PublishConfirmed(ctx context.Context, event *events.Envelope) error
+	ConsumeMessages(router *Router)
+	ListDeadLetters(limit int) ([]DeadLetter, error)
+	ReplayDeadLetters(ctx context.Context, ids []string) (int, error)
+	StopConsuming()
+	State() ConnectionState
+	CheckConnection() error
 	Close()
 }
 
+// MessageHandler processes a consumed event. The context carries the trace of the publisher.
+// An error retries the event until it is dead-lettered.
+type MessageHandler func(context.Context, *events.Envelope) error
+
 // RabbitMQHandler handles RabbitMQ connections and operations
 type rabbitMQHandler struct {
-	conn    *amqp.Connection
-	channel *amqp.Channel
+	cfg         config.RabbitMQConfig
+	url         string
+	mu          sync.Mutex // Guards the fields below, replaced on every reconnection
+	conn        *amqp.Connection
+	channel     *amqp.Channel
+	state       ConnectionState
+	router      *Router // Router of the consumer, resumed on every reconnection
+	consuming   bool
+	pending     []pendingMessage // Messages published while disconnected
+	closed      chan struct{}    // Closed with the handler, stopping the reconnections
+	consumersWg sync.WaitGroup   // Tracks the goroutines running the handlers
+
+	confirmMu sync.Mutex // Guards the channel in confirm mode, one confirmed publication at a time
+	confirmCh *amqp.Channel
+	confirms  chan amqp.Confirmation
 }
 
 const EXCHANGE_NAME = "suedataplatform"
+const CONSUMER_TAG = "notebook-service"
 
 const (
 	PVC      = "PVC"
 	NOTEBOOK = "NOTEBOOK"
+	WEBHOOK  = "WEBHOOK"
 
 	CREATE = "CREATE"
 	DELETE = "DELETE"
+	EXPIRE = "EXPIRE"
+	START  = "START"
+	STOP   = "STOP"
+	FAIL   = "FAIL"
+	TEST   = "TEST"
 )
 
-// NewRabbitMQHandler initializes and returns a RabbitMQHandler
-func NewRabbitMQHandler() RabbitMQHandler {
-	rabbitmqUrl := os.Getenv("RABBIT_MQ_URL")
-	username := os.Getenv("RABBIT_MQ_USERNAME")
-	password := os.Getenv("RABBIT_MQ_PASSWORD")
+// NewRabbitMQHandler returns a RabbitMQHandler connecting in the background, so the service starts while
+// RabbitMQ is down. The connection is opened again whenever it is lost. The memory driver returns a MemoryBus
+// of the given exchange instead, which delivers the events to the services sharing it in the process.
+func NewRabbitMQHandler(cfg config.RabbitMQConfig, exchange *MemoryExchange) RabbitMQHandler {
+	if cfg.Driver == config.MEMORY_DRIVER {
+		log.Println("Using the in-memory message bus, events are only delivered within the process")
+		return exchange.NewBus(cfg, BOUND_KEYS, false)
+	}
 
-	url := fmt.Sprintf("amqp://%s:%s@%s/", username, password, rabbitmqUrl)
+	rbmq := newRabbitMQHandler(cfg)
+	go rbmq.maintainConnection()
+	return rbmq
+}
 
-	conn, err := amqp.Dial(url)
+func newRabbitMQHandler(cfg config.RabbitMQConfig) *rabbitMQHandler {
+	return &rabbitMQHandler{
+		cfg:    cfg,
+		url:    fmt.Sprintf("amqp://%s:%s@%s/", cfg.Username, cfg.Password, cfg.URL),
+		state:  CONNECTING,
+		closed: make(chan struct{}),
+	}
+}
+
+// PublishEvent wraps the data in an event envelope and publishes it under the event type as routing key
+func (r *rabbitMQHandler) PublishEvent(ctx context.Context, eventType, actor string, data proto.Message) error {
+	event, err := NewEvent(eventType, actor, data)
 	if err != nil {
-		log.Panicf("Failed to connect to RabbitMQ: %v", err)
+		return err
 	}
 
-	ch, err := conn.Channel()
+	msg, err := eventPublishing(event)
 	if err != nil {
-		log.Panicf("Failed to open a RabbitMQ channel: %v", err)
-	}
-
-	err = ch.ExchangeDeclare(
-		EXCHANGE_NAME,
-		"direct",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+		return err
+	}
+
+	err = r.publish(ctx, eventType, msg)
 	if err != nil {
-		log.Panicf("Failed to declare an exchange: %v", err)
+		return err
 	}
 
-	rbmq := &rabbitMQHandler{
-		conn:    conn,
-		channel: ch,
+	log.Printf("Event published: %s %s (%s)", event.Type, event.Id, event.Subject)
+	return nil
+}
+
+// PublishConfirmed publishes an event and waits until RabbitMQ confirms it was stored, so the event can
+// be forgotten by the caller. Fails right away while disconnected.
+func (r *rabbitMQHandler) PublishConfirmed(ctx context.Context, event *events.Envelope) error {
+	msg, err := eventPublishing(event)
+	if err != nil {
+		return err
 	}
 
-	return rbmq
+	msg.Headers = amqp.Table{}
+	_, span := startPublishSpan(ctx, event.Type, msg.Headers)
+	defer span.End()
+
+	err = r.sendConfirmed(ctx, event.Type, msg)
+	metrics.RecordPublished(event.Type, err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("failed to publish event %s to RabbitMQ: %w", event.Id, err)
+	}
+
+	return nil
+}
+
+// Publishes a message on the channel in confirm mode, opened with every connection and again after a failure
+func (r *rabbitMQHandler) sendConfirmed(ctx context.Context, key string, msg amqp.Publishing) error {
+	r.confirmMu.Lock()
+	defer r.confirmMu.Unlock()
+
+	if r.confirmCh == nil {
+		ch, err := r.openChannel()
+		if err != nil {
+			return err
+		}
+		confirms, err := confirmMode(ch)
+		if err != nil {
+			return err
+		}
+		r.confirmCh = ch
+		r.confirms = confirms
+	}
+
+	if err := r.confirmCh.Publish(EXCHANGE_NAME, key, false, false, msg); err != nil {
+		r.closeConfirmChannel()
+		return err
+	}
+
+	select {
+	case confirmation, ok := <-r.confirms:
+		if !ok {
+			r.closeConfirmChannel()
+			return errors.New("channel closed before the confirmation")
+		}
+		if !confirmation.Ack {
+			return errors.New("message rejected by RabbitMQ")
+		}
+		return nil
+	case <-ctx.Done():
+		// A late confirmation would be taken for the one of the next message
+		r.closeConfirmChannel()
+		return ctx.Err()
+	}
 }
 
-// Publish publishes a message to the queue
-func (r *rabbitMQHandler) Publish(key, message string) error {
-	err := r.channel.Publish(
-		EXCHANGE_NAME, // exchange
-		key,           // routing key
-		false,         // mandatory
-		false,         // immediate
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        []byte(message),
-		},
-	)
+// Puts the channel in confirm mode, returning the confirmations of its publications. The channel is closed on failure.
+func confirmMode(ch *amqp.Channel) (chan amqp.Confirmation, error) {
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed enabling publisher confirms: %w", err)
+	}
+	return ch.NotifyPublish(make(chan amqp.Confirmation, 1)), nil
+}
+
+// Drops the channel in confirm mode, called with the confirm lock held
+func (r *rabbitMQHandler) closeConfirmChannel() {
+	r.confirmCh.Close()
+	r.confirmCh = nil
+	r.confirms = nil
+}
+
+// Returns the message carrying an event envelope
+func eventPublishing(event *events.Envelope) (amqp.Publishing, error) {
+	body, err := proto.Marshal(event)
 	if err != nil {
+		return amqp.Publishing{}, fmt.Errorf("failed encoding event %s: %v", event.Type, err)
+	}
+
+	return amqp.Publishing{
+		ContentType:  EVENT_CONTENT_TYPE,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    event.Id,
+		Type:         event.Type,
+		AppId:        event.Source,
+		Timestamp:    event.Time.AsTime(),
+		Body:         body,
+	}, nil
+}
+
+// Publishes a message to the exchange, passing on the trace context in its headers
+func (r *rabbitMQHandler) publish(ctx context.Context, key string, msg amqp.Publishing) error {
+	msg.Headers = amqp.Table{}
+	_, span := startPublishSpan(ctx, key, msg.Headers)
+	defer span.End()
+
+	err := r.send(key, msg)
+	metrics.RecordPublished(key, err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return fmt.Errorf("failed to publish message to RabbitMQ: %w", err)
 	}
 
-	log.Printf("Message published: %s", message)
 	return nil
 }
 
-// Close the RabbitMQ connection and channel
+// State returns the current state of the connection
+func (r *rabbitMQHandler) State() ConnectionState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state
+}
+
+// CheckConnection reports an error while the handler is not connected to RabbitMQ
+func (r *rabbitMQHandler) CheckConnection() error {
+	if state := r.State(); state != CONNECTED {
+		return fmt.Errorf("connection to RabbitMQ is %s", state)
+	}
+	return nil
+}
+
+// Close the RabbitMQ connection and channel, and stop reconnecting
 func (r *rabbitMQHandler) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.state == CLOSED {
+		return
+	}
+	connected := r.state == CONNECTED
+	r.state = CLOSED
+	close(r.closed)
+
+	if len(r.pending) > 0 {
+		log.Printf("Dropping %d messages published while disconnected from RabbitMQ", len(r.pending))
+	}
+	if !connected {
+		return
+	}
 	if err := r.channel.Close(); err != nil {
 		log.Printf("Failed to close RabbitMQ channel: %v", err)
 	}
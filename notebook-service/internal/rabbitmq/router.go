@@ -0,0 +1,73 @@
+package rabbitmq
+
+import (
+	"context"
+	"log"
+	"notebook-service/api/events"
+	"sort"
+)
+
+// Middleware wraps the handlers of a router, running code around every consumed event
+type Middleware func(MessageHandler) MessageHandler
+
+// Router dispatches the consumed events to the handler of their type, through its middlewares
+type Router struct {
+	handlers    map[string]MessageHandler
+	fallback    MessageHandler
+	middlewares []Middleware
+}
+
+func NewRouter() *Router {
+	return &Router{
+		handlers: map[string]MessageHandler{},
+		fallback: DropUnrouted,
+	}
+}
+
+// Use adds middlewares to the router, the first one being the outermost
+func (r *Router) Use(middlewares ...Middleware) *Router {
+	r.middlewares = append(r.middlewares, middlewares...)
+	return r
+}
+
+// Handle routes the events with the routing key to the handler
+func (r *Router) Handle(key string, handler MessageHandler) *Router {
+	r.handlers[key] = handler
+	return r
+}
+
+// Default sets the handler of the events no handler is routed for, dropping them otherwise
+func (r *Router) Default(handler MessageHandler) *Router {
+	r.fallback = handler
+	return r
+}
+
+// Keys returns the routing keys having a handler, sorted
+func (r *Router) Keys() []string {
+	keys := make([]string, 0, len(r.handlers))
+	for key := range r.handlers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Dispatch runs the handler of the event type, or the default handler, wrapped in the middlewares
+func (r *Router) Dispatch(ctx context.Context, event *events.Envelope) error {
+	handler, exists := r.handlers[event.Type]
+	if !exists {
+		handler = r.fallback
+	}
+
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		handler = r.middlewares[i](handler)
+	}
+
+	return handler(ctx, event)
+}
+
+// DropUnrouted acknowledges the events of a bound routing key nobody handles, as no retry would change that
+func DropUnrouted(ctx context.Context, event *events.Envelope) error {
+	log.Printf("No handler for event %s %s, dropping it", event.Type, event.Id)
+	return nil
+}
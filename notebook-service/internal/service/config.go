@@ -2,58 +2,54 @@ package service
 
 import (
 	"notebook-service/api/controller"
+	"notebook-service/internal"
+	"notebook-service/internal/config"
 	"notebook-service/internal/mongo_repository"
 	"notebook-service/internal/rabbitmq"
 	"notebook-service/redis_repository"
-	"os"
-
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/rest"
 )
 
 type NotebookService struct {
-	rbmq      rabbitmq.RabbitMQHandler
-	redisRepo redis_repository.NotebookRepository
-	mongoRepo mongo_repository.NotebookRepository
+	rbmq        rabbitmq.RabbitMQHandler
+	redisRepo   redis_repository.NotebookRepository
+	mongoRepo   mongo_repository.NotebookRepository
+	profiles    mongo_repository.SchedulingProfileRepository
+	idempotency redis_repository.IdempotencyRepository
+	operations  *OperationsService
+	usage       mongo_repository.UsageRepository
+	kube        *internal.KubeClients
+	outbox      *OutboxRelay
 	controller.UnimplementedNotebookServiceServer
 }
 
-func GenerateNotebookService(rbmq rabbitmq.RabbitMQHandler, redisRepo redis_repository.NotebookRepository, mongoRepo mongo_repository.NotebookRepository) controller.NotebookServiceServer {
-	// Set the message handlers
-	handlers := map[string]func([]byte){
-		rabbitmq.GenerateRoutingKey(rabbitmq.PVC, rabbitmq.DELETE): HandlePVCDeleted,
-	}
-
-	go rbmq.ConsumeMessages(handlers)
-
-	return &NotebookService{rbmq: rbmq, mongoRepo: mongoRepo, redisRepo: redisRepo}
-}
-
-type Configuration struct {
-	UrlBase                   string
-	Namespace                 string
-	KubeflowKustomizationPath string
+func GenerateNotebookService(rbmq rabbitmq.RabbitMQHandler, redisRepo redis_repository.NotebookRepository, mongoRepo mongo_repository.NotebookRepository, profiles mongo_repository.SchedulingProfileRepository, idempotency redis_repository.IdempotencyRepository, operations *OperationsService, usage mongo_repository.UsageRepository, kube *internal.KubeClients, outbox *OutboxRelay, dedup rabbitmq.DedupStore, webhooks *WebhookDispatcher) controller.NotebookServiceServer {
+	service := &NotebookService{rbmq: rbmq, mongoRepo: mongoRepo, redisRepo: redisRepo, profiles: profiles, idempotency: idempotency, operations: operations, usage: usage, kube: kube, outbox: outbox}
+	operations.OnFailure(service.recordOperationFailure)
+
+	// Deliver the events of the service to the webhooks once published, the events of the other services are
+	// delivered once consumed
+	outbox.OnPublished(webhooks.Enqueue)
+
+	// Route the events to their handlers, the events of the other bound keys are only delivered to the webhooks
+	router := rabbitmq.NewRouter().
+		Use(
+			rabbitmq.Tracing(),
+			rabbitmq.Metrics(),
+			rabbitmq.Logging(),
+			rabbitmq.Deduplicate(dedup),
+			rabbitmq.Recovery(),
+			webhooks.Middleware(),
+		).
+		Handle(rabbitmq.GenerateRoutingKey(rabbitmq.PVC, rabbitmq.DELETE), rabbitmq.OnEvent(service.HandlePVCDeleted))
+
+	rbmq.ConsumeMessages(router)
+
+	return service
 }
 
-func getEnvironmentVariable(varname string) string {
-	variable, exists := os.LookupEnv(varname)
-	if !exists {
-		panic("Expected environment variable '" + varname + "' to exist.")
-	}
-
-	return variable
-}
-
-var CreateDynamicClient = func(config *rest.Config) (dynamic.Interface, error) {
-	return dynamic.NewForConfig(config)
-}
+// Settings of the service, loaded and validated at startup
+type Configuration = config.ServiceConfig
 
 var GetConfiguration = func() Configuration {
-	config := Configuration{}
-
-	config.UrlBase = getEnvironmentVariable("URLBASE")
-	config.Namespace = getEnvironmentVariable("NAMESPACE")
-	config.KubeflowKustomizationPath = getEnvironmentVariable("KUBEFLOW_KUSTOMIZATION_PATH")
-
-	return config
+	return config.Get().Service
 }
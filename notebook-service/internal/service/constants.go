@@ -1,7 +1,16 @@
 package service
 
+import "k8s.io/apimachinery/pkg/runtime/schema"
+
 // Define Kubeflow-related constants in one place
 const (
 	KUBEFLOW_GROUP       = "kubeflow.org"
 	KUBEFLOW_API_VERSION = "v1"
 )
+
+// GroupVersionResource of the Kubeflow notebook custom resource
+var notebookGVR = schema.GroupVersionResource{
+	Group:    KUBEFLOW_GROUP,
+	Version:  KUBEFLOW_API_VERSION,
+	Resource: KUBEFLOW_NOTEBOOKS_RESOURCE,
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"notebook-service/api/controller"
+	"notebook-service/internal/auth"
+	"notebook-service/internal/rabbitmq"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const DEFAULT_DEAD_LETTER_LIMIT = 50
+const MAX_DEAD_LETTER_LIMIT = 200
+
+// DeadLettersService lets administrators inspect and replay the events the consumers gave up on
+type DeadLettersService struct {
+	rbmq rabbitmq.RabbitMQHandler
+	controller.UnimplementedDeadLettersServer
+}
+
+func GenerateDeadLettersService(rbmq rabbitmq.RabbitMQHandler) *DeadLettersService {
+	return &DeadLettersService{rbmq: rbmq}
+}
+
+// ListDeadLetters returns the oldest dead letters, leaving them in the queue
+func (s *DeadLettersService) ListDeadLetters(ctx context.Context, req *controller.ListDeadLettersRequest) (*controller.ListDeadLettersResponse, error) {
+	if auth.GetRole(ctx) != auth.ADMIN {
+		return nil, status.Error(codes.PermissionDenied, "only administrators can manage dead letters")
+	}
+
+	limit := int(req.Limit)
+	if limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid limit")
+	}
+	if limit == 0 {
+		limit = DEFAULT_DEAD_LETTER_LIMIT
+	}
+	limit = min(limit, MAX_DEAD_LETTER_LIMIT)
+
+	deadLetters, err := s.rbmq.ListDeadLetters(limit)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	response := &controller.ListDeadLettersResponse{}
+	for _, deadLetter := range deadLetters {
+		response.DeadLetters = append(response.DeadLetters, deadLetterToResponse(deadLetter))
+	}
+
+	return response, nil
+}
+
+// ReplayDeadLetters publishes the selected dead letters again, every dead letter when none is selected
+func (s *DeadLettersService) ReplayDeadLetters(ctx context.Context, req *controller.ReplayDeadLettersRequest) (*controller.ReplayDeadLettersResponse, error) {
+	if auth.GetRole(ctx) != auth.ADMIN {
+		return nil, status.Error(codes.PermissionDenied, "only administrators can manage dead letters")
+	}
+
+	replayed, err := s.rbmq.ReplayDeadLetters(ctx, req.Ids)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "replayed %d dead letters before failing: %v", replayed, err)
+	}
+
+	return &controller.ReplayDeadLettersResponse{Replayed: int32(replayed)}, nil
+}
+
+func deadLetterToResponse(deadLetter rabbitmq.DeadLetter) *controller.DeadLetter {
+	response := &controller.DeadLetter{
+		Id:         deadLetter.ID,
+		RoutingKey: deadLetter.RoutingKey,
+		Retries:    int32(deadLetter.Retries),
+		Error:      deadLetter.Error,
+	}
+	if event := deadLetter.Event; event != nil {
+		response.Subject = event.Subject
+		response.Actor = event.Actor
+		response.Time = event.Time
+	}
+
+	return response
+}
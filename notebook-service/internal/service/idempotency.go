@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"notebook-service/internal/auth"
+	"notebook-service/redis_repository"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Metadata header carrying the idempotency key of requests without a request id
+const IDEMPOTENCY_KEY_HEADER = "idempotency-key"
+
+const DEFAULT_IDEMPOTENCY_TTL = 24 * time.Hour
+
+// Time a request holds its key, so the retry of a crashed attempt is executed again afterwards
+const IDEMPOTENCY_LEASE = 5 * time.Minute
+
+// Returns the idempotency key from the request id, falling back to the metadata header
+func getIdempotencyKey(ctx context.Context, requestId *string) string {
+	if requestId != nil && *requestId != "" {
+		return *requestId
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(IDEMPOTENCY_KEY_HEADER); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// Failures that may succeed when retried are not stored, so the retry executes the request again
+func isRetryableCode(code codes.Code) bool {
+	switch code {
+	case codes.Internal, codes.Unavailable, codes.Aborted, codes.DeadlineExceeded, codes.Canceled, codes.Unknown:
+		return true
+	default:
+		return false
+	}
+}
+
+// Runs the handler once per idempotency key. A replayed request with the same key and payload
+// receives the stored outcome of the first execution instead of running the handler again.
+func runIdempotent[T proto.Message](
+	ctx context.Context,
+	repo redis_repository.IdempotencyRepository,
+	method string,
+	requestId *string,
+	req proto.Message,
+	handler func() (T, error),
+) (T, error) {
+	var empty T
+
+	key := getIdempotencyKey(ctx, requestId)
+	if key == "" {
+		return handler()
+	}
+
+	// Keys are scoped to the method and the user so clients cannot collide with each other
+	username, _ := ctx.Value(auth.CtxKey).(string)
+	scopedKey := method + ":" + username + ":" + key
+
+	payload, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return empty, status.Errorf(codes.Internal, "failed encoding request: %v", err)
+	}
+	payloadHash := sha256.Sum256(payload)
+	hash := hex.EncodeToString(payloadHash[:])
+
+	record, reserved, err := repo.Reserve(ctx, scopedKey, hash, IDEMPOTENCY_LEASE)
+	if err != nil {
+		return empty, status.Error(codes.Internal, err.Error())
+	}
+
+	if !reserved {
+		if record.PayloadHash != hash {
+			return empty, status.Errorf(codes.InvalidArgument, "idempotency key %s was already used for a different request", key)
+		}
+		if !record.Completed {
+			return empty, status.Errorf(codes.Aborted, "request with idempotency key %s is still in progress", key)
+		}
+		if codes.Code(record.Code) != codes.OK {
+			return empty, status.Error(codes.Code(record.Code), record.Message)
+		}
+
+		response := empty.ProtoReflect().New().Interface().(T)
+		if err := proto.Unmarshal(record.Response, response); err != nil {
+			return empty, status.Errorf(codes.Internal, "failed decoding stored response: %v", err)
+		}
+		return response, nil
+	}
+
+	response, err := handler()
+
+	code := status.Code(err)
+	if isRetryableCode(code) {
+		if releaseErr := repo.Release(ctx, scopedKey); releaseErr != nil {
+			log.Printf("Warning: %v", releaseErr)
+		}
+		return response, err
+	}
+
+	record = &redis_repository.IdempotencyRecord{
+		PayloadHash: hash,
+		Completed:   true,
+		Code:        uint32(code),
+	}
+	if err != nil {
+		record.Message = status.Convert(err).Message()
+	} else {
+		record.Response, err = proto.Marshal(response)
+		if err != nil {
+			return response, status.Errorf(codes.Internal, "failed encoding response: %v", err)
+		}
+	}
+
+	ttl := GetConfiguration().IdempotencyTTL
+	if ttl <= 0 {
+		ttl = DEFAULT_IDEMPOTENCY_TTL
+	}
+
+	// The request already succeeded, so a failure to store the outcome only loses the deduplication
+	if completeErr := repo.Complete(ctx, scopedKey, record, ttl); completeErr != nil {
+		log.Printf("Warning: %v", completeErr)
+	}
+
+	return response, err
+}
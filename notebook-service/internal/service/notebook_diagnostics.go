@@ -0,0 +1,163 @@
+package service
+
+import (
+	"notebook-service/api/controller"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Human readable explanation and suggested fix of a diagnosis category
+type diagnosisDescription struct {
+	explanation string
+	suggestion  string
+}
+
+var diagnosisDescriptions = map[controller.DiagnosisCategory]diagnosisDescription{
+	controller.DiagnosisCategory_IMAGE_PULL_FAILED: {
+		explanation: "The notebook image could not be pulled from the registry.",
+		suggestion:  "Check that the image name and tag exist and that the cluster can reach the registry.",
+	},
+	controller.DiagnosisCategory_INSUFFICIENT_CPU: {
+		explanation: "No node has enough free CPU for the requested minimum CPU.",
+		suggestion:  "Lower the minimum CPU of the notebook or stop other notebooks to free capacity.",
+	},
+	controller.DiagnosisCategory_INSUFFICIENT_MEMORY: {
+		explanation: "No node has enough free memory for the requested minimum memory.",
+		suggestion:  "Lower the minimum memory of the notebook or stop other notebooks to free capacity.",
+	},
+	controller.DiagnosisCategory_VOLUME_NOT_BOUND: {
+		explanation: "The workspace volume is not bound to a persistent volume.",
+		suggestion:  "Check that the storage class exists and can provision volumes of the requested size.",
+	},
+	controller.DiagnosisCategory_OOM_KILLED: {
+		explanation: "The notebook container was killed because it used more memory than its limit.",
+		suggestion:  "Increase the maximum memory of the notebook or reduce the memory used by the workload.",
+	},
+	controller.DiagnosisCategory_CRASH_LOOP_BACK_OFF: {
+		explanation: "The notebook container keeps crashing right after it starts.",
+		suggestion:  "Read the notebook logs to find out why the container exits.",
+	},
+	controller.DiagnosisCategory_UNSCHEDULABLE: {
+		explanation: "The notebook pod cannot be placed on any node.",
+		suggestion:  "Check the scheduling message for the constraint that cannot be satisfied.",
+	},
+	controller.DiagnosisCategory_QUOTA_EXCEEDED: {
+		explanation: "Creating the notebook would exceed the resource quota of the namespace.",
+		suggestion:  "Delete unused notebooks or volumes, or ask an administrator to raise the quota.",
+	},
+}
+
+// Creates a diagnosis of the given category for a Kubernetes object
+func newDiagnosis(category controller.DiagnosisCategory, kind string, name string, detail string) *controller.Diagnosis {
+	description := diagnosisDescriptions[category]
+
+	return &controller.Diagnosis{
+		Category:    category,
+		ObjectKind:  kind,
+		ObjectName:  name,
+		Explanation: description.explanation,
+		Suggestion:  description.suggestion,
+		Detail:      detail,
+	}
+}
+
+// Classifies the message of a scheduling failure
+func classifySchedulingMessage(message string) controller.DiagnosisCategory {
+	lowerMessage := strings.ToLower(message)
+
+	switch {
+	case strings.Contains(lowerMessage, "insufficient cpu"):
+		return controller.DiagnosisCategory_INSUFFICIENT_CPU
+	case strings.Contains(lowerMessage, "insufficient memory"):
+		return controller.DiagnosisCategory_INSUFFICIENT_MEMORY
+	case strings.Contains(lowerMessage, "persistentvolumeclaim"):
+		return controller.DiagnosisCategory_VOLUME_NOT_BOUND
+	default:
+		return controller.DiagnosisCategory_UNSCHEDULABLE
+	}
+}
+
+// Classifies a warning event, returning UNCLASSIFIED for events without a known cause
+func classifyEvent(event v1.Event) controller.DiagnosisCategory {
+	lowerMessage := strings.ToLower(event.Message)
+
+	switch {
+	case event.Reason == "FailedScheduling":
+		return classifySchedulingMessage(event.Message)
+	case event.Reason == "ErrImagePull", event.Reason == "ImagePullBackOff", event.Reason == "InvalidImageName",
+		strings.Contains(lowerMessage, "pulling image"), strings.Contains(lowerMessage, "errimagepull"):
+		return controller.DiagnosisCategory_IMAGE_PULL_FAILED
+	case strings.Contains(lowerMessage, "back-off restarting failed container"):
+		return controller.DiagnosisCategory_CRASH_LOOP_BACK_OFF
+	case event.Reason == "OOMKilling":
+		return controller.DiagnosisCategory_OOM_KILLED
+	case event.Reason == "ProvisioningFailed", event.Reason == "FailedBinding", event.Reason == "FailedAttachVolume", event.Reason == "FailedMount":
+		return controller.DiagnosisCategory_VOLUME_NOT_BOUND
+	case strings.Contains(lowerMessage, "exceeded quota"):
+		return controller.DiagnosisCategory_QUOTA_EXCEEDED
+	default:
+		return controller.DiagnosisCategory_UNCLASSIFIED
+	}
+}
+
+// Inspects the status of a pod for scheduling and container failures
+func diagnosePod(pod *v1.Pod) []*controller.Diagnosis {
+	var diagnoses []*controller.Diagnosis
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodScheduled && condition.Status == v1.ConditionFalse && condition.Reason == v1.PodReasonUnschedulable {
+			category := classifySchedulingMessage(condition.Message)
+			diagnoses = append(diagnoses, newDiagnosis(category, "Pod", pod.Name, condition.Message))
+		}
+	}
+
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if waiting := containerStatus.State.Waiting; waiting != nil {
+			switch waiting.Reason {
+			case "ErrImagePull", "ImagePullBackOff", "InvalidImageName":
+				diagnoses = append(diagnoses, newDiagnosis(controller.DiagnosisCategory_IMAGE_PULL_FAILED, "Pod", pod.Name, waiting.Message))
+			case "CrashLoopBackOff":
+				diagnoses = append(diagnoses, newDiagnosis(controller.DiagnosisCategory_CRASH_LOOP_BACK_OFF, "Pod", pod.Name, waiting.Message))
+			}
+		}
+
+		// The container may be restarting already, so check the previous termination as well
+		for _, terminated := range []*v1.ContainerStateTerminated{containerStatus.State.Terminated, containerStatus.LastTerminationState.Terminated} {
+			if terminated != nil && terminated.Reason == "OOMKilled" {
+				detail := "container " + containerStatus.Name + " was OOMKilled"
+				diagnoses = append(diagnoses, newDiagnosis(controller.DiagnosisCategory_OOM_KILLED, "Pod", pod.Name, detail))
+				break
+			}
+		}
+	}
+
+	return diagnoses
+}
+
+// Inspects the status of a persistent volume claim
+func diagnosePvc(pvc *v1.PersistentVolumeClaim) []*controller.Diagnosis {
+	if pvc.Status.Phase == v1.ClaimPending {
+		detail := "persistent volume claim " + pvc.Name + " is pending"
+		return []*controller.Diagnosis{newDiagnosis(controller.DiagnosisCategory_VOLUME_NOT_BOUND, "PersistentVolumeClaim", pvc.Name, detail)}
+	}
+
+	return nil
+}
+
+// Removes diagnoses reporting the same category for the same object
+func deduplicateDiagnoses(diagnoses []*controller.Diagnosis) []*controller.Diagnosis {
+	seen := map[string]bool{}
+	var result []*controller.Diagnosis
+
+	for _, diagnosis := range diagnoses {
+		key := diagnosis.Category.String() + "/" + diagnosis.ObjectKind + "/" + diagnosis.ObjectName
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, diagnosis)
+	}
+
+	return result
+}
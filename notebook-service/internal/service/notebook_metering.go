@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"log"
+	"notebook-service/internal/model"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Returns the requested cores and memory in GB of a notebook container
+func requestedUsage(requests v1.ResourceList) (float64, float64) {
+	cpu := requests[v1.ResourceCPU]
+	memory := requests[v1.ResourceMemory]
+
+	return cpu.AsApproximateFloat64(), quantityGB(memory)
+}
+
+// Converts a memory or storage quantity to GB
+func quantityGB(quantity resource.Quantity) float64 {
+	return quantity.AsApproximateFloat64() / 1e9
+}
+
+// Records a lifecycle transition and updates the usage intervals of the notebook. Metering must not
+// fail the lifecycle change it follows, so errors are only logged.
+func (s *NotebookService) recordUsage(ctx context.Context, event *model.UsageEvent) {
+	err := s.usage.InsertUsageEvent(ctx, event)
+	if err != nil {
+		log.Printf("Failed to record usage event: %v", err)
+	}
+
+	openCompute := false
+	switch event.Transition {
+	case model.USAGE_CREATE:
+		openCompute = true
+		if event.StorageGB > 0 {
+			s.openUsageInterval(ctx, event, model.USAGE_STORAGE)
+		}
+	case model.USAGE_START:
+		// Close an interval left open by a missed stop, so it is not charged twice
+		s.closeUsageIntervals(ctx, event, model.USAGE_COMPUTE)
+		openCompute = true
+	case model.USAGE_STOP:
+		s.closeUsageIntervals(ctx, event, model.USAGE_COMPUTE)
+	case model.USAGE_RESIZE:
+		// A stopped notebook has no open interval and is only charged again once started
+		openCompute = s.closeUsageIntervals(ctx, event, model.USAGE_COMPUTE) > 0
+	case model.USAGE_DELETE:
+		s.closeUsageIntervals(ctx, event, "")
+	}
+
+	if openCompute {
+		s.openUsageInterval(ctx, event, model.USAGE_COMPUTE)
+	}
+}
+
+func (s *NotebookService) openUsageInterval(ctx context.Context, event *model.UsageEvent, kind string) {
+	interval := &model.UsageInterval{
+		NotebookName: event.NotebookName,
+		Username:     event.Username,
+		Team:         event.Team,
+		Kind:         kind,
+		Start:        event.Timestamp,
+	}
+	if kind == model.USAGE_COMPUTE {
+		interval.Cpu = event.Cpu
+		interval.MemoryGB = event.MemoryGB
+	} else {
+		interval.StorageGB = event.StorageGB
+	}
+
+	err := s.usage.OpenUsageInterval(ctx, interval)
+	if err != nil {
+		log.Printf("Failed to open usage interval: %v", err)
+	}
+}
+
+func (s *NotebookService) closeUsageIntervals(ctx context.Context, event *model.UsageEvent, kind string) int64 {
+	closed, err := s.usage.CloseUsageIntervals(ctx, event.NotebookName, kind, event.Timestamp)
+	if err != nil {
+		log.Printf("Failed to close usage intervals: %v", err)
+	}
+
+	return closed
+}
+
+// Accumulated usage of one group of a report
+type usageTotals struct {
+	cpuHours      float64
+	memoryGBHours float64
+	storageGBDays float64
+}
+
+// Sums the usage of the intervals within [start, end). Open intervals are charged until now.
+func aggregateUsage(intervals []model.UsageInterval, start time.Time, end time.Time, now time.Time, groupKey func(model.UsageInterval) string) map[string]*usageTotals {
+	totals := map[string]*usageTotals{}
+
+	for _, interval := range intervals {
+		from := interval.Start
+		if from.Before(start) {
+			from = start
+		}
+
+		to := now
+		if interval.End != nil {
+			to = *interval.End
+		}
+		if to.After(end) {
+			to = end
+		}
+
+		if !to.After(from) {
+			continue
+		}
+		hours := to.Sub(from).Hours()
+
+		key := groupKey(interval)
+		if totals[key] == nil {
+			totals[key] = &usageTotals{}
+		}
+
+		switch interval.Kind {
+		case model.USAGE_COMPUTE:
+			totals[key].cpuHours += interval.Cpu * hours
+			totals[key].memoryGBHours += interval.MemoryGB * hours
+		case model.USAGE_STORAGE:
+			totals[key].storageGBDays += interval.StorageGB * hours / 24
+		}
+	}
+
+	return totals
+}
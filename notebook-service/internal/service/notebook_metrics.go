@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// Name of the notebook list cache in the cache metrics
+const NOTEBOOK_CACHE = "notebooks"
+
+// States notebooks are counted by
+const (
+	NOTEBOOK_RUNNING = "running"
+	NOTEBOOK_PENDING = "pending"
+	NOTEBOOK_STOPPED = "stopped"
+)
+
+// CountNotebooksByState returns a count of the notebooks of the namespace by whether they are running, starting or stopped
+func CountNotebooksByState(dynamicClient dynamic.Interface) func(context.Context) (map[string]float64, error) {
+	return func(ctx context.Context) (map[string]float64, error) {
+		return countNotebooksByState(ctx, dynamicClient)
+	}
+}
+
+func countNotebooksByState(ctx context.Context, dynamicClient dynamic.Interface) (map[string]float64, error) {
+	notebooks, err := dynamicClient.Resource(notebookGVR).Namespace(GetConfiguration().Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]float64{NOTEBOOK_RUNNING: 0, NOTEBOOK_PENDING: 0, NOTEBOOK_STOPPED: 0}
+	for _, notebook := range notebooks.Items {
+		counts[notebookState(&notebook)]++
+	}
+
+	return counts, nil
+}
+
+// Returns the state of a notebook from its stop annotation and the ready replicas reported by the controller
+func notebookState(notebook *unstructured.Unstructured) string {
+	if _, stopped := notebook.GetAnnotations()[STOPPED_ANNOTATION]; stopped {
+		return NOTEBOOK_STOPPED
+	}
+
+	readyReplicas, _, _ := unstructured.NestedInt64(notebook.Object, "status", "readyReplicas")
+	if readyReplicas > 0 {
+		return NOTEBOOK_RUNNING
+	}
+
+	return NOTEBOOK_PENDING
+}
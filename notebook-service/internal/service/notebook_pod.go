@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Label the notebook controller puts on the pods of a notebook
+const NOTEBOOK_NAME_LABEL = "notebook-name"
+
+// findNotebookPod returns the pod backing a notebook, preferring a running pod over the others
+func findNotebookPod(ctx context.Context, clientset kubernetes.Interface, namespace string, notebookName string) (*v1.Pod, error) {
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", NOTEBOOK_NAME_LABEL, notebookName),
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed listing notebook pods: %v", err)
+	}
+
+	if len(pods.Items) == 0 {
+		return nil, status.Errorf(codes.NotFound, "no pod found for notebook %s", notebookName)
+	}
+
+	for i := range pods.Items {
+		if pods.Items[i].Status.Phase == v1.PodRunning {
+			return &pods.Items[i], nil
+		}
+	}
+
+	return &pods.Items[0], nil
+}
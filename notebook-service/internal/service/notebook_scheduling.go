@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"notebook-service/api/controller"
+	"notebook-service/internal/auth"
+	"notebook-service/internal/model"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+const SCHEDULING_PROFILE_ANNOTATION = "suedataplatform/scheduling-profile"
+
+// Returns the scheduling profile selected in the request, or the default profile of the notebook type.
+// A default profile the role of the user is not permitted to use is ignored instead of failing the request.
+func (s *NotebookService) resolveSchedulingProfile(ctx context.Context, req *controller.CreateNotebookRequest) (*model.SchedulingProfile, error) {
+	role := auth.GetRole(ctx)
+
+	if req.SchedulingProfile != nil {
+		profile, err := s.profiles.GetSchedulingProfile(ctx, *req.SchedulingProfile)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if profile == nil {
+			return nil, status.Errorf(codes.NotFound, "scheduling profile %s not found", *req.SchedulingProfile)
+		}
+		if role != auth.ADMIN && !profile.PermitsRole(role) {
+			return nil, status.Errorf(codes.PermissionDenied, "scheduling profile %s is not permitted for role %s", profile.Name, role)
+		}
+
+		return profile, nil
+	}
+
+	notebookType := controller.NotebookType_JUPITER
+	if req.Type != nil {
+		notebookType = *req.Type
+	}
+
+	profile, err := s.profiles.FindDefaultSchedulingProfile(ctx, notebookType.String())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if profile == nil || (role != auth.ADMIN && !profile.PermitsRole(role)) {
+		return nil, nil
+	}
+
+	return profile, nil
+}
+
+// Sets the node placement of the profile on the notebook pod
+func applySchedulingProfile(notebook *model.Notebook, profile *model.SchedulingProfile) {
+	if profile == nil {
+		return
+	}
+
+	if notebook.Metadata.Annotations == nil {
+		notebook.Metadata.Annotations = map[string]string{}
+	}
+	notebook.Metadata.Annotations[SCHEDULING_PROFILE_ANNOTATION] = profile.Name
+
+	podSpec := &notebook.Spec.Template.Spec
+	podSpec.NodeSelector = profile.NodeSelector
+	podSpec.Tolerations = profile.Tolerations
+	podSpec.Affinity = profile.Affinity
+	podSpec.PriorityClassName = profile.PriorityClassName
+}
+
+// Validates a scheduling profile sent by an administrator and converts it to its database model
+func schedulingProfileFromRequest(req *controller.SchedulingProfile) (*model.SchedulingProfile, error) {
+	if errs := validation.IsDNS1123Label(req.Name); len(errs) > 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid scheduling profile name: %s", strings.Join(errs, ", "))
+	}
+
+	profile := &model.SchedulingProfile{
+		Name:              req.Name,
+		Description:       req.Description,
+		NodeSelector:      req.NodeSelector,
+		PriorityClassName: req.PriorityClassName,
+		AllowedRoles:      req.AllowedRoles,
+	}
+
+	for _, toleration := range req.Tolerations {
+		operator := v1.TolerationOperator(toleration.Operator)
+		if operator != "" && operator != v1.TolerationOpExists && operator != v1.TolerationOpEqual {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid toleration operator: %s", toleration.Operator)
+		}
+		if operator == v1.TolerationOpExists && toleration.Value != "" {
+			return nil, status.Error(codes.InvalidArgument, "toleration value must be empty when the operator is Exists")
+		}
+
+		effect := v1.TaintEffect(toleration.Effect)
+		if effect != "" && effect != v1.TaintEffectNoSchedule && effect != v1.TaintEffectPreferNoSchedule && effect != v1.TaintEffectNoExecute {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid toleration effect: %s", toleration.Effect)
+		}
+
+		profile.Tolerations = append(profile.Tolerations, v1.Toleration{
+			Key:               toleration.Key,
+			Operator:          operator,
+			Value:             toleration.Value,
+			Effect:            effect,
+			TolerationSeconds: toleration.TolerationSeconds,
+		})
+	}
+
+	if req.Affinity != "" {
+		var affinity v1.Affinity
+		if err := yaml.UnmarshalStrict([]byte(req.Affinity), &affinity); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid affinity: %v", err)
+		}
+		profile.Affinity = &affinity
+	}
+
+	for _, role := range req.AllowedRoles {
+		if role != auth.ADMIN && role != auth.DS {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid role: %s", role)
+		}
+	}
+
+	for _, notebookType := range req.DefaultForTypes {
+		if !slices.Contains(profile.DefaultForTypes, notebookType.String()) {
+			profile.DefaultForTypes = append(profile.DefaultForTypes, notebookType.String())
+		}
+	}
+
+	return profile, nil
+}
+
+// Converts a scheduling profile from its database model to the response message
+func schedulingProfileToResponse(profile *model.SchedulingProfile) (*controller.SchedulingProfile, error) {
+	response := &controller.SchedulingProfile{
+		Name:              profile.Name,
+		Description:       profile.Description,
+		NodeSelector:      profile.NodeSelector,
+		PriorityClassName: profile.PriorityClassName,
+		AllowedRoles:      profile.AllowedRoles,
+	}
+
+	for _, toleration := range profile.Tolerations {
+		response.Tolerations = append(response.Tolerations, &controller.Toleration{
+			Key:               toleration.Key,
+			Operator:          string(toleration.Operator),
+			Value:             toleration.Value,
+			Effect:            string(toleration.Effect),
+			TolerationSeconds: toleration.TolerationSeconds,
+		})
+	}
+
+	if profile.Affinity != nil {
+		affinity, err := yaml.Marshal(profile.Affinity)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed encoding affinity: %v", err)
+		}
+		response.Affinity = string(affinity)
+	}
+
+	for _, notebookType := range profile.DefaultForTypes {
+		response.DefaultForTypes = append(response.DefaultForTypes, controller.NotebookType(controller.NotebookType_value[notebookType]))
+	}
+
+	return response, nil
+}
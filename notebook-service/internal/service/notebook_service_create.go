@@ -3,19 +3,19 @@ package service
 import (
 	"context"
 	"fmt"
+	"log"
 	"notebook-service/api/controller"
-	"notebook-service/internal"
+	"notebook-service/api/events"
 	"notebook-service/internal/auth"
 	"notebook-service/internal/model"
+	"notebook-service/internal/rabbitmq"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/types/known/emptypb"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	k8sYaml "k8s.io/apimachinery/pkg/runtime/serializer/yaml"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
@@ -56,7 +56,17 @@ func setBoolValue(value *bool) bool {
 	return *value
 }
 
-func (s *NotebookService) CreateNotebook(ctx context.Context, req *controller.CreateNotebookRequest) (*emptypb.Empty, error) {
+// Parsed resource quantities of a create notebook request
+type notebookResources struct {
+	cpuLimit      resource.Quantity
+	cpuRequest    resource.Quantity
+	memoryLimit   resource.Quantity
+	memoryRequest resource.Quantity
+	volumeSize    resource.Quantity
+}
+
+// Validates the requested resources, falling back to the defaults for missing values
+func parseNotebookResources(req *controller.CreateNotebookRequest) (*notebookResources, error) {
 	cpuLimitResource, err := resource.ParseQuantity(setStringValue(req.MaxCpu, "2"))
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, "invalid max cpu")
@@ -85,98 +95,142 @@ func (s *NotebookService) CreateNotebook(ctx context.Context, req *controller.Cr
 
 	}
 
-	environmentConfig := GetConfiguration()
-	namespace := environmentConfig.Namespace
+	return &notebookResources{
+		cpuLimit:      cpuLimitResource,
+		cpuRequest:    cpuRequestResource,
+		memoryLimit:   memoryLimitResource,
+		memoryRequest: memoryRequestResource,
+		volumeSize:    parsedVolumeSize,
+	}, nil
+}
+
+// CreateNotebook validates the request and starts an operation creating the workspace volume and the notebook
+func (s *NotebookService) CreateNotebook(ctx context.Context, req *controller.CreateNotebookRequest) (*controller.Operation, error) {
+	return runIdempotent(ctx, s.idempotency, "CreateNotebook", req.RequestId, req, func() (*controller.Operation, error) {
+		return s.createNotebook(ctx, req)
+	})
+}
 
-	config, err := internal.GetKubeConfig()
+func (s *NotebookService) createNotebook(ctx context.Context, req *controller.CreateNotebookRequest) (*controller.Operation, error) {
+	resources, err := parseNotebookResources(req)
 	if err != nil {
-		return nil, status.Error(codes.Internal, "failed getting kube config")
+		return nil, err
 	}
 
-	dynamicClient, err := CreateDynamicClient(config)
+	schedulingProfile, err := s.resolveSchedulingProfile(ctx, req)
 	if err != nil {
-		return nil, status.Error(codes.Internal, "failed creating dynamic client")
+		return nil, err
 	}
 
-	clientset, err := kubernetes.NewForConfig(config)
+	environmentConfig := GetConfiguration()
+	namespace := environmentConfig.Namespace
+
+	storage, err := resolveVolumeStorage(environmentConfig, req)
 	if err != nil {
-		return nil, status.Error(codes.Internal, "failed creating new client set")
+		return nil, err
 	}
 
-	pvc := setStringValue(req.Pvc, "")
+	dynamicClient := s.kube.Dynamic
 
-	pvcArg := pvc
-	if pvcArg == "" {
-		_, err = CreatePvcResource(clientset, namespace, req.Name, parsedVolumeSize)
-		if err != nil {
-			return nil, status.Error(codes.Internal, "failed creating pvc")
+	clientset := s.kube.Clientset
 
-		}
-		pvcArg = req.Name + WORKSPACE_SUFFIX
-	}
+	var steps []operationStep
 
-	_, err = createNotebookResource(dynamicClient, namespace, req.Name, req.Type, cpuLimitResource, cpuRequestResource, memoryLimitResource, memoryRequestResource, pvcArg)
-	if err != nil {
-		return nil, status.Error(codes.Internal, "failed creating new notebook")
+	usageEvent := &model.UsageEvent{
+		NotebookName: req.Name,
+		Username:     ctx.Value(auth.CtxKey).(string),
+		Team:         auth.GetTeam(ctx),
+		Transition:   model.USAGE_CREATE,
+		Cpu:          resources.cpuRequest.AsApproximateFloat64(),
+		MemoryGB:     quantityGB(resources.memoryRequest),
+	}
 
+	pvcArg := setStringValue(req.Pvc, "")
+	if pvcArg == "" {
+		pvcDefinition := createNotebookPvcDefinition(namespace, req.Name, usageEvent.Username, resources.volumeSize, storage)
+		pvcArg = pvcDefinition.Name
+
+		// Only a volume created with the notebook is charged to it
+		usageEvent.StorageGB = quantityGB(resources.volumeSize)
+
+		steps = append(steps, operationStep{name: "create-volume", run: func(context.Context) error {
+			_, err := CreatePvcResource(clientset, &pvcDefinition, metav1.CreateOptions{})
+			if err != nil {
+				return status.Error(codes.Internal, "failed creating pvc")
+			}
+			return nil
+		}})
 	}
 
-	fmt.Printf("Notebook '%s' created successfully. Please wait a few seconds for the notebook to start.\n", req.Name)
+	notebook := createNotebookDefinition(namespace, req.Name, req.Type, resources.cpuLimit, resources.cpuRequest, resources.memoryLimit, resources.memoryRequest, pvcArg, createEnvFromSources(req.EnvFrom))
+	applySchedulingProfile(notebook, schedulingProfile)
 
-	// Store in database
 	notebookEntity := &model.NotebookEntity{
 		Username:     ctx.Value(auth.CtxKey).(string),
 		NotebookName: req.Name,
 	}
 
-	err = s.mongoRepo.CreateNotebook(notebookEntity)
+	steps = append(steps,
+		operationStep{name: "create-notebook", run: func(context.Context) error {
+			_, err := createNotebookResource(dynamicClient, notebook, metav1.CreateOptions{})
+			if err != nil {
+				return status.Error(codes.Internal, "failed creating new notebook")
+			}
+
+			fmt.Printf("Notebook '%s' created successfully. Please wait a few seconds for the notebook to start.\n", req.Name)
+			return nil
+		}},
+		operationStep{name: "register-notebook", run: func(ctx context.Context) error {
+			if err := s.registerNotebook(ctx, notebookEntity); err != nil {
+				return err
+			}
+
+			// Keyed by the operation, so a retried step records the creation event once
+			key := rabbitmq.GenerateRoutingKey(rabbitmq.NOTEBOOK, rabbitmq.CREATE)
+			err := s.outbox.RecordOnce(ctx, operationID(ctx)+":"+key, key, notebookEntity.Username, &events.NotebookCreated{NotebookName: req.Name})
+			if err != nil {
+				log.Printf("Failed to record notebook creation event: %v", err)
+				return status.Error(codes.Internal, err.Error())
+			}
+			return nil
+		}},
+		s.recordUsageStep(usageEvent),
+		operationStep{name: "open-notebook", run: func(context.Context) error {
+			CallOpen(req.Name, setBoolValue(req.Open))
+			return nil
+		}},
+	)
+
+	return s.operations.start(ctx, "CreateNotebook", req.Name, steps)
+}
+
+// Stores the notebook in the database and in the cache of the user, if it exists
+func (s *NotebookService) registerNotebook(ctx context.Context, notebookEntity *model.NotebookEntity) error {
+	err := s.mongoRepo.CreateNotebook(ctx, notebookEntity)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return status.Error(codes.Internal, err.Error())
 	}
 
-	// Update cache if exists
-	exists, err := s.redisRepo.CheckCacheExists(notebookEntity.Username)
+	exists, err := s.redisRepo.CheckCacheExists(ctx, notebookEntity.Username)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return status.Error(codes.Internal, err.Error())
 	}
 	if exists {
-		err = s.redisRepo.AddNotebook(notebookEntity.Username, notebookEntity.NotebookName)
+		err = s.redisRepo.AddNotebook(ctx, notebookEntity.Username, notebookEntity.NotebookName)
 		if err != nil {
-			return nil, status.Error(codes.Internal, err.Error())
+			return status.Error(codes.Internal, err.Error())
 		}
 	}
 
-	open := setBoolValue(req.Open)
-	CallOpen(req.Name, open)
-
-	return nil, nil
+	return nil
 }
 
-var CreatePvcResource = func(clientset *kubernetes.Clientset, namespace string, notebookName string, volumeSize resource.Quantity) (*v1.PersistentVolumeClaim, error) {
-	pvc := createNotebookPvcDefinition(namespace, notebookName, volumeSize)
-	client := clientset.CoreV1().PersistentVolumeClaims(namespace)
-	return client.Create(context.TODO(), &pvc, metav1.CreateOptions{})
+var CreatePvcResource = func(clientset kubernetes.Interface, pvc *v1.PersistentVolumeClaim, options metav1.CreateOptions) (*v1.PersistentVolumeClaim, error) {
+	client := clientset.CoreV1().PersistentVolumeClaims(pvc.Namespace)
+	return client.Create(context.TODO(), pvc, options)
 }
 
-func createNotebookResource(
-	dynamicClient dynamic.Interface,
-	namespace string,
-	notebookName string,
-	notebookType *controller.NotebookType,
-	cpuLimitResource resource.Quantity,
-	cpuRequestResource resource.Quantity,
-	memoryLimitResource resource.Quantity,
-	memoryRequestResource resource.Quantity,
-	pvcName string,
-) (*unstructured.Unstructured, error) {
-	gvr := schema.GroupVersionResource{
-		Group:    KUBEFLOW_GROUP,
-		Version:  KUBEFLOW_API_VERSION,
-		Resource: KUBEFLOW_NOTEBOOKS_RESOURCE,
-	}
-
-	notebook := createNotebookDefinition(namespace, notebookName, notebookType, cpuLimitResource, cpuRequestResource, memoryLimitResource, memoryRequestResource, pvcName)
-
+func createNotebookResource(dynamicClient dynamic.Interface, notebook *model.Notebook, options metav1.CreateOptions) (*unstructured.Unstructured, error) {
 	yaml, err := yaml.Marshal(notebook)
 	if err != nil {
 		return nil, err
@@ -189,8 +243,8 @@ func createNotebookResource(
 		return nil, err
 	}
 
-	client := dynamicClient.Resource(gvr).Namespace(namespace)
-	createdNotebook, err := client.Create(context.Background(), obj, metav1.CreateOptions{})
+	client := dynamicClient.Resource(notebookGVR).Namespace(notebook.Metadata.Namespace)
+	createdNotebook, err := client.Create(context.Background(), obj, options)
 	if err != nil {
 		return nil, err
 	}
@@ -198,20 +252,30 @@ func createNotebookResource(
 	return createdNotebook, nil
 }
 
-func createNotebookPvcDefinition(namespace string, notebookName string, volumeSize resource.Quantity) v1.PersistentVolumeClaim {
+func createNotebookPvcDefinition(namespace string, notebookName string, owner string, volumeSize resource.Quantity, storage *volumeStorage) v1.PersistentVolumeClaim {
+	annotations := map[string]string{OWNER_ANNOTATION: owner}
+	for name, value := range storage.retention {
+		annotations[name] = value
+	}
+
 	return v1.PersistentVolumeClaim{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "PersistentVolumeClaim",
+		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      notebookName + WORKSPACE_SUFFIX,
-			Namespace: namespace,
+			Name:        notebookName + WORKSPACE_SUFFIX,
+			Namespace:   namespace,
+			Annotations: annotations,
 		},
 		Spec: v1.PersistentVolumeClaimSpec{
-			AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			AccessModes: []v1.PersistentVolumeAccessMode{storage.accessMode},
 			Resources: v1.VolumeResourceRequirements{
 				Requests: v1.ResourceList{
 					v1.ResourceName(v1.ResourceStorage): volumeSize,
 				},
 			},
-			StorageClassName: pointerToString("gp2"), // Add this
+			StorageClassName: storage.storageClass,
 		},
 	}
 }
@@ -230,6 +294,7 @@ func createNotebookDefinition(
 	memoryLimitResource resource.Quantity,
 	memoryRequestResource resource.Quantity,
 	pvcName string,
+	envFrom []v1.EnvFromSource,
 ) *model.Notebook {
 	image, annotations := getNotebookAnnotationsAndImage(namespace, notebookName, notebookType)
 
@@ -259,6 +324,7 @@ func createNotebookDefinition(
 									v1.ResourceMemory: memoryRequestResource,
 								},
 							},
+							EnvFrom: envFrom,
 							VolumeMounts: []v1.VolumeMount{
 								{
 									Name:      pvcName,
@@ -283,6 +349,24 @@ func createNotebookDefinition(
 	}
 }
 
+// Converts the requested environment sources to their Kubernetes counterpart
+func createEnvFromSources(sources []*controller.EnvFromSource) []v1.EnvFromSource {
+	var envFrom []v1.EnvFromSource
+
+	for _, source := range sources {
+		reference := v1.LocalObjectReference{Name: source.Name}
+
+		switch source.Kind {
+		case controller.EnvSourceKind_CONFIG_MAP:
+			envFrom = append(envFrom, v1.EnvFromSource{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: reference}})
+		case controller.EnvSourceKind_SECRET:
+			envFrom = append(envFrom, v1.EnvFromSource{SecretRef: &v1.SecretEnvSource{LocalObjectReference: reference}})
+		}
+	}
+
+	return envFrom
+}
+
 func getNotebookAnnotationsAndImage(
 	namespace string,
 	notebookName string,
@@ -5,14 +5,15 @@ import (
 	"errors"
 	"log"
 	"notebook-service/api/controller"
+	"notebook-service/api/events"
 	"notebook-service/internal/auth"
 	"notebook-service/internal/model"
+	"notebook-service/internal/rabbitmq"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
-	"k8s.io/client-go/rest"
 )
 
 func TestCreateNotebookInvalidRequests(t *testing.T) {
@@ -61,61 +62,29 @@ func TestCreateNotebookInvalidRequests(t *testing.T) {
 	assert.ErrorIs(t, err, status.Error(codes.InvalidArgument, "invalid volume size"))
 }
 
-func TestCreateNotebookGetKubeConfigError(t *testing.T) {
-	ctx := context.Background()
-
-	req := &controller.CreateNotebookRequest{
-		Name: "notebook-test",
-	}
-
-	restoreGetKubeConfig := mockGetKubeConfig(nil, errors.New("get kube config error"))
-	defer restoreGetKubeConfig()
-
-	res, err := notebookService.CreateNotebook(ctx, req)
-
-	assert.Nil(t, res)
-	assert.ErrorIs(t, err, status.Error(codes.Internal, "failed getting kube config"))
-}
-
-func TestCreateNotebookErrorCreateDynamicClient(t *testing.T) {
-	ctx := context.Background()
-
-	req := &controller.CreateNotebookRequest{
-		Name: "notebook-test",
-	}
-
-	restoreGetKubeConfig := mockGetKubeConfig(&rest.Config{}, nil)
-	defer restoreGetKubeConfig()
-
-	restoreCreateDynamicClient := mockCreateDynamicClient(errors.New("error at creating dynamic client"))
-	defer restoreCreateDynamicClient()
-
-	res, err := notebookService.CreateNotebook(ctx, req)
-
-	assert.Nil(t, res)
-	assert.ErrorIs(t, err, status.Error(codes.Internal, "failed creating dynamic client"))
-}
-
 func TestCreateNotebookErrorFailedGeneratingPVC(t *testing.T) {
-	ctx := context.Background()
-
 	req := &controller.CreateNotebookRequest{
 		Name: "notebook-test",
 	}
 
-	restoreGetKubeConfig := mockGetKubeConfig(&rest.Config{}, nil)
-	defer restoreGetKubeConfig()
-
-	restoreCreateDynamicClient := mockCreateDynamicClient(nil)
-	defer restoreCreateDynamicClient()
+	_, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
 
 	restoreCreatePVCResource := mockCreatePvcResource(nil, errors.New("pvc error"))
 	defer restoreCreatePVCResource()
 
-	res, err := notebookService.CreateNotebook(ctx, req)
+	res, err := notebookService.CreateNotebook(ctxWithValue, req)
 
-	assert.Nil(t, res)
-	assert.ErrorIs(t, err, status.Error(codes.Internal, "failed creating pvc"))
+	assert.Nil(t, err)
+	assert.Equal(t, "CreateNotebook", res.Kind)
+	assertOperationFailed(t, "create-volume", status.Error(codes.Internal, "failed creating pvc"))
+
+	// The failure is announced to the owner of the notebook
+	failed := lastRecordedEvent(t, rabbitmq.GenerateRoutingKey(rabbitmq.NOTEBOOK, rabbitmq.FAIL)).(*events.NotebookFailed)
+	assert.Equal(t, req.Name, failed.NotebookName)
+	assert.Equal(t, username, failed.Owner)
+	assert.Equal(t, "failed creating pvc", failed.Reason)
+	assert.Equal(t, lastOperation(t).ID, failed.OperationId)
 }
 
 func TestCreateNotebookFailedStoringNotebookToMongoDB(t *testing.T) {
@@ -123,11 +92,8 @@ func TestCreateNotebookFailedStoringNotebookToMongoDB(t *testing.T) {
 		Name: "notebook-test",
 	}
 
-	restoreGetKubeConfig := mockGetKubeConfig(&rest.Config{}, nil)
-	defer restoreGetKubeConfig()
-
-	restoreCreateDynamicClient := mockCreateDynamicClient(nil)
-	defer restoreCreateDynamicClient()
+	_, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
 
 	restoreCreatePVCResource := mockCreatePvcResource(pvc, nil)
 	defer restoreCreatePVCResource()
@@ -140,9 +106,9 @@ func TestCreateNotebookFailedStoringNotebookToMongoDB(t *testing.T) {
 	errMsg := "mongo error"
 	mongo.On("CreateNotebook", notebook).Return(errors.New(errMsg)).Once()
 
-	res, err := notebookService.CreateNotebook(ctxWithValue, req)
-	assert.Nil(t, res)
-	assert.ErrorIs(t, err, status.Error(codes.Internal, errMsg))
+	_, err := notebookService.CreateNotebook(ctxWithValue, req)
+	assert.Nil(t, err)
+	assertOperationFailed(t, "register-notebook", status.Error(codes.Internal, errMsg))
 }
 
 func TestCreateNotebookFailedCheckingCache(t *testing.T) {
@@ -150,11 +116,8 @@ func TestCreateNotebookFailedCheckingCache(t *testing.T) {
 		Name: "notebook-test",
 	}
 
-	restoreGetKubeConfig := mockGetKubeConfig(&rest.Config{}, nil)
-	defer restoreGetKubeConfig()
-
-	restoreCreateDynamicClient := mockCreateDynamicClient(nil)
-	defer restoreCreateDynamicClient()
+	_, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
 
 	restoreCreatePVCResource := mockCreatePvcResource(pvc, nil)
 	defer restoreCreatePVCResource()
@@ -174,9 +137,9 @@ func TestCreateNotebookFailedCheckingCache(t *testing.T) {
 	errMsg := "redis error"
 	redis.On("CheckCacheExists", notebook.Username).Return(false, errors.New(errMsg)).Once()
 
-	res, err := notebookService.CreateNotebook(ctxWithValue, req)
-	assert.Nil(t, res)
-	assert.ErrorIs(t, err, status.Error(codes.Internal, errMsg))
+	_, err := notebookService.CreateNotebook(ctxWithValue, req)
+	assert.Nil(t, err)
+	assertOperationFailed(t, "register-notebook", status.Error(codes.Internal, errMsg))
 }
 
 func TestCreateNotebookFailedCachingNotebook(t *testing.T) {
@@ -184,11 +147,8 @@ func TestCreateNotebookFailedCachingNotebook(t *testing.T) {
 		Name: "notebook-test",
 	}
 
-	restoreGetKubeConfig := mockGetKubeConfig(&rest.Config{}, nil)
-	defer restoreGetKubeConfig()
-
-	restoreCreateDynamicClient := mockCreateDynamicClient(nil)
-	defer restoreCreateDynamicClient()
+	_, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
 
 	restoreCreatePVCResource := mockCreatePvcResource(pvc, nil)
 	defer restoreCreatePVCResource()
@@ -208,9 +168,9 @@ func TestCreateNotebookFailedCachingNotebook(t *testing.T) {
 	errMsg := "redis error"
 	redis.On("AddNotebook", notebook.Username, req.Name).Return(errors.New(errMsg)).Once()
 
-	res, err := notebookService.CreateNotebook(ctxWithValue, req)
-	assert.Nil(t, res)
-	assert.ErrorIs(t, err, status.Error(codes.Internal, errMsg))
+	_, err := notebookService.CreateNotebook(ctxWithValue, req)
+	assert.Nil(t, err)
+	assertOperationFailed(t, "register-notebook", status.Error(codes.Internal, errMsg))
 }
 
 func TestCreateNotebookSuccess(t *testing.T) {
@@ -218,11 +178,8 @@ func TestCreateNotebookSuccess(t *testing.T) {
 		Name: "notebook-test",
 	}
 
-	restoreGetKubeConfig := mockGetKubeConfig(&rest.Config{}, nil)
-	defer restoreGetKubeConfig()
-
-	restoreCreateDynamicClient := mockCreateDynamicClient(nil)
-	defer restoreCreateDynamicClient()
+	_, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
 
 	restoreCreatePVCResource := mockCreatePvcResource(pvc, nil)
 	defer restoreCreatePVCResource()
@@ -238,9 +195,25 @@ func TestCreateNotebookSuccess(t *testing.T) {
 	// Mock checking the cache
 	redis.On("CheckCacheExists", notebook.Username).Return(false, nil).Once()
 
-	_, err := notebookService.CreateNotebook(ctxWithValue, req)
+	res, err := notebookService.CreateNotebook(ctxWithValue, req)
 	log.Println(err)
 	assert.Nil(t, err)
+	assert.Equal(t, req.Name, res.Target)
+	assert.False(t, res.Done)
+
+	operation := lastOperation(t)
+	assert.Equal(t, model.OPERATION_SUCCEEDED, operation.State)
+	assert.Nil(t, operation.Error)
+
+	var steps []string
+	for _, step := range operation.Steps {
+		assert.Equal(t, model.OPERATION_SUCCEEDED, step.State)
+		steps = append(steps, step.Name)
+	}
+	assert.Equal(t, []string{"create-volume", "create-notebook", "register-notebook", "record-usage", "open-notebook"}, steps)
+
+	created := lastRecordedEvent(t, rabbitmq.GenerateRoutingKey(rabbitmq.NOTEBOOK, rabbitmq.CREATE)).(*events.NotebookCreated)
+	assert.Equal(t, req.Name, created.NotebookName)
 }
 
 // Helper functions to create pointers
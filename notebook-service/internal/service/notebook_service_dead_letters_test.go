@@ -0,0 +1,78 @@
+package service_test
+
+import (
+	"errors"
+	"notebook-service/api/controller"
+	"notebook-service/api/events"
+	"notebook-service/internal/rabbitmq"
+	"notebook-service/internal/service"
+	"notebook-service/mocks/mock_rbmq"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestListDeadLettersRequiresAdmin(t *testing.T) {
+	deadLetters := service.GenerateDeadLettersService(new(mock_rbmq.RabbitMQClientMock))
+
+	_, err := deadLetters.ListDeadLetters(ctxWithValue, &controller.ListDeadLettersRequest{})
+	assert.ErrorIs(t, err, status.Error(codes.PermissionDenied, "only administrators can manage dead letters"))
+
+	_, err = deadLetters.ReplayDeadLetters(ctxWithValue, &controller.ReplayDeadLettersRequest{})
+	assert.ErrorIs(t, err, status.Error(codes.PermissionDenied, "only administrators can manage dead letters"))
+}
+
+func TestListDeadLetters(t *testing.T) {
+	event, err := rabbitmq.NewEvent("PVC.DELETE", "alice", &events.PvcDeleted{PvcName: "workspace-a"})
+	assert.Nil(t, err)
+
+	rbmq := new(mock_rbmq.RabbitMQClientMock)
+	rbmq.On("ListDeadLetters", service.DEFAULT_DEAD_LETTER_LIMIT).Return([]rabbitmq.DeadLetter{
+		{ID: event.Id, RoutingKey: "PVC.DELETE", Retries: 5, Error: "failed", Event: event},
+		{ID: "legacy-1", RoutingKey: "PVC.DELETE", Error: "failed decoding legacy payload"},
+	}, nil).Once()
+	rbmq.On("ListDeadLetters", service.MAX_DEAD_LETTER_LIMIT).Return(nil, errors.New("connection closed")).Once()
+
+	deadLetters := service.GenerateDeadLettersService(rbmq)
+
+	// Default limit
+	res, err := deadLetters.ListDeadLetters(adminCtx, &controller.ListDeadLettersRequest{})
+	assert.Nil(t, err)
+	assert.Len(t, res.DeadLetters, 2)
+	assert.Equal(t, event.Id, res.DeadLetters[0].Id)
+	assert.Equal(t, "workspace-a", res.DeadLetters[0].Subject)
+	assert.Equal(t, "alice", res.DeadLetters[0].Actor)
+	assert.Equal(t, int32(5), res.DeadLetters[0].Retries)
+	assert.Equal(t, "legacy-1", res.DeadLetters[1].Id)
+	assert.Empty(t, res.DeadLetters[1].Subject)
+
+	// Capped limit, failing broker
+	_, err = deadLetters.ListDeadLetters(adminCtx, &controller.ListDeadLettersRequest{Limit: 1000})
+	assert.ErrorIs(t, err, status.Error(codes.Unavailable, "connection closed"))
+
+	// Invalid limit
+	_, err = deadLetters.ListDeadLetters(adminCtx, &controller.ListDeadLettersRequest{Limit: -1})
+	assert.ErrorIs(t, err, status.Error(codes.InvalidArgument, "invalid limit"))
+
+	rbmq.AssertExpectations(t)
+}
+
+func TestReplayDeadLetters(t *testing.T) {
+	rbmq := new(mock_rbmq.RabbitMQClientMock)
+	rbmq.On("ReplayDeadLetters", []string{"event-1"}).Return(1, nil).Once()
+	rbmq.On("ReplayDeadLetters", []string(nil)).Return(2, errors.New("connection closed")).Once()
+
+	deadLetters := service.GenerateDeadLettersService(rbmq)
+
+	res, err := deadLetters.ReplayDeadLetters(adminCtx, &controller.ReplayDeadLettersRequest{Ids: []string{"event-1"}})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), res.Replayed)
+
+	// Every dead letter, failing after two
+	_, err = deadLetters.ReplayDeadLetters(adminCtx, &controller.ReplayDeadLettersRequest{})
+	assert.ErrorIs(t, err, status.Error(codes.Unavailable, "replayed 2 dead letters before failing: connection closed"))
+
+	rbmq.AssertExpectations(t)
+}
@@ -5,33 +5,36 @@ import (
 	"fmt"
 	"log"
 	"notebook-service/api/controller"
-	"notebook-service/internal"
+	"notebook-service/api/events"
 	"notebook-service/internal/auth"
+	"notebook-service/internal/model"
 	"notebook-service/internal/rabbitmq"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/types/known/emptypb"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
-// DeleteNotebook deletes a notebook in the specified namespace
-func (s *NotebookService) DeleteNotebook(ctx context.Context, req *controller.DeleteNotebookRequest) (*emptypb.Empty, error) {
-	//client := CreateDynamicClient
+// DeleteNotebook checks the caller owns the notebook and starts an operation deleting it
+func (s *NotebookService) DeleteNotebook(ctx context.Context, req *controller.DeleteNotebookRequest) (*controller.Operation, error) {
+	client := s.kube.Dynamic
 
-	config, err := internal.GetKubeConfig()
-	if err != nil {
-		return nil, status.Error(codes.Internal, "failed getting kube config")
-	}
+	// Extract the notebook name from the request
+	notebookName := req.NotebookName
 
-	client, err := CreateDynamicClient(config)
+	// Check the user owns the notebook before anything is deleted
+	username := ctx.Value(auth.CtxKey).(string)
+	team := auth.GetTeam(ctx)
+	isAuthorized, err := s.mongoRepo.AuthorizedUser(ctx, username, notebookName)
 	if err != nil {
-		return nil, status.Error(codes.Internal, "failed creating dynamic client")
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if !isAuthorized {
+		return nil, status.Error(codes.PermissionDenied, "user is unauthorized to perform this operation")
 	}
-
-	// Extract the notebook name from the request
-	notebookName := req.NotebookName
 
 	// Define the GroupVersionResource for Kubeflow Notebooks
 	gvr := schema.GroupVersionResource{
@@ -44,50 +47,62 @@ func (s *NotebookService) DeleteNotebook(ctx context.Context, req *controller.De
 	environmentConfig := GetConfiguration()
 	namespace := environmentConfig.Namespace
 
-	// Delete the notebook by name in the specified namespace
-	err = client.Resource(gvr).Namespace(namespace).Delete(context.TODO(), notebookName, metav1.DeleteOptions{})
-	if err != nil {
-		return nil, err
-	}
+	steps := []operationStep{
+		{name: "delete-notebook", run: func(ctx context.Context) error {
+			// Delete the notebook by name in the specified namespace. A notebook already gone was deleted by an
+			// earlier attempt interrupted before recording it, so its deletion is recorded now.
+			err := client.Resource(gvr).Namespace(namespace).Delete(ctx, notebookName, metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
 
-	// Publish a message to RabbitMQ
-	message := fmt.Sprintf("{\"notebook_name\": \"%s\"}", notebookName)
-	key := rabbitmq.GenerateRoutingKey(rabbitmq.NOTEBOOK, rabbitmq.DELETE)
-	log.Println(key)
-	err = s.rbmq.Publish(key, message)
-	if err != nil {
-		log.Printf("Failed to publish notebook deletion message: %v", err)
-		return nil, status.Errorf(codes.Internal, "Error publishing RabbitMQ message: %v", err)
-	}
+			fmt.Printf("Notebook '%s' deleted successfully.\n", notebookName)
 
-	fmt.Printf("Notebook '%s' deleted successfully.\n", notebookName)
-
-	// Delete notebook from database
-	username := ctx.Value(auth.CtxKey).(string)
-	isAuthorized, err := s.mongoRepo.AuthorizedUser(username, notebookName)
-	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-	if !isAuthorized {
-		return nil, status.Error(codes.PermissionDenied, "user is unauthorized to perform this operation")
+			// Record the deletion event in the same step, the relay publishes it even while RabbitMQ is down. It is
+			// keyed by the operation, so a retried step records it once.
+			key := rabbitmq.GenerateRoutingKey(rabbitmq.NOTEBOOK, rabbitmq.DELETE)
+			err = s.outbox.RecordOnce(ctx, operationID(ctx)+":"+key, key, username, &events.NotebookDeleted{NotebookName: notebookName})
+			if err != nil {
+				log.Printf("Failed to record notebook deletion event: %v", err)
+				return status.Error(codes.Internal, err.Error())
+			}
+			return nil
+		}},
+		{name: "unregister-notebook", run: func(ctx context.Context) error {
+			return s.unregisterNotebook(ctx, username, notebookName)
+		}},
+		{name: "record-usage", run: func(ctx context.Context) error {
+			s.recordUsage(ctx, &model.UsageEvent{
+				NotebookName: notebookName,
+				Username:     username,
+				Team:         team,
+				Transition:   model.USAGE_DELETE,
+				Timestamp:    time.Now(),
+			})
+			return nil
+		}},
 	}
 
-	err = s.mongoRepo.DeleteNotebook(notebookName)
+	return s.operations.start(ctx, "DeleteNotebook", notebookName, steps)
+}
+
+// Removes the notebook from the database and from the cache of the user, if it exists
+func (s *NotebookService) unregisterNotebook(ctx context.Context, username string, notebookName string) error {
+	err := s.mongoRepo.DeleteNotebook(ctx, notebookName)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return status.Error(codes.Internal, err.Error())
 	}
 
-	// Remove notebook from cache if cache exists
-	exists, err := s.redisRepo.CheckCacheExists(username)
+	exists, err := s.redisRepo.CheckCacheExists(ctx, username)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return status.Error(codes.Internal, err.Error())
 	}
 	if exists {
-		err = s.redisRepo.DeleteNotebook(username, notebookName)
+		err = s.redisRepo.DeleteNotebook(ctx, username, notebookName)
 		if err != nil {
-			return nil, status.Error(codes.Internal, err.Error())
+			return status.Error(codes.Internal, err.Error())
 		}
 	}
 
-	return &emptypb.Empty{}, nil
+	return nil
 }
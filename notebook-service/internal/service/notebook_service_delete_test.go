@@ -4,15 +4,17 @@ import (
 	"testing"
 
 	"notebook-service/api/controller"
+	"notebook-service/api/events"
+	"notebook-service/internal/model"
+	"notebook-service/internal/rabbitmq"
 	"notebook-service/internal/service"
 	mock_dynamic "notebook-service/mocks" // Correct import for the generated mocks
 
 	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
-	"google.golang.org/protobuf/types/known/emptypb"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/rest"
 )
 
 func TestDeleteNotebook(t *testing.T) {
@@ -29,9 +31,6 @@ func TestDeleteNotebook(t *testing.T) {
 		return mockConfig
 	}
 
-	restoreGetKubeConfig := mockGetKubeConfig(&rest.Config{}, nil)
-	defer restoreGetKubeConfig()
-
 	notebookName := "test-notebook"
 	gvr := schema.GroupVersionResource{
 		Group:    "kubeflow.org",
@@ -59,14 +58,8 @@ func TestDeleteNotebook(t *testing.T) {
 	}
 
 	// Mock dynamic client
-	oldCreateDynamicClient := service.CreateDynamicClient
-	service.CreateDynamicClient = func(*rest.Config) (dynamic.Interface, error) {
-		return mockDynamicClient, nil
-	}
-
-	defer func() {
-		service.CreateDynamicClient = oldCreateDynamicClient
-	}()
+	restoreDynamicClient := useDynamicClient(mockDynamicClient)
+	defer restoreDynamicClient()
 
 	// Mock if user authorized
 	mongo.On("AuthorizedUser", username, req.NotebookName).Return(true, nil).Once()
@@ -81,5 +74,49 @@ func TestDeleteNotebook(t *testing.T) {
 	resp, err := notebookService.DeleteNotebook(ctxWithValue, req)
 
 	assert.NoError(t, err)
-	assert.Equal(t, &emptypb.Empty{}, resp)
+	assert.Equal(t, "DeleteNotebook", resp.Kind)
+	assert.Equal(t, notebookName, resp.Target)
+	assert.Equal(t, model.OPERATION_SUCCEEDED, lastOperation(t).State)
+
+	// The deletion event is recorded in the outbox with the deletion, keyed by the operation
+	key := rabbitmq.GenerateRoutingKey(rabbitmq.NOTEBOOK, rabbitmq.DELETE)
+	recorded := outbox.Calls[len(outbox.Calls)-1].Arguments.Get(0).(*model.OutboxEvent)
+	assert.Equal(t, key, recorded.Type)
+	assert.Equal(t, uuid.NewSHA1(uuid.NameSpaceOID, []byte(resp.Id+":"+key)).String(), recorded.ID)
+}
+
+func TestDeleteNotebookAlreadyGoneFromTheCluster(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDynamicClient := mock_dynamic.NewMockInterface(ctrl)
+	mockResourceClient := mock_dynamic.NewMockNamespaceableResourceInterface(ctrl)
+
+	service.GetConfiguration = func() service.Configuration {
+		return service.Configuration{Namespace: "test-namespace"}
+	}
+
+	notebookName := "gone-notebook"
+	mockDynamicClient.EXPECT().Resource(gomock.Any()).Return(mockResourceClient).Times(1)
+	mockResourceClient.EXPECT().Namespace("test-namespace").Return(mockResourceClient).Times(1)
+	mockResourceClient.EXPECT().
+		Delete(gomock.Any(), notebookName, gomock.Any()).
+		Return(apierrors.NewNotFound(schema.GroupResource{Group: "kubeflow.org", Resource: "notebooks"}, notebookName)).
+		Times(1)
+
+	restoreDynamicClient := useDynamicClient(mockDynamicClient)
+	defer restoreDynamicClient()
+
+	mongo.On("AuthorizedUser", username, notebookName).Return(true, nil).Once()
+	mongo.On("DeleteNotebook", notebookName).Return(nil).Once()
+	redis.On("CheckCacheExists", username).Return(false, nil).Once()
+
+	resp, err := notebookService.DeleteNotebook(ctxWithValue, &controller.DeleteNotebookRequest{NotebookName: notebookName})
+
+	// An earlier attempt deleted the notebook before being interrupted, so its deletion is recorded now
+	assert.NoError(t, err)
+	assert.Equal(t, model.OPERATION_SUCCEEDED, lastOperation(t).State)
+	deleted := lastRecordedEvent(t, rabbitmq.GenerateRoutingKey(rabbitmq.NOTEBOOK, rabbitmq.DELETE)).(*events.NotebookDeleted)
+	assert.Equal(t, notebookName, deleted.NotebookName)
+	assert.Equal(t, "DeleteNotebook", resp.Kind)
 }
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"notebook-service/api/controller"
+	"notebook-service/internal/auth"
+	"notebook-service/internal/model"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Kubernetes object whose events are collected while diagnosing a notebook
+type diagnosedObject struct {
+	kind string
+	name string
+}
+
+// DiagnoseNotebook collects the Kubernetes events of a notebook and explains why it fails to start
+func (s *NotebookService) DiagnoseNotebook(ctx context.Context, req *controller.DiagnoseNotebookRequest) (*controller.DiagnoseNotebookResponse, error) {
+	// Only the owner of the notebook may diagnose it
+	username := ctx.Value(auth.CtxKey).(string)
+	isAuthorized, err := s.mongoRepo.AuthorizedUser(ctx, username, req.NotebookName)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if !isAuthorized {
+		return nil, status.Error(codes.PermissionDenied, "user is unauthorized to perform this operation")
+	}
+
+	dynamicClient := s.kube.Dynamic
+
+	clientset := s.kube.Clientset
+
+	namespace := GetConfiguration().Namespace
+
+	// Get the notebook custom resource to find out which volumes it mounts
+	notebookObject, err := dynamicClient.Resource(notebookGVR).Namespace(namespace).Get(ctx, req.NotebookName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, status.Errorf(codes.NotFound, "notebook %s not found", req.NotebookName)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed getting notebook: %v", err)
+	}
+
+	var notebook model.Notebook
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(notebookObject.Object, &notebook)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed decoding notebook: %v", err)
+	}
+
+	// The notebook controller creates a stateful set with the same name as the notebook
+	objects := []diagnosedObject{
+		{kind: KUBEFLOW_NOTEBOOK_KIND, name: req.NotebookName},
+		{kind: "StatefulSet", name: req.NotebookName},
+	}
+	var diagnoses []*controller.Diagnosis
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: NOTEBOOK_NAME_LABEL + "=" + req.NotebookName,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed listing notebook pods: %v", err)
+	}
+	for i := range pods.Items {
+		objects = append(objects, diagnosedObject{kind: "Pod", name: pods.Items[i].Name})
+		diagnoses = append(diagnoses, diagnosePod(&pods.Items[i])...)
+	}
+
+	for _, volume := range notebook.Spec.Template.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+
+		claimName := volume.PersistentVolumeClaim.ClaimName
+		objects = append(objects, diagnosedObject{kind: "PersistentVolumeClaim", name: claimName})
+
+		pvc, err := clientset.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, claimName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			detail := "persistent volume claim " + claimName + " does not exist"
+			diagnoses = append(diagnoses, newDiagnosis(controller.DiagnosisCategory_VOLUME_NOT_BOUND, "PersistentVolumeClaim", claimName, detail))
+			continue
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed getting persistent volume claim: %v", err)
+		}
+		diagnoses = append(diagnoses, diagnosePvc(pvc)...)
+	}
+
+	// Collect and classify the events of every object
+	var events []v1.Event
+	for _, object := range objects {
+		objectEvents, err := listObjectEvents(ctx, clientset, namespace, object)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed listing events: %v", err)
+		}
+		events = append(events, objectEvents...)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventLastSeen(events[i]).Before(eventLastSeen(events[j]))
+	})
+
+	response := &controller.DiagnoseNotebookResponse{}
+	for _, event := range events {
+		if event.Type == v1.EventTypeWarning {
+			if category := classifyEvent(event); category != controller.DiagnosisCategory_UNCLASSIFIED {
+				diagnoses = append(diagnoses, newDiagnosis(category, event.InvolvedObject.Kind, event.InvolvedObject.Name, event.Message))
+			}
+		}
+
+		response.Events = append(response.Events, &controller.NotebookEvent{
+			ObjectKind: event.InvolvedObject.Kind,
+			ObjectName: event.InvolvedObject.Name,
+			Type:       event.Type,
+			Reason:     event.Reason,
+			Message:    event.Message,
+			Count:      event.Count,
+			LastSeen:   timestamppb.New(eventLastSeen(event)),
+		})
+	}
+	response.Diagnoses = deduplicateDiagnoses(diagnoses)
+
+	return response, nil
+}
+
+// Lists the events involving a single object
+func listObjectEvents(ctx context.Context, clientset kubernetes.Interface, namespace string, object diagnosedObject) ([]v1.Event, error) {
+	selector := fields.Set{
+		"involvedObject.kind": object.kind,
+		"involvedObject.name": object.name,
+	}
+
+	list, err := clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	// Not every client honors field selectors, so filter again
+	var events []v1.Event
+	for _, event := range list.Items {
+		if event.InvolvedObject.Kind == object.kind && event.InvolvedObject.Name == object.name {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+// Returns the last time an event was observed
+func eventLastSeen(event v1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.FirstTimestamp.Time
+	}
+}
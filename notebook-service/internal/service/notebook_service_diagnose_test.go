@@ -0,0 +1,187 @@
+package service_test
+
+import (
+	"context"
+	"notebook-service/api/controller"
+	"notebook-service/internal/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+// Creates a notebook custom resource mounting the given persistent volume claim
+func createNotebookObject(notebookName string, claimName string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "kubeflow.org/v1",
+		"kind":       "Notebook",
+		"metadata": map[string]interface{}{
+			"name":      notebookName,
+			"namespace": service.GetConfiguration().Namespace,
+		},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": notebookName},
+					},
+					"volumes": []interface{}{
+						map[string]interface{}{
+							"name":                  claimName,
+							"persistentVolumeClaim": map[string]interface{}{"claimName": claimName},
+						},
+					},
+				},
+			},
+		},
+	}}
+}
+
+var notebookResource = schema.GroupVersionResource{Group: "kubeflow.org", Version: "v1", Resource: "notebooks"}
+
+// Creates a notebook custom resource with cpu and memory set on its container
+func createNotebookObjectWithResources(notebookName string, stopped bool) *unstructured.Unstructured {
+	notebook := createNotebookObject(notebookName, notebookName+service.WORKSPACE_SUFFIX)
+	if stopped {
+		notebook.SetAnnotations(map[string]string{service.STOPPED_ANNOTATION: "2026-01-01T00:00:00Z"})
+	}
+
+	container := map[string]interface{}{
+		"name": notebookName,
+		"resources": map[string]interface{}{
+			"requests": map[string]interface{}{"cpu": "1", "memory": "2G"},
+			"limits":   map[string]interface{}{"cpu": "2", "memory": "4Gi"},
+		},
+	}
+	unstructured.SetNestedSlice(notebook.Object, []interface{}{container}, "spec", "template", "spec", "containers")
+
+	return notebook
+}
+
+func getNotebookObject(t *testing.T, client *fake.FakeDynamicClient, notebookName string) *unstructured.Unstructured {
+	notebook, err := client.Resource(notebookResource).Namespace(service.GetConfiguration().Namespace).Get(context.Background(), notebookName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	return notebook
+}
+
+func createEvent(name string, kind string, objectName string, eventType string, reason string, message string) *v1.Event {
+	return &v1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: service.GetConfiguration().Namespace},
+		InvolvedObject: v1.ObjectReference{Kind: kind, Name: objectName},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		Count:          1,
+	}
+}
+
+func diagnosisCategories(response *controller.DiagnoseNotebookResponse) []controller.DiagnosisCategory {
+	var categories []controller.DiagnosisCategory
+	for _, diagnosis := range response.Diagnoses {
+		categories = append(categories, diagnosis.Category)
+	}
+	return categories
+}
+
+func TestDiagnoseNotebookUnauthorized(t *testing.T) {
+	req := &controller.DiagnoseNotebookRequest{NotebookName: "notebook-diagnose"}
+
+	mongo.On("AuthorizedUser", username, req.NotebookName).Return(false, nil).Once()
+
+	res, err := notebookService.DiagnoseNotebook(ctxWithValue, req)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.PermissionDenied, "user is unauthorized to perform this operation"))
+}
+
+func TestDiagnoseNotebookNotFound(t *testing.T) {
+	req := &controller.DiagnoseNotebookRequest{NotebookName: "notebook-diagnose"}
+
+	_, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
+
+	_, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	mongo.On("AuthorizedUser", username, req.NotebookName).Return(true, nil).Once()
+
+	res, err := notebookService.DiagnoseNotebook(ctxWithValue, req)
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestDiagnoseNotebookImagePullAndScheduling(t *testing.T) {
+	req := &controller.DiagnoseNotebookRequest{NotebookName: "notebook-diagnose"}
+	claimName := req.NotebookName + service.WORKSPACE_SUFFIX
+
+	_, restoreDynamicClient := useFakeDynamicClient(createNotebookObject(req.NotebookName, claimName))
+	defer restoreDynamicClient()
+
+	pod := createNotebookPod(req.NotebookName, v1.PodPending)
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name:  req.NotebookName,
+		State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "Back-off pulling image"}},
+	}}
+	pendingPvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: claimName, Namespace: service.GetConfiguration().Namespace},
+		Status:     v1.PersistentVolumeClaimStatus{Phase: v1.ClaimPending},
+	}
+	schedulingEvent := createEvent("event-1", "Pod", pod.Name, v1.EventTypeWarning, "FailedScheduling", "0/3 nodes are available: 3 Insufficient cpu.")
+	normalEvent := createEvent("event-2", "StatefulSet", req.NotebookName, v1.EventTypeNormal, "SuccessfulCreate", "create Pod notebook-diagnose-0")
+	otherEvent := createEvent("event-3", "Pod", "other-pod", v1.EventTypeWarning, "FailedScheduling", "0/3 nodes are available: 3 Insufficient memory.")
+
+	_, restoreClientset := useFakeClientset(pod, pendingPvc, schedulingEvent, normalEvent, otherEvent)
+	defer restoreClientset()
+
+	mongo.On("AuthorizedUser", username, req.NotebookName).Return(true, nil).Once()
+
+	res, err := notebookService.DiagnoseNotebook(ctxWithValue, req)
+
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []controller.DiagnosisCategory{
+		controller.DiagnosisCategory_IMAGE_PULL_FAILED,
+		controller.DiagnosisCategory_VOLUME_NOT_BOUND,
+		controller.DiagnosisCategory_INSUFFICIENT_CPU,
+	}, diagnosisCategories(res))
+	assert.Len(t, res.Events, 2)
+	for _, diagnosis := range res.Diagnoses {
+		assert.NotEmpty(t, diagnosis.Explanation)
+		assert.NotEmpty(t, diagnosis.Suggestion)
+	}
+}
+
+func TestDiagnoseNotebookMissingVolumeAndOOMKilled(t *testing.T) {
+	req := &controller.DiagnoseNotebookRequest{NotebookName: "notebook-diagnose"}
+
+	_, restoreDynamicClient := useFakeDynamicClient(createNotebookObject(req.NotebookName, "missing-pvc"))
+	defer restoreDynamicClient()
+
+	pod := createNotebookPod(req.NotebookName, v1.PodRunning)
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name:                 req.NotebookName,
+		State:                v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+		LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "OOMKilled"}},
+	}}
+
+	_, restoreClientset := useFakeClientset(pod)
+	defer restoreClientset()
+
+	mongo.On("AuthorizedUser", username, req.NotebookName).Return(true, nil).Once()
+
+	res, err := notebookService.DiagnoseNotebook(ctxWithValue, req)
+
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []controller.DiagnosisCategory{
+		controller.DiagnosisCategory_VOLUME_NOT_BOUND,
+		controller.DiagnosisCategory_CRASH_LOOP_BACK_OFF,
+		controller.DiagnosisCategory_OOM_KILLED,
+	}, diagnosisCategories(res))
+	assert.Empty(t, res.Events)
+}
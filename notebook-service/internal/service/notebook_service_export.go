@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"notebook-service/api/controller"
+	"notebook-service/internal/auth"
+	"notebook-service/internal/model"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// ExportNotebook returns a portable manifest describing an existing notebook
+func (s *NotebookService) ExportNotebook(ctx context.Context, req *controller.ExportNotebookRequest) (*controller.ExportNotebookResponse, error) {
+	// Only the owner of the notebook may export it
+	username := ctx.Value(auth.CtxKey).(string)
+	isAuthorized, err := s.mongoRepo.AuthorizedUser(ctx, username, req.NotebookName)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if !isAuthorized {
+		return nil, status.Error(codes.PermissionDenied, "user is unauthorized to perform this operation")
+	}
+
+	dynamicClient := s.kube.Dynamic
+
+	clientset := s.kube.Clientset
+
+	namespace := GetConfiguration().Namespace
+
+	notebookObject, err := dynamicClient.Resource(notebookGVR).Namespace(namespace).Get(ctx, req.NotebookName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, status.Errorf(codes.NotFound, "notebook %s not found", req.NotebookName)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed getting notebook: %v", err)
+	}
+
+	var notebook model.Notebook
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(notebookObject.Object, &notebook)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed decoding notebook: %v", err)
+	}
+
+	manifest := &model.NotebookManifest{
+		ApiVersion: model.NOTEBOOK_MANIFEST_API_VERSION,
+		Kind:       model.NOTEBOOK_MANIFEST_KIND,
+		Metadata:   model.NotebookManifestMetadata{Name: req.NotebookName},
+		Spec: model.NotebookManifestSpec{
+			Type:              getNotebookType(notebook.Metadata.Annotations).String(),
+			SchedulingProfile: notebook.Metadata.Annotations[SCHEDULING_PROFILE_ANNOTATION],
+		},
+	}
+
+	for _, container := range notebook.Spec.Template.Spec.Containers {
+		if container.Name != req.NotebookName {
+			continue
+		}
+
+		manifest.Spec.Resources = model.NotebookManifestResources{
+			MinCpu:    quantityString(container.Resources.Requests, v1.ResourceCPU),
+			MaxCpu:    quantityString(container.Resources.Limits, v1.ResourceCPU),
+			MinMemory: quantityString(container.Resources.Requests, v1.ResourceMemory),
+			MaxMemory: quantityString(container.Resources.Limits, v1.ResourceMemory),
+		}
+
+		for _, envFrom := range container.EnvFrom {
+			switch {
+			case envFrom.ConfigMapRef != nil:
+				manifest.Spec.EnvFrom = append(manifest.Spec.EnvFrom, model.NotebookManifestEnvFrom{
+					Kind: controller.EnvSourceKind_CONFIG_MAP.String(),
+					Name: envFrom.ConfigMapRef.Name,
+				})
+			case envFrom.SecretRef != nil:
+				manifest.Spec.EnvFrom = append(manifest.Spec.EnvFrom, model.NotebookManifestEnvFrom{
+					Kind: controller.EnvSourceKind_SECRET.String(),
+					Name: envFrom.SecretRef.Name,
+				})
+			}
+		}
+	}
+
+	for _, volume := range notebook.Spec.Template.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+
+		// A workspace created together with the notebook is exported by size, so the import creates a new one
+		claimName := volume.PersistentVolumeClaim.ClaimName
+		if claimName != req.NotebookName+WORKSPACE_SUFFIX {
+			manifest.Spec.Volume.ExistingClaim = claimName
+			break
+		}
+
+		pvc, err := clientset.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, claimName, metav1.GetOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, status.Errorf(codes.Internal, "failed getting persistent volume claim: %v", err)
+		}
+		if err == nil {
+			manifest.Spec.Volume.Size = quantityString(pvc.Spec.Resources.Requests, v1.ResourceStorage)
+			manifest.Spec.Volume.StorageClass = setStringValue(pvc.Spec.StorageClassName, "")
+			if len(pvc.Spec.AccessModes) > 0 {
+				if accessMode, exists := getVolumeAccessMode(pvc.Spec.AccessModes[0]); exists {
+					manifest.Spec.Volume.AccessMode = accessMode.String()
+				}
+			}
+		}
+		break
+	}
+
+	manifestYaml, err := yaml.Marshal(manifest)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed rendering manifest: %v", err)
+	}
+
+	return &controller.ExportNotebookResponse{Manifest: string(manifestYaml)}, nil
+}
+
+// Returns the notebook type matching the server type annotation of a notebook
+func getNotebookType(annotations map[string]string) controller.NotebookType {
+	switch annotations[SERVER_TYPE_ANNOTATION] {
+	case VSCODE_SERVER_TYPE:
+		return controller.NotebookType_VSCODE
+	case RSTUDIO_SERVER_TYPE:
+		return controller.NotebookType_RSTUDIO
+	default:
+		return controller.NotebookType_JUPITER
+	}
+}
+
+// Returns the quantity of a resource as a string, or an empty string if it is not set
+func quantityString(resources v1.ResourceList, name v1.ResourceName) string {
+	quantity, exists := resources[name]
+	if !exists {
+		return ""
+	}
+
+	return quantity.String()
+}
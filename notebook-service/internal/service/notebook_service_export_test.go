@@ -0,0 +1,117 @@
+package service_test
+
+import (
+	"notebook-service/api/controller"
+	"notebook-service/internal/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Creates a notebook custom resource with resources and environment sources set on its container
+func createExportableNotebookObject(notebookName string, claimName string) *unstructured.Unstructured {
+	notebook := createNotebookObject(notebookName, claimName)
+	notebook.SetAnnotations(map[string]string{service.SERVER_TYPE_ANNOTATION: service.VSCODE_SERVER_TYPE})
+
+	container := map[string]interface{}{
+		"name": notebookName,
+		"resources": map[string]interface{}{
+			"requests": map[string]interface{}{"cpu": "500m", "memory": "1Gi"},
+			"limits":   map[string]interface{}{"cpu": "2", "memory": "4Gi"},
+		},
+		"envFrom": []interface{}{
+			map[string]interface{}{"configMapRef": map[string]interface{}{"name": "spark-config"}},
+			map[string]interface{}{"secretRef": map[string]interface{}{"name": "s3-credentials"}},
+		},
+	}
+	unstructured.SetNestedSlice(notebook.Object, []interface{}{container}, "spec", "template", "spec", "containers")
+
+	return notebook
+}
+
+func TestExportNotebookUnauthorized(t *testing.T) {
+	req := &controller.ExportNotebookRequest{NotebookName: "notebook-export"}
+
+	mongo.On("AuthorizedUser", username, req.NotebookName).Return(false, nil).Once()
+
+	res, err := notebookService.ExportNotebook(ctxWithValue, req)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.PermissionDenied, "user is unauthorized to perform this operation"))
+}
+
+func TestExportNotebookNotFound(t *testing.T) {
+	req := &controller.ExportNotebookRequest{NotebookName: "notebook-export"}
+
+	_, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
+
+	_, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	mongo.On("AuthorizedUser", username, req.NotebookName).Return(true, nil).Once()
+
+	res, err := notebookService.ExportNotebook(ctxWithValue, req)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.NotFound, "notebook notebook-export not found"))
+}
+
+func TestExportNotebookSuccess(t *testing.T) {
+	req := &controller.ExportNotebookRequest{NotebookName: "notebook-export"}
+	claimName := req.NotebookName + service.WORKSPACE_SUFFIX
+
+	_, restoreDynamicClient := useFakeDynamicClient(createExportableNotebookObject(req.NotebookName, claimName))
+	defer restoreDynamicClient()
+
+	workspace := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: claimName, Namespace: service.GetConfiguration().Namespace},
+		Spec: v1.PersistentVolumeClaimSpec{
+			Resources: v1.VolumeResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("10Gi")},
+			},
+		},
+	}
+	_, restoreClientset := useFakeClientset(workspace)
+	defer restoreClientset()
+
+	mongo.On("AuthorizedUser", username, req.NotebookName).Return(true, nil).Once()
+
+	res, err := notebookService.ExportNotebook(ctxWithValue, req)
+
+	assert.Nil(t, err)
+	assert.Contains(t, res.Manifest, "kind: NotebookManifest")
+	assert.Contains(t, res.Manifest, "name: notebook-export")
+	assert.Contains(t, res.Manifest, "type: VSCODE")
+	assert.Contains(t, res.Manifest, "minCpu: 500m")
+	assert.Contains(t, res.Manifest, "maxMemory: 4Gi")
+	assert.Contains(t, res.Manifest, "size: 10Gi")
+	assert.Contains(t, res.Manifest, "kind: CONFIG_MAP")
+	assert.Contains(t, res.Manifest, "name: s3-credentials")
+	assert.NotContains(t, res.Manifest, "existingClaim")
+}
+
+func TestExportNotebookExistingClaim(t *testing.T) {
+	req := &controller.ExportNotebookRequest{NotebookName: "notebook-export"}
+
+	_, restoreDynamicClient := useFakeDynamicClient(createNotebookObject(req.NotebookName, "shared-data"))
+	defer restoreDynamicClient()
+
+	_, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	mongo.On("AuthorizedUser", username, req.NotebookName).Return(true, nil).Once()
+
+	res, err := notebookService.ExportNotebook(ctxWithValue, req)
+
+	assert.Nil(t, err)
+	assert.Contains(t, res.Manifest, "existingClaim: shared-data")
+	assert.Contains(t, res.Manifest, "type: JUPITER")
+	assert.NotContains(t, res.Manifest, "size:")
+}
@@ -5,6 +5,8 @@ import (
 	"log"
 	"notebook-service/api/controller"
 	"notebook-service/internal/auth"
+	"notebook-service/internal/metrics"
+	"notebook-service/internal/model"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -14,37 +16,59 @@ func (s *NotebookService) ListActiveNotebooks(ctx context.Context, request *cont
 	username := ctx.Value(auth.CtxKey).(string)
 
 	// Check if cache exists
-	cacheExists, err := s.redisRepo.CheckCacheExists(username)
+	cacheExists, err := s.redisRepo.CheckCacheExists(ctx, username)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
+	metrics.RecordCacheLookup(NOTEBOOK_CACHE, cacheExists)
 
 	var notebooks []string
+	var summaries []*controller.NotebookSummary
 
 	if cacheExists {
-		// Get notebook from cache if cache exists
-		notebooks, err = s.redisRepo.GetNotebooks(username)
+		// Get notebook from cache if cache exists, only the listings of healthy notebooks are cached
+		notebooks, err = s.redisRepo.GetNotebooks(ctx, username)
 		if err != nil {
 			return nil, status.Error(codes.Internal, err.Error())
 		}
+		for _, notebook := range notebooks {
+			summaries = append(summaries, &controller.NotebookSummary{Name: notebook})
+		}
 	} else {
 		// If cache does not exists
 		// Get the notebook list from mongodb
-		notebooks, err = s.mongoRepo.ListNotebooks(username)
+		entities, err := s.mongoRepo.ListNotebooks(ctx, username)
 		if err != nil {
 			return nil, status.Error(codes.Internal, err.Error())
 		}
 
-		go func() {
-			// Cache the notebook list
-			err = s.redisRepo.StoreNotebooks(username, notebooks)
-			if err != nil {
-				log.Println(err.Error())
+		healthy := true
+		for _, entity := range entities {
+			summary := &controller.NotebookSummary{Name: entity.NotebookName}
+			if entity.State == model.NOTEBOOK_VOLUME_MISSING {
+				summary.Health = controller.NotebookHealth_VOLUME_MISSING
+				healthy = false
 			}
-		}()
+			notebooks = append(notebooks, entity.NotebookName)
+			summaries = append(summaries, summary)
+		}
+
+		// The cache only holds the names, so a listing with a flagged notebook is read from mongodb each time
+		if healthy {
+			// The list is cached once the response is sent, so on a context outliving the request
+			cacheCtx := context.WithoutCancel(ctx)
+			go func() {
+				// Cache the notebook list
+				err := s.redisRepo.StoreNotebooks(cacheCtx, username, notebooks)
+				if err != nil {
+					log.Println(err.Error())
+				}
+			}()
+		}
 	}
 
 	return &controller.ListActiveNotebooksResponse{
 		NotebookNames: notebooks,
+		Notebooks:     summaries,
 	}, nil
 }
@@ -3,6 +3,7 @@ package service_test
 import (
 	"errors"
 	"notebook-service/api/controller"
+	"notebook-service/internal/model"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,6 +12,8 @@ import (
 )
 
 var notebooks = []string{"notebook1", "notebook2"}
+var notebookEntities = []model.NotebookEntity{{NotebookName: "notebook1"}, {NotebookName: "notebook2"}}
+var notebookSummaries = []*controller.NotebookSummary{{Name: "notebook1"}, {Name: "notebook2"}}
 
 func TestGetNotebooksFailedCheckingCache(t *testing.T) {
 	req := &controller.ListActiveNotebooksRequest{}
@@ -50,7 +53,7 @@ func TestGetNotebooksSuccessFromCache(t *testing.T) {
 	// Mock getting cache data
 	redis.On("GetNotebooks", username).Return(notebooks, nil).Once()
 
-	expectedResponse := &controller.ListActiveNotebooksResponse{NotebookNames: notebooks}
+	expectedResponse := &controller.ListActiveNotebooksResponse{NotebookNames: notebooks, Notebooks: notebookSummaries}
 
 	res, err := notebookService.ListActiveNotebooks(ctxWithValue, req)
 
@@ -81,15 +84,42 @@ func TestGetNotebooksSuccessGettingNotebooksFromMongoDB(t *testing.T) {
 	redis.On("CheckCacheExists", username).Return(false, nil).Once()
 
 	// Mock getting notebook list from mongodb
-	mongo.On("ListNotebooks", username).Return(notebooks, nil).Once()
+	mongo.On("ListNotebooks", username).Return(notebookEntities, nil).Once()
 
 	// Mock caching the notebooks
 	redis.On("StoreNotebooks", username, notebooks).Return(nil).Once()
 
-	expectedResponse := &controller.ListActiveNotebooksResponse{NotebookNames: notebooks}
+	expectedResponse := &controller.ListActiveNotebooksResponse{NotebookNames: notebooks, Notebooks: notebookSummaries}
 
 	res, err := notebookService.ListActiveNotebooks(ctxWithValue, req)
 
 	assert.Nil(t, err)
 	assert.Equal(t, expectedResponse, res)
 }
+
+func TestGetNotebooksWithMissingVolumeAreNotCached(t *testing.T) {
+	req := &controller.ListActiveNotebooksRequest{}
+
+	// Mock checking cache
+	redis.On("CheckCacheExists", username).Return(false, nil).Once()
+
+	// Mock getting notebook list from mongodb, with a notebook whose volume was deleted
+	mongo.On("ListNotebooks", username).Return([]model.NotebookEntity{
+		{NotebookName: "notebook1"},
+		{NotebookName: "notebook2", State: model.NOTEBOOK_VOLUME_MISSING},
+	}, nil).Once()
+
+	expectedResponse := &controller.ListActiveNotebooksResponse{
+		NotebookNames: notebooks,
+		Notebooks: []*controller.NotebookSummary{
+			{Name: "notebook1", Health: controller.NotebookHealth_HEALTHY},
+			{Name: "notebook2", Health: controller.NotebookHealth_VOLUME_MISSING},
+		},
+	}
+
+	res, err := notebookService.ListActiveNotebooks(ctxWithValue, req)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResponse, res)
+	redis.AssertNotCalled(t, "StoreNotebooks", username, notebooks)
+}
@@ -0,0 +1,179 @@
+package service_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"notebook-service/api/controller"
+	"notebook-service/internal/service"
+	"notebook-service/mocks/mock_mongo"
+	"notebook-service/redis_repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Hashes a request the same way the service does
+func payloadHash(t *testing.T, req proto.Message) string {
+	payload, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	assert.Nil(t, err)
+
+	hash := sha256.Sum256(payload)
+	return hex.EncodeToString(hash[:])
+}
+
+func TestCreateNotebookIdempotentStoresOutcome(t *testing.T) {
+	req := &controller.CreateNotebookRequest{
+		Name:      "notebook-idempotent",
+		MaxCpu:    stringPtr("a"),
+		RequestId: stringPtr("retry-1"),
+	}
+	key := "CreateNotebook:" + username + ":retry-1"
+	hash := payloadHash(t, req)
+
+	idempotency.On("Reserve", key, hash, service.IDEMPOTENCY_LEASE).Return(nil, true, nil).Once()
+	idempotency.On("Complete", key, &redis_repository.IdempotencyRecord{
+		PayloadHash: hash,
+		Completed:   true,
+		Code:        uint32(codes.InvalidArgument),
+		Message:     "invalid max cpu",
+	}, service.DEFAULT_IDEMPOTENCY_TTL).Return(nil).Once()
+
+	res, err := notebookService.CreateNotebook(ctxWithValue, req)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.InvalidArgument, "invalid max cpu"))
+	idempotency.AssertExpectations(t)
+}
+
+func TestCreateNotebookIdempotentReplaysSuccess(t *testing.T) {
+	req := &controller.CreateNotebookRequest{
+		Name:      "notebook-idempotent",
+		RequestId: stringPtr("retry-2"),
+	}
+	key := "CreateNotebook:" + username + ":retry-2"
+	hash := payloadHash(t, req)
+
+	// No Kubernetes client is mocked, so executing the request again would fail
+	idempotency.On("Reserve", key, hash, service.IDEMPOTENCY_LEASE).Return(&redis_repository.IdempotencyRecord{
+		PayloadHash: hash,
+		Completed:   true,
+		Code:        uint32(codes.OK),
+	}, false, nil).Once()
+
+	res, err := notebookService.CreateNotebook(ctxWithValue, req)
+
+	assert.Nil(t, err)
+	assert.NotNil(t, res)
+}
+
+func TestCreateNotebookIdempotentReplaysFailure(t *testing.T) {
+	req := &controller.CreateNotebookRequest{
+		Name:      "notebook-idempotent",
+		RequestId: stringPtr("retry-3"),
+	}
+	hash := payloadHash(t, req)
+
+	idempotency.On("Reserve", "CreateNotebook:"+username+":retry-3", hash, service.IDEMPOTENCY_LEASE).Return(&redis_repository.IdempotencyRecord{
+		PayloadHash: hash,
+		Completed:   true,
+		Code:        uint32(codes.PermissionDenied),
+		Message:     "scheduling profile high-memory is not permitted for role DS",
+	}, false, nil).Once()
+
+	res, err := notebookService.CreateNotebook(ctxWithValue, req)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.PermissionDenied, "scheduling profile high-memory is not permitted for role DS"))
+}
+
+func TestCreateNotebookIdempotentDifferentPayload(t *testing.T) {
+	req := &controller.CreateNotebookRequest{
+		Name:      "notebook-idempotent",
+		RequestId: stringPtr("retry-4"),
+	}
+
+	idempotency.On("Reserve", "CreateNotebook:"+username+":retry-4", mock.Anything, service.IDEMPOTENCY_LEASE).Return(&redis_repository.IdempotencyRecord{
+		PayloadHash: "other",
+		Completed:   true,
+	}, false, nil).Once()
+
+	res, err := notebookService.CreateNotebook(ctxWithValue, req)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.InvalidArgument, "idempotency key retry-4 was already used for a different request"))
+}
+
+func TestCreateNotebookIdempotentInProgress(t *testing.T) {
+	req := &controller.CreateNotebookRequest{Name: "notebook-idempotent"}
+	hash := payloadHash(t, req)
+
+	// The key is read from the metadata when the request has no request id
+	ctx := metadata.NewIncomingContext(ctxWithValue, metadata.Pairs(service.IDEMPOTENCY_KEY_HEADER, "retry-5"))
+
+	idempotency.On("Reserve", "CreateNotebook:"+username+":retry-5", hash, service.IDEMPOTENCY_LEASE).Return(&redis_repository.IdempotencyRecord{
+		PayloadHash: hash,
+	}, false, nil).Once()
+
+	res, err := notebookService.CreateNotebook(ctx, req)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.Aborted, "request with idempotency key retry-5 is still in progress"))
+}
+
+func TestCreateNotebookIdempotentReleasesRetryableFailure(t *testing.T) {
+	req := &controller.CreateNotebookRequest{
+		Name:              "notebook-idempotent",
+		RequestId:         stringPtr("retry-6"),
+		SchedulingProfile: stringPtr("gpu"),
+	}
+	key := "CreateNotebook:" + username + ":retry-6"
+
+	profiles := new(mock_mongo.MockSchedulingProfiles)
+	profiles.On("GetSchedulingProfile", "gpu").Return(nil, errors.New("connection refused")).Once()
+	notebookService := createNotebookServiceWithProfiles(profiles)
+
+	idempotency.On("Reserve", key, mock.Anything, service.IDEMPOTENCY_LEASE).Return(nil, true, nil).Once()
+	idempotency.On("Release", key).Return(nil).Once()
+
+	res, err := notebookService.CreateNotebook(ctxWithValue, req)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.Internal, "connection refused"))
+	idempotency.AssertExpectations(t)
+}
+
+func TestCreateNotebookIdempotentStoresSuccess(t *testing.T) {
+	req := &controller.CreateNotebookRequest{
+		Name:      "notebook-test",
+		RequestId: stringPtr("retry-7"),
+	}
+	key := "CreateNotebook:" + username + ":retry-7"
+
+	_, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
+
+	restoreCreatePvcResource := mockCreatePvcResource(pvc, nil)
+	defer restoreCreatePvcResource()
+
+	restoreCallOpen := mockCallOpen()
+	defer restoreCallOpen()
+
+	mongo.On("CreateNotebook", mock.Anything).Return(nil).Once()
+	redis.On("CheckCacheExists", username).Return(false, nil).Once()
+
+	idempotency.On("Reserve", key, mock.Anything, service.IDEMPOTENCY_LEASE).Return(nil, true, nil).Once()
+	idempotency.On("Complete", key, mock.MatchedBy(func(record *redis_repository.IdempotencyRecord) bool {
+		return record.Completed && record.Code == uint32(codes.OK)
+	}), service.DEFAULT_IDEMPOTENCY_TTL).Return(nil).Once()
+
+	_, err := notebookService.CreateNotebook(ctxWithValue, req)
+
+	assert.Nil(t, err)
+	idempotency.AssertExpectations(t)
+}
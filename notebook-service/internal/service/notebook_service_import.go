@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"notebook-service/api/controller"
+	"notebook-service/internal/model"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+// Maximum number of suffixes tried when renaming an imported notebook
+const MAX_IMPORT_RENAME_ATTEMPTS = 20
+
+// ImportNotebook validates an exported manifest and creates the notebook it describes
+func (s *NotebookService) ImportNotebook(ctx context.Context, req *controller.ImportNotebookRequest) (*controller.ImportNotebookResponse, error) {
+	var manifest model.NotebookManifest
+	err := yaml.UnmarshalStrict([]byte(req.Manifest), &manifest)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid manifest: %v", err)
+	}
+
+	createRequest, err := createRequestFromManifest(&manifest)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		createRequest.Name = *req.Name
+	}
+
+	createsVolume := createRequest.Pvc == nil
+	if errs := notebookNameErrors(createRequest.Name, createsVolume); len(errs) > 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid notebook name: %s", strings.Join(errs, ", "))
+	}
+
+	// Validate the resources before looking at the cluster
+	_, err = parseNotebookResources(createRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient := s.kube.Dynamic
+
+	namespace := GetConfiguration().Namespace
+
+	exists, err := notebookExists(ctx, dynamicClient, namespace, createRequest.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		switch req.OnConflict {
+		case controller.ImportConflictPolicy_SKIP:
+			return &controller.ImportNotebookResponse{NotebookName: createRequest.Name, Skipped: true}, nil
+		case controller.ImportConflictPolicy_RENAME:
+			createRequest.Name, err = findFreeNotebookName(ctx, dynamicClient, namespace, createRequest.Name, createsVolume)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, status.Errorf(codes.AlreadyExists, "notebook %s already exists", createRequest.Name)
+		}
+	}
+
+	operation, err := s.createNotebook(ctx, createRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &controller.ImportNotebookResponse{NotebookName: createRequest.Name, OperationId: &operation.Id}, nil
+}
+
+// Converts a manifest to the equivalent create notebook request
+func createRequestFromManifest(manifest *model.NotebookManifest) (*controller.CreateNotebookRequest, error) {
+	if manifest.ApiVersion != model.NOTEBOOK_MANIFEST_API_VERSION {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported manifest api version: %s", manifest.ApiVersion)
+	}
+	if manifest.Kind != model.NOTEBOOK_MANIFEST_KIND {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported manifest kind: %s", manifest.Kind)
+	}
+
+	notebookType, exists := controller.NotebookType_value[manifest.Spec.Type]
+	if !exists {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid notebook type: %s", manifest.Spec.Type)
+	}
+
+	volume := manifest.Spec.Volume
+	if volume.Size != "" && volume.ExistingClaim != "" {
+		return nil, status.Error(codes.InvalidArgument, "volume size and existing claim are mutually exclusive")
+	}
+
+	if volume.ExistingClaim != "" && (volume.StorageClass != "" || volume.AccessMode != "") {
+		return nil, status.Error(codes.InvalidArgument, "storage class and access mode only apply to new volumes")
+	}
+
+	request := &controller.CreateNotebookRequest{
+		Name:              manifest.Metadata.Name,
+		Type:              controller.NotebookType(notebookType).Enum(),
+		MinCpu:            optionalString(manifest.Spec.Resources.MinCpu),
+		MaxCpu:            optionalString(manifest.Spec.Resources.MaxCpu),
+		MinMemory:         optionalString(manifest.Spec.Resources.MinMemory),
+		MaxMemory:         optionalString(manifest.Spec.Resources.MaxMemory),
+		Volume:            optionalString(volume.Size),
+		Pvc:               optionalString(volume.ExistingClaim),
+		StorageClass:      optionalString(volume.StorageClass),
+		SchedulingProfile: optionalString(manifest.Spec.SchedulingProfile),
+	}
+
+	if volume.AccessMode != "" {
+		accessMode, exists := controller.VolumeAccessMode_value[volume.AccessMode]
+		if !exists {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid access mode: %s", volume.AccessMode)
+		}
+		request.AccessMode = controller.VolumeAccessMode(accessMode).Enum()
+	}
+
+	for _, envFrom := range manifest.Spec.EnvFrom {
+		kind, exists := controller.EnvSourceKind_value[envFrom.Kind]
+		if !exists {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid env source kind: %s", envFrom.Kind)
+		}
+		if envFrom.Name == "" {
+			return nil, status.Error(codes.InvalidArgument, "env source name cannot be empty")
+		}
+
+		request.EnvFrom = append(request.EnvFrom, &controller.EnvFromSource{
+			Kind: controller.EnvSourceKind(kind),
+			Name: envFrom.Name,
+		})
+	}
+
+	return request, nil
+}
+
+// Checks if a notebook custom resource with the given name exists
+func notebookExists(ctx context.Context, dynamicClient dynamic.Interface, namespace string, notebookName string) (bool, error) {
+	_, err := dynamicClient.Resource(notebookGVR).Namespace(namespace).Get(ctx, notebookName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, status.Errorf(codes.Internal, "failed getting notebook: %v", err)
+	}
+
+	return true, nil
+}
+
+// Returns the reasons the name can't be given to a notebook. The workspace volume created with the notebook is
+// named after it and mounted under its name, which must be a DNS label as well.
+func notebookNameErrors(notebookName string, createsVolume bool) []string {
+	if errs := validation.IsDNS1123Label(notebookName); len(errs) > 0 {
+		return errs
+	}
+	if !createsVolume {
+		return nil
+	}
+
+	var errs []string
+	for _, err := range validation.IsDNS1123Label(notebookName + WORKSPACE_SUFFIX) {
+		errs = append(errs, fmt.Sprintf("workspace volume %s%s: %s", notebookName, WORKSPACE_SUFFIX, err))
+	}
+	return errs
+}
+
+// Finds the first free name made of the notebook name and a numeric suffix. The notebook name is shortened
+// when needed, so the name with its suffix stays valid.
+func findFreeNotebookName(ctx context.Context, dynamicClient dynamic.Interface, namespace string, notebookName string, createsVolume bool) (string, error) {
+	maxLength := validation.DNS1123LabelMaxLength
+	if createsVolume {
+		maxLength -= len(WORKSPACE_SUFFIX)
+	}
+
+	for i := 1; i <= MAX_IMPORT_RENAME_ATTEMPTS; i++ {
+		suffix := fmt.Sprintf("-%d", i)
+		base := strings.TrimRight(notebookName[:min(len(notebookName), maxLength-len(suffix))], "-")
+		candidate := base + suffix
+		if errs := notebookNameErrors(candidate, createsVolume); len(errs) > 0 {
+			return "", status.Errorf(codes.InvalidArgument, "invalid notebook name %s: %s", candidate, strings.Join(errs, ", "))
+		}
+
+		exists, err := notebookExists(ctx, dynamicClient, namespace, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+	}
+
+	return "", status.Errorf(codes.AlreadyExists, "no free name found for notebook %s", notebookName)
+}
+
+// Returns nil for empty strings so the defaults of the create request apply
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,235 @@
+package service_test
+
+import (
+	"notebook-service/api/controller"
+	"notebook-service/internal/model"
+	"notebook-service/internal/service"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const importManifest = `apiVersion: suedataplatform/v1
+kind: NotebookManifest
+metadata:
+  name: notebook-import
+spec:
+  type: RSTUDIO
+  resources:
+    minCpu: 500m
+    maxCpu: "2"
+  volume:
+    size: 5G
+  envFrom:
+  - kind: SECRET
+    name: s3-credentials
+`
+
+func TestImportNotebookInvalidManifests(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		err      error
+	}{
+		{
+			name:     "wrong kind",
+			manifest: "apiVersion: suedataplatform/v1\nkind: Notebook\nmetadata:\n  name: a\nspec:\n  type: JUPITER\n",
+			err:      status.Error(codes.InvalidArgument, "unsupported manifest kind: Notebook"),
+		},
+		{
+			name:     "invalid type",
+			manifest: "apiVersion: suedataplatform/v1\nkind: NotebookManifest\nmetadata:\n  name: a\nspec:\n  type: EMACS\n",
+			err:      status.Error(codes.InvalidArgument, "invalid notebook type: EMACS"),
+		},
+		{
+			name:     "invalid name",
+			manifest: "apiVersion: suedataplatform/v1\nkind: NotebookManifest\nmetadata:\n  name: Not_Valid\nspec:\n  type: JUPITER\n",
+			err:      status.Error(codes.InvalidArgument, "invalid notebook name: a lowercase RFC 1123 label must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character (e.g. 'my-name',  or '123-abc', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?')"),
+		},
+		{
+			name:     "invalid cpu",
+			manifest: "apiVersion: suedataplatform/v1\nkind: NotebookManifest\nmetadata:\n  name: a\nspec:\n  type: JUPITER\n  resources:\n    maxCpu: a\n",
+			err:      status.Error(codes.InvalidArgument, "invalid max cpu"),
+		},
+		{
+			name:     "size and existing claim",
+			manifest: "apiVersion: suedataplatform/v1\nkind: NotebookManifest\nmetadata:\n  name: a\nspec:\n  type: JUPITER\n  volume:\n    size: 5G\n    existingClaim: shared\n",
+			err:      status.Error(codes.InvalidArgument, "volume size and existing claim are mutually exclusive"),
+		},
+		{
+			name:     "invalid env source kind",
+			manifest: "apiVersion: suedataplatform/v1\nkind: NotebookManifest\nmetadata:\n  name: a\nspec:\n  type: JUPITER\n  envFrom:\n  - kind: VAULT\n    name: s\n",
+			err:      status.Error(codes.InvalidArgument, "invalid env source kind: VAULT"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := notebookService.ImportNotebook(ctxWithValue, &controller.ImportNotebookRequest{Manifest: tt.manifest})
+			assert.Nil(t, res)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestImportNotebookUnknownField(t *testing.T) {
+	req := &controller.ImportNotebookRequest{Manifest: importManifest + "  gpu: 1\n"}
+
+	res, err := notebookService.ImportNotebook(ctxWithValue, req)
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestImportNotebookConflictFail(t *testing.T) {
+	req := &controller.ImportNotebookRequest{Manifest: importManifest}
+
+	_, restoreDynamicClient := useFakeDynamicClient(createNotebookObject("notebook-import", "shared-data"))
+	defer restoreDynamicClient()
+
+	res, err := notebookService.ImportNotebook(ctxWithValue, req)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.AlreadyExists, "notebook notebook-import already exists"))
+}
+
+func TestImportNotebookConflictSkip(t *testing.T) {
+	req := &controller.ImportNotebookRequest{
+		Manifest:   importManifest,
+		OnConflict: controller.ImportConflictPolicy_SKIP,
+	}
+
+	dynamicClient, restoreDynamicClient := useFakeDynamicClient(createNotebookObject("notebook-import", "shared-data"))
+	defer restoreDynamicClient()
+
+	res, err := notebookService.ImportNotebook(ctxWithValue, req)
+
+	assert.Nil(t, err)
+	assert.True(t, res.Skipped)
+	assert.Equal(t, "notebook-import", res.NotebookName)
+	assert.Nil(t, res.OperationId)
+	for _, action := range dynamicClient.Actions() {
+		assert.NotEqual(t, "create", action.GetVerb())
+	}
+}
+
+func TestImportNotebookConflictRename(t *testing.T) {
+	req := &controller.ImportNotebookRequest{
+		Manifest:   importManifest,
+		OnConflict: controller.ImportConflictPolicy_RENAME,
+	}
+
+	_, restoreDynamicClient := useFakeDynamicClient(
+		createNotebookObject("notebook-import", "shared-data"),
+		createNotebookObject("notebook-import-1", "shared-data"),
+	)
+	defer restoreDynamicClient()
+
+	_, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	restoreCreatePvcResource := mockCreatePvcResource(pvc, nil)
+	defer restoreCreatePvcResource()
+
+	restoreCallOpen := mockCallOpen()
+	defer restoreCallOpen()
+
+	notebook := &model.NotebookEntity{
+		Username:     username,
+		NotebookName: "notebook-import-2",
+	}
+	mongo.On("CreateNotebook", notebook).Return(nil).Once()
+	redis.On("CheckCacheExists", username).Return(false, nil).Once()
+
+	res, err := notebookService.ImportNotebook(ctxWithValue, req)
+
+	assert.Nil(t, err)
+	assert.False(t, res.Skipped)
+	assert.Equal(t, "notebook-import-2", res.NotebookName)
+	assert.NotNil(t, res.OperationId)
+}
+
+func TestImportNotebookNameOverride(t *testing.T) {
+	req := &controller.ImportNotebookRequest{
+		Manifest: importManifest,
+		Name:     stringPtr("notebook-copy"),
+	}
+
+	_, restoreDynamicClient := useFakeDynamicClient(createNotebookObject("notebook-import", "shared-data"))
+	defer restoreDynamicClient()
+
+	_, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	restoreCreatePvcResource := mockCreatePvcResource(pvc, nil)
+	defer restoreCreatePvcResource()
+
+	restoreCallOpen := mockCallOpen()
+	defer restoreCallOpen()
+
+	notebook := &model.NotebookEntity{
+		Username:     username,
+		NotebookName: "notebook-copy",
+	}
+	mongo.On("CreateNotebook", notebook).Return(nil).Once()
+	redis.On("CheckCacheExists", username).Return(false, nil).Once()
+
+	res, err := notebookService.ImportNotebook(ctxWithValue, req)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "notebook-copy", res.NotebookName)
+}
+
+func TestImportNotebookNameTooLongForItsWorkspaceVolume(t *testing.T) {
+	// Valid for the notebook, but not once suffixed for its workspace volume
+	name := "notebook-import-" + strings.Repeat("a", 38)
+	req := &controller.ImportNotebookRequest{Manifest: importManifest, Name: &name}
+
+	res, err := notebookService.ImportNotebook(ctxWithValue, req)
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.ErrorContains(t, err, "invalid notebook name: workspace volume "+name+"-workspace: must be no more than 63 characters")
+}
+
+func TestImportNotebookConflictRenameShortensLongNames(t *testing.T) {
+	// As long as the workspace volume allows, the suffixed names are shortened
+	name := "notebook-import-" + strings.Repeat("a", 37)
+	base := "notebook-import-" + strings.Repeat("a", 35)
+	req := &controller.ImportNotebookRequest{
+		Manifest:   importManifest,
+		Name:       &name,
+		OnConflict: controller.ImportConflictPolicy_RENAME,
+	}
+
+	_, restoreDynamicClient := useFakeDynamicClient(
+		createNotebookObject(name, "shared-data"),
+		createNotebookObject(base+"-1", "shared-data"),
+	)
+	defer restoreDynamicClient()
+
+	_, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	restoreCreatePvcResource := mockCreatePvcResource(pvc, nil)
+	defer restoreCreatePvcResource()
+
+	restoreCallOpen := mockCallOpen()
+	defer restoreCallOpen()
+
+	notebook := &model.NotebookEntity{
+		Username:     username,
+		NotebookName: base + "-2",
+	}
+	mongo.On("CreateNotebook", notebook).Return(nil).Once()
+	redis.On("CheckCacheExists", username).Return(false, nil).Once()
+
+	res, err := notebookService.ImportNotebook(ctxWithValue, req)
+
+	assert.Nil(t, err)
+	assert.Equal(t, base+"-2", res.NotebookName)
+	assert.Len(t, res.NotebookName+service.WORKSPACE_SUFFIX, 63)
+}
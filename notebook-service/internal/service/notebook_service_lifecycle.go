@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"notebook-service/api/events"
+	"notebook-service/internal/auth"
+	"notebook-service/internal/model"
+	"notebook-service/internal/rabbitmq"
+	"time"
+)
+
+// Annotation the Kubeflow notebook controller reads to scale a notebook down to zero pods
+const STOPPED_ANNOTATION = "kubeflow-resource-stopped"
+
+// Returns the patch setting the stop annotation of a notebook
+func stopPatch() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{STOPPED_ANNOTATION: time.Now().UTC().Format(time.RFC3339)},
+		},
+	})
+}
+
+// Creates the usage event of a transition with the resources requested by the notebook container
+func newUsageEvent(ctx context.Context, notebook *model.Notebook, transition string) *model.UsageEvent {
+	event := &model.UsageEvent{
+		NotebookName: notebook.Metadata.Name,
+		Username:     ctx.Value(auth.CtxKey).(string),
+		Team:         auth.GetTeam(ctx),
+		Transition:   transition,
+	}
+
+	for _, container := range notebook.Spec.Template.Spec.Containers {
+		if container.Name == notebook.Metadata.Name {
+			event.Cpu, event.MemoryGB = requestedUsage(container.Resources.Requests)
+		}
+	}
+
+	return event
+}
+
+// Records the failure of an operation on a notebook for its owner
+func (s *NotebookService) recordOperationFailure(ctx context.Context, operation *model.Operation) {
+	data := &events.NotebookFailed{
+		NotebookName: operation.Target,
+		Owner:        operation.Username,
+		Reason:       operation.Error.Message,
+		OperationId:  operation.ID,
+	}
+
+	key := rabbitmq.GenerateRoutingKey(rabbitmq.NOTEBOOK, rabbitmq.FAIL)
+	if err := s.outbox.Record(context.WithoutCancel(ctx), key, operation.Username, data); err != nil {
+		log.Printf("Failed to record failure of operation %s: %v", operation.ID, err)
+	}
+}
+
+// Step recording the usage event once the transition was applied
+func (s *NotebookService) recordUsageStep(event *model.UsageEvent) operationStep {
+	return operationStep{name: "record-usage", run: func(ctx context.Context) error {
+		event.Timestamp = time.Now()
+		s.recordUsage(ctx, event)
+		return nil
+	}}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"notebook-service/api/controller"
+	"notebook-service/internal/auth"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GetNotebookLogs streams the container logs of the pod backing a notebook
+func (s *NotebookService) GetNotebookLogs(req *controller.GetNotebookLogsRequest, stream controller.NotebookService_GetNotebookLogsServer) error {
+	ctx := stream.Context()
+
+	if req.TailLines != nil && *req.TailLines < 0 {
+		return status.Error(codes.InvalidArgument, "invalid tail lines")
+	}
+
+	// Only the owner of the notebook may read its logs
+	username := ctx.Value(auth.CtxKey).(string)
+	isAuthorized, err := s.mongoRepo.AuthorizedUser(ctx, username, req.NotebookName)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if !isAuthorized {
+		return status.Error(codes.PermissionDenied, "user is unauthorized to perform this operation")
+	}
+
+	clientset := s.kube.Clientset
+
+	namespace := GetConfiguration().Namespace
+
+	pod, err := findNotebookPod(ctx, clientset, namespace, req.NotebookName)
+	if err != nil {
+		return err
+	}
+
+	// The notebook container is named after the notebook
+	container := setStringValue(req.Container, req.NotebookName)
+	if !podHasContainer(pod, container) {
+		return status.Errorf(codes.InvalidArgument, "container %s does not exist in pod %s", container, pod.Name)
+	}
+
+	options := &v1.PodLogOptions{
+		Container: container,
+		Follow:    setBoolValue(req.Follow),
+		TailLines: req.TailLines,
+	}
+	if req.SinceTime != nil {
+		sinceTime := metav1.NewTime(req.SinceTime.AsTime())
+		options.SinceTime = &sinceTime
+	}
+
+	logs, err := clientset.CoreV1().Pods(namespace).GetLogs(pod.Name, options).Stream(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed streaming logs: %v", err)
+	}
+	defer logs.Close()
+
+	// Send the logs line by line until the stream ends or the client goes away
+	reader := bufio.NewReader(logs)
+	for {
+		line, readErr := reader.ReadString('\n')
+		if len(line) > 0 {
+			err = stream.Send(&controller.NotebookLogChunk{
+				PodName:   pod.Name,
+				Container: container,
+				Content:   line,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if errors.Is(readErr, io.EOF) {
+			return nil
+		}
+		if readErr != nil {
+			if ctx.Err() != nil {
+				return status.FromContextError(ctx.Err()).Err()
+			}
+			return status.Errorf(codes.Internal, "failed reading logs: %v", readErr)
+		}
+	}
+}
+
+// Checks if a container with the given name is part of the pod
+func podHasContainer(pod *v1.Pod, name string) bool {
+	for _, container := range pod.Spec.Containers {
+		if container.Name == name {
+			return true
+		}
+	}
+	for _, container := range pod.Spec.InitContainers {
+		if container.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
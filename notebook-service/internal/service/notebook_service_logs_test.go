@@ -0,0 +1,124 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"notebook-service/api/controller"
+	"notebook-service/internal/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Fake server stream collecting the sent log chunks
+type logStreamMock struct {
+	grpc.ServerStream
+	ctx    context.Context
+	chunks []*controller.NotebookLogChunk
+}
+
+func (m *logStreamMock) Context() context.Context {
+	return m.ctx
+}
+
+func (m *logStreamMock) Send(chunk *controller.NotebookLogChunk) error {
+	m.chunks = append(m.chunks, chunk)
+	return nil
+}
+
+func createNotebookPod(notebookName string, phase v1.PodPhase) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      notebookName + "-0",
+			Namespace: service.GetConfiguration().Namespace,
+			Labels:    map[string]string{service.NOTEBOOK_NAME_LABEL: notebookName},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: notebookName}},
+		},
+		Status: v1.PodStatus{Phase: phase},
+	}
+}
+
+func TestGetNotebookLogsUnauthorized(t *testing.T) {
+	req := &controller.GetNotebookLogsRequest{NotebookName: "notebook-logs"}
+
+	mongo.On("AuthorizedUser", username, req.NotebookName).Return(false, nil).Once()
+
+	err := notebookService.GetNotebookLogs(req, &logStreamMock{ctx: ctxWithValue})
+
+	assert.ErrorIs(t, err, status.Error(codes.PermissionDenied, "user is unauthorized to perform this operation"))
+}
+
+func TestGetNotebookLogsFailedCheckingAuthorization(t *testing.T) {
+	req := &controller.GetNotebookLogsRequest{NotebookName: "notebook-logs"}
+
+	errMsg := "mongo error"
+	mongo.On("AuthorizedUser", username, req.NotebookName).Return(false, errors.New(errMsg)).Once()
+
+	err := notebookService.GetNotebookLogs(req, &logStreamMock{ctx: ctxWithValue})
+
+	assert.ErrorIs(t, err, status.Error(codes.Internal, errMsg))
+}
+
+func TestGetNotebookLogsInvalidTailLines(t *testing.T) {
+	tailLines := int64(-1)
+	req := &controller.GetNotebookLogsRequest{NotebookName: "notebook-logs", TailLines: &tailLines}
+
+	err := notebookService.GetNotebookLogs(req, &logStreamMock{ctx: ctxWithValue})
+
+	assert.ErrorIs(t, err, status.Error(codes.InvalidArgument, "invalid tail lines"))
+}
+
+func TestGetNotebookLogsPodNotFound(t *testing.T) {
+	req := &controller.GetNotebookLogsRequest{NotebookName: "notebook-logs"}
+
+	_, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	mongo.On("AuthorizedUser", username, req.NotebookName).Return(true, nil).Once()
+
+	err := notebookService.GetNotebookLogs(req, &logStreamMock{ctx: ctxWithValue})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGetNotebookLogsUnknownContainer(t *testing.T) {
+	container := "sidecar"
+	req := &controller.GetNotebookLogsRequest{NotebookName: "notebook-logs", Container: &container}
+
+	_, restoreClientset := useFakeClientset(createNotebookPod(req.NotebookName, v1.PodRunning))
+	defer restoreClientset()
+
+	mongo.On("AuthorizedUser", username, req.NotebookName).Return(true, nil).Once()
+
+	err := notebookService.GetNotebookLogs(req, &logStreamMock{ctx: ctxWithValue})
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGetNotebookLogsSuccess(t *testing.T) {
+	follow := true
+	tailLines := int64(10)
+	req := &controller.GetNotebookLogsRequest{NotebookName: "notebook-logs", Follow: &follow, TailLines: &tailLines}
+
+	pod := createNotebookPod(req.NotebookName, v1.PodRunning)
+	_, restoreClientset := useFakeClientset(pod)
+	defer restoreClientset()
+
+	mongo.On("AuthorizedUser", username, req.NotebookName).Return(true, nil).Once()
+
+	stream := &logStreamMock{ctx: ctxWithValue}
+	err := notebookService.GetNotebookLogs(req, stream)
+
+	assert.Nil(t, err)
+	// The fake clientset always answers with "fake logs"
+	assert.Equal(t, []*controller.NotebookLogChunk{
+		{PodName: pod.Name, Container: req.NotebookName, Content: "fake logs"},
+	}, stream.chunks)
+}
@@ -0,0 +1,30 @@
+package service_test
+
+import (
+	"context"
+	"notebook-service/internal/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCountNotebooksByState(t *testing.T) {
+	running := createNotebookObject("notebook-running", "workspace")
+	unstructured.SetNestedField(running.Object, int64(1), "status", "readyReplicas")
+
+	stopped := createNotebookObject("notebook-stopped", "workspace")
+	stopped.SetAnnotations(map[string]string{service.STOPPED_ANNOTATION: "2026-01-01T00:00:00Z"})
+
+	_, restoreDynamicClient := useFakeDynamicClient(running, stopped, createNotebookObject("notebook-pending", "workspace"))
+	defer restoreDynamicClient()
+
+	counts, err := service.CountNotebooksByState(kube.Dynamic)(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]float64{
+		service.NOTEBOOK_RUNNING: 1,
+		service.NOTEBOOK_PENDING: 1,
+		service.NOTEBOOK_STOPPED: 1,
+	}, counts)
+}
@@ -0,0 +1,188 @@
+package service_test
+
+import (
+	"context"
+	"notebook-service/api/controller"
+	"notebook-service/internal/model"
+	"notebook-service/internal/service"
+	"notebook-service/mocks/mock_mongo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func createOperation(id string, owner string, state string) *model.Operation {
+	return &model.Operation{
+		ID:        id,
+		Kind:      "CreateNotebook",
+		Target:    "notebook-test",
+		Username:  owner,
+		State:     state,
+		Steps:     []model.OperationStep{{Name: "create-notebook", State: state}},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+func TestGetOperationNotFound(t *testing.T) {
+	operations.On("GetOperation", "missing").Return(nil, nil).Once()
+
+	res, err := operationsService.GetOperation(ctxWithValue, &controller.GetOperationRequest{Id: "missing"})
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.NotFound, "operation missing not found"))
+}
+
+func TestGetOperationOfOtherUser(t *testing.T) {
+	operation := createOperation("op-other", "other-user", model.OPERATION_RUNNING)
+	operations.On("GetOperation", operation.ID).Return(operation, nil).Twice()
+
+	res, err := operationsService.GetOperation(dataScientistCtx, &controller.GetOperationRequest{Id: operation.ID})
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.PermissionDenied, "user is unauthorized to perform this operation"))
+
+	// Administrators may follow the operations of every user
+	res, err = operationsService.GetOperation(adminCtx, &controller.GetOperationRequest{Id: operation.ID})
+
+	assert.Nil(t, err)
+	assert.Equal(t, controller.OperationState_RUNNING, res.State)
+	assert.False(t, res.Done)
+	assert.Equal(t, "create-notebook", res.Steps[0].Name)
+}
+
+func TestListOperationsPaging(t *testing.T) {
+	done := true
+	listed := []model.Operation{
+		*createOperation("op-3", username, model.OPERATION_SUCCEEDED),
+		*createOperation("op-2", username, model.OPERATION_FAILED),
+		*createOperation("op-1", username, model.OPERATION_CANCELLED),
+	}
+
+	// One more operation than the page size is requested to detect the next page
+	operations.On("ListOperations", username, (*string)(nil), &done, int64(4), int64(3)).Return(listed, nil).Once()
+
+	res, err := operationsService.ListOperations(ctxWithValue, &controller.ListOperationsRequest{
+		Done:      &done,
+		PageSize:  2,
+		PageToken: "4",
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, res.Operations, 2)
+	assert.Equal(t, "op-3", res.Operations[0].Id)
+	assert.True(t, res.Operations[0].Done)
+	assert.Equal(t, "6", res.NextPageToken)
+}
+
+func TestListOperationsInvalidPageToken(t *testing.T) {
+	res, err := operationsService.ListOperations(ctxWithValue, &controller.ListOperationsRequest{PageToken: "abc"})
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.InvalidArgument, "invalid page token"))
+}
+
+func TestCancelOperationAlreadyDone(t *testing.T) {
+	operation := createOperation("op-done", username, model.OPERATION_SUCCEEDED)
+	operations.On("GetOperation", operation.ID).Return(operation, nil).Once()
+
+	res, err := operationsService.CancelOperation(ctxWithValue, &controller.CancelOperationRequest{Id: operation.ID})
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.FailedPrecondition, "operation op-done is already done"))
+}
+
+func TestCancelOperationSkipsRemainingSteps(t *testing.T) {
+	req := &controller.CreateNotebookRequest{
+		Name: "notebook-cancel",
+	}
+
+	dynamicClient, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
+
+	clientset, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	// Hold the steps back until the operation was cancelled
+	var task func()
+	oldRunAsync := service.RunAsync
+	service.RunAsync = func(run func()) {
+		task = run
+	}
+	defer func() {
+		service.RunAsync = oldRunAsync
+	}()
+
+	res, err := notebookService.CreateNotebook(ctxWithValue, req)
+	assert.Nil(t, err)
+	assert.Equal(t, controller.OperationState_PENDING, res.State)
+
+	operations.On("GetOperation", res.Id).Return(createOperation(res.Id, username, model.OPERATION_PENDING), nil).Once()
+	operations.On("RequestCancel", res.Id).Return(nil).Once()
+
+	cancelled, err := operationsService.CancelOperation(ctxWithValue, &controller.CancelOperationRequest{Id: res.Id})
+	assert.Nil(t, err)
+	assert.True(t, cancelled.CancelRequested)
+
+	task()
+
+	operation := lastOperation(t)
+	assert.Equal(t, res.Id, operation.ID)
+	assert.Equal(t, model.OPERATION_CANCELLED, operation.State)
+	assert.Equal(t, uint32(codes.Canceled), operation.Error.Code)
+	for _, step := range operation.Steps {
+		assert.Equal(t, model.OPERATION_CANCELLED, step.State)
+	}
+	assert.Empty(t, clientset.Actions())
+	assert.Empty(t, dynamicClient.Actions())
+	operations.AssertExpectations(t)
+}
+
+func TestCancelOperationThroughAnotherInstance(t *testing.T) {
+	dynamicClient, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
+
+	clientset, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	// The cancellation is requested on another instance once the first step started
+	repo := new(mock_mongo.MockOperations)
+	repo.On("CreateOperation", mock.Anything).Return(nil)
+	repo.On("UpdateOperation", mock.Anything).Return(nil)
+	repo.On("CancelRequested", mock.Anything).Return(false, nil).Once()
+	repo.On("CancelRequested", mock.Anything).Return(true, nil)
+	running := service.GenerateOperationsService(repo)
+	notebookService := service.GenerateNotebookService(bus, redis, mongo, profiles, idempotency, running, usage, kube, outboxRelay, dedup, webhookDispatcher)
+
+	res, err := notebookService.CreateNotebook(ctxWithValue, &controller.CreateNotebookRequest{Name: "notebook-remote-cancel"})
+	assert.Nil(t, err)
+
+	created := repo.Calls[0].Arguments.Get(0).(*model.Operation)
+	assert.NotEmpty(t, created.Owner)
+	assert.True(t, created.LeaseExpiry.After(time.Now()))
+
+	operation := repo.Calls[len(repo.Calls)-1].Arguments.Get(0).(*model.Operation)
+	assert.Equal(t, res.Id, operation.ID)
+	assert.Equal(t, model.OPERATION_CANCELLED, operation.State)
+	assert.Equal(t, model.OPERATION_SUCCEEDED, operation.Steps[0].State)
+	for _, step := range operation.Steps[1:] {
+		assert.Equal(t, model.OPERATION_CANCELLED, step.State)
+	}
+	assert.NotEmpty(t, clientset.Actions())
+	assert.Empty(t, dynamicClient.Actions())
+}
+
+func TestFailInterruptedOperations(t *testing.T) {
+	repo := new(mock_mongo.MockOperations)
+	repo.On("FailInterruptedOperations", mock.MatchedBy(func(reason model.OperationError) bool {
+		return reason.Code == uint32(codes.Aborted)
+	})).Return(int64(2), nil).Once()
+
+	service.GenerateOperationsService(repo).FailInterruptedOperations(context.Background())
+
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,186 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"notebook-service/api/events"
+	"notebook-service/internal/config"
+	"notebook-service/internal/model"
+	"notebook-service/internal/rabbitmq"
+	"notebook-service/internal/service"
+	"notebook-service/mocks/mock_mongo"
+	"notebook-service/mocks/mock_rbmq"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/protobuf/proto"
+)
+
+func createOutboxRelay(repo *mock_mongo.MockOutbox, rbmq *mock_rbmq.RabbitMQClientMock) *service.OutboxRelay {
+	return service.GenerateOutboxRelay(repo, rbmq, config.OutboxConfig{PollInterval: time.Second, BatchSize: 10})
+}
+
+// Creates an event of the outbox as recorded by the relay
+func createOutboxEvent(t *testing.T, notebookName string) model.OutboxEvent {
+	key := rabbitmq.GenerateRoutingKey(rabbitmq.NOTEBOOK, rabbitmq.DELETE)
+	event, err := rabbitmq.NewEvent(key, username, &events.NotebookDeleted{NotebookName: notebookName})
+	assert.NoError(t, err)
+
+	envelope, err := proto.Marshal(event)
+	assert.NoError(t, err)
+
+	return model.OutboxEvent{ID: event.Id, Type: event.Type, Envelope: envelope, CreatedAt: event.Time.AsTime()}
+}
+
+func TestOutboxRecord(t *testing.T) {
+	repo := new(mock_mongo.MockOutbox)
+	repo.On("AddEvent", mock.Anything).Return(nil).Once()
+	relay := createOutboxRelay(repo, new(mock_rbmq.RabbitMQClientMock))
+
+	key := rabbitmq.GenerateRoutingKey(rabbitmq.NOTEBOOK, rabbitmq.DELETE)
+	err := relay.Record(context.Background(), key, username, &events.NotebookDeleted{NotebookName: "notebook-test"})
+	assert.NoError(t, err)
+
+	recorded := repo.Calls[0].Arguments.Get(0).(*model.OutboxEvent)
+	assert.Equal(t, key, recorded.Type)
+	assert.Nil(t, recorded.SentAt)
+
+	event := &events.Envelope{}
+	assert.NoError(t, proto.Unmarshal(recorded.Envelope, event))
+	assert.Equal(t, recorded.ID, event.Id)
+	assert.Equal(t, username, event.Actor)
+	assert.Equal(t, "notebook-test", event.Subject)
+}
+
+func TestOutboxRecordFailed(t *testing.T) {
+	repo := new(mock_mongo.MockOutbox)
+	repo.On("AddEvent", mock.Anything).Return(errors.New("mongo down")).Once()
+	relay := createOutboxRelay(repo, new(mock_rbmq.RabbitMQClientMock))
+
+	key := rabbitmq.GenerateRoutingKey(rabbitmq.NOTEBOOK, rabbitmq.DELETE)
+	err := relay.Record(context.Background(), key, username, &events.NotebookDeleted{NotebookName: "notebook-test"})
+	assert.Error(t, err)
+}
+
+func TestOutboxRelayPublishesAndMarksSent(t *testing.T) {
+	first := createOutboxEvent(t, "first")
+	second := createOutboxEvent(t, "second")
+
+	repo := new(mock_mongo.MockOutbox)
+	repo.On("FindPendingEvents", int64(10)).Return([]model.OutboxEvent{first, second}, nil).Once()
+	repo.On("MarkEventSent", first.ID).Return(nil).Once()
+	repo.On("MarkEventSent", second.ID).Return(nil).Once()
+
+	rbmq := new(mock_rbmq.RabbitMQClientMock)
+	rbmq.On("PublishConfirmed", mock.MatchedBy(func(event *events.Envelope) bool { return event.Id == first.ID })).Return(nil).Once()
+	rbmq.On("PublishConfirmed", mock.MatchedBy(func(event *events.Envelope) bool { return event.Id == second.ID })).Return(nil).Once()
+
+	createOutboxRelay(repo, rbmq).Relay(context.Background())
+
+	repo.AssertExpectations(t)
+	rbmq.AssertExpectations(t)
+}
+
+func TestOutboxRelayStopsAtFailure(t *testing.T) {
+	first := createOutboxEvent(t, "first")
+	second := createOutboxEvent(t, "second")
+
+	repo := new(mock_mongo.MockOutbox)
+	repo.On("FindPendingEvents", int64(10)).Return([]model.OutboxEvent{first, second}, nil).Once()
+	repo.On("MarkEventFailed", first.ID, rabbitmq.ErrNotConnected.Error()).Return(nil).Once()
+
+	rbmq := new(mock_rbmq.RabbitMQClientMock)
+	rbmq.On("PublishConfirmed", mock.Anything).Return(rabbitmq.ErrNotConnected).Once()
+
+	createOutboxRelay(repo, rbmq).Relay(context.Background())
+
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "MarkEventSent", mock.Anything)
+	rbmq.AssertNumberOfCalls(t, "PublishConfirmed", 1)
+}
+
+func TestOutboxRelayLeavesEventsUnsentWhenTheirHandlingFailed(t *testing.T) {
+	pending := createOutboxEvent(t, "notebook-test")
+
+	repo := new(mock_mongo.MockOutbox)
+	repo.On("FindPendingEvents", int64(10)).Return([]model.OutboxEvent{pending}, nil).Once()
+	repo.On("MarkEventFailed", pending.ID, "mongo down").Return(nil).Once()
+
+	rbmq := new(mock_rbmq.RabbitMQClientMock)
+	rbmq.On("PublishConfirmed", mock.Anything).Return(nil).Once()
+
+	relay := createOutboxRelay(repo, rbmq)
+	var handled []string
+	relay.OnPublished(func(ctx context.Context, event *events.Envelope) error {
+		handled = append(handled, event.Id)
+		return errors.New("mongo down")
+	})
+	relay.Relay(context.Background())
+
+	// Published and handled again on the next run
+	assert.Equal(t, []string{pending.ID}, handled)
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "MarkEventSent", mock.Anything)
+}
+
+func TestOutboxRelaySkipsUndecodableEvents(t *testing.T) {
+	broken := model.OutboxEvent{ID: "broken", Envelope: []byte{0xff}}
+	valid := createOutboxEvent(t, "valid")
+
+	repo := new(mock_mongo.MockOutbox)
+	repo.On("FindPendingEvents", int64(10)).Return([]model.OutboxEvent{broken, valid}, nil).Once()
+	repo.On("MarkEventDiscarded", broken.ID, mock.Anything).Return(nil).Once()
+	repo.On("MarkEventSent", valid.ID).Return(nil).Once()
+
+	rbmq := new(mock_rbmq.RabbitMQClientMock)
+	rbmq.On("PublishConfirmed", mock.Anything).Return(nil).Once()
+
+	createOutboxRelay(repo, rbmq).Relay(context.Background())
+
+	repo.AssertExpectations(t)
+	rbmq.AssertExpectations(t)
+}
+
+func TestOutboxRelayDiscardsAFullBatchOfUndecodableEvents(t *testing.T) {
+	var broken []model.OutboxEvent
+	for i := 0; i < 10; i++ {
+		broken = append(broken, model.OutboxEvent{ID: fmt.Sprintf("broken-%d", i), Envelope: []byte{0xff}})
+	}
+	valid := createOutboxEvent(t, "valid")
+
+	// The discarded events are no longer pending, so the next batch holds the events behind them
+	repo := new(mock_mongo.MockOutbox)
+	repo.On("FindPendingEvents", int64(10)).Return(broken, nil).Once()
+	repo.On("FindPendingEvents", int64(10)).Return([]model.OutboxEvent{valid}, nil).Once()
+	repo.On("MarkEventDiscarded", mock.Anything, mock.Anything).Return(nil).Times(10)
+	repo.On("MarkEventSent", valid.ID).Return(nil).Once()
+
+	rbmq := new(mock_rbmq.RabbitMQClientMock)
+	rbmq.On("PublishConfirmed", mock.Anything).Return(nil).Once()
+
+	createOutboxRelay(repo, rbmq).Relay(context.Background())
+
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "MarkEventFailed", mock.Anything, mock.Anything)
+	rbmq.AssertExpectations(t)
+}
+
+func TestOutboxRelayPublishesToTheBus(t *testing.T) {
+	pending := createOutboxEvent(t, "notebook-test")
+
+	repo := new(mock_mongo.MockOutbox)
+	repo.On("FindPendingEvents", int64(10)).Return([]model.OutboxEvent{pending}, nil).Once()
+	repo.On("MarkEventSent", pending.ID).Return(nil).Once()
+
+	bus := rabbitmq.NewMemoryBus(config.Default().RabbitMQ, true)
+	service.GenerateOutboxRelay(repo, bus, config.OutboxConfig{PollInterval: time.Second, BatchSize: 10}).Relay(context.Background())
+
+	repo.AssertExpectations(t)
+	published := bus.Published()
+	assert.Len(t, published, 1)
+	assert.Equal(t, pending.ID, published[0].Id)
+	assert.Equal(t, "notebook-test", published[0].Subject)
+}
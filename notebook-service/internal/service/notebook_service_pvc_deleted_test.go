@@ -0,0 +1,183 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"notebook-service/api/events"
+	"notebook-service/internal/config"
+	"notebook-service/internal/model"
+	"notebook-service/internal/rabbitmq"
+	"notebook-service/internal/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var pvcDeleteKey = rabbitmq.GenerateRoutingKey(rabbitmq.PVC, rabbitmq.DELETE)
+
+// Makes the service apply the volume missing policy until the returned function restores the configuration
+func useVolumeMissingPolicy(policy string) func() {
+	oldFunc := service.GetConfiguration
+	service.GetConfiguration = func() service.Configuration {
+		configuration := oldFunc()
+		configuration.VolumeMissingPolicy = policy
+		return configuration
+	}
+	return func() {
+		service.GetConfiguration = oldFunc
+	}
+}
+
+// Publishes the deletion of a PVC on the bus, handled by the service before returning
+func publishPVCDeleted(t *testing.T, pvcName string) {
+	err := bus.PublishEvent(context.Background(), pvcDeleteKey, "admin", &events.PvcDeleted{PvcName: pvcName})
+	assert.Nil(t, err)
+}
+
+func TestPVCDeletedFlagsTheNotebooksMountingIt(t *testing.T) {
+	client, restoreDynamicClient := useFakeDynamicClient(
+		createNotebookObjectWithResources("notebook-flagged", false),
+		createNotebookObjectWithResources("notebook-other", false),
+	)
+	defer restoreDynamicClient()
+
+	mongo.On("SetNotebookState", "notebook-flagged", model.NOTEBOOK_VOLUME_MISSING).Return(&model.NotebookEntity{NotebookName: "notebook-flagged", Username: username, State: model.NOTEBOOK_VOLUME_MISSING}, nil).Once()
+	redis.On("InvalidateCache", username).Return(nil).Once()
+
+	publishPVCDeleted(t, "notebook-flagged"+service.WORKSPACE_SUFFIX)
+
+	mongo.AssertCalled(t, "SetNotebookState", "notebook-flagged", model.NOTEBOOK_VOLUME_MISSING)
+	mongo.AssertNotCalled(t, "SetNotebookState", "notebook-other", model.NOTEBOOK_VOLUME_MISSING)
+	redis.AssertCalled(t, "InvalidateCache", username)
+
+	// The owner is told the notebook can't run anymore
+	failed := lastRecordedEvent(t, rabbitmq.GenerateRoutingKey(rabbitmq.NOTEBOOK, rabbitmq.FAIL)).(*events.NotebookFailed)
+	assert.Equal(t, "notebook-flagged", failed.NotebookName)
+	assert.Equal(t, username, failed.Owner)
+	assert.Equal(t, model.NOTEBOOK_VOLUME_MISSING, failed.Reason)
+
+	// The flag policy leaves the notebook running
+	_, stopped := getNotebookObject(t, client, "notebook-flagged").GetAnnotations()[service.STOPPED_ANNOTATION]
+	assert.False(t, stopped)
+}
+
+func TestPVCDeletedStopsTheNotebooksMountingIt(t *testing.T) {
+	restoreConfig := useVolumeMissingPolicy(config.VOLUME_MISSING_STOP)
+	defer restoreConfig()
+
+	client, restoreDynamicClient := useFakeDynamicClient(createNotebookObjectWithResources("notebook-stopped", false))
+	defer restoreDynamicClient()
+
+	mongo.On("SetNotebookState", "notebook-stopped", model.NOTEBOOK_VOLUME_MISSING).Return(&model.NotebookEntity{NotebookName: "notebook-stopped", Username: username, State: model.NOTEBOOK_VOLUME_MISSING}, nil).Once()
+	redis.On("InvalidateCache", username).Return(nil).Once()
+
+	publishPVCDeleted(t, "notebook-stopped"+service.WORKSPACE_SUFFIX)
+
+	_, stopped := getNotebookObject(t, client, "notebook-stopped").GetAnnotations()[service.STOPPED_ANNOTATION]
+	assert.True(t, stopped)
+	assertUsageRecorded(t, "notebook-stopped", model.USAGE_STOP)
+}
+
+func TestPVCDeletedSkipsNotebooksNotStored(t *testing.T) {
+	restoreConfig := useVolumeMissingPolicy(config.VOLUME_MISSING_STOP)
+	defer restoreConfig()
+
+	client, restoreDynamicClient := useFakeDynamicClient(createNotebookObjectWithResources("notebook-unknown", false))
+	defer restoreDynamicClient()
+
+	mongo.On("SetNotebookState", "notebook-unknown", model.NOTEBOOK_VOLUME_MISSING).Return(nil, nil).Once()
+
+	publishPVCDeleted(t, "notebook-unknown"+service.WORKSPACE_SUFFIX)
+
+	_, stopped := getNotebookObject(t, client, "notebook-unknown").GetAnnotations()[service.STOPPED_ANNOTATION]
+	assert.False(t, stopped)
+}
+
+func TestPVCDeletedIsRetriedOnFailure(t *testing.T) {
+	_, restoreDynamicClient := useFakeDynamicClient(createNotebookObjectWithResources("notebook-retried", false))
+	defer restoreDynamicClient()
+
+	// Fails once, the retry flags the notebook
+	mongo.On("SetNotebookState", "notebook-retried", model.NOTEBOOK_VOLUME_MISSING).Return(nil, errors.New("mongo error")).Once()
+	mongo.On("SetNotebookState", "notebook-retried", model.NOTEBOOK_VOLUME_MISSING).Return(&model.NotebookEntity{NotebookName: "notebook-retried", Username: username, State: model.NOTEBOOK_VOLUME_MISSING}, nil).Once()
+	redis.On("InvalidateCache", username).Return(nil).Once()
+
+	deadLetters, err := bus.ListDeadLetters(100)
+	assert.Nil(t, err)
+
+	publishPVCDeleted(t, "notebook-retried"+service.WORKSPACE_SUFFIX)
+
+	after, err := bus.ListDeadLetters(100)
+	assert.Nil(t, err)
+	assert.Len(t, after, len(deadLetters))
+	redis.AssertCalled(t, "InvalidateCache", username)
+}
+
+// Returns the ids of the events of the type recorded in the outbox, oldest first
+func recordedEventIds(eventType string) []string {
+	var ids []string
+	for _, call := range outbox.Calls {
+		if recorded, ok := call.Arguments.Get(0).(*model.OutboxEvent); ok && recorded.Type == eventType {
+			ids = append(ids, recorded.ID)
+		}
+	}
+	return ids
+}
+
+func TestPVCDeletedAnnouncesTheFailureOncePerEvent(t *testing.T) {
+	_, restoreDynamicClient := useFakeDynamicClient(createNotebookObjectWithResources("notebook-redelivered", false))
+	defer restoreDynamicClient()
+
+	mongo.On("SetNotebookState", "notebook-redelivered", model.NOTEBOOK_VOLUME_MISSING).Return(&model.NotebookEntity{NotebookName: "notebook-redelivered", Username: username, State: model.NOTEBOOK_VOLUME_MISSING}, nil).Times(3)
+	redis.On("InvalidateCache", username).Return(nil).Times(3)
+
+	data := &events.PvcDeleted{PvcName: "notebook-redelivered" + service.WORKSPACE_SUFFIX}
+	event, err := rabbitmq.NewEvent(pvcDeleteKey, "admin", data)
+	assert.Nil(t, err)
+	other, err := rabbitmq.NewEvent(pvcDeleteKey, "admin", data)
+	assert.Nil(t, err)
+
+	failKey := rabbitmq.GenerateRoutingKey(rabbitmq.NOTEBOOK, rabbitmq.FAIL)
+	recorded := len(recordedEventIds(failKey))
+
+	// The redelivery records the event under the same id, left as is by the outbox
+	handler := notebookService.(*service.NotebookService)
+	assert.Nil(t, handler.HandlePVCDeleted(context.Background(), event, data))
+	assert.Nil(t, handler.HandlePVCDeleted(context.Background(), event, data))
+	assert.Nil(t, handler.HandlePVCDeleted(context.Background(), other, data))
+
+	ids := recordedEventIds(failKey)[recorded:]
+	assert.Len(t, ids, 3)
+	assert.Equal(t, ids[0], ids[1])
+	assert.NotEqual(t, ids[0], ids[2])
+}
+
+func TestPVCDeletedPublishedByThePvcServiceOnASharedBus(t *testing.T) {
+	_, restoreDynamicClient := useFakeDynamicClient(createNotebookObjectWithResources("notebook-shared", false))
+	defer restoreDynamicClient()
+
+	// Both services build their bus on the same exchange, each consuming the keys it binds
+	exchange := rabbitmq.NewMemoryExchange()
+	notebookBus := exchange.NewBus(config.Default().RabbitMQ, rabbitmq.BOUND_KEYS, false)
+	pvcBus := exchange.NewBus(config.Default().RabbitMQ, []string{"NOTEBOOK.DELETE", "NOTEBOOK.CREATE"}, false)
+	service.GenerateNotebookService(notebookBus, redis, mongo, profiles, idempotency, operationsService, usage, kube, outboxRelay, dedup, webhookDispatcher)
+
+	var pvcHandled []string
+	pvcBus.ConsumeMessages(rabbitmq.NewRouter().Handle("NOTEBOOK.DELETE", func(ctx context.Context, event *events.Envelope) error {
+		pvcHandled = append(pvcHandled, event.Subject)
+		return nil
+	}))
+
+	mongo.On("SetNotebookState", "notebook-shared", model.NOTEBOOK_VOLUME_MISSING).Return(&model.NotebookEntity{NotebookName: "notebook-shared", Username: username, State: model.NOTEBOOK_VOLUME_MISSING}, nil).Once()
+	redis.On("InvalidateCache", username).Return(nil).Once()
+
+	// The deletion published by the pvc service reaches the router of the notebook service
+	err := pvcBus.PublishEvent(context.Background(), pvcDeleteKey, "admin", &events.PvcDeleted{PvcName: "notebook-shared" + service.WORKSPACE_SUFFIX})
+	assert.Nil(t, err)
+	mongo.AssertCalled(t, "SetNotebookState", "notebook-shared", model.NOTEBOOK_VOLUME_MISSING)
+
+	// And the events of the notebook service reach the pvc service
+	err = notebookBus.PublishEvent(context.Background(), "NOTEBOOK.DELETE", "alice", &events.NotebookDeleted{NotebookName: "notebook-shared"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"notebook-shared"}, pvcHandled)
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"notebook-service/api/controller"
+	"notebook-service/internal/auth"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// RenderNotebook validates a create notebook request and returns the manifests it would apply.
+// The manifests are also sent to the cluster as a server side dry run, so admission webhooks and
+// quota are evaluated without creating anything.
+func (s *NotebookService) RenderNotebook(ctx context.Context, req *controller.CreateNotebookRequest) (*controller.RenderNotebookResponse, error) {
+	resources, err := parseNotebookResources(req)
+	if err != nil {
+		return nil, err
+	}
+
+	schedulingProfile, err := s.resolveSchedulingProfile(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	environmentConfig := GetConfiguration()
+	namespace := environmentConfig.Namespace
+
+	storage, err := resolveVolumeStorage(environmentConfig, req)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient := s.kube.Dynamic
+
+	clientset := s.kube.Clientset
+
+	response := &controller.RenderNotebookResponse{Accepted: true}
+	dryRun := metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}}
+
+	pvcName := setStringValue(req.Pvc, "")
+	if pvcName == "" {
+		pvcDefinition := createNotebookPvcDefinition(namespace, req.Name, ctx.Value(auth.CtxKey).(string), resources.volumeSize, storage)
+		pvcName = pvcDefinition.Name
+
+		pvcManifest, err := yaml.Marshal(pvcDefinition)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed rendering pvc: %v", err)
+		}
+		response.PvcManifest = pointerToString(string(pvcManifest))
+
+		_, err = CreatePvcResource(clientset, &pvcDefinition, dryRun)
+		if err != nil {
+			if err = addDryRunRejection(response, "pvc", err); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	notebook := createNotebookDefinition(namespace, req.Name, req.Type, resources.cpuLimit, resources.cpuRequest, resources.memoryLimit, resources.memoryRequest, pvcName, createEnvFromSources(req.EnvFrom))
+	applySchedulingProfile(notebook, schedulingProfile)
+
+	notebookManifest, err := yaml.Marshal(notebook)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed rendering notebook: %v", err)
+	}
+	response.NotebookManifest = string(notebookManifest)
+
+	_, err = createNotebookResource(dynamicClient, notebook, dryRun)
+	if err != nil {
+		if err = addDryRunRejection(response, "notebook", err); err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// Records a dry run rejected by the API server. Errors that did not come from the API server
+// are returned, since they say nothing about the manifest.
+func addDryRunRejection(response *controller.RenderNotebookResponse, resource string, err error) error {
+	var apiStatus apierrors.APIStatus
+	if !errors.As(err, &apiStatus) {
+		return status.Errorf(codes.Internal, "failed dry run of %s: %v", resource, err)
+	}
+
+	response.Accepted = false
+	response.Rejections = append(response.Rejections, fmt.Sprintf("%s: %v", resource, err))
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package service_test
+
+import (
+	"errors"
+	"notebook-service/api/controller"
+	"notebook-service/internal/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// Asserts that every create action was sent as a server side dry run
+func assertDryRunCreates(t *testing.T, actions []k8stesting.Action) {
+	creates := 0
+	for _, action := range actions {
+		if createAction, ok := action.(k8stesting.CreateActionImpl); ok {
+			creates++
+			assert.Equal(t, []string{metav1.DryRunAll}, createAction.CreateOptions.DryRun)
+		}
+	}
+	assert.Equal(t, 1, creates)
+}
+
+func TestRenderNotebookInvalidRequest(t *testing.T) {
+	req := &controller.CreateNotebookRequest{
+		Name:   "notebook-render",
+		MaxCpu: stringPtr("a"),
+	}
+
+	res, err := notebookService.RenderNotebook(ctxWithValue, req)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.InvalidArgument, "invalid max cpu"))
+}
+
+func TestRenderNotebookSuccess(t *testing.T) {
+	req := &controller.CreateNotebookRequest{
+		Name:   "notebook-render",
+		MaxCpu: stringPtr("4"),
+		Volume: stringPtr("5G"),
+	}
+
+	_, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
+
+	clientset, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	res, err := notebookService.RenderNotebook(ctxWithValue, req)
+
+	assert.Nil(t, err)
+	assert.True(t, res.Accepted)
+	assert.Empty(t, res.Rejections)
+	assert.Contains(t, res.NotebookManifest, "kind: Notebook")
+	assert.Contains(t, res.NotebookManifest, "claimName: notebook-render"+service.WORKSPACE_SUFFIX)
+	assert.Contains(t, res.NotebookManifest, "cpu: \"4\"")
+	assert.NotNil(t, res.PvcManifest)
+	assert.Contains(t, *res.PvcManifest, "kind: PersistentVolumeClaim")
+	assert.Contains(t, *res.PvcManifest, "storage: 5G")
+
+	// The fake dynamic client drops the create options, so only the pvc can be checked
+	assertDryRunCreates(t, clientset.Actions())
+}
+
+func TestRenderNotebookExistingPvc(t *testing.T) {
+	req := &controller.CreateNotebookRequest{
+		Name: "notebook-render",
+		Pvc:  stringPtr("shared-data"),
+	}
+
+	_, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
+
+	clientset, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	res, err := notebookService.RenderNotebook(ctxWithValue, req)
+
+	assert.Nil(t, err)
+	assert.True(t, res.Accepted)
+	assert.Nil(t, res.PvcManifest)
+	assert.Contains(t, res.NotebookManifest, "claimName: shared-data")
+	assert.Empty(t, clientset.Actions())
+}
+
+func TestRenderNotebookRejectedByServer(t *testing.T) {
+	req := &controller.CreateNotebookRequest{
+		Name: "notebook-render",
+	}
+
+	dynamicClient, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
+
+	_, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	// Simulate the quota admission rejecting the notebook
+	dynamicClient.PrependReactor("create", "notebooks", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		resource := schema.GroupResource{Group: "kubeflow.org", Resource: "notebooks"}
+		return true, nil, apierrors.NewForbidden(resource, req.Name, errors.New("exceeded quota"))
+	})
+
+	res, err := notebookService.RenderNotebook(ctxWithValue, req)
+
+	assert.Nil(t, err)
+	assert.False(t, res.Accepted)
+	assert.Len(t, res.Rejections, 1)
+	assert.Contains(t, res.Rejections[0], "exceeded quota")
+	assert.NotEmpty(t, res.NotebookManifest)
+}
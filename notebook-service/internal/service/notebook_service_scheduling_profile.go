@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"notebook-service/api/controller"
+	"notebook-service/internal/auth"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// PutSchedulingProfile creates or replaces a scheduling profile. Only administrators may manage profiles.
+func (s *NotebookService) PutSchedulingProfile(ctx context.Context, req *controller.SchedulingProfile) (*emptypb.Empty, error) {
+	if auth.GetRole(ctx) != auth.ADMIN {
+		return nil, status.Error(codes.PermissionDenied, "only administrators can manage scheduling profiles")
+	}
+
+	profile, err := schedulingProfileFromRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every notebook type can have at most one default profile
+	for _, notebookType := range profile.DefaultForTypes {
+		existing, err := s.profiles.FindDefaultSchedulingProfile(ctx, notebookType)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if existing != nil && existing.Name != profile.Name {
+			return nil, status.Errorf(codes.FailedPrecondition, "scheduling profile %s is already the default for %s", existing.Name, notebookType)
+		}
+	}
+
+	err = s.profiles.PutSchedulingProfile(ctx, profile)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// DeleteSchedulingProfile removes a scheduling profile. Notebooks already using it keep their placement.
+func (s *NotebookService) DeleteSchedulingProfile(ctx context.Context, req *controller.DeleteSchedulingProfileRequest) (*emptypb.Empty, error) {
+	if auth.GetRole(ctx) != auth.ADMIN {
+		return nil, status.Error(codes.PermissionDenied, "only administrators can manage scheduling profiles")
+	}
+
+	deleted, err := s.profiles.DeleteSchedulingProfile(ctx, req.Name)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if !deleted {
+		return nil, status.Errorf(codes.NotFound, "scheduling profile %s not found", req.Name)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// ListSchedulingProfiles returns the scheduling profiles the caller is permitted to select
+func (s *NotebookService) ListSchedulingProfiles(ctx context.Context, req *controller.ListSchedulingProfilesRequest) (*controller.ListSchedulingProfilesResponse, error) {
+	role := auth.GetRole(ctx)
+
+	profiles, err := s.profiles.ListSchedulingProfiles(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &controller.ListSchedulingProfilesResponse{}
+	for _, profile := range profiles {
+		if role != auth.ADMIN && !profile.PermitsRole(role) {
+			continue
+		}
+
+		profileResponse, err := schedulingProfileToResponse(&profile)
+		if err != nil {
+			return nil, err
+		}
+		response.Profiles = append(response.Profiles, profileResponse)
+	}
+
+	return response, nil
+}
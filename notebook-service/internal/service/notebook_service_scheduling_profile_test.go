@@ -0,0 +1,280 @@
+package service_test
+
+import (
+	"context"
+	"notebook-service/api/controller"
+	"notebook-service/internal/auth"
+	"notebook-service/internal/model"
+	"notebook-service/mocks/mock_mongo"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+)
+
+var adminCtx = context.WithValue(ctxWithValue, auth.RoleCtxKey, auth.ADMIN)
+var dataScientistCtx = context.WithValue(ctxWithValue, auth.RoleCtxKey, auth.DS)
+
+func createHighMemoryProfile(allowedRoles ...string) *model.SchedulingProfile {
+	return &model.SchedulingProfile{
+		Name:         "high-memory",
+		NodeSelector: map[string]string{"node-pool": "high-memory"},
+		Tolerations: []v1.Toleration{
+			{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "high-memory", Effect: v1.TaintEffectNoSchedule},
+		},
+		PriorityClassName: "notebook-high",
+		AllowedRoles:      allowedRoles,
+	}
+}
+
+func TestPutSchedulingProfileNotAdmin(t *testing.T) {
+	req := &controller.SchedulingProfile{Name: "high-memory"}
+
+	res, err := notebookService.PutSchedulingProfile(dataScientistCtx, req)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.PermissionDenied, "only administrators can manage scheduling profiles"))
+}
+
+func TestPutSchedulingProfileInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *controller.SchedulingProfile
+		err  error
+	}{
+		{
+			name: "invalid toleration operator",
+			req: &controller.SchedulingProfile{
+				Name:        "high-memory",
+				Tolerations: []*controller.Toleration{{Key: "dedicated", Operator: "In"}},
+			},
+			err: status.Error(codes.InvalidArgument, "invalid toleration operator: In"),
+		},
+		{
+			name: "value with exists operator",
+			req: &controller.SchedulingProfile{
+				Name:        "high-memory",
+				Tolerations: []*controller.Toleration{{Key: "dedicated", Operator: "Exists", Value: "high-memory"}},
+			},
+			err: status.Error(codes.InvalidArgument, "toleration value must be empty when the operator is Exists"),
+		},
+		{
+			name: "invalid toleration effect",
+			req: &controller.SchedulingProfile{
+				Name:        "high-memory",
+				Tolerations: []*controller.Toleration{{Key: "dedicated", Effect: "NoRun"}},
+			},
+			err: status.Error(codes.InvalidArgument, "invalid toleration effect: NoRun"),
+		},
+		{
+			name: "invalid role",
+			req: &controller.SchedulingProfile{
+				Name:         "high-memory",
+				AllowedRoles: []string{"GUEST"},
+			},
+			err: status.Error(codes.InvalidArgument, "invalid role: GUEST"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := notebookService.PutSchedulingProfile(adminCtx, tt.req)
+			assert.Nil(t, res)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestPutSchedulingProfileInvalidAffinity(t *testing.T) {
+	req := &controller.SchedulingProfile{
+		Name:     "high-memory",
+		Affinity: "nodeAffinity: {preferred: true}",
+	}
+
+	res, err := notebookService.PutSchedulingProfile(adminCtx, req)
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestPutSchedulingProfileDefaultConflict(t *testing.T) {
+	profileRepo := new(mock_mongo.MockSchedulingProfiles)
+	service := createNotebookServiceWithProfiles(profileRepo)
+
+	req := &controller.SchedulingProfile{
+		Name:            "high-memory",
+		DefaultForTypes: []controller.NotebookType{controller.NotebookType_RSTUDIO},
+	}
+
+	profileRepo.On("FindDefaultSchedulingProfile", "RSTUDIO").Return(&model.SchedulingProfile{Name: "standard"}, nil).Once()
+
+	res, err := service.PutSchedulingProfile(adminCtx, req)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.FailedPrecondition, "scheduling profile standard is already the default for RSTUDIO"))
+}
+
+func TestPutSchedulingProfileSuccess(t *testing.T) {
+	req := &controller.SchedulingProfile{
+		Name:         "high-memory",
+		NodeSelector: map[string]string{"node-pool": "high-memory"},
+		Tolerations: []*controller.Toleration{
+			{Key: "dedicated", Operator: "Equal", Value: "high-memory", Effect: "NoSchedule"},
+		},
+		Affinity: `nodeAffinity:
+  requiredDuringSchedulingIgnoredDuringExecution:
+    nodeSelectorTerms:
+    - matchExpressions:
+      - key: memory-class
+        operator: In
+        values: [large]
+`,
+		PriorityClassName: "notebook-high",
+		AllowedRoles:      []string{auth.DS},
+		DefaultForTypes:   []controller.NotebookType{controller.NotebookType_JUPITER},
+	}
+
+	profiles.On("PutSchedulingProfile", mock.MatchedBy(func(profile *model.SchedulingProfile) bool {
+		return profile.Name == "high-memory" &&
+			profile.Tolerations[0].Effect == v1.TaintEffectNoSchedule &&
+			profile.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions[0].Key == "memory-class" &&
+			profile.DefaultForTypes[0] == "JUPITER"
+	})).Return(nil).Once()
+
+	_, err := notebookService.PutSchedulingProfile(adminCtx, req)
+
+	assert.Nil(t, err)
+	profiles.AssertExpectations(t)
+}
+
+func TestDeleteSchedulingProfileNotFound(t *testing.T) {
+	req := &controller.DeleteSchedulingProfileRequest{Name: "high-memory"}
+
+	profiles.On("DeleteSchedulingProfile", req.Name).Return(false, nil).Once()
+
+	res, err := notebookService.DeleteSchedulingProfile(adminCtx, req)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.NotFound, "scheduling profile high-memory not found"))
+}
+
+func TestListSchedulingProfilesFiltersByRole(t *testing.T) {
+	storedProfiles := []model.SchedulingProfile{
+		*createHighMemoryProfile(auth.ADMIN),
+		{Name: "standard"},
+	}
+
+	profiles.On("ListSchedulingProfiles").Return(storedProfiles, nil).Twice()
+
+	res, err := notebookService.ListSchedulingProfiles(dataScientistCtx, &controller.ListSchedulingProfilesRequest{})
+	assert.Nil(t, err)
+	assert.Len(t, res.Profiles, 1)
+	assert.Equal(t, "standard", res.Profiles[0].Name)
+
+	res, err = notebookService.ListSchedulingProfiles(adminCtx, &controller.ListSchedulingProfilesRequest{})
+	assert.Nil(t, err)
+	assert.Len(t, res.Profiles, 2)
+	assert.Equal(t, "NoSchedule", res.Profiles[0].Tolerations[0].Effect)
+}
+
+func TestCreateNotebookSchedulingProfileNotPermitted(t *testing.T) {
+	req := &controller.CreateNotebookRequest{
+		Name:              "notebook-scheduling",
+		SchedulingProfile: stringPtr("high-memory"),
+	}
+
+	profiles.On("GetSchedulingProfile", "high-memory").Return(createHighMemoryProfile(auth.ADMIN), nil).Once()
+
+	res, err := notebookService.CreateNotebook(dataScientistCtx, req)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.PermissionDenied, "scheduling profile high-memory is not permitted for role DS"))
+}
+
+func TestCreateNotebookSchedulingProfileNotFound(t *testing.T) {
+	req := &controller.CreateNotebookRequest{
+		Name:              "notebook-scheduling",
+		SchedulingProfile: stringPtr("gpu"),
+	}
+
+	profiles.On("GetSchedulingProfile", "gpu").Return(nil, nil).Once()
+
+	res, err := notebookService.CreateNotebook(dataScientistCtx, req)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.NotFound, "scheduling profile gpu not found"))
+}
+
+func TestRenderNotebookSelectedSchedulingProfile(t *testing.T) {
+	req := &controller.CreateNotebookRequest{
+		Name:              "notebook-scheduling",
+		Pvc:               stringPtr("shared-data"),
+		SchedulingProfile: stringPtr("high-memory"),
+	}
+
+	_, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
+
+	_, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	profiles.On("GetSchedulingProfile", "high-memory").Return(createHighMemoryProfile(auth.DS), nil).Once()
+
+	res, err := notebookService.RenderNotebook(dataScientistCtx, req)
+
+	assert.Nil(t, err)
+	assert.Contains(t, res.NotebookManifest, "suedataplatform/scheduling-profile: high-memory")
+	assert.Contains(t, res.NotebookManifest, "node-pool: high-memory")
+	assert.Contains(t, res.NotebookManifest, "key: dedicated")
+	assert.Contains(t, res.NotebookManifest, "priorityClassName: notebook-high")
+}
+
+func TestRenderNotebookDefaultSchedulingProfile(t *testing.T) {
+	profileRepo := new(mock_mongo.MockSchedulingProfiles)
+	service := createNotebookServiceWithProfiles(profileRepo)
+
+	req := &controller.CreateNotebookRequest{
+		Name: "notebook-scheduling",
+		Type: controller.NotebookType_RSTUDIO.Enum(),
+		Pvc:  stringPtr("shared-data"),
+	}
+
+	_, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
+
+	_, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	profileRepo.On("FindDefaultSchedulingProfile", "RSTUDIO").Return(createHighMemoryProfile(), nil).Once()
+
+	res, err := service.RenderNotebook(dataScientistCtx, req)
+
+	assert.Nil(t, err)
+	assert.Contains(t, res.NotebookManifest, "node-pool: high-memory")
+}
+
+func TestRenderNotebookDefaultSchedulingProfileNotPermitted(t *testing.T) {
+	profileRepo := new(mock_mongo.MockSchedulingProfiles)
+	service := createNotebookServiceWithProfiles(profileRepo)
+
+	req := &controller.CreateNotebookRequest{
+		Name: "notebook-scheduling",
+		Pvc:  stringPtr("shared-data"),
+	}
+
+	_, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
+
+	_, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	profileRepo.On("FindDefaultSchedulingProfile", "JUPITER").Return(createHighMemoryProfile(auth.ADMIN), nil).Once()
+
+	res, err := service.RenderNotebook(dataScientistCtx, req)
+
+	assert.Nil(t, err)
+	assert.NotContains(t, res.NotebookManifest, "node-pool")
+}
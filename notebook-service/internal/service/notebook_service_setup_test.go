@@ -2,17 +2,25 @@ package service_test
 
 import (
 	"context"
+	"net/http"
 	"notebook-service/api/controller"
+	"notebook-service/api/events"
 	"notebook-service/internal"
 	"notebook-service/internal/auth"
+	"notebook-service/internal/config"
+	"notebook-service/internal/model"
+	"notebook-service/internal/rabbitmq"
 	"notebook-service/internal/service"
 	"notebook-service/mocks/mock_mongo"
-	"notebook-service/mocks/mock_rbmq"
 	"notebook-service/mocks/mock_redis"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -20,7 +28,7 @@ import (
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
 )
 
 var notebookService controller.NotebookServiceServer
@@ -28,6 +36,18 @@ var pvc *v1.PersistentVolumeClaim
 
 var redis *mock_redis.MockRedis
 var mongo *mock_mongo.MockMongo
+var profiles *mock_mongo.MockSchedulingProfiles
+var idempotency *mock_redis.MockIdempotency
+var operations *mock_mongo.MockOperations
+var operationsService *service.OperationsService
+var usage *mock_mongo.MockUsage
+var kube *internal.KubeClients
+var outbox *mock_mongo.MockOutbox
+var outboxRelay *service.OutboxRelay
+var webhooks *mock_mongo.MockWebhooks
+var webhookDispatcher *service.WebhookDispatcher
+var bus *rabbitmq.MemoryBus
+var dedup = rabbitmq.NewMemoryDedupStore(time.Minute, time.Hour)
 
 var username = "user"
 
@@ -56,8 +76,8 @@ func TestMain(m *testing.M) {
 		},
 	}
 
-	// Create mock rabbitmq
-	rbmq := new(mock_rbmq.RabbitMQClientMock)
+	// Create an in-memory bus, delivering the events to the service synchronously
+	bus = rabbitmq.NewMemoryBus(config.Default().RabbitMQ, true)
 	// Mock Redis Client
 	// redisMock := new(mock_redis.MockRedisClient)
 	// redisMock.On("Ping", mock.Anything).Return(nil)
@@ -68,44 +88,115 @@ func TestMain(m *testing.M) {
 	// Create mock mongodb repo
 	mongo = new(mock_mongo.MockMongo)
 
-	notebookService = service.GenerateNotebookService(rbmq, redis, mongo)
+	// Create mock scheduling profile repo without any default profiles
+	profiles = new(mock_mongo.MockSchedulingProfiles)
+	profiles.On("FindDefaultSchedulingProfile", mock.Anything).Return(nil, nil)
 
-	rbmq.On("Publish", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
-	rbmq.On("ConsumeMessages", mock.Anything).Return()
+	// Create mock idempotency repo
+	idempotency = new(mock_redis.MockIdempotency)
+
+	// Create mock operation repo and run the operations synchronously, so tests can assert their outcome
+	operations = new(mock_mongo.MockOperations)
+	operations.On("CreateOperation", mock.Anything).Return(nil)
+	operations.On("UpdateOperation", mock.Anything).Return(nil)
+	operations.On("CancelRequested", mock.Anything).Return(false, nil).Maybe()
+	operationsService = service.GenerateOperationsService(operations)
+	service.RunAsync = func(task func()) {
+		task()
+	}
+
+	// Create mock usage repo accepting every usage event
+	usage = new(mock_mongo.MockUsage)
+	usage.On("InsertUsageEvent", mock.Anything).Return(nil)
+	usage.On("OpenUsageInterval", mock.Anything).Return(nil)
+	usage.On("CloseUsageIntervals", mock.Anything, mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	// Share fake kubernetes clients, tests swap them for ones holding their objects
+	kube = &internal.KubeClients{Dynamic: createFakeDynamicClient(), Clientset: kubernetesfake.NewSimpleClientset()}
+
+	// Create mock outbox accepting every event
+	outbox = new(mock_mongo.MockOutbox)
+	outbox.On("AddEvent", mock.Anything).Return(nil)
+	outboxRelay = service.GenerateOutboxRelay(outbox, bus, config.OutboxConfig{PollInterval: time.Second, BatchSize: 10})
+
+	// Create mock webhook repo without any webhook
+	webhooks = new(mock_mongo.MockWebhooks)
+	webhooks.On("FindSubscribedWebhooks", mock.Anything).Return([]model.Webhook{}, nil)
+	webhookDispatcher = service.GenerateWebhookDispatcher(webhooks, http.DefaultClient, config.Default().Webhooks)
+
+	notebookService = service.GenerateNotebookService(bus, redis, mongo, profiles, idempotency, operationsService, usage, kube, outboxRelay, dedup, webhookDispatcher)
 
 	code := m.Run()
 
 	os.Exit(code)
 }
 
-func createFakeDynamicClient() *fake.FakeDynamicClient {
-	// Create a scheme and add corev1 to it
-	scheme := runtime.NewScheme()
-	v1.AddToScheme(scheme)
+// Creates a notebook service sharing the mocks of TestMain except for the scheduling profiles
+func createNotebookServiceWithProfiles(profiles *mock_mongo.MockSchedulingProfiles) controller.NotebookServiceServer {
+	return service.GenerateNotebookService(bus, redis, mongo, profiles, idempotency, operationsService, usage, kube, outboxRelay, dedup, webhookDispatcher)
+}
 
-	return fake.NewSimpleDynamicClient(scheme)
+// Returns the operation stored by the last progress update
+func lastOperation(t *testing.T) *model.Operation {
+	for i := len(operations.Calls) - 1; i >= 0; i-- {
+		if operations.Calls[i].Method == "UpdateOperation" {
+			return operations.Calls[i].Arguments.Get(0).(*model.Operation)
+		}
+	}
+
+	t.Fatal("no operation was updated")
+	return nil
 }
 
-func mockGetKubeConfig(config *rest.Config, err error) func() {
-	newFunc := func() (*rest.Config, error) {
-		return config, err
+// Returns the data of the last event of the type recorded in the outbox
+func lastRecordedEvent(t *testing.T, eventType string) proto.Message {
+	for i := len(outbox.Calls) - 1; i >= 0; i-- {
+		recorded, ok := outbox.Calls[i].Arguments.Get(0).(*model.OutboxEvent)
+		if !ok || recorded.Type != eventType {
+			continue
+		}
+
+		event := &events.Envelope{}
+		assert.Nil(t, proto.Unmarshal(recorded.Envelope, event))
+		data, err := event.Data.UnmarshalNew()
+		assert.Nil(t, err)
+		return data
 	}
-	oldGetKubeConfig := internal.GetKubeConfig
-	internal.GetKubeConfig = newFunc
 
-	return func() {
-		internal.GetKubeConfig = oldGetKubeConfig
+	t.Fatalf("no %s event was recorded", eventType)
+	return nil
+}
+
+// Asserts the last operation failed at the given step with the given error
+func assertOperationFailed(t *testing.T, step string, err error) {
+	operation := lastOperation(t)
+	expected := status.Convert(err)
+
+	assert.Equal(t, model.OPERATION_FAILED, operation.State)
+	assert.Equal(t, uint32(expected.Code()), operation.Error.Code)
+	assert.Equal(t, expected.Message(), operation.Error.Message)
+	for _, operationStep := range operation.Steps {
+		if operationStep.State == model.OPERATION_FAILED {
+			assert.Equal(t, step, operationStep.Name)
+		}
 	}
 }
 
+func createFakeDynamicClient() *fake.FakeDynamicClient {
+	// Create a scheme and add corev1 to it
+	scheme := runtime.NewScheme()
+	v1.AddToScheme(scheme)
+
+	return fake.NewSimpleDynamicClient(scheme)
+}
+
 const NAMESPACE = "kubeflow-user-example-com-test"
 
 func mockGetConfiguration() func() {
 	newFunc := func() service.Configuration {
 		return service.Configuration{
-			UrlBase:                   "http://localhost:8080",
-			Namespace:                 NAMESPACE,
-			KubeflowKustomizationPath: "/path/to/kustomization",
+			UrlBase:   "http://localhost:8080",
+			Namespace: NAMESPACE,
 		}
 	}
 
@@ -116,24 +207,21 @@ func mockGetConfiguration() func() {
 	}
 }
 
-func mockCreateDynamicClient(err error) func() {
-	var client *fake.FakeDynamicClient
-
-	if err == nil {
-		client = createFakeDynamicClient()
-	} else {
-		client = nil
+// Makes the services use the dynamic client until the returned function restores the previous one
+func useDynamicClient(client dynamic.Interface) func() {
+	oldClient := kube.Dynamic
+	kube.Dynamic = client
+	return func() {
+		kube.Dynamic = oldClient
 	}
+}
 
-	newFunc := func(config *rest.Config) (dynamic.Interface, error) {
-		return client, err
-	}
+func useFakeDynamicClient(objects ...runtime.Object) (*fake.FakeDynamicClient, func()) {
+	scheme := runtime.NewScheme()
+	v1.AddToScheme(scheme)
 
-	oldFunc := service.CreateDynamicClient
-	service.CreateDynamicClient = newFunc
-	return func() {
-		service.CreateDynamicClient = oldFunc
-	}
+	client := fake.NewSimpleDynamicClient(scheme, objects...)
+	return client, useDynamicClient(client)
 }
 
 func mockCallOpen() func() {
@@ -147,7 +235,7 @@ func mockCallOpen() func() {
 }
 
 func mockCreatePvcResource(sentPVC *v1.PersistentVolumeClaim, err error) func() {
-	newFunc := func(*kubernetes.Clientset, string, string, resource.Quantity) (*v1.PersistentVolumeClaim, error) {
+	newFunc := func(kubernetes.Interface, *v1.PersistentVolumeClaim, metav1.CreateOptions) (*v1.PersistentVolumeClaim, error) {
 		return sentPVC, err
 	}
 
@@ -158,3 +246,13 @@ func mockCreatePvcResource(sentPVC *v1.PersistentVolumeClaim, err error) func()
 		service.CreatePvcResource = oldFunc
 	}
 }
+
+func useFakeClientset(objects ...runtime.Object) (*kubernetesfake.Clientset, func()) {
+	clientset := kubernetesfake.NewSimpleClientset(objects...)
+
+	oldClientset := kube.Clientset
+	kube.Clientset = clientset
+	return clientset, func() {
+		kube.Clientset = oldClientset
+	}
+}
@@ -0,0 +1,176 @@
+package service_test
+
+import (
+	"notebook-service/api/controller"
+	"notebook-service/internal/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Overrides the storage settings of the current configuration
+func mockStorageConfiguration(defaultStorageClass string, allowedStorageClasses ...string) func() {
+	oldFunc := service.GetConfiguration
+	service.GetConfiguration = func() service.Configuration {
+		config := oldFunc()
+		config.DefaultStorageClass = defaultStorageClass
+		config.AllowedStorageClasses = allowedStorageClasses
+		return config
+	}
+
+	return func() {
+		service.GetConfiguration = oldFunc
+	}
+}
+
+func TestCreateNotebookStorageClassNotAllowed(t *testing.T) {
+	req := &controller.CreateNotebookRequest{
+		Name:         "notebook-storage",
+		StorageClass: stringPtr("gp2"),
+	}
+
+	restoreGetConfiguration := mockStorageConfiguration("standard", "standard", "nfs")
+	defer restoreGetConfiguration()
+
+	res, err := notebookService.CreateNotebook(ctxWithValue, req)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.InvalidArgument, "storage class gp2 is not allowed"))
+}
+
+func TestCreateNotebookStorageClassWithExistingPvc(t *testing.T) {
+	req := &controller.CreateNotebookRequest{
+		Name:       "notebook-storage",
+		Pvc:        stringPtr("shared-data"),
+		AccessMode: controller.VolumeAccessMode_READ_WRITE_MANY.Enum(),
+	}
+
+	res, err := notebookService.CreateNotebook(ctxWithValue, req)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.InvalidArgument, "storage class and access mode only apply to new volumes"))
+}
+
+func TestRenderNotebookDefaultStorage(t *testing.T) {
+	req := &controller.CreateNotebookRequest{Name: "notebook-storage"}
+
+	restoreGetConfiguration := mockStorageConfiguration("standard")
+	defer restoreGetConfiguration()
+
+	_, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
+
+	_, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	res, err := notebookService.RenderNotebook(ctxWithValue, req)
+
+	assert.Nil(t, err)
+	assert.Contains(t, *res.PvcManifest, "storageClassName: standard")
+	assert.Contains(t, *res.PvcManifest, "- ReadWriteOnce")
+}
+
+func TestRenderNotebookSelectedStorage(t *testing.T) {
+	req := &controller.CreateNotebookRequest{
+		Name:         "notebook-storage",
+		StorageClass: stringPtr("nfs"),
+		AccessMode:   controller.VolumeAccessMode_READ_WRITE_MANY.Enum(),
+	}
+
+	restoreGetConfiguration := mockStorageConfiguration("standard", "standard", "nfs")
+	defer restoreGetConfiguration()
+
+	_, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
+
+	_, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	res, err := notebookService.RenderNotebook(ctxWithValue, req)
+
+	assert.Nil(t, err)
+	assert.Contains(t, *res.PvcManifest, "storageClassName: nfs")
+	assert.Contains(t, *res.PvcManifest, "- ReadWriteMany")
+}
+
+func TestRenderNotebookClusterDefaultStorageClass(t *testing.T) {
+	req := &controller.CreateNotebookRequest{Name: "notebook-storage"}
+
+	_, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
+
+	_, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	res, err := notebookService.RenderNotebook(ctxWithValue, req)
+
+	assert.Nil(t, err)
+	assert.NotContains(t, *res.PvcManifest, "storageClassName")
+}
+
+func TestCreateNotebookRetentionWithExistingPvc(t *testing.T) {
+	req := &controller.CreateNotebookRequest{
+		Name:      "notebook-storage",
+		Pvc:       stringPtr("shared-data"),
+		Retention: &controller.VolumeRetention{Policy: controller.VolumeRetentionPolicy_RETAIN_VOLUME},
+	}
+
+	res, err := notebookService.CreateNotebook(ctxWithValue, req)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, status.Error(codes.InvalidArgument, "retention only applies to new volumes"))
+}
+
+func TestCreateNotebookInvalidRetention(t *testing.T) {
+	for retention, message := range map[*controller.VolumeRetention]string{
+		{Policy: controller.VolumeRetentionPolicy_RETAIN_VOLUME_FOR}:      "the RETAIN_VOLUME_FOR policy requires retention days",
+		{Policy: controller.VolumeRetentionPolicy_DELETE_VOLUME, Days: 3}: "retention days only apply to the RETAIN_VOLUME_FOR policy",
+		{Policy: controller.VolumeRetentionPolicy(7)}:                     "invalid retention policy: 7",
+	} {
+		req := &controller.CreateNotebookRequest{Name: "notebook-storage", Retention: retention}
+
+		res, err := notebookService.CreateNotebook(ctxWithValue, req)
+
+		assert.Nil(t, res)
+		assert.ErrorIs(t, err, status.Error(codes.InvalidArgument, message))
+	}
+}
+
+func TestRenderNotebookRetention(t *testing.T) {
+	req := &controller.CreateNotebookRequest{
+		Name:      "notebook-storage",
+		Retention: &controller.VolumeRetention{Policy: controller.VolumeRetentionPolicy_RETAIN_VOLUME_FOR, Days: 14},
+	}
+
+	_, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
+
+	_, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	res, err := notebookService.RenderNotebook(ctxWithValue, req)
+
+	assert.Nil(t, err)
+	assert.Contains(t, *res.PvcManifest, service.RETENTION_POLICY_ANNOTATION+": retain-for")
+	assert.Contains(t, *res.PvcManifest, service.RETENTION_DAYS_ANNOTATION+`: "14"`)
+	assert.Contains(t, *res.PvcManifest, service.OWNER_ANNOTATION+": "+username)
+}
+
+func TestRenderNotebookDefaultRetention(t *testing.T) {
+	req := &controller.CreateNotebookRequest{Name: "notebook-storage"}
+
+	_, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
+
+	_, restoreClientset := useFakeClientset()
+	defer restoreClientset()
+
+	res, err := notebookService.RenderNotebook(ctxWithValue, req)
+
+	// pvc-service applies its own policy to the volumes without one
+	assert.Nil(t, err)
+	assert.NotContains(t, *res.PvcManifest, service.RETENTION_POLICY_ANNOTATION)
+	assert.Contains(t, *res.PvcManifest, service.OWNER_ANNOTATION+": "+username)
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"notebook-service/api/controller"
+	"notebook-service/internal/auth"
+	"notebook-service/internal/model"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Group of the usage of users without a team
+const UNASSIGNED_TEAM = "unassigned"
+
+// GetUsageReport sums the CPU-hours, memory-GB-hours and storage-GB-days within a date range.
+// Administrators receive the usage of every user, other users only their own.
+func (s *NotebookService) GetUsageReport(ctx context.Context, req *controller.GetUsageReportRequest) (*controller.GetUsageReportResponse, error) {
+	if req.Start == nil {
+		return nil, status.Error(codes.InvalidArgument, "start of the report is required")
+	}
+
+	now := time.Now()
+	start := req.Start.AsTime()
+	end := now
+	if req.End != nil {
+		end = req.End.AsTime()
+	}
+	if !end.After(start) {
+		return nil, status.Error(codes.InvalidArgument, "end of the report must be after its start")
+	}
+
+	var username *string
+	if auth.GetRole(ctx) != auth.ADMIN {
+		caller := ctx.Value(auth.CtxKey).(string)
+		username = &caller
+	}
+
+	intervals, err := s.usage.FindUsageIntervals(ctx, start, end, username)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	totals := aggregateUsage(intervals, start, end, now, usageGroupKey(req.GroupBy))
+
+	response := &controller.GetUsageReportResponse{}
+	for group, total := range totals {
+		response.Rows = append(response.Rows, &controller.UsageReportRow{
+			Group:         group,
+			CpuHours:      total.cpuHours,
+			MemoryGbHours: total.memoryGBHours,
+			StorageGbDays: total.storageGBDays,
+		})
+	}
+	sort.Slice(response.Rows, func(i, j int) bool {
+		return response.Rows[i].Group < response.Rows[j].Group
+	})
+
+	if req.Csv {
+		report, err := renderUsageCsv(req.GroupBy, response.Rows)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed rendering usage report: %v", err)
+		}
+		response.Csv = &report
+	}
+
+	return response, nil
+}
+
+func usageGroupKey(grouping controller.UsageGrouping) func(model.UsageInterval) string {
+	switch grouping {
+	case controller.UsageGrouping_BY_TEAM:
+		return func(interval model.UsageInterval) string {
+			if interval.Team == "" {
+				return UNASSIGNED_TEAM
+			}
+			return interval.Team
+		}
+	case controller.UsageGrouping_BY_NOTEBOOK:
+		return func(interval model.UsageInterval) string {
+			return interval.NotebookName
+		}
+	default:
+		return func(interval model.UsageInterval) string {
+			return interval.Username
+		}
+	}
+}
+
+// Renders the rows as CSV with a header named after the grouping, e.g. user,cpu_hours,...
+func renderUsageCsv(grouping controller.UsageGrouping, rows []*controller.UsageReportRow) (string, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+
+	groupColumn := strings.ToLower(strings.TrimPrefix(grouping.String(), "BY_"))
+	err := writer.Write([]string{groupColumn, "cpu_hours", "memory_gb_hours", "storage_gb_days"})
+	if err != nil {
+		return "", err
+	}
+
+	for _, row := range rows {
+		err = writer.Write([]string{
+			row.Group,
+			strconv.FormatFloat(row.CpuHours, 'f', 3, 64),
+			strconv.FormatFloat(row.MemoryGbHours, 'f', 3, 64),
+			strconv.FormatFloat(row.StorageGbDays, 'f', 3, 64),
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	writer.Flush()
+	return buffer.String(), writer.Error()
+}
@@ -0,0 +1,176 @@
+package service_test
+
+import (
+	"context"
+	"notebook-service/api/controller"
+	"notebook-service/internal/auth"
+	"notebook-service/internal/config"
+	"notebook-service/internal/model"
+	"notebook-service/internal/service"
+	"notebook-service/mocks/mock_mongo"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var reportStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+var reportEnd = reportStart.Add(24 * time.Hour)
+
+// Creates a notebook service sharing the mocks of TestMain except for the usage repo
+func createNotebookServiceWithUsage(usage *mock_mongo.MockUsage) controller.NotebookServiceServer {
+	return service.GenerateNotebookService(bus, redis, mongo, profiles, idempotency, operationsService, usage, kube, outboxRelay, dedup, webhookDispatcher)
+}
+
+// Asserts a usage event with the given transition was recorded for the notebook
+func assertUsageRecorded(t *testing.T, notebookName string, transition string) {
+	usage.AssertCalled(t, "InsertUsageEvent", mock.MatchedBy(func(event *model.UsageEvent) bool {
+		return event.NotebookName == notebookName && event.Transition == transition
+	}))
+}
+
+// Intervals partially overlapping the report range, one of them still open
+func createUsageIntervals() []model.UsageInterval {
+	aliceEnd := reportStart.Add(6 * time.Hour)
+	bobEnd := reportEnd.Add(12 * time.Hour)
+
+	return []model.UsageInterval{
+		{NotebookName: "alice-notebook", Username: "alice", Team: "data", Kind: model.USAGE_COMPUTE, Cpu: 2, MemoryGB: 4, Start: reportStart.Add(-12 * time.Hour), End: &aliceEnd},
+		{NotebookName: "alice-notebook", Username: "alice", Team: "data", Kind: model.USAGE_STORAGE, StorageGB: 48, Start: reportStart},
+		{NotebookName: "bob-notebook", Username: "bob", Kind: model.USAGE_COMPUTE, Cpu: 1, MemoryGB: 2, Start: reportStart.Add(12 * time.Hour), End: &bobEnd},
+	}
+}
+
+func TestGetUsageReportInvalidRange(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *controller.GetUsageReportRequest
+		err  error
+	}{
+		{"missing start", &controller.GetUsageReportRequest{End: timestamppb.New(reportEnd)}, status.Error(codes.InvalidArgument, "start of the report is required")},
+		{"end before start", &controller.GetUsageReportRequest{Start: timestamppb.New(reportEnd), End: timestamppb.New(reportStart)}, status.Error(codes.InvalidArgument, "end of the report must be after its start")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := notebookService.GetUsageReport(adminCtx, tt.req)
+			assert.Nil(t, res)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestGetUsageReportByUser(t *testing.T) {
+	usage := new(mock_mongo.MockUsage)
+	usage.On("FindUsageIntervals", reportStart, reportEnd, (*string)(nil)).Return(createUsageIntervals(), nil).Once()
+
+	req := &controller.GetUsageReportRequest{Start: timestamppb.New(reportStart), End: timestamppb.New(reportEnd)}
+	res, err := createNotebookServiceWithUsage(usage).GetUsageReport(adminCtx, req)
+
+	assert.Nil(t, err)
+	assert.Nil(t, res.Csv)
+	assert.Len(t, res.Rows, 2)
+	assert.Equal(t, &controller.UsageReportRow{Group: "alice", CpuHours: 12, MemoryGbHours: 24, StorageGbDays: 48}, res.Rows[0])
+	assert.Equal(t, &controller.UsageReportRow{Group: "bob", CpuHours: 12, MemoryGbHours: 24}, res.Rows[1])
+	usage.AssertExpectations(t)
+}
+
+func TestGetUsageReportByTeamCsv(t *testing.T) {
+	usage := new(mock_mongo.MockUsage)
+	usage.On("FindUsageIntervals", reportStart, reportEnd, (*string)(nil)).Return(createUsageIntervals(), nil).Once()
+
+	req := &controller.GetUsageReportRequest{
+		Start:   timestamppb.New(reportStart),
+		End:     timestamppb.New(reportEnd),
+		GroupBy: controller.UsageGrouping_BY_TEAM,
+		Csv:     true,
+	}
+	res, err := createNotebookServiceWithUsage(usage).GetUsageReport(adminCtx, req)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "data", res.Rows[0].Group)
+	assert.Equal(t, service.UNASSIGNED_TEAM, res.Rows[1].Group)
+	assert.Equal(t, "team,cpu_hours,memory_gb_hours,storage_gb_days\n"+
+		"data,12.000,24.000,48.000\n"+
+		"unassigned,12.000,24.000,0.000\n", *res.Csv)
+}
+
+func TestGetUsageReportOnlyOwnUsage(t *testing.T) {
+	usage := new(mock_mongo.MockUsage)
+	usage.On("FindUsageIntervals", reportStart, reportEnd, mock.MatchedBy(func(caller *string) bool {
+		return caller != nil && *caller == username
+	})).Return([]model.UsageInterval{}, nil).Once()
+
+	req := &controller.GetUsageReportRequest{Start: timestamppb.New(reportStart), End: timestamppb.New(reportEnd)}
+	res, err := createNotebookServiceWithUsage(usage).GetUsageReport(dataScientistCtx, req)
+
+	assert.Nil(t, err)
+	assert.Empty(t, res.Rows)
+	usage.AssertExpectations(t)
+}
+
+// Signs a token with the claims issued by token-service
+func issueToken(t *testing.T, username, role, team string) string {
+	claims := jwt.MapClaims{
+		"iss":          username,
+		"role":         role,
+		"team":         team,
+		"expired_time": time.Now().Add(time.Hour).Unix(),
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.Get().Auth.SecretKey))
+	assert.Nil(t, err)
+	return token
+}
+
+func TestGetUsageReportByTeamOfTheIssuedToken(t *testing.T) {
+	req := &controller.CreateNotebookRequest{Name: "notebook-team"}
+
+	_, restoreDynamicClient := useFakeDynamicClient()
+	defer restoreDynamicClient()
+	restoreCreatePVCResource := mockCreatePvcResource(pvc, nil)
+	defer restoreCreatePVCResource()
+
+	mongo.On("CreateNotebook", &model.NotebookEntity{Username: username, NotebookName: req.Name}).Return(nil).Once()
+	redis.On("CheckCacheExists", username).Return(false, nil).Once()
+
+	// Create the notebook with the token of the user, through the authentication of the server
+	md := metadata.Pairs("authorization", "Bearer "+issueToken(t, username, auth.DS, "data-science"))
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	info := &grpc.UnaryServerInfo{FullMethod: controller.NotebookService_CreateNotebook_FullMethodName}
+	_, err := auth.AuthInterceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return notebookService.CreateNotebook(ctx, req.(*controller.CreateNotebookRequest))
+	})
+	assert.Nil(t, err)
+
+	// Report the compute interval the creation opened, charged to the team of the token
+	var opened []model.UsageInterval
+	for _, call := range usage.Calls {
+		if interval, ok := call.Arguments.Get(0).(*model.UsageInterval); ok && interval.NotebookName == req.Name && interval.Kind == model.USAGE_COMPUTE {
+			opened = append(opened, *interval)
+		}
+	}
+	assert.Len(t, opened, 1)
+
+	start, end := opened[0].Start.Add(-time.Hour), time.Now()
+	reportUsage := new(mock_mongo.MockUsage)
+	reportUsage.On("FindUsageIntervals", mock.Anything, mock.Anything, (*string)(nil)).Return(opened, nil).Once()
+
+	res, err := createNotebookServiceWithUsage(reportUsage).GetUsageReport(adminCtx, &controller.GetUsageReportRequest{
+		Start:   timestamppb.New(start),
+		End:     timestamppb.New(end),
+		GroupBy: controller.UsageGrouping_BY_TEAM,
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, res.Rows, 1)
+	assert.Equal(t, "data-science", res.Rows[0].Group)
+	assert.Greater(t, res.Rows[0].CpuHours, float64(0))
+}
@@ -0,0 +1,460 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"notebook-service/api/controller"
+	"notebook-service/api/events"
+	"notebook-service/internal/config"
+	"notebook-service/internal/model"
+	"notebook-service/internal/rabbitmq"
+	"notebook-service/internal/service"
+	"notebook-service/mocks/mock_mongo"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var webhookStopKey = rabbitmq.GenerateRoutingKey(rabbitmq.NOTEBOOK, rabbitmq.STOP)
+
+var webhookConfig = config.WebhooksConfig{
+	PollInterval: time.Second,
+	BatchSize:    10,
+	Timeout:      time.Second,
+	MaxAttempts:  3,
+	RetryDelay:   time.Minute,
+	Retention:    time.Hour,
+}
+
+// Hosts of the webhooks, refusing every internal host
+var webhookHosts = service.NewWebhookHosts(nil)
+
+func createWebhookDispatcher(repo *mock_mongo.MockWebhooks) *service.WebhookDispatcher {
+	return service.GenerateWebhookDispatcher(repo, http.DefaultClient, webhookConfig)
+}
+
+func createWebhook(id, owner, url string) *model.Webhook {
+	return &model.Webhook{ID: id, Owner: owner, URL: url, Secret: "signing-secret", CreatedAt: time.Now()}
+}
+
+// Starts an endpoint answering the given statuses in turn, returning the requests it received
+func startWebhookEndpoint(t *testing.T, statuses ...int) (*httptest.Server, *[]*http.Request, *[][]byte) {
+	var requests []*http.Request
+	var bodies [][]byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.Nil(t, err)
+		requests = append(requests, r)
+		bodies = append(bodies, body)
+		w.WriteHeader(statuses[min(len(requests), len(statuses))-1])
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests, &bodies
+}
+
+func createDueDelivery(t *testing.T, webhook *model.Webhook, attempts int) model.WebhookDelivery {
+	event, err := rabbitmq.NewEvent(webhookStopKey, username, &events.NotebookStopped{NotebookName: "notebook-webhook"})
+	assert.Nil(t, err)
+
+	repo := new(mock_mongo.MockWebhooks)
+	repo.On("FindSubscribedWebhooks", webhookStopKey).Return([]model.Webhook{*webhook}, nil)
+	repo.On("AddDeliveries", mock.Anything).Return(nil)
+	assert.Nil(t, createWebhookDispatcher(repo).Enqueue(context.Background(), event))
+
+	delivery := repo.Calls[1].Arguments.Get(0).([]model.WebhookDelivery)[0]
+	delivery.Attempts = attempts
+	return delivery
+}
+
+// Runs the dispatcher on the delivery and returns it as stored after the attempt
+func deliverOnce(t *testing.T, repo *mock_mongo.MockWebhooks, delivery model.WebhookDelivery) *model.WebhookDelivery {
+	repo.On("FindDueDeliveries", int64(webhookConfig.BatchSize)).Return([]model.WebhookDelivery{delivery}, nil).Once()
+	repo.On("ClaimDelivery", delivery.ID).Return(true, nil).Once()
+	repo.On("UpdateDelivery", mock.Anything).Return(nil).Once()
+
+	createWebhookDispatcher(repo).Deliver(context.Background())
+
+	return repo.Calls[len(repo.Calls)-1].Arguments.Get(0).(*model.WebhookDelivery)
+}
+
+func TestCreateWebhookInvalidArgument(t *testing.T) {
+	webhooksService := service.GenerateWebhooksService(new(mock_mongo.MockWebhooks), nil, webhookHosts)
+
+	for _, test := range []struct {
+		req     *controller.CreateWebhookRequest
+		message string
+	}{
+		{&controller.CreateWebhookRequest{Url: "hooks.example.com", Secret: "s"}, "url must be an absolute http or https URL"},
+		{&controller.CreateWebhookRequest{Url: "ftp://hooks.example.com", Secret: "s"}, "url must be an absolute http or https URL"},
+		{&controller.CreateWebhookRequest{Url: "https://hooks.example.com"}, "secret is required"},
+		{&controller.CreateWebhookRequest{Url: "https://hooks.example.com", Secret: "s", EventTypes: []string{"NOTEBOOK.EXPLODE"}}, "unknown event type NOTEBOOK.EXPLODE"},
+	} {
+		_, err := webhooksService.CreateWebhook(ctxWithValue, test.req)
+		assert.ErrorIs(t, err, status.Error(codes.InvalidArgument, test.message))
+	}
+}
+
+func TestCreateGlobalWebhookReservedToAdministrators(t *testing.T) {
+	repo := new(mock_mongo.MockWebhooks)
+	repo.On("CreateWebhook", mock.Anything).Return(nil).Once()
+	webhooksService := service.GenerateWebhooksService(repo, nil, webhookHosts)
+	req := &controller.CreateWebhookRequest{Url: "https://hooks.example.com", Secret: "s", Global: true}
+
+	_, err := webhooksService.CreateWebhook(ctxWithValue, req)
+	assert.ErrorIs(t, err, status.Error(codes.PermissionDenied, "only administrators can register global webhooks"))
+
+	webhook, err := webhooksService.CreateWebhook(adminCtx, req)
+	assert.Nil(t, err)
+	assert.True(t, webhook.Global)
+}
+
+func TestCreateWebhook(t *testing.T) {
+	repo := new(mock_mongo.MockWebhooks)
+	repo.On("CreateWebhook", mock.Anything).Return(nil).Once()
+	webhooksService := service.GenerateWebhooksService(repo, nil, webhookHosts)
+
+	webhook, err := webhooksService.CreateWebhook(ctxWithValue, &controller.CreateWebhookRequest{
+		Url:        "https://hooks.example.com/notebooks",
+		EventTypes: []string{"NOTEBOOK.STOP", "PVC.EXPIRE"},
+		Secret:     "signing-secret",
+	})
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, webhook.Id)
+	assert.Equal(t, username, webhook.Owner)
+	assert.Equal(t, []string{"NOTEBOOK.STOP", "PVC.EXPIRE"}, webhook.EventTypes)
+
+	stored := repo.Calls[0].Arguments.Get(0).(*model.Webhook)
+	assert.Equal(t, webhook.Id, stored.ID)
+	assert.Equal(t, "signing-secret", stored.Secret)
+}
+
+func TestCreateWebhookRefusesInternalHosts(t *testing.T) {
+	repo := new(mock_mongo.MockWebhooks)
+	webhooksService := service.GenerateWebhooksService(repo, nil, webhookHosts)
+
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hooks",
+		"http://localhost/hooks",
+		"http://[::1]/hooks",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.5/hooks",
+		"http://192.168.1.10/hooks",
+		"http://pvc-service:50052",
+		"http://notebook-service.kubeflow.svc.cluster.local/hooks",
+	} {
+		_, err := webhooksService.CreateWebhook(ctxWithValue, &controller.CreateWebhookRequest{Url: url, Secret: "s"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), url)
+		assert.ErrorContains(t, err, "url can't target an internal host", url)
+	}
+	repo.AssertNotCalled(t, "CreateWebhook", mock.Anything)
+}
+
+func TestCreateWebhookOnAllowedInternalHosts(t *testing.T) {
+	repo := new(mock_mongo.MockWebhooks)
+	repo.On("CreateWebhook", mock.Anything).Return(nil).Times(2)
+	hosts := service.NewWebhookHosts([]string{"hooks.kubeflow.svc.cluster.local", "10.0.0.0/8"})
+	webhooksService := service.GenerateWebhooksService(repo, nil, hosts)
+
+	_, err := webhooksService.CreateWebhook(ctxWithValue, &controller.CreateWebhookRequest{Url: "http://hooks.kubeflow.svc.cluster.local/notebooks", Secret: "s"})
+	assert.Nil(t, err)
+	_, err = webhooksService.CreateWebhook(ctxWithValue, &controller.CreateWebhookRequest{Url: "http://10.0.0.5/notebooks", Secret: "s"})
+	assert.Nil(t, err)
+
+	_, err = webhooksService.CreateWebhook(ctxWithValue, &controller.CreateWebhookRequest{Url: "http://192.168.1.10/notebooks", Secret: "s"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	repo.AssertExpectations(t)
+}
+
+func TestListWebhooksOfTheCaller(t *testing.T) {
+	repo := new(mock_mongo.MockWebhooks)
+	repo.On("ListWebhooks", username).Return([]model.Webhook{*createWebhook("webhook-1", username, "https://hooks.example.com")}, nil).Once()
+	repo.On("ListWebhooks", "").Return([]model.Webhook{}, nil).Once()
+	webhooksService := service.GenerateWebhooksService(repo, nil, webhookHosts)
+
+	res, err := webhooksService.ListWebhooks(ctxWithValue, &controller.ListWebhooksRequest{})
+	assert.Nil(t, err)
+	assert.Len(t, res.Webhooks, 1)
+	assert.Equal(t, "webhook-1", res.Webhooks[0].Id)
+
+	// Administrators list every webhook
+	_, err = webhooksService.ListWebhooks(adminCtx, &controller.ListWebhooksRequest{})
+	assert.Nil(t, err)
+	repo.AssertExpectations(t)
+}
+
+func TestDeleteWebhookOfOtherUser(t *testing.T) {
+	repo := new(mock_mongo.MockWebhooks)
+	repo.On("GetWebhook", "webhook-1").Return(createWebhook("webhook-1", "other", "https://hooks.example.com"), nil)
+	repo.On("DeleteWebhook", "webhook-1").Return(true, nil).Once()
+	webhooksService := service.GenerateWebhooksService(repo, nil, webhookHosts)
+
+	_, err := webhooksService.DeleteWebhook(ctxWithValue, &controller.DeleteWebhookRequest{Id: "webhook-1"})
+	assert.ErrorIs(t, err, status.Error(codes.NotFound, "webhook webhook-1 not found"))
+	repo.AssertNotCalled(t, "DeleteWebhook", "webhook-1")
+
+	_, err = webhooksService.DeleteWebhook(adminCtx, &controller.DeleteWebhookRequest{Id: "webhook-1"})
+	assert.Nil(t, err)
+}
+
+func TestListWebhookDeliveries(t *testing.T) {
+	webhook := createWebhook("webhook-1", username, "https://hooks.example.com")
+	delivery := createDueDelivery(t, webhook, 0)
+
+	repo := new(mock_mongo.MockWebhooks)
+	repo.On("GetWebhook", webhook.ID).Return(webhook, nil)
+	repo.On("ListDeliveries", webhook.ID, int64(service.DEFAULT_WEBHOOK_DELIVERY_LIMIT)).Return([]model.WebhookDelivery{delivery}, nil).Once()
+	webhooksService := service.GenerateWebhooksService(repo, nil, webhookHosts)
+
+	res, err := webhooksService.ListWebhookDeliveries(ctxWithValue, &controller.ListWebhookDeliveriesRequest{WebhookId: webhook.ID})
+
+	assert.Nil(t, err)
+	assert.Len(t, res.Deliveries, 1)
+	assert.Equal(t, controller.WebhookDeliveryState_DELIVERY_PENDING, res.Deliveries[0].State)
+	assert.Equal(t, webhookStopKey, res.Deliveries[0].EventType)
+	assert.NotNil(t, res.Deliveries[0].NextAttemptAt)
+
+	_, err = webhooksService.ListWebhookDeliveries(ctxWithValue, &controller.ListWebhookDeliveriesRequest{WebhookId: webhook.ID, Limit: -1})
+	assert.ErrorIs(t, err, status.Error(codes.InvalidArgument, "invalid limit"))
+}
+
+func TestTestWebhookSignsTheDelivery(t *testing.T) {
+	server, requests, bodies := startWebhookEndpoint(t, http.StatusNoContent)
+	webhook := createWebhook("webhook-1", username, server.URL)
+
+	repo := new(mock_mongo.MockWebhooks)
+	repo.On("GetWebhook", webhook.ID).Return(webhook, nil)
+	repo.On("AddDeliveries", mock.Anything).Return(nil).Once()
+	webhooksService := service.GenerateWebhooksService(repo, createWebhookDispatcher(repo), webhookHosts)
+
+	delivery, err := webhooksService.TestWebhook(ctxWithValue, &controller.TestWebhookRequest{Id: webhook.ID})
+
+	assert.Nil(t, err)
+	assert.Equal(t, controller.WebhookDeliveryState_DELIVERY_SUCCEEDED, delivery.State)
+	assert.Equal(t, int32(http.StatusNoContent), delivery.ResponseStatus)
+	assert.Equal(t, "WEBHOOK.TEST", delivery.EventType)
+
+	// The endpoint can check the signature with the secret
+	assert.Len(t, *requests, 1)
+	request, body := (*requests)[0], (*bodies)[0]
+	timestamp := request.Header.Get(service.WEBHOOK_TIMESTAMP_HEADER)
+	assert.Equal(t, service.SignWebhookPayload("signing-secret", timestamp, body), request.Header.Get(service.WEBHOOK_SIGNATURE_HEADER))
+	assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+	assert.Equal(t, webhook.ID, request.Header.Get(service.WEBHOOK_ID_HEADER))
+
+	event := &events.Envelope{}
+	assert.Nil(t, protojson.Unmarshal(body, event))
+	assert.Equal(t, webhook.ID, event.Subject)
+	assert.Equal(t, delivery.EventId, event.Id)
+
+	// The test delivery is kept in the log
+	logged := repo.Calls[1].Arguments.Get(0).([]model.WebhookDelivery)
+	assert.Equal(t, model.DELIVERY_SUCCEEDED, logged[0].State)
+}
+
+func TestTestWebhookReportsTheFailure(t *testing.T) {
+	server, _, _ := startWebhookEndpoint(t, http.StatusInternalServerError)
+	webhook := createWebhook("webhook-1", username, server.URL)
+
+	repo := new(mock_mongo.MockWebhooks)
+	repo.On("GetWebhook", webhook.ID).Return(webhook, nil)
+	repo.On("AddDeliveries", mock.Anything).Return(nil).Once()
+	webhooksService := service.GenerateWebhooksService(repo, createWebhookDispatcher(repo), webhookHosts)
+
+	delivery, err := webhooksService.TestWebhook(ctxWithValue, &controller.TestWebhookRequest{Id: webhook.ID})
+
+	assert.Nil(t, err)
+	assert.Equal(t, controller.WebhookDeliveryState_DELIVERY_FAILED, delivery.State)
+	assert.Equal(t, int32(http.StatusInternalServerError), delivery.ResponseStatus)
+	assert.Equal(t, "endpoint answered 500 Internal Server Error", delivery.LastError)
+}
+
+func TestWebhookClientRefusesInternalAddresses(t *testing.T) {
+	server, requests, _ := startWebhookEndpoint(t, http.StatusNoContent)
+	// A public looking name resolving to an internal address, as after a DNS rebinding
+	webhook := createWebhook("webhook-1", username, strings.Replace(server.URL, "127.0.0.1", "localhost", 1))
+
+	repo := new(mock_mongo.MockWebhooks)
+	repo.On("AddDeliveries", mock.Anything).Return(nil).Times(2)
+
+	dispatcher := service.GenerateWebhookDispatcher(repo, webhookHosts.Client(), webhookConfig)
+	delivery, err := dispatcher.Test(context.Background(), webhook, username)
+	assert.Nil(t, err)
+	assert.Equal(t, model.DELIVERY_FAILED, delivery.State)
+	assert.Contains(t, delivery.LastError, "is internal")
+	assert.Empty(t, *requests)
+
+	// Delivered once the administrators allowed the addresses
+	allowed := service.NewWebhookHosts([]string{"127.0.0.0/8", "::1"})
+	dispatcher = service.GenerateWebhookDispatcher(repo, allowed.Client(), webhookConfig)
+	delivery, err = dispatcher.Test(context.Background(), webhook, username)
+	assert.Nil(t, err)
+	assert.Equal(t, model.DELIVERY_SUCCEEDED, delivery.State)
+	assert.Len(t, *requests, 1)
+}
+
+func TestWebhookDispatcherEnqueuesTheEventsOfTheOwner(t *testing.T) {
+	global := createWebhook("webhook-global", "admin", "https://hooks.example.com/all")
+	global.Global = true
+
+	repo := new(mock_mongo.MockWebhooks)
+	repo.On("FindSubscribedWebhooks", rabbitmq.GenerateRoutingKey(rabbitmq.PVC, rabbitmq.EXPIRE)).Return([]model.Webhook{
+		*createWebhook("webhook-owner", "alice", "https://hooks.example.com/alice"),
+		*createWebhook("webhook-other", "bob", "https://hooks.example.com/bob"),
+		*global,
+	}, nil).Once()
+	repo.On("AddDeliveries", mock.Anything).Return(nil).Once()
+
+	// The volume is expired by the platform, the owner is carried by the data
+	event, err := rabbitmq.NewEvent(rabbitmq.GenerateRoutingKey(rabbitmq.PVC, rabbitmq.EXPIRE), "pvc-service", &events.PvcExpiring{PvcName: "workspace-a", Owner: "alice"})
+	assert.Nil(t, err)
+
+	assert.Nil(t, createWebhookDispatcher(repo).Enqueue(context.Background(), event))
+
+	deliveries := repo.Calls[1].Arguments.Get(0).([]model.WebhookDelivery)
+	assert.Len(t, deliveries, 2)
+	assert.Equal(t, "webhook-owner", deliveries[0].WebhookID)
+	assert.Equal(t, "webhook-global", deliveries[1].WebhookID)
+	assert.Equal(t, event.Id+":webhook-owner", deliveries[0].ID)
+	assert.Equal(t, model.DELIVERY_PENDING, deliveries[0].State)
+}
+
+func TestWebhookMiddlewareSkipsFailedEvents(t *testing.T) {
+	repo := new(mock_mongo.MockWebhooks)
+	repo.On("FindSubscribedWebhooks", webhookStopKey).Return([]model.Webhook{*createWebhook("webhook-1", username, "https://hooks.example.com")}, nil)
+	repo.On("AddDeliveries", mock.Anything).Return(errors.New("mongo error")).Once()
+	handler := createWebhookDispatcher(repo).Middleware()
+
+	event, err := rabbitmq.NewEvent(webhookStopKey, username, &events.NotebookStopped{NotebookName: "notebook-webhook"})
+	assert.Nil(t, err)
+
+	// A failed handler is retried before the event is delivered
+	err = handler(func(context.Context, *events.Envelope) error { return errors.New("handler error") })(context.Background(), event)
+	assert.EqualError(t, err, "handler error")
+	repo.AssertNotCalled(t, "FindSubscribedWebhooks", webhookStopKey)
+
+	// Failing to queue the deliveries fails the event, so it is consumed again
+	err = handler(rabbitmq.DropUnrouted)(context.Background(), event)
+	assert.EqualError(t, err, "mongo error")
+}
+
+func TestWebhookDispatcherRetriesWithBackoff(t *testing.T) {
+	server, requests, _ := startWebhookEndpoint(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK)
+	webhook := createWebhook("webhook-1", username, server.URL)
+
+	repo := new(mock_mongo.MockWebhooks)
+	repo.On("GetWebhook", webhook.ID).Return(webhook, nil)
+
+	delivery := deliverOnce(t, repo, createDueDelivery(t, webhook, 0))
+	assert.Equal(t, model.DELIVERY_PENDING, delivery.State)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, delivery.ResponseStatus)
+	assert.WithinDuration(t, time.Now().Add(webhookConfig.RetryDelay), delivery.NextAttemptAt, time.Second)
+
+	// The delay doubles on each retry
+	delivery = deliverOnce(t, repo, *delivery)
+	assert.Equal(t, 2, delivery.Attempts)
+	assert.WithinDuration(t, time.Now().Add(2*webhookConfig.RetryDelay), delivery.NextAttemptAt, time.Second)
+
+	delivery = deliverOnce(t, repo, *delivery)
+	assert.Equal(t, model.DELIVERY_SUCCEEDED, delivery.State)
+	assert.Equal(t, 3, delivery.Attempts)
+	assert.Empty(t, delivery.LastError)
+	assert.NotNil(t, delivery.FinishedAt)
+	assert.Len(t, *requests, 3)
+	assert.Equal(t, delivery.EventID, (*requests)[2].Header.Get(service.WEBHOOK_EVENT_ID_HEADER))
+	assert.Equal(t, webhookStopKey, (*requests)[2].Header.Get(service.WEBHOOK_EVENT_HEADER))
+}
+
+func TestWebhookDispatcherGivesUpAfterTheLastAttempt(t *testing.T) {
+	server, _, _ := startWebhookEndpoint(t, http.StatusBadGateway)
+	webhook := createWebhook("webhook-1", username, server.URL)
+
+	repo := new(mock_mongo.MockWebhooks)
+	repo.On("GetWebhook", webhook.ID).Return(webhook, nil)
+
+	delivery := deliverOnce(t, repo, createDueDelivery(t, webhook, webhookConfig.MaxAttempts-1))
+
+	assert.Equal(t, model.DELIVERY_FAILED, delivery.State)
+	assert.Equal(t, webhookConfig.MaxAttempts, delivery.Attempts)
+	assert.Equal(t, "endpoint answered 502 Bad Gateway", delivery.LastError)
+	assert.NotNil(t, delivery.FinishedAt)
+}
+
+func TestWebhookDispatcherDropsDeliveriesOfDeletedWebhooks(t *testing.T) {
+	webhook := createWebhook("webhook-1", username, "https://hooks.example.com")
+
+	repo := new(mock_mongo.MockWebhooks)
+	repo.On("GetWebhook", webhook.ID).Return(nil, nil)
+
+	delivery := deliverOnce(t, repo, createDueDelivery(t, webhook, 0))
+
+	assert.Equal(t, model.DELIVERY_FAILED, delivery.State)
+	assert.Equal(t, 0, delivery.Attempts)
+	assert.Equal(t, "webhook was deleted", delivery.LastError)
+}
+
+func TestWebhookDispatcherSkipsDeliveriesClaimedByAnotherInstance(t *testing.T) {
+	server, requests, _ := startWebhookEndpoint(t, http.StatusOK)
+	webhook := createWebhook("webhook-1", username, server.URL)
+	delivery := createDueDelivery(t, webhook, 0)
+
+	repo := new(mock_mongo.MockWebhooks)
+	repo.On("FindDueDeliveries", int64(webhookConfig.BatchSize)).Return([]model.WebhookDelivery{delivery}, nil).Once()
+	repo.On("ClaimDelivery", delivery.ID).Return(false, nil).Once()
+
+	createWebhookDispatcher(repo).Deliver(context.Background())
+
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "UpdateDelivery", mock.Anything)
+	assert.Empty(t, *requests)
+}
+
+func TestWebhookDispatcherLeasesTheDeliveriesItAttempts(t *testing.T) {
+	webhook := createWebhook("webhook-1", username, "https://hooks.example.com")
+
+	repo := new(mock_mongo.MockWebhooks)
+	repo.On("GetWebhook", webhook.ID).Return(nil, nil)
+
+	// The outcome is stored under the lease, which the repository checks and releases
+	delivery := deliverOnce(t, repo, createDueDelivery(t, webhook, 0))
+	assert.NotEmpty(t, delivery.LeaseOwner)
+	assert.WithinDuration(t, time.Now().Add(webhookConfig.Timeout+service.WEBHOOK_LEASE_MARGIN), *delivery.LeaseExpiry, time.Second)
+}
+
+func TestWebhookDispatcherReceivesConsumedEvents(t *testing.T) {
+	// Events of the other services without a handler are delivered to the webhooks too
+	key := rabbitmq.GenerateRoutingKey(rabbitmq.PVC, rabbitmq.EXPIRE)
+	assert.Nil(t, bus.PublishEvent(context.Background(), key, username, &events.PvcExpiring{PvcName: "pvc-webhook"}))
+
+	webhooks.AssertCalled(t, "FindSubscribedWebhooks", key)
+}
+
+func TestWebhookDispatcherReceivesPublishedEvents(t *testing.T) {
+	key := rabbitmq.GenerateRoutingKey(rabbitmq.NOTEBOOK, rabbitmq.CREATE)
+	event, err := rabbitmq.NewEvent(key, username, &events.NotebookCreated{NotebookName: "notebook-webhook"})
+	assert.Nil(t, err)
+	envelope, err := proto.Marshal(event)
+	assert.Nil(t, err)
+
+	// The events of the service are not consumed back, the relay hands them over once published
+	outbox.On("FindPendingEvents", int64(10)).Return([]model.OutboxEvent{{ID: event.Id, Type: key, Envelope: envelope}}, nil).Once()
+	outbox.On("MarkEventSent", event.Id).Return(nil).Once()
+
+	outboxRelay.Relay(context.Background())
+
+	webhooks.AssertCalled(t, "FindSubscribedWebhooks", key)
+	outbox.AssertCalled(t, "MarkEventSent", event.Id)
+}
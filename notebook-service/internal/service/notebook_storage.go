@@ -0,0 +1,117 @@
+package service
+
+import (
+	"notebook-service/api/controller"
+	"slices"
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+)
+
+var accessModes = map[controller.VolumeAccessMode]v1.PersistentVolumeAccessMode{
+	controller.VolumeAccessMode_READ_WRITE_ONCE:     v1.ReadWriteOnce,
+	controller.VolumeAccessMode_READ_WRITE_MANY:     v1.ReadWriteMany,
+	controller.VolumeAccessMode_READ_ONLY_MANY:      v1.ReadOnlyMany,
+	controller.VolumeAccessMode_READ_WRITE_ONCE_POD: v1.ReadWriteOncePod,
+}
+
+// Annotations of a new workspace volume, read by pvc-service once the notebook is deleted
+const (
+	RETENTION_POLICY_ANNOTATION = "suedataplatform/retention-policy" // Unset uses the retention policy of pvc-service
+	RETENTION_DAYS_ANNOTATION   = "suedataplatform/retention-days"
+	OWNER_ANNOTATION            = "suedataplatform/owner" // User notified before the volume is removed
+)
+
+// Retention policies as named in the annotations of the volumes
+var retentionPolicies = map[controller.VolumeRetentionPolicy]string{
+	controller.VolumeRetentionPolicy_DELETE_VOLUME:     "delete",
+	controller.VolumeRetentionPolicy_RETAIN_VOLUME:     "retain",
+	controller.VolumeRetentionPolicy_RETAIN_VOLUME_FOR: "retain-for",
+}
+
+// Storage class, access mode and retention of a new workspace volume
+type volumeStorage struct {
+	storageClass *string // nil uses the default storage class of the cluster
+	accessMode   v1.PersistentVolumeAccessMode
+	retention    map[string]string // Annotations of the requested retention, empty uses the default one
+}
+
+// Validates the requested storage class and access mode, falling back to the platform defaults
+func resolveVolumeStorage(config Configuration, req *controller.CreateNotebookRequest) (*volumeStorage, error) {
+	if req.Pvc != nil && (req.StorageClass != nil || req.AccessMode != nil) {
+		return nil, status.Error(codes.InvalidArgument, "storage class and access mode only apply to new volumes")
+	}
+	if req.Pvc != nil && req.Retention != nil {
+		return nil, status.Error(codes.InvalidArgument, "retention only applies to new volumes")
+	}
+
+	storage := &volumeStorage{accessMode: v1.PersistentVolumeAccessMode(config.DefaultAccessMode)}
+	if storage.accessMode == "" {
+		storage.accessMode = v1.ReadWriteOnce
+	}
+
+	if req.AccessMode != nil {
+		accessMode, exists := accessModes[*req.AccessMode]
+		if !exists {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid access mode: %v", *req.AccessMode)
+		}
+		storage.accessMode = accessMode
+	}
+
+	if config.DefaultStorageClass != "" {
+		storage.storageClass = pointerToString(config.DefaultStorageClass)
+	}
+
+	if req.StorageClass != nil {
+		if len(config.AllowedStorageClasses) > 0 && !slices.Contains(config.AllowedStorageClasses, *req.StorageClass) {
+			return nil, status.Errorf(codes.InvalidArgument, "storage class %s is not allowed", *req.StorageClass)
+		}
+		storage.storageClass = req.StorageClass
+	}
+
+	if req.Retention != nil {
+		retention, err := resolveVolumeRetention(req.Retention)
+		if err != nil {
+			return nil, err
+		}
+		storage.retention = retention
+	}
+
+	return storage, nil
+}
+
+// Validates the requested retention and returns the annotations holding it
+func resolveVolumeRetention(retention *controller.VolumeRetention) (map[string]string, error) {
+	policy, exists := retentionPolicies[retention.Policy]
+	if !exists {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid retention policy: %v", retention.Policy)
+	}
+
+	if retention.Policy != controller.VolumeRetentionPolicy_RETAIN_VOLUME_FOR {
+		if retention.Days != 0 {
+			return nil, status.Error(codes.InvalidArgument, "retention days only apply to the RETAIN_VOLUME_FOR policy")
+		}
+		return map[string]string{RETENTION_POLICY_ANNOTATION: policy}, nil
+	}
+
+	if retention.Days == 0 {
+		return nil, status.Error(codes.InvalidArgument, "the RETAIN_VOLUME_FOR policy requires retention days")
+	}
+	return map[string]string{
+		RETENTION_POLICY_ANNOTATION: policy,
+		RETENTION_DAYS_ANNOTATION:   strconv.FormatUint(uint64(retention.Days), 10),
+	}, nil
+}
+
+// Returns the access mode enum matching a Kubernetes access mode
+func getVolumeAccessMode(accessMode v1.PersistentVolumeAccessMode) (controller.VolumeAccessMode, bool) {
+	for mode, kubernetesMode := range accessModes {
+		if kubernetesMode == accessMode {
+			return mode, true
+		}
+	}
+
+	return controller.VolumeAccessMode_READ_WRITE_ONCE, false
+}
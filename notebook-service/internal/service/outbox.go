@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"log"
+	"notebook-service/api/events"
+	"notebook-service/internal/config"
+	"notebook-service/internal/model"
+	"notebook-service/internal/mongo_repository"
+	"notebook-service/internal/rabbitmq"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+)
+
+// OutboxRelay publishes the events recorded in the outbox. Events are recorded with the state change they
+// announce and kept until RabbitMQ confirmed them, so they are delivered at least once even while RabbitMQ
+// is down or the service restarts.
+type OutboxRelay struct {
+	repo mongo_repository.OutboxRepository
+	rbmq rabbitmq.RabbitMQHandler
+	cfg  config.OutboxConfig
+	wake chan struct{}
+	// Called with every event once published, before it is marked sent
+	onPublished func(ctx context.Context, event *events.Envelope) error
+}
+
+func GenerateOutboxRelay(repo mongo_repository.OutboxRepository, rbmq rabbitmq.RabbitMQHandler, cfg config.OutboxConfig) *OutboxRelay {
+	return &OutboxRelay{
+		repo: repo,
+		rbmq: rbmq,
+		cfg:  cfg,
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// OnPublished sets the function called with every published event. Failing leaves the event unsent, so both
+// the publication and the call are made again on the next run.
+func (r *OutboxRelay) OnPublished(handler func(ctx context.Context, event *events.Envelope) error) {
+	r.onPublished = handler
+}
+
+// Record stores an event in the outbox, published by the relay in the background
+func (r *OutboxRelay) Record(ctx context.Context, eventType, actor string, data proto.Message) error {
+	event, err := rabbitmq.NewEvent(eventType, actor, data)
+	if err != nil {
+		return err
+	}
+
+	return r.add(ctx, event)
+}
+
+// RecordOnce stores an event in the outbox under an id derived from the key, an event already stored under it
+// being left as is. Handlers pass the id of the consumed event, so their retries and redeliveries record it once.
+func (r *OutboxRelay) RecordOnce(ctx context.Context, key, eventType, actor string, data proto.Message) error {
+	event, err := rabbitmq.NewEvent(eventType, actor, data)
+	if err != nil {
+		return err
+	}
+	event.Id = uuid.NewSHA1(uuid.NameSpaceOID, []byte(key)).String()
+
+	return r.add(ctx, event)
+}
+
+func (r *OutboxRelay) add(ctx context.Context, event *events.Envelope) error {
+	envelope, err := proto.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	err = r.repo.AddEvent(ctx, &model.OutboxEvent{
+		ID:        event.Id,
+		Type:      event.Type,
+		Envelope:  envelope,
+		CreatedAt: event.Time.AsTime(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Publish without waiting for the next poll
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Run publishes the recorded events until the context is done, at every poll interval and after every record
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.Relay(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// Relay publishes the unsent events oldest first. It stops at the first failure to keep the order of the
+// events, the remaining ones are published on the next run.
+func (r *OutboxRelay) Relay(ctx context.Context) {
+	for {
+		pending, err := r.repo.FindPendingEvents(ctx, int64(r.cfg.BatchSize))
+		if err != nil {
+			log.Printf("Failed reading the outbox: %v", err)
+			return
+		}
+
+		for _, outboxEvent := range pending {
+			if !r.publish(ctx, outboxEvent) {
+				return
+			}
+		}
+
+		if len(pending) < r.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+// Publishes an event of the outbox and marks it sent, returning whether the relay can go on
+func (r *OutboxRelay) publish(ctx context.Context, outboxEvent model.OutboxEvent) bool {
+	event := &events.Envelope{}
+	if err := proto.Unmarshal(outboxEvent.Envelope, event); err != nil {
+		// Can never be published, it is discarded so the next runs don't read it again and left for inspection
+		log.Printf("Discarding event %s of the outbox: %v", outboxEvent.ID, err)
+		if err := r.repo.MarkEventDiscarded(ctx, outboxEvent.ID, err.Error(), time.Now()); err != nil {
+			log.Printf("Failed discarding event %s of the outbox: %v", outboxEvent.ID, err)
+			return false
+		}
+		return true
+	}
+
+	if err := r.rbmq.PublishConfirmed(ctx, event); err != nil {
+		log.Printf("Failed publishing event %s of the outbox: %v", outboxEvent.ID, err)
+		r.markFailed(ctx, outboxEvent.ID, err)
+		return false
+	}
+
+	if r.onPublished != nil {
+		if err := r.onPublished(ctx, event); err != nil {
+			log.Printf("Failed handling published event %s of the outbox: %v", outboxEvent.ID, err)
+			r.markFailed(ctx, outboxEvent.ID, err)
+			return false
+		}
+	}
+
+	// Failing here publishes the event again on the next run, consumers may receive it twice
+	if err := r.repo.MarkEventSent(ctx, outboxEvent.ID, time.Now()); err != nil {
+		log.Printf("Failed marking event %s of the outbox sent: %v", outboxEvent.ID, err)
+		return false
+	}
+
+	log.Printf("Event published: %s %s (%s)", event.Type, event.Id, event.Subject)
+	return true
+}
+
+func (r *OutboxRelay) markFailed(ctx context.Context, id string, cause error) {
+	if err := r.repo.MarkEventFailed(ctx, id, cause.Error()); err != nil {
+		log.Printf("Failed recording the failure of event %s: %v", id, err)
+	}
+}
@@ -1,26 +1,108 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
+	"notebook-service/api/events"
+	"notebook-service/internal/auth"
+	"notebook-service/internal/config"
+	"notebook-service/internal/model"
+	"notebook-service/internal/rabbitmq"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
 )
 
-// PVCDeletedEvent represents the structure of the deletion event
-type PVCDeletedEvent struct {
-	PvcName string `json:"pvc_name"`
+// HandlePVCDeleted applies the volume missing policy to the notebooks mounting the deleted PVC. A notebook
+// already flagged or stopped is left as is, so the event can be retried.
+func (s *NotebookService) HandlePVCDeleted(ctx context.Context, event *events.Envelope, data *events.PvcDeleted) error {
+	client := s.kube.Dynamic.Resource(notebookGVR).Namespace(GetConfiguration().Namespace)
+
+	notebooks, err := client.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed listing notebooks: %v", err)
+	}
+
+	for _, notebookObject := range notebooks.Items {
+		var notebook model.Notebook
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(notebookObject.Object, &notebook)
+		if err != nil {
+			return fmt.Errorf("failed decoding notebook %s: %v", notebookObject.GetName(), err)
+		}
+
+		if !mountsClaim(&notebook, data.PvcName) {
+			continue
+		}
+
+		log.Printf("PVC %s deleted by %s is mounted by notebook %s", data.PvcName, event.Actor, notebook.Metadata.Name)
+		if err := s.handleVolumeMissing(ctx, client, &notebook, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-// handlePVCDeleted processes the event when a PVC is deleted
-func HandlePVCDeleted(message []byte) {
-	var event PVCDeletedEvent
-	err := json.Unmarshal(message, &event)
+// Returns whether the notebook mounts the persistent volume claim
+func mountsClaim(notebook *model.Notebook, claimName string) bool {
+	for _, volume := range notebook.Spec.Template.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == claimName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Flags a notebook whose volume is missing, announces it to its owner and stops it when the policy asks to.
+// The announcement is recorded once per deletion event, however many times the event is handled.
+func (s *NotebookService) handleVolumeMissing(ctx context.Context, client dynamic.ResourceInterface, notebook *model.Notebook, event *events.Envelope) error {
+	entity, err := s.mongoRepo.SetNotebookState(ctx, notebook.Metadata.Name, model.NOTEBOOK_VOLUME_MISSING)
 	if err != nil {
-		log.Printf("Error unmarshalling message: %v", err)
-		return
+		return err
+	}
+	if entity == nil {
+		// The notebook wasn't created through the service, no user lists it
+		log.Printf("Notebook %s is not stored, skipping it", notebook.Metadata.Name)
+		return nil
 	}
 
-	// Process the event (e.g., log it or update internal state)
-	fmt.Printf("Notebook service received notification: PVC '%s' has been deleted.\n", event.PvcName)
-	// Implement additional logic here, like updating the database or cleaning up resources.
+	// Drop the cached listing of the owner, the next listing reads the state from MongoDB
+	if err := s.redisRepo.InvalidateCache(ctx, entity.Username); err != nil {
+		return err
+	}
+
+	failed := &events.NotebookFailed{NotebookName: notebook.Metadata.Name, Owner: entity.Username, Reason: model.NOTEBOOK_VOLUME_MISSING}
+	key := rabbitmq.GenerateRoutingKey(rabbitmq.NOTEBOOK, rabbitmq.FAIL)
+	if err := s.outbox.RecordOnce(ctx, event.Id+":"+notebook.Metadata.Name, key, event.Actor, failed); err != nil {
+		return err
+	}
+
+	if GetConfiguration().VolumeMissingPolicy != config.VOLUME_MISSING_STOP {
+		return nil
+	}
+	if _, stopped := notebook.Metadata.Annotations[STOPPED_ANNOTATION]; stopped {
+		return nil
+	}
+
+	patch, err := stopPatch()
+	if err != nil {
+		return fmt.Errorf("failed encoding patch: %v", err)
+	}
+	_, err = client.Patch(ctx, notebook.Metadata.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed stopping notebook %s: %v", notebook.Metadata.Name, err)
+	}
+
+	// Meter the stop for the owner of the notebook, as if they had stopped it
+	ownerCtx := context.WithValue(ctx, auth.CtxKey, entity.Username)
+	usageEvent := newUsageEvent(ownerCtx, notebook, model.USAGE_STOP)
+	usageEvent.Timestamp = time.Now()
+	s.recordUsage(ownerCtx, usageEvent)
+
+	return nil
 }
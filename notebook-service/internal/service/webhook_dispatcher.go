@@ -0,0 +1,346 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"notebook-service/api/events"
+	"notebook-service/internal/config"
+	"notebook-service/internal/metrics"
+	"notebook-service/internal/model"
+	"notebook-service/internal/mongo_repository"
+	"notebook-service/internal/rabbitmq"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Headers of the requests delivering an event to a webhook
+const (
+	WEBHOOK_ID_HEADER        = "X-Webhook-Id"
+	WEBHOOK_EVENT_ID_HEADER  = "X-Event-Id"
+	WEBHOOK_EVENT_HEADER     = "X-Event-Type"
+	WEBHOOK_TIMESTAMP_HEADER = "X-Webhook-Timestamp"
+	WEBHOOK_SIGNATURE_HEADER = "X-Webhook-Signature"
+)
+
+// Upper bound of the delay between two attempts of a delivery
+const MAX_WEBHOOK_RETRY_DELAY = time.Hour
+
+// Time left to store the outcome of an attempt once the endpoint timed out, before the delivery can be claimed again
+const WEBHOOK_LEASE_MARGIN = time.Minute
+
+// WebhookDispatcher delivers the consumed and published events to the webhooks subscribed to them. Deliveries
+// are queued in MongoDB before the event is acknowledged or marked sent and retried with an exponential backoff,
+// so an event is delivered at least once even while an endpoint is down or the service restarts. Each attempt
+// is claimed first, so the replicas of the service don't deliver the same event twice.
+type WebhookDispatcher struct {
+	repo   mongo_repository.WebhookRepository
+	client *http.Client
+	cfg    config.WebhooksConfig
+	owner  string
+	wake   chan struct{}
+}
+
+func GenerateWebhookDispatcher(repo mongo_repository.WebhookRepository, client *http.Client, cfg config.WebhooksConfig) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		repo:   repo,
+		client: client,
+		cfg:    cfg,
+		owner:  newInstanceOwner(),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Middleware queues the deliveries of every event handled without error. Failing to queue them fails the
+// event, so it is consumed again.
+func (d *WebhookDispatcher) Middleware() rabbitmq.Middleware {
+	return func(next rabbitmq.MessageHandler) rabbitmq.MessageHandler {
+		return func(ctx context.Context, event *events.Envelope) error {
+			if err := next(ctx, event); err != nil {
+				return err
+			}
+			return d.Enqueue(ctx, event)
+		}
+	}
+}
+
+// Enqueue queues a delivery of the event for each subscribed webhook. A webhook only receives the events
+// of its owner, unless it is global.
+func (d *WebhookDispatcher) Enqueue(ctx context.Context, event *events.Envelope) error {
+	webhooks, err := d.repo.FindSubscribedWebhooks(ctx, event.Type)
+	if err != nil {
+		return err
+	}
+
+	owner := ownerOf(event)
+	var deliveries []model.WebhookDelivery
+	for _, webhook := range webhooks {
+		if !webhook.Global && webhook.Owner != owner {
+			continue
+		}
+
+		delivery, err := newWebhookDelivery(&webhook, event)
+		if err != nil {
+			return err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	if err := d.repo.AddDeliveries(ctx, deliveries); err != nil {
+		return err
+	}
+
+	// Deliver without waiting for the next poll
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Returns the user an event is about, the owner carried by its data or else the user who caused it
+func ownerOf(event *events.Envelope) string {
+	data, err := event.Data.UnmarshalNew()
+	if err != nil {
+		return event.Actor
+	}
+
+	switch data := data.(type) {
+	case *events.NotebookFailed:
+		return data.Owner
+	case *events.PvcExpiring:
+		if data.Owner != "" {
+			return data.Owner
+		}
+	}
+	return event.Actor
+}
+
+func newWebhookDelivery(webhook *model.Webhook, event *events.Envelope) (*model.WebhookDelivery, error) {
+	envelope, err := proto.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding event %s: %v", event.Id, err)
+	}
+
+	now := time.Now()
+	return &model.WebhookDelivery{
+		ID:            event.Id + ":" + webhook.ID,
+		WebhookID:     webhook.ID,
+		EventID:       event.Id,
+		EventType:     event.Type,
+		Envelope:      envelope,
+		State:         model.DELIVERY_PENDING,
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}, nil
+}
+
+// Run delivers the due deliveries until the context is done, at every poll interval and after every enqueue
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.Deliver(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// Deliver makes an attempt of every due delivery, oldest first, skipping the ones another instance claimed
+func (d *WebhookDispatcher) Deliver(ctx context.Context) {
+	for {
+		due, err := d.repo.FindDueDeliveries(ctx, time.Now(), int64(d.cfg.BatchSize))
+		if err != nil {
+			log.Printf("Failed reading the webhook deliveries: %v", err)
+			return
+		}
+
+		for i := range due {
+			delivery := &due[i]
+			claimed, err := d.claim(ctx, delivery)
+			if err != nil {
+				log.Printf("Failed claiming webhook delivery %s: %v", delivery.ID, err)
+				return
+			}
+			if !claimed {
+				continue
+			}
+
+			d.attemptDelivery(ctx, delivery)
+
+			if err := d.repo.UpdateDelivery(ctx, delivery); err != nil {
+				// Left due, the endpoint may receive the event again on the next run
+				log.Printf("Failed storing webhook delivery %s: %v", delivery.ID, err)
+				return
+			}
+		}
+
+		if len(due) < d.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+// Leases the delivery to this instance for the time of an attempt, returning false when another instance has it
+func (d *WebhookDispatcher) claim(ctx context.Context, delivery *model.WebhookDelivery) (bool, error) {
+	now := time.Now()
+	leaseExpiry := now.Add(d.cfg.Timeout + WEBHOOK_LEASE_MARGIN)
+
+	claimed, err := d.repo.ClaimDelivery(ctx, delivery.ID, d.owner, now, leaseExpiry)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	delivery.LeaseOwner = d.owner
+	delivery.LeaseExpiry = &leaseExpiry
+	return true, nil
+}
+
+// Makes an attempt of the delivery and schedules the next one when it failed
+func (d *WebhookDispatcher) attemptDelivery(ctx context.Context, delivery *model.WebhookDelivery) {
+	webhook, err := d.repo.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		log.Printf("Failed getting webhook %s: %v", delivery.WebhookID, err)
+		delivery.LastError = err.Error()
+		d.retry(delivery)
+		return
+	}
+	if webhook == nil {
+		d.finish(delivery, model.DELIVERY_FAILED)
+		delivery.LastError = "webhook was deleted"
+		return
+	}
+
+	event := &events.Envelope{}
+	if err := proto.Unmarshal(delivery.Envelope, event); err != nil {
+		// Can never be delivered, it is left in the log for inspection
+		d.finish(delivery, model.DELIVERY_FAILED)
+		delivery.LastError = fmt.Sprintf("failed decoding event: %v", err)
+		return
+	}
+
+	delivery.Attempts++
+	delivery.ResponseStatus, err = d.send(ctx, webhook, event)
+	metrics.RecordWebhookDelivery(delivery.EventType, err)
+	if err != nil {
+		log.Printf("Failed delivering event %s to webhook %s (attempt %d): %v", delivery.EventID, webhook.ID, delivery.Attempts, err)
+		delivery.LastError = err.Error()
+		d.retry(delivery)
+		return
+	}
+
+	delivery.LastError = ""
+	d.finish(delivery, model.DELIVERY_SUCCEEDED)
+}
+
+// Test sends a WEBHOOK.TEST event to the endpoint of the webhook once and logs the delivery
+func (d *WebhookDispatcher) Test(ctx context.Context, webhook *model.Webhook, actor string) (*model.WebhookDelivery, error) {
+	key := rabbitmq.GenerateRoutingKey(rabbitmq.WEBHOOK, rabbitmq.TEST)
+	event, err := rabbitmq.NewEvent(key, actor, &events.WebhookTest{WebhookId: webhook.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := newWebhookDelivery(webhook, event)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Attempts = 1
+	delivery.ResponseStatus, err = d.send(ctx, webhook, event)
+	metrics.RecordWebhookDelivery(delivery.EventType, err)
+	if err != nil {
+		delivery.LastError = err.Error()
+		d.finish(delivery, model.DELIVERY_FAILED)
+	} else {
+		d.finish(delivery, model.DELIVERY_SUCCEEDED)
+	}
+
+	if err := d.repo.AddDeliveries(ctx, []model.WebhookDelivery{*delivery}); err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// Schedules the next attempt of the delivery, doubling the delay on each attempt, or gives it up after the last one
+func (d *WebhookDispatcher) retry(delivery *model.WebhookDelivery) {
+	if delivery.Attempts >= d.cfg.MaxAttempts {
+		d.finish(delivery, model.DELIVERY_FAILED)
+		return
+	}
+
+	delay := d.cfg.RetryDelay
+	for i := 1; i < delivery.Attempts && delay < MAX_WEBHOOK_RETRY_DELAY; i++ {
+		delay *= 2
+	}
+	delivery.NextAttemptAt = time.Now().Add(min(delay, MAX_WEBHOOK_RETRY_DELAY))
+}
+
+func (d *WebhookDispatcher) finish(delivery *model.WebhookDelivery, state string) {
+	finishedAt := time.Now()
+	delivery.State = state
+	delivery.FinishedAt = &finishedAt
+}
+
+// Posts the event to the endpoint of the webhook as JSON, returning the status it answered
+func (d *WebhookDispatcher) send(ctx context.Context, webhook *model.Webhook, event *events.Envelope) (int, error) {
+	body, err := protojson.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("failed encoding event: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed creating request: %v", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WEBHOOK_ID_HEADER, webhook.ID)
+	request.Header.Set(WEBHOOK_EVENT_ID_HEADER, event.Id)
+	request.Header.Set(WEBHOOK_EVENT_HEADER, event.Type)
+	request.Header.Set(WEBHOOK_TIMESTAMP_HEADER, timestamp)
+	request.Header.Set(WEBHOOK_SIGNATURE_HEADER, SignWebhookPayload(webhook.Secret, timestamp, body))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("endpoint answered %s", response.Status)
+	}
+
+	return response.StatusCode, nil
+}
+
+// SignWebhookPayload returns the signature of a delivery, the HMAC-SHA256 of the timestamp and the body keyed
+// with the secret of the webhook. Endpoints compute it again to check the delivery came from the platform.
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + string(body)))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Suffixes of the names only resolved inside the cluster or the host, as are the names without a dot
+var internalHostSuffixes = []string{".localhost", ".local", ".internal", ".svc", ".cluster.local"}
+
+// Shared address space of the carrier-grade NATs, used by some clusters for their pods and services
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// WebhookHosts decides which hosts the webhooks may target. Loopback, link-local, private and cluster service
+// hosts are refused unless the configuration allows them, so users can't reach the internal services.
+type WebhookHosts struct {
+	names    []string
+	networks []*net.IPNet
+}
+
+// NewWebhookHosts returns the hosts allowing the given host names, IP addresses and CIDR ranges besides the public hosts
+func NewWebhookHosts(allowed []string) *WebhookHosts {
+	hosts := &WebhookHosts{}
+	for _, entry := range allowed {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			hosts.networks = append(hosts.networks, network)
+		} else if ip := net.ParseIP(entry); ip != nil {
+			hosts.networks = append(hosts.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+		} else {
+			hosts.names = append(hosts.names, strings.ToLower(strings.TrimSuffix(entry, ".")))
+		}
+	}
+	return hosts
+}
+
+// Check refuses the internal hosts not allowed. Names are only checked against the cluster names here, the
+// addresses they resolve to are checked when connecting, as they may change.
+func (h *WebhookHosts) Check(host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		return h.checkIP(ip)
+	}
+
+	name := strings.ToLower(strings.TrimSuffix(host, "."))
+	if h.allowsName(name) {
+		return nil
+	}
+	if name == "localhost" || !strings.Contains(name, ".") {
+		return fmt.Errorf("host %s is internal", host)
+	}
+	for _, suffix := range internalHostSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return fmt.Errorf("host %s is internal", host)
+		}
+	}
+
+	return nil
+}
+
+// Client returns an HTTP client connecting only to the hosts allowed. The address is checked once resolved,
+// right before connecting, so a name resolving to an internal address later on is still refused.
+func (h *WebhookHosts) Client() *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	guarded := &net.Dialer{
+		Timeout:   dialer.Timeout,
+		KeepAlive: dialer.KeepAlive,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			return h.checkIP(net.ParseIP(host))
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if h.allowsName(strings.ToLower(strings.TrimSuffix(host, "."))) {
+			return dialer.DialContext(ctx, network, address)
+		}
+		return guarded.DialContext(ctx, network, address)
+	}
+
+	return &http.Client{Transport: transport}
+}
+
+func (h *WebhookHosts) allowsName(name string) bool {
+	return slices.Contains(h.names, name)
+}
+
+// Refuses the internal addresses outside of the allowed networks
+func (h *WebhookHosts) checkIP(ip net.IP) error {
+	if ip == nil {
+		return errors.New("invalid address")
+	}
+	for _, network := range h.networks {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("address %s is internal", ip)
+	}
+
+	return nil
+}
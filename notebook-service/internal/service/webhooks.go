@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"notebook-service/api/controller"
+	"notebook-service/internal/auth"
+	"notebook-service/internal/model"
+	"notebook-service/internal/mongo_repository"
+	"notebook-service/internal/rabbitmq"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const DEFAULT_WEBHOOK_DELIVERY_LIMIT = 50
+const MAX_WEBHOOK_DELIVERY_LIMIT = 200
+
+// WebhooksService lets users register the endpoints the platform events are delivered to
+type WebhooksService struct {
+	repo       mongo_repository.WebhookRepository
+	dispatcher *WebhookDispatcher
+	hosts      *WebhookHosts
+	controller.UnimplementedWebhooksServer
+}
+
+func GenerateWebhooksService(repo mongo_repository.WebhookRepository, dispatcher *WebhookDispatcher, hosts *WebhookHosts) *WebhooksService {
+	return &WebhooksService{repo: repo, dispatcher: dispatcher, hosts: hosts}
+}
+
+// CreateWebhook registers an endpoint for the caller, delivered the events of the caller or every event when global
+func (s *WebhooksService) CreateWebhook(ctx context.Context, req *controller.CreateWebhookRequest) (*controller.Webhook, error) {
+	endpoint, err := url.Parse(req.Url)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, status.Error(codes.InvalidArgument, "url must be an absolute http or https URL")
+	}
+	if err := s.hosts.Check(endpoint.Hostname()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "url can't target an internal host: %v", err)
+	}
+	if req.Secret == "" {
+		return nil, status.Error(codes.InvalidArgument, "secret is required")
+	}
+	if req.Global && auth.GetRole(ctx) != auth.ADMIN {
+		return nil, status.Error(codes.PermissionDenied, "only administrators can register global webhooks")
+	}
+
+	eventTypes := rabbitmq.EventTypes()
+	for _, eventType := range req.EventTypes {
+		if !slices.Contains(eventTypes, eventType) {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown event type %s", eventType)
+		}
+	}
+
+	webhook := &model.Webhook{
+		ID:         uuid.NewString(),
+		Owner:      ctx.Value(auth.CtxKey).(string),
+		URL:        req.Url,
+		EventTypes: req.EventTypes,
+		Secret:     req.Secret,
+		Global:     req.Global,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.repo.CreateWebhook(ctx, webhook); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return webhookToResponse(webhook), nil
+}
+
+// ListWebhooks returns the webhooks of the caller, every webhook for administrators
+func (s *WebhooksService) ListWebhooks(ctx context.Context, req *controller.ListWebhooksRequest) (*controller.ListWebhooksResponse, error) {
+	owner := ctx.Value(auth.CtxKey).(string)
+	if auth.GetRole(ctx) == auth.ADMIN {
+		owner = ""
+	}
+
+	webhooks, err := s.repo.ListWebhooks(ctx, owner)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &controller.ListWebhooksResponse{}
+	for i := range webhooks {
+		response.Webhooks = append(response.Webhooks, webhookToResponse(&webhooks[i]))
+	}
+
+	return response, nil
+}
+
+// DeleteWebhook removes a webhook, its pending deliveries are given up
+func (s *WebhooksService) DeleteWebhook(ctx context.Context, req *controller.DeleteWebhookRequest) (*emptypb.Empty, error) {
+	if _, err := s.getOwnedWebhook(ctx, req.Id); err != nil {
+		return nil, err
+	}
+
+	deleted, err := s.repo.DeleteWebhook(ctx, req.Id)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if !deleted {
+		return nil, status.Errorf(codes.NotFound, "webhook %s not found", req.Id)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// ListWebhookDeliveries returns the delivery log of a webhook, newest first
+func (s *WebhooksService) ListWebhookDeliveries(ctx context.Context, req *controller.ListWebhookDeliveriesRequest) (*controller.ListWebhookDeliveriesResponse, error) {
+	limit := int64(req.Limit)
+	if limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid limit")
+	}
+	if limit == 0 {
+		limit = DEFAULT_WEBHOOK_DELIVERY_LIMIT
+	}
+	limit = min(limit, MAX_WEBHOOK_DELIVERY_LIMIT)
+
+	if _, err := s.getOwnedWebhook(ctx, req.WebhookId); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.repo.ListDeliveries(ctx, req.WebhookId, limit)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &controller.ListWebhookDeliveriesResponse{}
+	for i := range deliveries {
+		response.Deliveries = append(response.Deliveries, deliveryToResponse(&deliveries[i]))
+	}
+
+	return response, nil
+}
+
+// TestWebhook sends a test event to the endpoint of a webhook and returns the outcome of the delivery
+func (s *WebhooksService) TestWebhook(ctx context.Context, req *controller.TestWebhookRequest) (*controller.WebhookDelivery, error) {
+	webhook, err := s.getOwnedWebhook(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.dispatcher.Test(ctx, webhook, ctx.Value(auth.CtxKey).(string))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return deliveryToResponse(delivery), nil
+}
+
+// Returns the webhook if the caller registered it or is an administrator
+func (s *WebhooksService) getOwnedWebhook(ctx context.Context, id string) (*model.Webhook, error) {
+	webhook, err := s.repo.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	// Webhooks of other users are reported missing, so their ids can't be probed
+	if webhook == nil || (webhook.Owner != ctx.Value(auth.CtxKey).(string) && auth.GetRole(ctx) != auth.ADMIN) {
+		return nil, status.Errorf(codes.NotFound, "webhook %s not found", id)
+	}
+
+	return webhook, nil
+}
+
+func webhookToResponse(webhook *model.Webhook) *controller.Webhook {
+	return &controller.Webhook{
+		Id:         webhook.ID,
+		Owner:      webhook.Owner,
+		Url:        webhook.URL,
+		EventTypes: webhook.EventTypes,
+		Global:     webhook.Global,
+		CreatedAt:  timestamppb.New(webhook.CreatedAt),
+	}
+}
+
+func deliveryToResponse(delivery *model.WebhookDelivery) *controller.WebhookDelivery {
+	response := &controller.WebhookDelivery{
+		Id:             delivery.ID,
+		WebhookId:      delivery.WebhookID,
+		EventId:        delivery.EventID,
+		EventType:      delivery.EventType,
+		State:          deliveryStateToResponse(delivery.State),
+		Attempts:       int32(delivery.Attempts),
+		ResponseStatus: int32(delivery.ResponseStatus),
+		LastError:      delivery.LastError,
+		CreatedAt:      timestamppb.New(delivery.CreatedAt),
+	}
+	if delivery.State == model.DELIVERY_PENDING {
+		response.NextAttemptAt = timestamppb.New(delivery.NextAttemptAt)
+	}
+	if delivery.FinishedAt != nil {
+		response.FinishedAt = timestamppb.New(*delivery.FinishedAt)
+	}
+
+	return response
+}
+
+func deliveryStateToResponse(state string) controller.WebhookDeliveryState {
+	return controller.WebhookDeliveryState(controller.WebhookDeliveryState_value["DELIVERY_"+state])
+}
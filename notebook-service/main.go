@@ -5,11 +5,18 @@ import (
 	"log"
 	"notebook-service/db"
 	"notebook-service/grpc"
+	"notebook-service/internal"
+	"notebook-service/internal/config"
+	"notebook-service/internal/health"
+	"notebook-service/internal/metrics"
 	"notebook-service/internal/mongo_repository"
 	"notebook-service/internal/rabbitmq"
 	"notebook-service/internal/service"
+	"notebook-service/internal/tracing"
 	"notebook-service/redis_repository"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/joho/godotenv"
 )
@@ -23,28 +30,95 @@ func main() {
 			log.Fatalf("failed to load environment variables: %v", err)
 		}
 	}
-	rbmq := rabbitmq.NewRabbitMQHandler()
-	defer rbmq.Close()
+
+	// Load the configuration, the environment variables override the file
+	config.Setup()
+
+	// Stop gracefully on SIGTERM, sent by Kubernetes during rolling deploys, or on an interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Setup the tracing, flushing the remaining spans on exit
+	shutdownTracing := tracing.SetupTracing("notebook-service")
+	defer shutdownTracing(context.Background())
+
+	// Create the kubernetes clients once, they are shared by every request
+	kube, err := internal.NewKubeClients(config.Get().Kubernetes)
+	if err != nil {
+		log.Fatalf("Failed setting up kubernetes clients: %v", err)
+	}
+
+	// Serve the metrics
+	metrics.RegisterKubernetesMetrics()
+	metrics.RegisterCountGauge("notebooks", "Number of notebooks in the namespace, by state.", "state", service.CountNotebooksByState(kube.Dynamic))
+	metrics.SetupMetricsServer()
+
+	rbmq := rabbitmq.NewRabbitMQHandler(config.Get().RabbitMQ, rabbitmq.NewMemoryExchange())
 
 	// Create redis connection
-	ctx := context.Background()
 	redisClient := db.Setup(ctx)
-	if redisClient == nil {
-		log.Printf("Error regarding redis: redisClient is nil!")
-		return
-	}
-	redisRepo := redis_repository.CreateNotebookRepository(redisClient, ctx)
+	redisRepo := redis_repository.CreateNotebookRepository(redisClient)
+	idempotencyRepo := redis_repository.CreateIdempotencyRepository(redisClient)
+	eventDedupRepo := redis_repository.CreateEventDedupRepository(redisClient, config.Get().RabbitMQ.DedupLease, config.Get().RabbitMQ.DedupTTL)
 
 	// Create mongo connection
 	mongoDB := db.SetupMongoDB()
 
 	// Create mongo repository
 	mongoRepo := mongo_repository.CreateNotebookRepository(mongoDB)
+	profileRepo := mongo_repository.CreateSchedulingProfileRepository(mongoDB)
+	operationRepo := mongo_repository.CreateOperationRepository(mongoDB)
+	usageRepo := mongo_repository.CreateUsageRepository(mongoDB)
+	outboxRepo := mongo_repository.CreateOutboxRepository(mongoDB, config.Get().Outbox.Retention)
+	webhookRepo := mongo_repository.CreateWebhookRepository(mongoDB, config.Get().Webhooks.Retention)
+
+	operationsService := service.GenerateOperationsService(operationRepo)
+	deadLettersService := service.GenerateDeadLettersService(rbmq)
+	operationsService.FailInterruptedOperations(ctx)
 
-	defer mongoDB.Client().Disconnect(context.Background())
+	// Keep the leases of the operations until they were drained at shutdown
+	leaseCtx, stopLeases := context.WithCancel(context.Background())
+	defer stopLeases()
+	go operationsService.Run(leaseCtx)
 
-	notebookService := service.GenerateNotebookService(rbmq, redisRepo, mongoRepo)
+	// Publish the events recorded in the outbox until the shutdown, the unsent ones are published on the next start
+	outboxRelay := service.GenerateOutboxRelay(outboxRepo, rbmq, config.Get().Outbox)
+	go outboxRelay.Run(ctx)
+
+	// Deliver the events to the webhooks until the shutdown, the pending deliveries are retried on the next start
+	webhookHosts := service.NewWebhookHosts(config.Get().Webhooks.AllowedHosts)
+	webhookDispatcher := service.GenerateWebhookDispatcher(webhookRepo, webhookHosts.Client(), config.Get().Webhooks)
+	webhooksService := service.GenerateWebhooksService(webhookRepo, webhookDispatcher, webhookHosts)
+	go webhookDispatcher.Run(ctx)
+
+	notebookService := service.GenerateNotebookService(rbmq, redisRepo, mongoRepo, profileRepo, idempotencyRepo, operationsService, usageRepo, kube, outboxRelay, eventDedupRepo, webhookDispatcher)
 	//go service.ListenForPvcDeletion(rabbitmq.RabbitMQHandler{})
-	grpc.SetupGRPCServer(notebookService)
 
+	// Keep checking the dependencies, so the readiness follows them
+	checker := health.NewChecker()
+	checker.AddCheck(health.REDIS, health.Redis(redisClient))
+	checker.AddCheck(health.MONGODB, health.MongoDB(mongoDB.Client()))
+	checker.AddCheck(health.RABBITMQ, health.RabbitMQ(rbmq))
+	checker.AddCheck(health.KUBERNETES, health.Kubernetes(kube.Clientset))
+	go checker.Run(ctx)
+
+	grpc.SetupGRPCServer(ctx, notebookService, operationsService, deadLettersService, webhooksService, checker)
+
+	// Finish the work already accepted before closing the connections it uses
+	rbmq.StopConsuming()
+	drainCtx, cancel := context.WithTimeout(context.Background(), grpc.SHUTDOWN_TIMEOUT)
+	defer cancel()
+	operationsService.Drain(drainCtx)
+	stopLeases()
+
+	// Close the connections in order
+	if err := redisClient.Close(); err != nil {
+		log.Printf("Failed to close redis connection: %v", err)
+	}
+	if err := mongoDB.Client().Disconnect(context.Background()); err != nil {
+		log.Printf("Failed to close mongodb connection: %v", err)
+	}
+	rbmq.Close()
+
+	log.Println("Notebook service stopped")
 }
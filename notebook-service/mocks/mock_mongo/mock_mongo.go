@@ -1,6 +1,7 @@
 package mock_mongo
 
 import (
+	"context"
 	"notebook-service/internal/model"
 
 	"github.com/stretchr/testify/mock"
@@ -11,27 +12,37 @@ type MockMongo struct {
 	mock.Mock
 }
 
-func (r *MockMongo) AuthorizedUser(username, notebookName string) (bool, error) {
+func (r *MockMongo) AuthorizedUser(ctx context.Context, username, notebookName string) (bool, error) {
 	args := r.Called(username, notebookName)
 	return args.Bool(0), args.Error(1)
 }
 
-func (r *MockMongo) CreateNotebook(notebook *model.NotebookEntity) error {
+func (r *MockMongo) CreateNotebook(ctx context.Context, notebook *model.NotebookEntity) error {
 	args := r.Called(notebook)
 	return args.Error(0)
 }
 
-func (r *MockMongo) DeleteNotebook(notebookName string) error {
+func (r *MockMongo) DeleteNotebook(ctx context.Context, notebookName string) error {
 	args := r.Called(notebookName)
 	return args.Error(0)
 }
 
-func (r *MockMongo) ListNotebooks(username string) ([]string, error) {
+func (r *MockMongo) ListNotebooks(ctx context.Context, username string) ([]model.NotebookEntity, error) {
 	args := r.Called(username)
 
-	if notebooks, ok := args.Get(0).([]string); ok {
+	if notebooks, ok := args.Get(0).([]model.NotebookEntity); ok {
 		return notebooks, args.Error(1)
 	}
 
 	return nil, args.Error(1)
 }
+
+func (r *MockMongo) SetNotebookState(ctx context.Context, notebookName string, state string) (*model.NotebookEntity, error) {
+	args := r.Called(notebookName, state)
+
+	if notebook, ok := args.Get(0).(*model.NotebookEntity); ok {
+		return notebook, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
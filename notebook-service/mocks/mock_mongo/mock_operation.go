@@ -0,0 +1,64 @@
+package mock_mongo
+
+import (
+	"context"
+	"notebook-service/internal/model"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockOperations is mocking the operation repository of mongodb
+type MockOperations struct {
+	mock.Mock
+}
+
+func (r *MockOperations) CreateOperation(ctx context.Context, operation *model.Operation) error {
+	args := r.Called(operation)
+	return args.Error(0)
+}
+
+func (r *MockOperations) UpdateOperation(ctx context.Context, operation *model.Operation) error {
+	args := r.Called(operation)
+	return args.Error(0)
+}
+
+func (r *MockOperations) GetOperation(ctx context.Context, id string) (*model.Operation, error) {
+	args := r.Called(id)
+
+	if operation, ok := args.Get(0).(*model.Operation); ok {
+		return operation, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (r *MockOperations) ListOperations(ctx context.Context, username string, kind *string, done *bool, offset int64, limit int64) ([]model.Operation, error) {
+	args := r.Called(username, kind, done, offset, limit)
+
+	if operations, ok := args.Get(0).([]model.Operation); ok {
+		return operations, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (r *MockOperations) RequestCancel(ctx context.Context, id string) error {
+	args := r.Called(id)
+	return args.Error(0)
+}
+
+func (r *MockOperations) CancelRequested(ctx context.Context, id string) (bool, error) {
+	args := r.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (r *MockOperations) RenewLeases(ctx context.Context, owner string, expiry time.Time) error {
+	args := r.Called(owner, expiry)
+	return args.Error(0)
+}
+
+func (r *MockOperations) FailInterruptedOperations(ctx context.Context, reason model.OperationError) (int64, error) {
+	args := r.Called(reason)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -0,0 +1,44 @@
+package mock_mongo
+
+import (
+	"context"
+	"notebook-service/internal/model"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockOutbox is mocking the outbox repository of mongodb
+type MockOutbox struct {
+	mock.Mock
+}
+
+func (r *MockOutbox) AddEvent(ctx context.Context, event *model.OutboxEvent) error {
+	args := r.Called(event)
+	return args.Error(0)
+}
+
+func (r *MockOutbox) FindPendingEvents(ctx context.Context, limit int64) ([]model.OutboxEvent, error) {
+	args := r.Called(limit)
+
+	if events, ok := args.Get(0).([]model.OutboxEvent); ok {
+		return events, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (r *MockOutbox) MarkEventSent(ctx context.Context, id string, sentAt time.Time) error {
+	args := r.Called(id)
+	return args.Error(0)
+}
+
+func (r *MockOutbox) MarkEventFailed(ctx context.Context, id string, cause string) error {
+	args := r.Called(id, cause)
+	return args.Error(0)
+}
+
+func (r *MockOutbox) MarkEventDiscarded(ctx context.Context, id string, cause string, failedAt time.Time) error {
+	args := r.Called(id, cause)
+	return args.Error(0)
+}
@@ -0,0 +1,53 @@
+package mock_mongo
+
+import (
+	"context"
+	"notebook-service/internal/model"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockSchedulingProfiles is mocking the scheduling profile repository of mongodb
+type MockSchedulingProfiles struct {
+	mock.Mock
+}
+
+func (r *MockSchedulingProfiles) PutSchedulingProfile(ctx context.Context, profile *model.SchedulingProfile) error {
+	args := r.Called(profile)
+	return args.Error(0)
+}
+
+func (r *MockSchedulingProfiles) DeleteSchedulingProfile(ctx context.Context, name string) (bool, error) {
+	args := r.Called(name)
+	return args.Bool(0), args.Error(1)
+}
+
+func (r *MockSchedulingProfiles) GetSchedulingProfile(ctx context.Context, name string) (*model.SchedulingProfile, error) {
+	args := r.Called(name)
+
+	if profile, ok := args.Get(0).(*model.SchedulingProfile); ok {
+		return profile, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (r *MockSchedulingProfiles) FindDefaultSchedulingProfile(ctx context.Context, notebookType string) (*model.SchedulingProfile, error) {
+	args := r.Called(notebookType)
+
+	if profile, ok := args.Get(0).(*model.SchedulingProfile); ok {
+		return profile, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (r *MockSchedulingProfiles) ListSchedulingProfiles(ctx context.Context) ([]model.SchedulingProfile, error) {
+	args := r.Called()
+
+	if profiles, ok := args.Get(0).([]model.SchedulingProfile); ok {
+		return profiles, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
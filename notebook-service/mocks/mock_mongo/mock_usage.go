@@ -0,0 +1,39 @@
+package mock_mongo
+
+import (
+	"context"
+	"notebook-service/internal/model"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockUsage is mocking the usage repository of mongodb
+type MockUsage struct {
+	mock.Mock
+}
+
+func (r *MockUsage) InsertUsageEvent(ctx context.Context, event *model.UsageEvent) error {
+	args := r.Called(event)
+	return args.Error(0)
+}
+
+func (r *MockUsage) OpenUsageInterval(ctx context.Context, interval *model.UsageInterval) error {
+	args := r.Called(interval)
+	return args.Error(0)
+}
+
+func (r *MockUsage) CloseUsageIntervals(ctx context.Context, notebookName string, kind string, end time.Time) (int64, error) {
+	args := r.Called(notebookName, kind, end)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (r *MockUsage) FindUsageIntervals(ctx context.Context, start time.Time, end time.Time, username *string) ([]model.UsageInterval, error) {
+	args := r.Called(start, end, username)
+
+	if intervals, ok := args.Get(0).([]model.UsageInterval); ok {
+		return intervals, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
@@ -0,0 +1,89 @@
+package mock_mongo
+
+import (
+	"context"
+	"notebook-service/internal/model"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockWebhooks is mocking the webhook repository of mongodb
+type MockWebhooks struct {
+	mock.Mock
+}
+
+func (r *MockWebhooks) CreateWebhook(ctx context.Context, webhook *model.Webhook) error {
+	args := r.Called(webhook)
+	return args.Error(0)
+}
+
+func (r *MockWebhooks) GetWebhook(ctx context.Context, id string) (*model.Webhook, error) {
+	args := r.Called(id)
+
+	if webhook, ok := args.Get(0).(*model.Webhook); ok {
+		return webhook, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (r *MockWebhooks) ListWebhooks(ctx context.Context, owner string) ([]model.Webhook, error) {
+	args := r.Called(owner)
+
+	if webhooks, ok := args.Get(0).([]model.Webhook); ok {
+		return webhooks, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (r *MockWebhooks) DeleteWebhook(ctx context.Context, id string) (bool, error) {
+	args := r.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (r *MockWebhooks) FindSubscribedWebhooks(ctx context.Context, eventType string) ([]model.Webhook, error) {
+	args := r.Called(eventType)
+
+	if webhooks, ok := args.Get(0).([]model.Webhook); ok {
+		return webhooks, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (r *MockWebhooks) AddDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error {
+	args := r.Called(deliveries)
+	return args.Error(0)
+}
+
+func (r *MockWebhooks) FindDueDeliveries(ctx context.Context, now time.Time, limit int64) ([]model.WebhookDelivery, error) {
+	args := r.Called(limit)
+
+	if deliveries, ok := args.Get(0).([]model.WebhookDelivery); ok {
+		return deliveries, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (r *MockWebhooks) ClaimDelivery(ctx context.Context, id, owner string, now, leaseExpiry time.Time) (bool, error) {
+	args := r.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (r *MockWebhooks) UpdateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	args := r.Called(delivery)
+	return args.Error(0)
+}
+
+func (r *MockWebhooks) ListDeliveries(ctx context.Context, webhookID string, limit int64) ([]model.WebhookDelivery, error) {
+	args := r.Called(webhookID, limit)
+
+	if deliveries, ok := args.Get(0).([]model.WebhookDelivery); ok {
+		return deliveries, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
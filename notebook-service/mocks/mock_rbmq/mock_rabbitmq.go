@@ -2,7 +2,12 @@
 package mock_rbmq
 
 import (
+	"context"
+	"notebook-service/api/events"
+	"notebook-service/internal/rabbitmq"
+
 	"github.com/stretchr/testify/mock"
+	"google.golang.org/protobuf/proto"
 )
 
 // Mock RabbitMQ client
@@ -10,9 +15,15 @@ type RabbitMQClientMock struct {
 	mock.Mock
 }
 
-// Method to publish a message. Not doing anything during test
-func (rbmq *RabbitMQClientMock) Publish(queue string, message string) error {
-	args := rbmq.Called(queue, message)
+// Method to publish an event. Not doing anything during test, the context is not recorded
+func (rbmq *RabbitMQClientMock) PublishEvent(ctx context.Context, eventType, actor string, data proto.Message) error {
+	args := rbmq.Called(eventType, actor, data)
+	return args.Error(0)
+}
+
+// Method to publish an event with a confirmation, the context is not recorded
+func (rbmq *RabbitMQClientMock) PublishConfirmed(ctx context.Context, event *events.Envelope) error {
+	args := rbmq.Called(event)
 	return args.Error(0)
 }
 
@@ -22,6 +33,36 @@ func (rbmq *RabbitMQClientMock) Close() {
 }
 
 // Empty method for consume messages
-func (rbmq *RabbitMQClientMock) ConsumeMessages(handlers map[string]func([]byte)) {
-	rbmq.Called(handlers)
+func (rbmq *RabbitMQClientMock) ConsumeMessages(router *rabbitmq.Router) {
+	rbmq.Called(router)
+}
+
+// Method to list the dead letters, returning the ones set for the test
+func (rbmq *RabbitMQClientMock) ListDeadLetters(limit int) ([]rabbitmq.DeadLetter, error) {
+	args := rbmq.Called(limit)
+	deadLetters, _ := args.Get(0).([]rabbitmq.DeadLetter)
+	return deadLetters, args.Error(1)
+}
+
+// Method to replay the dead letters, the context is not recorded
+func (rbmq *RabbitMQClientMock) ReplayDeadLetters(ctx context.Context, ids []string) (int, error) {
+	args := rbmq.Called(ids)
+	return args.Int(0), args.Error(1)
+}
+
+// Method to get the state of the connection, returning the one set for the test
+func (rbmq *RabbitMQClientMock) State() rabbitmq.ConnectionState {
+	args := rbmq.Called()
+	return args.Get(0).(rabbitmq.ConnectionState)
+}
+
+// Method to check the connection, reporting the error set for the test
+func (rbmq *RabbitMQClientMock) CheckConnection() error {
+	args := rbmq.Called()
+	return args.Error(0)
+}
+
+// Empty method for stopping the consumers
+func (rbmq *RabbitMQClientMock) StopConsuming() {
+	rbmq.Called()
 }
@@ -0,0 +1,34 @@
+package mock_redis
+
+import (
+	"context"
+	"notebook-service/redis_repository"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockIdempotency is mocking the idempotency repository of redis
+type MockIdempotency struct {
+	mock.Mock
+}
+
+func (r *MockIdempotency) Reserve(ctx context.Context, key string, payloadHash string, lease time.Duration) (*redis_repository.IdempotencyRecord, bool, error) {
+	args := r.Called(key, payloadHash, lease)
+
+	if record, ok := args.Get(0).(*redis_repository.IdempotencyRecord); ok {
+		return record, args.Bool(1), args.Error(2)
+	}
+
+	return nil, args.Bool(1), args.Error(2)
+}
+
+func (r *MockIdempotency) Complete(ctx context.Context, key string, record *redis_repository.IdempotencyRecord, ttl time.Duration) error {
+	args := r.Called(key, record, ttl)
+	return args.Error(0)
+}
+
+func (r *MockIdempotency) Release(ctx context.Context, key string) error {
+	args := r.Called(key)
+	return args.Error(0)
+}
@@ -1,6 +1,7 @@
 package mock_redis
 
 import (
+	"context"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -9,22 +10,22 @@ type MockRedis struct {
 	mock.Mock
 }
 
-func (r *MockRedis) CheckCacheExists(username string) (bool, error) {
+func (r *MockRedis) CheckCacheExists(ctx context.Context, username string) (bool, error) {
 	args := r.Called(username)
 	return args.Bool(0), args.Error(1)
 }
 
-func (r *MockRedis) StoreNotebooks(username string, notebookNames []string) error {
+func (r *MockRedis) StoreNotebooks(ctx context.Context, username string, notebookNames []string) error {
 	args := r.Called(username, notebookNames)
 	return args.Error(0)
 }
 
-func (r *MockRedis) AddNotebook(username string, notebookName string) error {
+func (r *MockRedis) AddNotebook(ctx context.Context, username string, notebookName string) error {
 	args := r.Called(username, notebookName)
 	return args.Error(0)
 }
 
-func (r *MockRedis) GetNotebooks(username string) ([]string, error) {
+func (r *MockRedis) GetNotebooks(ctx context.Context, username string) ([]string, error) {
 	args := r.Called(username)
 
 	if notebooks, ok := args.Get(0).([]string); ok {
@@ -34,7 +35,12 @@ func (r *MockRedis) GetNotebooks(username string) ([]string, error) {
 	return nil, args.Error(1)
 }
 
-func (r *MockRedis) DeleteNotebook(username string, notebookName string) error {
+func (r *MockRedis) DeleteNotebook(ctx context.Context, username string, notebookName string) error {
 	args := r.Called(username, notebookName)
 	return args.Error(0)
 }
+
+func (r *MockRedis) InvalidateCache(ctx context.Context, username string) error {
+	args := r.Called(username)
+	return args.Error(0)
+}
@@ -0,0 +1,69 @@
+package redis_repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Value of the key of an event leased to its handler, any other value marking it handled
+const EVENT_PROCESSING = "processing"
+
+type EventDedupRepositoryImpl struct {
+	DB    *redis.Client
+	Lease time.Duration
+	TTL   time.Duration
+}
+
+// CreateEventDedupRepository returns a repository leasing the events for the lease while they are handled,
+// then remembering the handled events for the ttl
+func CreateEventDedupRepository(db *redis.Client, lease time.Duration, ttl time.Duration) EventDedupRepository {
+	return &EventDedupRepositoryImpl{DB: db, Lease: lease, TTL: ttl}
+}
+
+// Helper to generate the key
+func (r *EventDedupRepositoryImpl) generateKey(id string) string {
+	return "event:processed:" + id
+}
+
+// Claim leases the event to the handler. When it is taken, the second result tells whether it was handled.
+func (r *EventDedupRepositoryImpl) Claim(ctx context.Context, id string) (bool, bool, error) {
+	claimed, err := r.DB.SetNX(ctx, r.generateKey(id), EVENT_PROCESSING, r.Lease).Result()
+	if err != nil {
+		return false, false, fmt.Errorf("failed claiming event %s: %v", id, err)
+	}
+	if claimed {
+		return true, false, nil
+	}
+
+	value, err := r.DB.Get(ctx, r.generateKey(id)).Result()
+	if err == redis.Nil {
+		// The lease expired in between, so try again
+		return r.Claim(ctx, id)
+	}
+	if err != nil {
+		return false, false, fmt.Errorf("failed getting event %s: %v", id, err)
+	}
+
+	return false, value != EVENT_PROCESSING, nil
+}
+
+// Complete marks the event handled for the ttl
+func (r *EventDedupRepositoryImpl) Complete(ctx context.Context, id string) error {
+	if err := r.DB.Set(ctx, r.generateKey(id), time.Now().Unix(), r.TTL).Err(); err != nil {
+		return fmt.Errorf("failed completing event %s: %v", id, err)
+	}
+
+	return nil
+}
+
+// Release forgets the event, so it is handled again on its retry
+func (r *EventDedupRepositoryImpl) Release(ctx context.Context, id string) error {
+	if err := r.DB.Del(ctx, r.generateKey(id)).Err(); err != nil {
+		return fmt.Errorf("failed releasing event %s: %v", id, err)
+	}
+
+	return nil
+}
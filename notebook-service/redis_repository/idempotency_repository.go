@@ -0,0 +1,21 @@
+package redis_repository
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotencyRecord is the stored outcome of a request sent with an idempotency key
+type IdempotencyRecord struct {
+	PayloadHash string `json:"payloadHash"`
+	Completed   bool   `json:"completed"`
+	Code        uint32 `json:"code"`
+	Message     string `json:"message"`
+	Response    []byte `json:"response"`
+}
+
+type IdempotencyRepository interface {
+	Reserve(ctx context.Context, key string, payloadHash string, lease time.Duration) (*IdempotencyRecord, bool, error)
+	Complete(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error
+	Release(ctx context.Context, key string) error
+}
@@ -0,0 +1,78 @@
+package redis_repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type IdempotencyRepositoryImpl struct {
+	DB *redis.Client
+}
+
+func CreateIdempotencyRepository(db *redis.Client) IdempotencyRepository {
+	return &IdempotencyRepositoryImpl{DB: db}
+}
+
+// Helper to generate the key
+func (r *IdempotencyRepositoryImpl) generateKey(key string) string {
+	return "idempotency:" + key
+}
+
+// Reserve claims the key for a new request. If the key is already taken, the stored record is returned instead.
+func (r *IdempotencyRepositoryImpl) Reserve(ctx context.Context, key string, payloadHash string, lease time.Duration) (*IdempotencyRecord, bool, error) {
+	record, err := json.Marshal(&IdempotencyRecord{PayloadHash: payloadHash})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed encoding idempotency record: %v", err)
+	}
+
+	reserved, err := r.DB.SetNX(ctx, r.generateKey(key), record, lease).Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed reserving idempotency key: %v", err)
+	}
+	if reserved {
+		return nil, true, nil
+	}
+
+	stored, err := r.DB.Get(ctx, r.generateKey(key)).Bytes()
+	if err == redis.Nil {
+		// The reservation expired in between, so try again
+		return r.Reserve(ctx, key, payloadHash, lease)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed getting idempotency record: %v", err)
+	}
+
+	var existing IdempotencyRecord
+	if err := json.Unmarshal(stored, &existing); err != nil {
+		return nil, false, fmt.Errorf("failed decoding idempotency record: %v", err)
+	}
+
+	return &existing, false, nil
+}
+
+// Complete stores the outcome of the request for the given time
+func (r *IdempotencyRepositoryImpl) Complete(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed encoding idempotency record: %v", err)
+	}
+
+	if err := r.DB.Set(ctx, r.generateKey(key), value, ttl).Err(); err != nil {
+		return fmt.Errorf("failed storing idempotency record: %v", err)
+	}
+
+	return nil
+}
+
+// Release frees the key so the request can be executed again
+func (r *IdempotencyRepositoryImpl) Release(ctx context.Context, key string) error {
+	if err := r.DB.Del(ctx, r.generateKey(key)).Err(); err != nil {
+		return fmt.Errorf("failed releasing idempotency key: %v", err)
+	}
+
+	return nil
+}
@@ -1,9 +1,12 @@
 package redis_repository
 
+import "context"
+
 type NotebookRepository interface {
-	CheckCacheExists(string) (bool, error)
-	StoreNotebooks(string, []string) error
-	AddNotebook(string, string) error
-	GetNotebooks(string) ([]string, error)
-	DeleteNotebook(string, string) error
+	CheckCacheExists(context.Context, string) (bool, error)
+	StoreNotebooks(context.Context, string, []string) error
+	AddNotebook(context.Context, string, string) error
+	GetNotebooks(context.Context, string) ([]string, error)
+	DeleteNotebook(context.Context, string, string) error
+	InvalidateCache(context.Context, string) error
 }
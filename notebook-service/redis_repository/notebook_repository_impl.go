@@ -9,12 +9,11 @@ import (
 )
 
 type NotebookRepositoryImpl struct {
-	DB      *redis.Client
-	context context.Context
+	DB *redis.Client
 }
 
-func CreateNotebookRepository(db *redis.Client, context context.Context) NotebookRepository {
-	return &NotebookRepositoryImpl{DB: db, context: context}
+func CreateNotebookRepository(db *redis.Client) NotebookRepository {
+	return &NotebookRepositoryImpl{DB: db}
 }
 
 // Helper to generate the key
@@ -23,11 +22,11 @@ func (r *NotebookRepositoryImpl) generateKey(username string) string {
 }
 
 // CheckCacheExists checks if user's cache exists in redis
-func (r *NotebookRepositoryImpl) CheckCacheExists(username string) (bool, error) {
+func (r *NotebookRepositoryImpl) CheckCacheExists(ctx context.Context, username string) (bool, error) {
 	// Get the data key
 	key := r.generateKey(username)
 
-	exists, err := r.DB.Exists(r.context, key).Result()
+	exists, err := r.DB.Exists(ctx, key).Result()
 	if err != nil {
 		return false, fmt.Errorf("failed checking cache existence: %v", err)
 	}
@@ -36,16 +35,16 @@ func (r *NotebookRepositoryImpl) CheckCacheExists(username string) (bool, error)
 }
 
 // StoreNotebooks cache the get active notebooks values to redis
-func (r *NotebookRepositoryImpl) StoreNotebooks(username string, notebooks []string) error {
+func (r *NotebookRepositoryImpl) StoreNotebooks(ctx context.Context, username string, notebooks []string) error {
 	// Get the data key
 	key := r.generateKey(username)
 
 	// Store the notebooks
 	pipe := r.DB.Pipeline()
-	pipe.SAdd(r.context, key, notebooks)
-	pipe.Expire(r.context, key, 2*time.Hour)
+	pipe.SAdd(ctx, key, notebooks)
+	pipe.Expire(ctx, key, 2*time.Hour)
 
-	if _, err := pipe.Exec(r.context); err != nil {
+	if _, err := pipe.Exec(ctx); err != nil {
 		return fmt.Errorf("failed storing notebooks: %v", err)
 	}
 
@@ -53,12 +52,12 @@ func (r *NotebookRepositoryImpl) StoreNotebooks(username string, notebooks []str
 }
 
 // AddNotebook add a notebook value to existing cache
-func (r *NotebookRepositoryImpl) AddNotebook(username string, notebook string) error {
+func (r *NotebookRepositoryImpl) AddNotebook(ctx context.Context, username string, notebook string) error {
 	// Get the data key
 	key := r.generateKey(username)
 
 	// Store the notebooks
-	if err := r.DB.SAdd(r.context, key, notebook).Err(); err != nil {
+	if err := r.DB.SAdd(ctx, key, notebook).Err(); err != nil {
 		return fmt.Errorf("failed adding notebook: %v", err)
 	}
 
@@ -66,12 +65,12 @@ func (r *NotebookRepositoryImpl) AddNotebook(username string, notebook string) e
 }
 
 // GetNotebooks retrieved cached notebook list
-func (r *NotebookRepositoryImpl) GetNotebooks(username string) ([]string, error) {
+func (r *NotebookRepositoryImpl) GetNotebooks(ctx context.Context, username string) ([]string, error) {
 	// Get cache key
 	key := r.generateKey(username)
 
 	// Get the list of notebook
-	notebooks, err := r.DB.SMembers(r.context, key).Result()
+	notebooks, err := r.DB.SMembers(ctx, key).Result()
 	if err != nil {
 		return []string{}, fmt.Errorf("failed getting the list of notebook: %v", err)
 	}
@@ -79,13 +78,22 @@ func (r *NotebookRepositoryImpl) GetNotebooks(username string) ([]string, error)
 	return notebooks, nil
 }
 
+// InvalidateCache removes the cached notebook list of the user, read again from MongoDB by the next listing
+func (r *NotebookRepositoryImpl) InvalidateCache(ctx context.Context, username string) error {
+	if err := r.DB.Del(ctx, r.generateKey(username)).Err(); err != nil {
+		return fmt.Errorf("failed invalidating notebook cache: %v", err)
+	}
+
+	return nil
+}
+
 // DeleteNotebook removes a notebook from Redis cache
-func (r *NotebookRepositoryImpl) DeleteNotebook(username, notebook string) error {
+func (r *NotebookRepositoryImpl) DeleteNotebook(ctx context.Context, username, notebook string) error {
 	// Get cache key
 	key := r.generateKey(username)
 
 	// Delete the notebook from existing cache
-	if err := r.DB.SRem(r.context, key, notebook).Err(); err != nil {
+	if err := r.DB.SRem(ctx, key, notebook).Err(); err != nil {
 		return fmt.Errorf("failed deleting notebook %s: %v", notebook, err)
 	}
 
@@ -3,8 +3,9 @@ package db
 import (
 	"context"
 	"log"
-	"os"
+	"pvc-service/internal/config"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -14,13 +15,17 @@ var db *redis.Client
 func Setup(c context.Context) *redis.Client {
 	// Connect to redis
 	db = redis.NewClient(&redis.Options{
-		Addr:     os.Getenv("REDIS_URL"),
-		Password: os.Getenv("REDIS_PASSWORD"),
+		Addr:     config.Get().Redis.URL,
+		Password: config.Get().Redis.Password,
 		DB:       0,
 	})
 
-	log.Printf("address: %s", os.Getenv("REDIS_URL"))
-	log.Printf("password: %s", os.Getenv("REDIS_PASSWORD"))
+	// Trace every redis command
+	if err := redisotel.InstrumentTracing(db); err != nil {
+		log.Printf("Failed to instrument redis tracing: %v", err)
+	}
+
+	log.Printf("address: %s", config.Get().Redis.URL)
 
 	// Check connectivity
 	err := db.Ping(c).Err()
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"pvc-service/internal/config"
+	"pvc-service/internal/metrics"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+)
+
+// Method to setup the mongodb connection
+func SetupMongoDB() *mongo.Database {
+	// Set the client option
+	cfg := config.Get().MongoDB
+
+	opt := options.Client().ApplyURI(fmt.Sprintf("mongodb://%s:%s@%s", cfg.User, cfg.Password, cfg.URL)).SetMonitor(combineMonitors(metrics.MongoMonitor(), otelmongo.NewMonitor()))
+
+	// Connect to mongodb
+	client, err := mongo.Connect(context.TODO(), opt)
+	if err != nil {
+		log.Fatalf("failed connecting to mongodb: %v", err)
+	}
+
+	// Check the connection
+	err = client.Ping(context.TODO(), nil)
+	if err != nil {
+		log.Fatalf("failed sending a ping command to mongodb: %v", err)
+	}
+
+	return client.Database(cfg.Database)
+}
+
+// Combines command monitors, since the client only accepts one. Commands are both measured and traced this way.
+func combineMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, monitor := range monitors {
+				if monitor.Started != nil {
+					monitor.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, monitor := range monitors {
+				if monitor.Succeeded != nil {
+					monitor.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, monitor := range monitors {
+				if monitor.Failed != nil {
+					monitor.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}
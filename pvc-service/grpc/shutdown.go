@@ -0,0 +1,51 @@
+package grpc
+
+import (
+	"context"
+	"log"
+	"net"
+	"pvc-service/internal/health"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Time given to the running calls to finish once the service is stopping
+const SHUTDOWN_TIMEOUT = 30 * time.Second
+
+// Serves until the context is done, then stops accepting calls and waits for the running ones
+func serve(ctx context.Context, server *grpc.Server, lis net.Listener, checker *health.Checker) error {
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		gracefulStop(server, checker)
+	}()
+
+	// The server is stopped before serving when the context is done early
+	if err := server.Serve(lis); err != nil && err != grpc.ErrServerStopped {
+		return err
+	}
+
+	<-stopped
+	return nil
+}
+
+// Reports the service as not serving and stops it gracefully, closing the calls still running after the deadline
+func gracefulStop(server *grpc.Server, checker *health.Checker) {
+	log.Println("Stopping the gRPC server")
+	checker.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(SHUTDOWN_TIMEOUT):
+		log.Printf("gRPC calls still running after %v, closing them", SHUTDOWN_TIMEOUT)
+		server.Stop()
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
+	"pvc-service/internal/config"
+	"strings"
 
 	"github.com/dgrijalva/jwt-go"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
@@ -21,7 +23,8 @@ const (
 
 type key string
 
-const ctxKey key = "username"
+const CtxKey key = "username"
+const RoleCtxKey key = "role"
 
 type JWTClaims struct {
 	Username string `json:"iss"`
@@ -30,7 +33,7 @@ type JWTClaims struct {
 }
 
 func ValidateToken(tokenString string) (*JWTClaims, error) {
-	jwtSecret := os.Getenv("SECRET_KEY")
+	jwtSecret := config.Get().Auth.SecretKey
 
 	claims := &JWTClaims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
@@ -48,6 +51,10 @@ func AuthInterceptor(
 	info *grpc.UnaryServerInfo,
 	handler grpc.UnaryHandler,
 ) (resp interface{}, err error) {
+	if isPublicMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+
 	// Extract the token from the metadata
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
@@ -67,8 +74,23 @@ func AuthInterceptor(
 		return nil, status.Error(codes.Unauthenticated, "invalid token")
 	}
 
-	// Set the username in the context for later use
-	ctx = context.WithValue(ctx, ctxKey, claims.Username)
+	// Set the username and role in the context for later use
+	ctx = context.WithValue(ctx, CtxKey, claims.Username)
+	ctx = context.WithValue(ctx, RoleCtxKey, claims.Role)
 
 	return handler(ctx, req)
 }
+
+// Health checks are answered without a token, so probes and the gateway can call them
+func isPublicMethod(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/"+healthpb.Health_ServiceDesc.ServiceName+"/")
+}
+
+// Returns the role stored in the context, or UNKNOWN if there is none
+func GetRole(ctx context.Context) string {
+	role, ok := ctx.Value(RoleCtxKey).(string)
+	if !ok || role == "" {
+		return UNKNOWN
+	}
+	return role
+}
@@ -0,0 +1,235 @@
+// Package that loads the typed configuration of the service from a file and the environment
+package config
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Exporters selectable for the tracing
+const (
+	OTLP_EXPORTER   = "otlp"
+	STDOUT_EXPORTER = "stdout"
+)
+
+// Drivers selectable for the messaging
+const (
+	AMQP_DRIVER   = "amqp"   // RabbitMQ
+	MEMORY_DRIVER = "memory" // In-process bus, for the tests and the local development without RabbitMQ
+)
+
+// Policies applied to the workspace volume of a deleted notebook
+const (
+	RETENTION_DELETE     = "delete"     // Removed with the notebook
+	RETENTION_RETAIN     = "retain"     // Kept until deleted through the service
+	RETENTION_RETAIN_FOR = "retain-for" // Kept for some days, then removed
+)
+
+// Configuration of the service. Each field can be set in the YAML file and overridden by its
+// environment variable. Secrets are redacted when the configuration is printed.
+type Config struct {
+	Server     ServerConfig     `yaml:"server"`
+	Metrics    MetricsConfig    `yaml:"metrics"`
+	Tracing    TracingConfig    `yaml:"tracing"`
+	Auth       AuthConfig       `yaml:"auth"`
+	Redis      RedisConfig      `yaml:"redis"`
+	MongoDB    MongoDBConfig    `yaml:"mongodb"`
+	RabbitMQ   RabbitMQConfig   `yaml:"rabbitmq"`
+	Kubernetes KubernetesConfig `yaml:"kubernetes"`
+	Outbox     OutboxConfig     `yaml:"outbox"`
+	Retention  RetentionConfig  `yaml:"retention"`
+	Service    ServiceConfig    `yaml:"service"`
+}
+
+type ServerConfig struct {
+	URL string `yaml:"url" env:"SERVER_URL" required:"true"`
+}
+
+type MetricsConfig struct {
+	URL string `yaml:"url" env:"METRICS_URL" required:"true"`
+}
+
+type TracingConfig struct {
+	Exporter string `yaml:"exporter" env:"TRACING_EXPORTER"` // Empty disables the tracing
+}
+
+type AuthConfig struct {
+	SecretKey string `yaml:"secretKey" env:"SECRET_KEY" required:"true" secret:"true"` // Key signing the JWT of the users
+}
+
+type RedisConfig struct {
+	URL      string `yaml:"url" env:"REDIS_URL" required:"true"`
+	Password string `yaml:"password" env:"REDIS_PASSWORD" secret:"true"`
+}
+
+type MongoDBConfig struct {
+	URL      string `yaml:"url" env:"DB_URL" required:"true"`
+	User     string `yaml:"user" env:"DB_USER" required:"true"`
+	Password string `yaml:"password" env:"DB_PASS" required:"true" secret:"true"`
+	Database string `yaml:"database" env:"DB_NAME" required:"true"`
+}
+
+type RabbitMQConfig struct {
+	Driver         string        `yaml:"driver" env:"RABBIT_MQ_DRIVER"`
+	URL            string        `yaml:"url" env:"RABBIT_MQ_URL"` // Required by the amqp driver, as are the username and the password
+	Username       string        `yaml:"username" env:"RABBIT_MQ_USERNAME"`
+	Password       string        `yaml:"password" env:"RABBIT_MQ_PASSWORD" secret:"true"`
+	Prefetch       int           `yaml:"prefetch" env:"RABBIT_MQ_PREFETCH"`              // Messages delivered to the consumer before their ack
+	Workers        int           `yaml:"workers" env:"RABBIT_MQ_WORKERS"`                // Messages handled concurrently, 1 keeps the publication order
+	DedupTTL       time.Duration `yaml:"dedupTTL" env:"RABBIT_MQ_DEDUP_TTL"`             // Time a handled event is remembered to skip its duplicates
+	DedupLease     time.Duration `yaml:"dedupLease" env:"RABBIT_MQ_DEDUP_LEASE"`         // Time an event is leased to its handler, its redeliveries being retried until then
+	MaxRetries     int           `yaml:"maxRetries" env:"RABBIT_MQ_MAX_RETRIES"`         // Retries of a failed message before it is dead-lettered
+	RetryDelay     time.Duration `yaml:"retryDelay" env:"RABBIT_MQ_RETRY_DELAY"`         // Delay of the first retry, doubled on each retry
+	ReconnectDelay time.Duration `yaml:"reconnectDelay" env:"RABBIT_MQ_RECONNECT_DELAY"` // Delay of the first reconnection attempt, doubled on each attempt
+	PublishBuffer  int           `yaml:"publishBuffer" env:"RABBIT_MQ_PUBLISH_BUFFER"`   // Messages kept while disconnected, 0 fails the publications right away
+}
+
+type OutboxConfig struct {
+	PollInterval time.Duration `yaml:"pollInterval" env:"OUTBOX_POLL_INTERVAL"` // Interval between two looks for unsent events
+	BatchSize    int           `yaml:"batchSize" env:"OUTBOX_BATCH_SIZE"`       // Events read from the outbox at once
+	Retention    time.Duration `yaml:"retention" env:"OUTBOX_RETENTION"`        // Time the sent events are kept before MongoDB removes them
+}
+
+type RetentionConfig struct {
+	Policy        string        `yaml:"policy" env:"VOLUME_RETENTION_POLICY"`                // Applied to the workspace volumes created without one
+	Days          int           `yaml:"days" env:"VOLUME_RETENTION_DAYS"`                    // Days a volume is kept by the retain-for policy, unless set for the volume
+	NotifyBefore  time.Duration `yaml:"notifyBefore" env:"VOLUME_RETENTION_NOTIFY_BEFORE"`   // Time before the removal of a kept volume its owner is notified
+	SweepInterval time.Duration `yaml:"sweepInterval" env:"VOLUME_RETENTION_SWEEP_INTERVAL"` // Interval between two looks for the kept volumes to notify about or remove
+}
+
+type KubernetesConfig struct {
+	Kubeconfig string `yaml:"kubeconfig" env:"KUBECONFIG_PATH"` // Empty uses the in-cluster config, or the kubeconfig of kubectl outside a cluster
+	Context    string `yaml:"context" env:"KUBE_CONTEXT"`       // Empty uses the current context of the kubeconfig
+}
+
+type ServiceConfig struct {
+	UrlBase               string        `yaml:"urlBase" env:"URLBASE" required:"true"`
+	Namespace             string        `yaml:"namespace" env:"NAMESPACE" required:"true"`
+	DefaultStorageClass   string        `yaml:"defaultStorageClass" env:"DEFAULT_STORAGE_CLASS"`     // Empty uses the default storage class of the cluster
+	AllowedStorageClasses []string      `yaml:"allowedStorageClasses" env:"ALLOWED_STORAGE_CLASSES"` // Empty allows every storage class
+	DefaultAccessMode     string        `yaml:"defaultAccessMode" env:"DEFAULT_ACCESS_MODE"`
+	IdempotencyTTL        time.Duration `yaml:"idempotencyTTL" env:"IDEMPOTENCY_TTL"` // Time the outcome of a request with an idempotency key is kept
+}
+
+// Returns the configuration used for the settings left unset
+func Default() *Config {
+	return &Config{
+		Server:  ServerConfig{URL: ":50052"},
+		Metrics: MetricsConfig{URL: ":9090"},
+		RabbitMQ: RabbitMQConfig{
+			Driver:         AMQP_DRIVER,
+			Prefetch:       10,
+			Workers:        1,
+			DedupTTL:       24 * time.Hour,
+			DedupLease:     30 * time.Second,
+			MaxRetries:     5,
+			RetryDelay:     time.Second,
+			ReconnectDelay: time.Second,
+		},
+		Outbox: OutboxConfig{
+			PollInterval: 5 * time.Second,
+			BatchSize:    100,
+			Retention:    7 * 24 * time.Hour,
+		},
+		Retention: RetentionConfig{
+			Policy:        RETENTION_RETAIN_FOR,
+			Days:          7,
+			NotifyBefore:  24 * time.Hour,
+			SweepInterval: 10 * time.Minute,
+		},
+		Service: ServiceConfig{
+			DefaultAccessMode: "ReadWriteOnce",
+			IdempotencyTTL:    24 * time.Hour,
+		},
+	}
+}
+
+// Checks the values that can't be described by the tags
+func (c *Config) validate() []error {
+	var errs []error
+
+	if !slices.Contains([]string{"", OTLP_EXPORTER, STDOUT_EXPORTER}, c.Tracing.Exporter) {
+		errs = append(errs, fmt.Errorf("tracing.exporter must be %s or %s, got %q", OTLP_EXPORTER, STDOUT_EXPORTER, c.Tracing.Exporter))
+	}
+
+	accessModes := []string{"ReadWriteOnce", "ReadWriteMany", "ReadOnlyMany", "ReadWriteOncePod"}
+	if !slices.Contains(accessModes, c.Service.DefaultAccessMode) {
+		errs = append(errs, fmt.Errorf("service.defaultAccessMode must be one of %v, got %q", accessModes, c.Service.DefaultAccessMode))
+	}
+
+	if c.Service.DefaultStorageClass != "" && len(c.Service.AllowedStorageClasses) > 0 && !slices.Contains(c.Service.AllowedStorageClasses, c.Service.DefaultStorageClass) {
+		errs = append(errs, fmt.Errorf("service.defaultStorageClass %s is not in service.allowedStorageClasses", c.Service.DefaultStorageClass))
+	}
+
+	switch c.RabbitMQ.Driver {
+	case AMQP_DRIVER:
+		for _, setting := range []struct{ path, env, value string }{
+			{"rabbitmq.url", "RABBIT_MQ_URL", c.RabbitMQ.URL},
+			{"rabbitmq.username", "RABBIT_MQ_USERNAME", c.RabbitMQ.Username},
+			{"rabbitmq.password", "RABBIT_MQ_PASSWORD", c.RabbitMQ.Password},
+		} {
+			if setting.value == "" {
+				errs = append(errs, fmt.Errorf("%s is required by the %s driver, set it in the config file or with %s", setting.path, AMQP_DRIVER, setting.env))
+			}
+		}
+	case MEMORY_DRIVER:
+	default:
+		errs = append(errs, fmt.Errorf("rabbitmq.driver must be %s or %s, got %q", AMQP_DRIVER, MEMORY_DRIVER, c.RabbitMQ.Driver))
+	}
+	if c.RabbitMQ.Prefetch <= 0 {
+		errs = append(errs, fmt.Errorf("rabbitmq.prefetch must be positive, got %d", c.RabbitMQ.Prefetch))
+	}
+	if c.RabbitMQ.Workers <= 0 || c.RabbitMQ.Workers > c.RabbitMQ.Prefetch {
+		errs = append(errs, fmt.Errorf("rabbitmq.workers must be between 1 and rabbitmq.prefetch, got %d", c.RabbitMQ.Workers))
+	}
+	if c.RabbitMQ.DedupTTL <= 0 {
+		errs = append(errs, fmt.Errorf("rabbitmq.dedupTTL must be positive, got %v", c.RabbitMQ.DedupTTL))
+	}
+	if c.RabbitMQ.DedupLease <= 0 || c.RabbitMQ.DedupLease > c.RabbitMQ.DedupTTL {
+		errs = append(errs, fmt.Errorf("rabbitmq.dedupLease must be positive and at most rabbitmq.dedupTTL, got %v", c.RabbitMQ.DedupLease))
+	}
+	if c.RabbitMQ.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("rabbitmq.maxRetries can't be negative, got %d", c.RabbitMQ.MaxRetries))
+	}
+	if c.RabbitMQ.RetryDelay < time.Millisecond {
+		errs = append(errs, fmt.Errorf("rabbitmq.retryDelay must be at least 1ms, got %v", c.RabbitMQ.RetryDelay))
+	}
+
+	if c.RabbitMQ.ReconnectDelay <= 0 {
+		errs = append(errs, fmt.Errorf("rabbitmq.reconnectDelay must be positive, got %v", c.RabbitMQ.ReconnectDelay))
+	}
+	if c.RabbitMQ.PublishBuffer < 0 {
+		errs = append(errs, fmt.Errorf("rabbitmq.publishBuffer can't be negative, got %d", c.RabbitMQ.PublishBuffer))
+	}
+
+	if c.Outbox.PollInterval <= 0 {
+		errs = append(errs, fmt.Errorf("outbox.pollInterval must be positive, got %v", c.Outbox.PollInterval))
+	}
+	if c.Outbox.BatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("outbox.batchSize must be positive, got %d", c.Outbox.BatchSize))
+	}
+	if c.Outbox.Retention < time.Second {
+		errs = append(errs, fmt.Errorf("outbox.retention must be at least 1s, got %v", c.Outbox.Retention))
+	}
+
+	retentionPolicies := []string{RETENTION_DELETE, RETENTION_RETAIN, RETENTION_RETAIN_FOR}
+	if !slices.Contains(retentionPolicies, c.Retention.Policy) {
+		errs = append(errs, fmt.Errorf("retention.policy must be one of %v, got %q", retentionPolicies, c.Retention.Policy))
+	}
+	if c.Retention.Days <= 0 {
+		errs = append(errs, fmt.Errorf("retention.days must be positive, got %d", c.Retention.Days))
+	}
+	if c.Retention.NotifyBefore < 0 {
+		errs = append(errs, fmt.Errorf("retention.notifyBefore can't be negative, got %v", c.Retention.NotifyBefore))
+	}
+	if c.Retention.SweepInterval <= 0 {
+		errs = append(errs, fmt.Errorf("retention.sweepInterval must be positive, got %v", c.Retention.SweepInterval))
+	}
+
+	if c.Service.IdempotencyTTL <= 0 {
+		errs = append(errs, fmt.Errorf("service.idempotencyTTL must be positive, got %v", c.Service.IdempotencyTTL))
+	}
+
+	return errs
+}
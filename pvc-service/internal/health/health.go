@@ -0,0 +1,136 @@
+// Package that reports the health of the service through the standard gRPC health checking protocol
+package health
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	CHECK_INTERVAL = 10 * time.Second
+	CHECK_TIMEOUT  = 3 * time.Second
+)
+
+// LIVENESS stays SERVING while the process runs, since restarting it does not bring a dependency back.
+// The readiness is served for the empty service name and for every registered gRPC service.
+const LIVENESS = "liveness"
+
+// Check reports an error when a dependency cannot be used
+type Check func(ctx context.Context) error
+
+type dependency struct {
+	name  string
+	check Check
+	err   error
+}
+
+// Checker periodically checks the critical dependencies and serves the result over gRPC
+type Checker struct {
+	server       *health.Server
+	dependencies []*dependency
+	services     []string
+	ready        bool
+	mu           sync.Mutex
+}
+
+// Method to create a checker. The service is not ready until the dependencies were checked once.
+func NewChecker() *Checker {
+	server := health.NewServer()
+	server.SetServingStatus(LIVENESS, healthpb.HealthCheckResponse_SERVING)
+	server.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	return &Checker{server: server}
+}
+
+// AddCheck registers a critical dependency. Its own status is served under its name.
+func (c *Checker) AddCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dependencies = append(c.dependencies, &dependency{name: name, check: check})
+	c.server.SetServingStatus(name, healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+// Register serves the health service on the gRPC server, reporting the readiness of the services already registered on it
+func (c *Checker) Register(server *grpc.Server) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name := range server.GetServiceInfo() {
+		c.services = append(c.services, name)
+		c.server.SetServingStatus(name, servingStatus(c.ready))
+	}
+
+	healthpb.RegisterHealthServer(server, c.server)
+}
+
+// Run checks the dependencies at every interval until the context is done
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(CHECK_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		c.CheckAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckAll runs every check once and updates the served status, reporting whether the service is ready
+func (c *Checker) CheckAll(ctx context.Context) bool {
+	c.mu.Lock()
+	dependencies := c.dependencies
+	c.mu.Unlock()
+
+	// Run the checks without holding the lock, as they can take until the timeout
+	ready := true
+	for _, dep := range dependencies {
+		checkCtx, cancel := context.WithTimeout(ctx, CHECK_TIMEOUT)
+		err := dep.check(checkCtx)
+		cancel()
+
+		// Only log the changes, the checks run too often otherwise
+		if err != nil && dep.err == nil {
+			log.Printf("Dependency %s is down: %v", dep.name, err)
+		} else if err == nil && dep.err != nil {
+			log.Printf("Dependency %s recovered", dep.name)
+		}
+		dep.err = err
+
+		c.server.SetServingStatus(dep.name, servingStatus(err == nil))
+		ready = ready && err == nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ready = ready
+	c.server.SetServingStatus("", servingStatus(ready))
+	for _, name := range c.services {
+		c.server.SetServingStatus(name, servingStatus(ready))
+	}
+
+	return ready
+}
+
+func servingStatus(serving bool) healthpb.HealthCheckResponse_ServingStatus {
+	if serving {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
+
+// Shutdown reports every service as NOT_SERVING for good, so no new calls are routed to the stopping server
+func (c *Checker) Shutdown() {
+	c.server.Shutdown()
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var grpcHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "grpc_server_handled_total",
+	Help: "Number of gRPC calls completed by the server, by method and status code.",
+}, []string{"method", "code"})
+
+var grpcHandlingSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "grpc_server_handling_seconds",
+	Help:    "Time the server took to complete gRPC calls, by method.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"method"})
+
+// UnaryServerInterceptor counts and times every unary call
+func UnaryServerInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (resp interface{}, err error) {
+	start := time.Now()
+	resp, err = handler(ctx, req)
+	observeCall(info.FullMethod, start, err)
+
+	return resp, err
+}
+
+func observeCall(method string, start time.Time, err error) {
+	grpcHandled.WithLabelValues(method, status.Code(err).String()).Inc()
+	grpcHandlingSeconds.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,74 @@
+// Package that exposes the prometheus metrics of the service
+package metrics
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"pvc-service/internal/config"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Time a gauge may take to count its values while prometheus scrapes the service
+const COLLECT_TIMEOUT = 10 * time.Second
+
+// Method to serve the metrics on the configured url in the background
+func SetupMetricsServer() {
+	url := config.Get().Metrics.URL
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{
+		Addr:              url,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		log.Printf("Metrics server running on %s", url)
+		if err := server.ListenAndServe(); err != nil {
+			log.Printf("Unable to run metrics server on %s: %v", url, err)
+		}
+	}()
+}
+
+// Counts the values of a gauge by label
+type CountFunc func(ctx context.Context) (map[string]float64, error)
+
+// Gauge whose values are counted every time prometheus scrapes the service
+type countCollector struct {
+	desc  *prometheus.Desc
+	count CountFunc
+}
+
+// Method to register a gauge with a single label, counted on every scrape
+func RegisterCountGauge(name string, help string, label string, count CountFunc) {
+	prometheus.MustRegister(&countCollector{
+		desc:  prometheus.NewDesc(name, help, []string{label}, nil),
+		count: count,
+	})
+}
+
+func (c *countCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *countCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), COLLECT_TIMEOUT)
+	defer cancel()
+
+	counts, err := c.count(ctx)
+	if err != nil {
+		// Skip the gauge, so the other metrics are still scraped
+		log.Printf("Failed counting %s: %v", c.desc, err)
+		return
+	}
+
+	for label, value := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, value, label)
+	}
+}
@@ -0,0 +1,78 @@
+package rabbitmq
+
+import (
+	"pvc-service/api/events"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Second, retryDelay(time.Second, 1))
+	assert.Equal(t, 2*time.Second, retryDelay(time.Second, 2))
+	assert.Equal(t, 16*time.Second, retryDelay(time.Second, 5))
+
+	// Capped, however many retries
+	assert.Equal(t, MAX_RETRY_DELAY, retryDelay(time.Second, 100))
+
+	assert.Equal(t, QUEUE_NAME+".retry.4s", retryQueueName(retryDelay(time.Second, 3)))
+}
+
+func TestRetryHeaders(t *testing.T) {
+	// First delivery
+	d := amqp.Delivery{RoutingKey: "PVC.DELETE"}
+	assert.Equal(t, "PVC.DELETE", originalRoutingKey(d))
+	assert.Equal(t, 0, retriesOf(d))
+
+	// Delivery coming back from a delay queue
+	d = amqp.Delivery{
+		RoutingKey: QUEUE_NAME,
+		Headers:    amqp.Table{ROUTING_KEY_HEADER: "PVC.DELETE", RETRIES_HEADER: int32(2)},
+	}
+	assert.Equal(t, "PVC.DELETE", originalRoutingKey(d))
+	assert.Equal(t, 2, retriesOf(d))
+}
+
+func TestPublishingOfAssignsMissingIds(t *testing.T) {
+	d := amqp.Delivery{ContentType: LEGACY_CONTENT_TYPE, Body: []byte(`{}`)}
+
+	msg := publishingOf(d)
+
+	assert.NotEmpty(t, msg.MessageId)
+	assert.Equal(t, amqp.Persistent, msg.DeliveryMode)
+	assert.Equal(t, LEGACY_CONTENT_TYPE, msg.ContentType)
+
+	// Existing ids are kept
+	d.MessageId = "event-1"
+	assert.Equal(t, "event-1", publishingOf(d).MessageId)
+}
+
+func TestToDeadLetter(t *testing.T) {
+	event, err := NewEvent("PVC.DELETE", "alice", &events.PvcDeleted{PvcName: "workspace-a"})
+	assert.Nil(t, err)
+	body, err := proto.Marshal(event)
+	assert.Nil(t, err)
+
+	d := amqp.Delivery{
+		RoutingKey:  QUEUE_NAME,
+		MessageId:   event.Id,
+		ContentType: EVENT_CONTENT_TYPE,
+		Headers:     amqp.Table{ROUTING_KEY_HEADER: "PVC.DELETE", RETRIES_HEADER: int32(5), ERROR_HEADER: "failed"},
+		Body:        body,
+	}
+
+	deadLetter := toDeadLetter(d)
+
+	assert.Equal(t, event.Id, deadLetter.ID)
+	assert.Equal(t, "PVC.DELETE", deadLetter.RoutingKey)
+	assert.Equal(t, 5, deadLetter.Retries)
+	assert.Equal(t, "failed", deadLetter.Error)
+	assert.True(t, proto.Equal(event, deadLetter.Event))
+
+	// Messages that can't be decoded are still listed
+	d.Body = []byte{0xff}
+	assert.Nil(t, toDeadLetter(d).Event)
+}
@@ -0,0 +1,96 @@
+package rabbitmq
+
+import (
+	"context"
+	"pvc-service/api/events"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNewEvent(t *testing.T) {
+	event, err := NewEvent("NOTEBOOK.DELETE", "alice", &events.NotebookDeleted{NotebookName: "notebook-a"})
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, event.Id)
+	assert.Equal(t, "NOTEBOOK.DELETE", event.Type)
+	assert.Equal(t, CONSUMER_TAG, event.Source)
+	assert.Equal(t, "notebook-a", event.Subject)
+	assert.Equal(t, "alice", event.Actor)
+	assert.Equal(t, uint32(SCHEMA_VERSION), event.SchemaVersion)
+	assert.NotNil(t, event.Time)
+}
+
+func TestDecodeEventRoundTrip(t *testing.T) {
+	event, err := NewEvent("PVC.DELETE", "alice", &events.PvcDeleted{PvcName: "workspace-a"})
+	assert.Nil(t, err)
+	body, err := proto.Marshal(event)
+	assert.Nil(t, err)
+
+	decoded, err := DecodeEvent(amqp.Delivery{ContentType: EVENT_CONTENT_TYPE, RoutingKey: "PVC.DELETE", Body: body})
+
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(event, decoded))
+}
+
+func TestDecodeEventLegacyJSON(t *testing.T) {
+	published := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	d := amqp.Delivery{
+		ContentType: LEGACY_CONTENT_TYPE,
+		RoutingKey:  "PVC.DELETE",
+		MessageId:   "legacy-1",
+		AppId:       "pvc-service",
+		Timestamp:   published,
+		Body:        []byte(`{"pvc_name": "workspace-a", "unknown": true}`),
+	}
+
+	event, err := DecodeEvent(d)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "legacy-1", event.Id)
+	assert.Equal(t, "PVC.DELETE", event.Type)
+	assert.Equal(t, "pvc-service", event.Source)
+	assert.Equal(t, "workspace-a", event.Subject)
+	assert.Equal(t, uint32(0), event.SchemaVersion)
+	assert.Equal(t, published, event.Time.AsTime())
+
+	data, err := event.Data.UnmarshalNew()
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(&events.PvcDeleted{PvcName: "workspace-a"}, data))
+}
+
+func TestDecodeEventErrors(t *testing.T) {
+	// No schema for the routing key
+	_, err := DecodeEvent(amqp.Delivery{RoutingKey: "UNKNOWN.EVENT", Body: []byte(`{}`)})
+	assert.ErrorContains(t, err, "no schema for routing key UNKNOWN.EVENT")
+
+	// Invalid legacy payload
+	_, err = DecodeEvent(amqp.Delivery{RoutingKey: "PVC.DELETE", Body: []byte(`not json`)})
+	assert.ErrorContains(t, err, "failed decoding legacy payload")
+
+	// Invalid envelope
+	_, err = DecodeEvent(amqp.Delivery{ContentType: EVENT_CONTENT_TYPE, RoutingKey: "PVC.DELETE", Body: []byte{0xff}})
+	assert.ErrorContains(t, err, "failed decoding event")
+}
+
+func TestOnEvent(t *testing.T) {
+	var received *events.PvcDeleted
+	handler := OnEvent(func(ctx context.Context, event *events.Envelope, data *events.PvcDeleted) error {
+		received = data
+		return nil
+	})
+
+	// Data of the expected type is passed on
+	event, _ := NewEvent("PVC.DELETE", "alice", &events.PvcDeleted{PvcName: "workspace-a"})
+	assert.Nil(t, handler(context.Background(), event))
+	assert.Equal(t, "workspace-a", received.PvcName)
+
+	// Data of another type fails
+	received = nil
+	event, _ = NewEvent("PVC.DELETE", "alice", &events.NotebookDeleted{NotebookName: "notebook-a"})
+	assert.ErrorContains(t, handler(context.Background(), event), "unexpected data")
+	assert.Nil(t, received)
+}
@@ -0,0 +1,238 @@
+package rabbitmq
+
+import (
+	"context"
+	"log"
+	"pvc-service/api/events"
+	"pvc-service/internal/config"
+	"slices"
+	"sync"
+
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	"google.golang.org/protobuf/proto"
+)
+
+// MemoryExchange routes the events published on its buses to the buses binding their routing key, as the
+// RabbitMQ exchange does for the queues of the services. Services started in the same process share their events
+// by building their bus on the same exchange.
+type MemoryExchange struct {
+	mu    sync.Mutex
+	buses []*MemoryBus
+}
+
+func NewMemoryExchange() *MemoryExchange {
+	return &MemoryExchange{}
+}
+
+// NewBus returns a bus of the exchange consuming the given routing keys, retrying the events as configured and
+// keeping a copy of the events it publishes when capture is set
+func (e *MemoryExchange) NewBus(cfg config.RabbitMQConfig, bindings []string, capture bool) *MemoryBus {
+	bus := &MemoryBus{exchange: e, cfg: cfg, bindings: bindings, capture: capture}
+
+	e.mu.Lock()
+	e.buses = append(e.buses, bus)
+	e.mu.Unlock()
+
+	return bus
+}
+
+// Delivers the event to every bus binding its key. Like the exchange, the events no bus is bound for are dropped.
+func (e *MemoryExchange) route(ctx context.Context, event *events.Envelope) {
+	e.mu.Lock()
+	buses := slices.Clone(e.buses)
+	e.mu.Unlock()
+
+	for _, bus := range buses {
+		if slices.Contains(bus.bindings, event.Type) {
+			bus.receive(ctx, event)
+		}
+	}
+}
+
+// MemoryBus is an in-process RabbitMQHandler for the tests and the local development. The events published
+// under a bound routing key are delivered to the routers right away, in the goroutine of the publisher, so
+// they are handled once the publication returns. Failed events are retried without delay, then dead-lettered.
+type MemoryBus struct {
+	exchange    *MemoryExchange
+	cfg         config.RabbitMQConfig
+	bindings    []string
+	capture     bool
+	mu          sync.Mutex // Guards the fields below
+	router      *Router
+	closed      bool
+	queued      []*events.Envelope // Events of the bound keys received while nobody consumes
+	published   []*events.Envelope // Every published event, when capturing
+	deadLetters []DeadLetter
+	deliveries  sync.WaitGroup // Tracks the events being handled
+}
+
+// NewMemoryBus returns a bus of an exchange of its own consuming the bound keys of the service, so it only
+// delivers the events the service publishes itself
+func NewMemoryBus(cfg config.RabbitMQConfig, capture bool) *MemoryBus {
+	return NewMemoryExchange().NewBus(cfg, BOUND_KEYS, capture)
+}
+
+// PublishEvent wraps the data in an event envelope and publishes it under the event type as routing key
+func (b *MemoryBus) PublishEvent(ctx context.Context, eventType, actor string, data proto.Message) error {
+	event, err := NewEvent(eventType, actor, data)
+	if err != nil {
+		return err
+	}
+
+	return b.publish(ctx, event)
+}
+
+// PublishConfirmed publishes the event, confirmed as soon as it was handled
+func (b *MemoryBus) PublishConfirmed(ctx context.Context, event *events.Envelope) error {
+	return b.publish(ctx, event)
+}
+
+func (b *MemoryBus) publish(ctx context.Context, event *events.Envelope) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrNotConnected
+	}
+	if b.capture {
+		b.published = append(b.published, proto.Clone(event).(*events.Envelope))
+	}
+	b.mu.Unlock()
+
+	b.exchange.route(ctx, event)
+	return nil
+}
+
+// Handles an event routed to the bus, or queues it while nobody consumes
+func (b *MemoryBus) receive(ctx context.Context, event *events.Envelope) {
+	b.mu.Lock()
+	if b.router == nil {
+		b.queued = append(b.queued, event)
+		b.mu.Unlock()
+		return
+	}
+	router := b.router
+	b.deliveries.Add(1)
+	b.mu.Unlock()
+
+	defer b.deliveries.Done()
+	b.deliver(ctx, router, event)
+}
+
+// Runs the router until the event is handled or its retries are exhausted
+func (b *MemoryBus) deliver(ctx context.Context, router *Router, event *events.Envelope) {
+	// Handlers only get the trace of the publisher, as through RabbitMQ
+	headers := amqp.Table{}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(headers))
+
+	var err error
+	for retries := 0; retries <= b.cfg.MaxRetries; retries++ {
+		if err = router.Dispatch(extractTraceContext(headers), event); err == nil {
+			return
+		}
+	}
+
+	log.Printf("Dead-lettering event %s %s after %d retries: %v", event.Type, event.Id, b.cfg.MaxRetries, err)
+	b.mu.Lock()
+	b.deadLetters = append(b.deadLetters, DeadLetter{
+		ID:         event.Id,
+		RoutingKey: event.Type,
+		Retries:    b.cfg.MaxRetries,
+		Error:      err.Error(),
+		Event:      event,
+	})
+	b.mu.Unlock()
+}
+
+// ConsumeMessages delivers the events of the bound keys to the router, starting with the ones queued until now
+func (b *MemoryBus) ConsumeMessages(router *Router) {
+	b.mu.Lock()
+	b.router = router
+	queued := b.queued
+	b.queued = nil
+	b.deliveries.Add(1)
+	b.mu.Unlock()
+
+	defer b.deliveries.Done()
+	for _, event := range queued {
+		b.deliver(context.Background(), router, event)
+	}
+}
+
+// StopConsuming queues the next events and waits for the ones being handled
+func (b *MemoryBus) StopConsuming() {
+	b.mu.Lock()
+	b.router = nil
+	b.mu.Unlock()
+
+	b.deliveries.Wait()
+}
+
+// ListDeadLetters returns up to limit dead-lettered events, oldest first
+func (b *MemoryBus) ListDeadLetters(limit int) ([]DeadLetter, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return slices.Clone(b.deadLetters[:min(limit, len(b.deadLetters))]), nil
+}
+
+// ReplayDeadLetters publishes the dead-lettered events with the given ids again, or every one when no id is given
+func (b *MemoryBus) ReplayDeadLetters(ctx context.Context, ids []string) (int, error) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return 0, ErrNotConnected
+	}
+	var replayed, kept []DeadLetter
+	for _, deadLetter := range b.deadLetters {
+		if len(ids) == 0 || slices.Contains(ids, deadLetter.ID) {
+			replayed = append(replayed, deadLetter)
+		} else {
+			kept = append(kept, deadLetter)
+		}
+	}
+	b.deadLetters = kept
+	b.mu.Unlock()
+
+	// Replayed to the bus only, as the dead letters go back to the queue of the service
+	for _, deadLetter := range replayed {
+		b.receive(ctx, deadLetter.Event)
+	}
+
+	return len(replayed), nil
+}
+
+// Published returns the events published since the bus was created, when capturing
+func (b *MemoryBus) Published() []*events.Envelope {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return slices.Clone(b.published)
+}
+
+// State returns CONNECTED until the bus is closed
+func (b *MemoryBus) State() ConnectionState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return CLOSED
+	}
+	return CONNECTED
+}
+
+// CheckConnection fails once the bus is closed
+func (b *MemoryBus) CheckConnection() error {
+	if b.State() != CONNECTED {
+		return ErrNotConnected
+	}
+	return nil
+}
+
+// Close rejects the next publications
+func (b *MemoryBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+}
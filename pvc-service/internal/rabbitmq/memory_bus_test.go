@@ -0,0 +1,128 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"pvc-service/api/events"
+	"pvc-service/internal/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns a bus retrying twice and a router counting the handled events of the bound keys
+func createMemoryBus(capture bool, failures int) (*MemoryBus, *Router, map[string]int) {
+	handled := map[string]int{}
+	handler := func(ctx context.Context, event *events.Envelope) error {
+		handled[event.Id]++
+		if handled[event.Id] <= failures {
+			return errors.New("failed")
+		}
+		return nil
+	}
+
+	router := NewRouter().Use(Recovery())
+	for _, key := range BOUND_KEYS {
+		router.Handle(key, handler)
+	}
+
+	return NewMemoryBus(config.RabbitMQConfig{MaxRetries: 2}, capture), router, handled
+}
+
+func TestMemoryBusDeliversBoundKeys(t *testing.T) {
+	bus, router, handled := createMemoryBus(true, 0)
+	bus.ConsumeMessages(router)
+
+	assert.Nil(t, bus.PublishEvent(context.Background(), "NOTEBOOK.DELETE", "alice", &events.NotebookDeleted{NotebookName: "a"}))
+	assert.Nil(t, bus.PublishEvent(context.Background(), "PVC.DELETE", "alice", &events.PvcDeleted{PvcName: "workspace-a"}))
+
+	// Handled before the publication returned, the event of the key nobody is bound to is only captured
+	published := bus.Published()
+	assert.Len(t, published, 2)
+	assert.Equal(t, map[string]int{published[0].Id: 1}, handled)
+	assert.Equal(t, "PVC.DELETE", published[1].Type)
+	assert.Equal(t, "workspace-a", published[1].Subject)
+}
+
+func TestMemoryBusQueuesUntilConsumed(t *testing.T) {
+	bus, router, handled := createMemoryBus(false, 0)
+
+	assert.Nil(t, bus.PublishEvent(context.Background(), "NOTEBOOK.CREATE", "alice", &events.NotebookCreated{NotebookName: "a"}))
+	assert.Empty(t, handled)
+	assert.Empty(t, bus.Published())
+
+	bus.ConsumeMessages(router)
+	assert.Len(t, handled, 1)
+
+	bus.StopConsuming()
+	assert.Nil(t, bus.PublishEvent(context.Background(), "NOTEBOOK.CREATE", "alice", &events.NotebookCreated{NotebookName: "b"}))
+	assert.Len(t, handled, 1)
+}
+
+func TestMemoryBusDeadLettersAndReplays(t *testing.T) {
+	bus, router, handled := createMemoryBus(false, 3)
+	bus.ConsumeMessages(router)
+
+	event, err := NewEvent("NOTEBOOK.DELETE", "alice", &events.NotebookDeleted{NotebookName: "a"})
+	assert.Nil(t, err)
+	assert.Nil(t, bus.PublishConfirmed(context.Background(), event))
+
+	// Handled once, then retried twice
+	assert.Equal(t, 3, handled[event.Id])
+	deadLetters, err := bus.ListDeadLetters(10)
+	assert.Nil(t, err)
+	assert.Len(t, deadLetters, 1)
+	assert.Equal(t, event.Id, deadLetters[0].ID)
+	assert.Equal(t, "NOTEBOOK.DELETE", deadLetters[0].RoutingKey)
+	assert.Equal(t, "failed", deadLetters[0].Error)
+
+	replayed, err := bus.ReplayDeadLetters(context.Background(), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, replayed)
+	assert.Equal(t, 4, handled[event.Id])
+
+	deadLetters, err = bus.ListDeadLetters(10)
+	assert.Nil(t, err)
+	assert.Empty(t, deadLetters)
+}
+
+func TestMemoryBusClose(t *testing.T) {
+	bus, _, _ := createMemoryBus(false, 0)
+	assert.Equal(t, CONNECTED, bus.State())
+	assert.Nil(t, bus.CheckConnection())
+
+	bus.Close()
+
+	assert.Equal(t, CLOSED, bus.State())
+	assert.ErrorIs(t, bus.CheckConnection(), ErrNotConnected)
+	assert.ErrorIs(t, bus.PublishEvent(context.Background(), "NOTEBOOK.DELETE", "alice", &events.NotebookDeleted{}), ErrNotConnected)
+}
+
+func TestMemoryExchangeDeliversToTheBoundBuses(t *testing.T) {
+	exchange := NewMemoryExchange()
+	publisher := exchange.NewBus(config.RabbitMQConfig{}, []string{"PVC.DELETE"}, false)
+	consumer := exchange.NewBus(config.RabbitMQConfig{}, []string{"NOTEBOOK.DELETE"}, false)
+
+	handled := map[string][]string{}
+	router := func(name string) *Router {
+		return NewRouter().Handle("NOTEBOOK.DELETE", func(ctx context.Context, event *events.Envelope) error {
+			handled[name] = append(handled[name], event.Subject)
+			return errors.New("failed")
+		})
+	}
+	publisher.ConsumeMessages(router("publisher"))
+	consumer.ConsumeMessages(router("consumer"))
+
+	// Only the bus binding the key handles the event, the publisher doesn't
+	assert.Nil(t, publisher.PublishEvent(context.Background(), "NOTEBOOK.DELETE", "alice", &events.NotebookDeleted{NotebookName: "a"}))
+	assert.Equal(t, map[string][]string{"consumer": {"a"}}, handled)
+
+	// Dead letters are replayed to their bus only
+	replayed, err := publisher.ReplayDeadLetters(context.Background(), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, replayed)
+	replayed, err = consumer.ReplayDeadLetters(context.Background(), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, replayed)
+	assert.Equal(t, map[string][]string{"consumer": {"a", "a"}}, handled)
+}
@@ -0,0 +1,173 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"pvc-service/api/events"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns a middleware recording its name before and after the handler
+func recording(name string, calls *[]string) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, event *events.Envelope) error {
+			*calls = append(*calls, name+" before")
+			err := next(ctx, event)
+			*calls = append(*calls, name+" after")
+			return err
+		}
+	}
+}
+
+func TestRouterDispatchesThroughMiddlewares(t *testing.T) {
+	var calls []string
+	router := NewRouter().
+		Use(recording("outer", &calls), recording("inner", &calls)).
+		Handle("PVC.DELETE", func(context.Context, *events.Envelope) error {
+			calls = append(calls, "handler")
+			return nil
+		})
+
+	err := router.Dispatch(context.Background(), &events.Envelope{Type: "PVC.DELETE"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"outer before", "inner before", "handler", "inner after", "outer after"}, calls)
+	assert.Equal(t, []string{"PVC.DELETE"}, router.Keys())
+}
+
+func TestRouterDefaultHandler(t *testing.T) {
+	// Events nobody handles are dropped rather than crashing the consumer
+	router := NewRouter()
+	assert.Nil(t, router.Dispatch(context.Background(), &events.Envelope{Type: "PVC.CREATE"}))
+
+	var handled *events.Envelope
+	router.Default(func(ctx context.Context, event *events.Envelope) error {
+		handled = event
+		return errors.New("unrouted")
+	})
+
+	event := &events.Envelope{Type: "PVC.CREATE"}
+	assert.EqualError(t, router.Dispatch(context.Background(), event), "unrouted")
+	assert.Same(t, event, handled)
+}
+
+func TestRecoveryTurnsPanicsIntoErrors(t *testing.T) {
+	router := NewRouter().
+		Use(Recovery()).
+		Handle("PVC.DELETE", func(context.Context, *events.Envelope) error {
+			panic("boom")
+		})
+
+	err := router.Dispatch(context.Background(), &events.Envelope{Type: "PVC.DELETE"})
+
+	assert.EqualError(t, err, "handler panicked: boom")
+}
+
+func TestDeduplicateRunsHandlersOncePerEvent(t *testing.T) {
+	failing := true
+	runs := 0
+	router := NewRouter().
+		Use(Deduplicate(NewMemoryDedupStore(time.Minute, time.Hour))).
+		Handle("PVC.DELETE", func(context.Context, *events.Envelope) error {
+			runs++
+			if failing {
+				return errors.New("failed")
+			}
+			return nil
+		})
+	event := &events.Envelope{Id: "event-1", Type: "PVC.DELETE"}
+
+	// A failed event is handled again on its retry
+	assert.EqualError(t, router.Dispatch(context.Background(), event), "failed")
+	failing = false
+	assert.Nil(t, router.Dispatch(context.Background(), event))
+	assert.Equal(t, 2, runs)
+
+	// Then its duplicates are skipped
+	assert.Nil(t, router.Dispatch(context.Background(), event))
+	assert.Equal(t, 2, runs)
+
+	// Other events are handled
+	assert.Nil(t, router.Dispatch(context.Background(), &events.Envelope{Id: "event-2", Type: "PVC.DELETE"}))
+	assert.Equal(t, 3, runs)
+}
+
+func TestDeduplicateHandlesEventsWhoseHandlerNeverReturned(t *testing.T) {
+	store := NewMemoryDedupStore(50*time.Millisecond, time.Hour)
+	runs := 0
+	router := NewRouter().
+		Use(Deduplicate(store)).
+		Handle("PVC.DELETE", func(context.Context, *events.Envelope) error {
+			runs++
+			return nil
+		})
+	event := &events.Envelope{Id: "event-1", Type: "PVC.DELETE"}
+
+	// Claimed by a handler that crashed before returning
+	claimed, processed, err := store.Claim(context.Background(), event.Id)
+	assert.Nil(t, err)
+	assert.True(t, claimed)
+	assert.False(t, processed)
+
+	// The redelivery is retried while the event is leased
+	assert.EqualError(t, router.Dispatch(context.Background(), event), "event event-1 is being handled, retrying it later")
+	assert.Equal(t, 0, runs)
+
+	// Then handled once the lease expired
+	time.Sleep(60 * time.Millisecond)
+	assert.Nil(t, router.Dispatch(context.Background(), event))
+	assert.Equal(t, 1, runs)
+
+	// And remembered past the lease
+	time.Sleep(60 * time.Millisecond)
+	assert.Nil(t, router.Dispatch(context.Background(), event))
+	assert.Equal(t, 1, runs)
+}
+
+func TestMemoryDedupStoreExpires(t *testing.T) {
+	store := NewMemoryDedupStore(time.Minute, 0)
+
+	claimed, _, err := store.Claim(context.Background(), "event-1")
+	assert.Nil(t, err)
+	assert.True(t, claimed)
+	assert.Nil(t, store.Complete(context.Background(), "event-1"))
+
+	// Remembered for no time
+	claimed, processed, err := store.Claim(context.Background(), "event-1")
+	assert.Nil(t, err)
+	assert.True(t, claimed)
+	assert.False(t, processed)
+}
+
+// Store failing every call
+type failingDedupStore struct{}
+
+func (failingDedupStore) Claim(context.Context, string) (bool, bool, error) {
+	return false, false, errors.New("redis down")
+}
+
+func (failingDedupStore) Complete(context.Context, string) error {
+	return errors.New("redis down")
+}
+
+func (failingDedupStore) Release(context.Context, string) error {
+	return errors.New("redis down")
+}
+
+func TestDeduplicateHandlesEventsWhenTheStoreFails(t *testing.T) {
+	runs := 0
+	router := NewRouter().
+		Use(Deduplicate(failingDedupStore{})).
+		Handle("PVC.DELETE", func(context.Context, *events.Envelope) error {
+			runs++
+			return nil
+		})
+	event := &events.Envelope{Id: "event-1", Type: "PVC.DELETE"}
+
+	assert.Nil(t, router.Dispatch(context.Background(), event))
+	assert.Nil(t, router.Dispatch(context.Background(), event))
+	assert.Equal(t, 2, runs)
+}
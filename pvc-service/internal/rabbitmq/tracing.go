@@ -0,0 +1,65 @@
+package rabbitmq
+
+import (
+	"context"
+
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const TRACER_NAME = "pvc-service/internal/rabbitmq"
+
+// headerCarrier stores the trace context in the headers of a message
+type headerCarrier amqp.Table
+
+func (c headerCarrier) Get(key string) string {
+	value, _ := c[key].(string)
+	return value
+}
+
+func (c headerCarrier) Set(key string, value string) {
+	c[key] = value
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Starts the span of a published message and writes its context to the headers
+func startPublishSpan(ctx context.Context, key string, headers amqp.Table) (context.Context, trace.Span) {
+	ctx, span := otel.Tracer(TRACER_NAME).Start(ctx, key+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(messagingAttributes(key)...),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(headers))
+
+	return ctx, span
+}
+
+// Returns a context carrying the trace context in the headers of a consumed message
+func extractTraceContext(headers amqp.Table) context.Context {
+	return otel.GetTextMapPropagator().Extract(context.Background(), headerCarrier(headers))
+}
+
+// Starts the span of a consumed message as a child of the trace context of the publisher
+func startConsumeSpan(ctx context.Context, key string) (context.Context, trace.Span) {
+	return otel.Tracer(TRACER_NAME).Start(ctx, key+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(messagingAttributes(key)...),
+	)
+}
+
+func messagingAttributes(key string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.MessagingSystemRabbitmq,
+		semconv.MessagingDestinationName(EXCHANGE_NAME),
+		semconv.MessagingRabbitmqDestinationRoutingKey(key),
+	}
+}
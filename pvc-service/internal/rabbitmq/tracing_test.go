@@ -0,0 +1,41 @@
+package rabbitmq
+
+import (
+	"context"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceContextPropagatedThroughHeaders(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "DeleteNotebook")
+
+	headers := amqp.Table{}
+	_, publishSpan := startPublishSpan(ctx, "NOTEBOOK.DELETE", headers)
+	publishSpan.End()
+	parent.End()
+
+	assert.Contains(t, headers, "traceparent")
+
+	consumeCtx, consumeSpan := startConsumeSpan(extractTraceContext(headers), "NOTEBOOK.DELETE")
+	consumeSpan.End()
+
+	assert.Equal(t, parent.SpanContext().TraceID(), trace.SpanFromContext(consumeCtx).SpanContext().TraceID())
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 3)
+	assert.Equal(t, "NOTEBOOK.DELETE publish", spans[0].Name())
+	assert.Equal(t, trace.SpanKindProducer, spans[0].SpanKind())
+	assert.Equal(t, "NOTEBOOK.DELETE process", spans[2].Name())
+	assert.Equal(t, publishSpan.SpanContext().SpanID(), spans[2].Parent().SpanID())
+}
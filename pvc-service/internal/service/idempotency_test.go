@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"pvc-service/api/controller"
+	"pvc-service/internal"
+	"pvc-service/mocks/mock_repository"
+	"pvc-service/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Hashes a request the same way runIdempotent does
+func payloadHash(t *testing.T, request proto.Message) string {
+	payload, err := proto.MarshalOptions{Deterministic: true}.Marshal(request)
+	assert.Nil(t, err)
+
+	hash := sha256.Sum256(payload)
+	return hex.EncodeToString(hash[:])
+}
+
+func TestCreateVolume_IdempotentReplay(t *testing.T) {
+	requestId := "retry-1"
+	req := &controller.CreatePvcRequest{Name: "test-volume", RequestId: &requestId}
+	hash := payloadHash(t, req)
+
+	mockIdempotency := &mock_repository.IdempotencyRepositoryMock{}
+	mockIdempotency.On("Reserve", "CreateVolume::retry-1", hash, IDEMPOTENCY_LEASE).Return(&repository.IdempotencyRecord{
+		PayloadHash: hash,
+		Completed:   true,
+		Code:        uint32(codes.OK),
+	}, false, nil)
+
+	// The pvc repository is not mocked, so executing the request again would panic
+	s := &PVCService{idempotency: mockIdempotency, ctx: context.Background()}
+
+	// Execute
+	res, err := s.CreateVolume(context.Background(), req)
+
+	// Verify
+	assert.Nil(t, err)
+	assert.NotNil(t, res)
+}
+
+func TestCreateVolume_IdempotentDifferentPayload(t *testing.T) {
+	req := &controller.CreatePvcRequest{Name: "test-volume"}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IDEMPOTENCY_KEY_HEADER, "retry-2"))
+
+	mockIdempotency := &mock_repository.IdempotencyRepositoryMock{}
+	mockIdempotency.On("Reserve", "CreateVolume::retry-2", mock.Anything, IDEMPOTENCY_LEASE).Return(&repository.IdempotencyRecord{
+		PayloadHash: "other",
+		Completed:   true,
+	}, false, nil)
+
+	s := &PVCService{idempotency: mockIdempotency, ctx: context.Background()}
+
+	// Execute
+	_, err := s.CreateVolume(ctx, req)
+
+	// Verify
+	assert.ErrorIs(t, err, status.Error(codes.InvalidArgument, "idempotency key retry-2 was already used for a different request"))
+}
+
+func TestCreateVolume_IdempotentStoresOutcome(t *testing.T) {
+	internal.IsValidKubernetesName = mockIsValidKubernetesName
+	GetConfiguration = mockGetConfiguration
+
+	defer func() {
+		internal.IsValidKubernetesName = originalIsValidKubernetesName
+		GetConfiguration = originalGetConfiguration
+	}()
+
+	requestId := "retry-3"
+	req := &controller.CreatePvcRequest{Name: "test-volume", RequestId: &requestId}
+	hash := payloadHash(t, req)
+
+	mockRepo := &mock_repository.PvcRepositoryMock{}
+	mockRepo.On("CheckPvcExistsInCache", "test-volume").Return(true, nil)
+
+	mockIdempotency := &mock_repository.IdempotencyRepositoryMock{}
+	mockIdempotency.On("Reserve", "CreateVolume::retry-3", hash, IDEMPOTENCY_LEASE).Return(nil, true, nil)
+	mockIdempotency.On("Complete", "CreateVolume::retry-3", &repository.IdempotencyRecord{
+		PayloadHash: hash,
+		Completed:   true,
+		Code:        uint32(codes.AlreadyExists),
+		Message:     "PVC test-volume already exists",
+	}, DEFAULT_IDEMPOTENCY_TTL).Return(nil)
+
+	s := &PVCService{db: mockRepo, idempotency: mockIdempotency, ctx: context.Background()}
+
+	// Execute
+	_, err := s.CreateVolume(context.Background(), req)
+
+	// Verify
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	mockIdempotency.AssertExpectations(t)
+}
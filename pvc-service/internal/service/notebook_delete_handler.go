@@ -1,26 +1,11 @@
 package service
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
+	"context"
+	"pvc-service/api/events"
 )
 
-// PVCDeletedEvent represents the structure of the deletion event
-type NotebookDeletedEvent struct {
-	NotebookName string `json:"notebook_name"`
-}
-
-// handleNotebookDeleted processes the event when a notebook is deleted
-func HandleNotebookDeleted(message []byte) {
-	var event NotebookDeletedEvent
-	err := json.Unmarshal(message, &event)
-	if err != nil {
-		log.Printf("Error unmarshalling message: %v", err)
-		return
-	}
-
-	// Process the event (e.g., log it or update internal state)
-	fmt.Printf("PVC service received notification: Notebook '%s' has been deleted.\n", event.NotebookName)
-	// Implement additional logic here, like updating the database or cleaning up resources.
+// HandleNotebookDeleted applies the retention policy of the workspace volume of the deleted notebook
+func (r *VolumeRetention) HandleNotebookDeleted(ctx context.Context, event *events.Envelope, data *events.NotebookDeleted) error {
+	return r.Apply(ctx, data.NotebookName+WORKSPACE_SUFFIX, event.Actor)
 }
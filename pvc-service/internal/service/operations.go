@@ -0,0 +1,398 @@
+package service
+
+import (
+	"context"
+	"log"
+	"os"
+	"pvc-service/api/controller"
+	"pvc-service/internal/auth"
+	"pvc-service/repository"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const DEFAULT_OPERATION_PAGE_SIZE = 50
+const MAX_OPERATION_PAGE_SIZE = 200
+
+// Time given to the operations cancelled at shutdown to store their state
+const CANCEL_GRACE_PERIOD = 5 * time.Second
+
+// Time after which an operation whose instance stopped renewing it is taken as interrupted
+const OPERATION_LEASE = 30 * time.Second
+
+// Runs the steps of an operation in the background
+var RunAsync = func(task func()) {
+	go task()
+}
+
+// A named unit of work of an operation
+type operationStep struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// Key of the id of the running operation in the context of its steps
+type operationKey struct{}
+
+// Returns the id of the operation running the step, so the step can key what it records on it
+func operationID(ctx context.Context) string {
+	id, _ := ctx.Value(operationKey{}).(string)
+	return id
+}
+
+// OperationsService persists the long running volume operations and runs their steps
+type OperationsService struct {
+	repo    repository.OperationRepository
+	owner   string // Instance running the operations started here
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+	running sync.WaitGroup
+	controller.UnimplementedOperationsServer
+}
+
+func GenerateOperationsService(repo repository.OperationRepository) *OperationsService {
+	return &OperationsService{
+		repo:    repo,
+		owner:   newOperationOwner(),
+		cancels: map[string]context.CancelFunc{},
+	}
+}
+
+// Identifies this instance of the service, the host name telling apart the replicas in the stored operations
+func newOperationOwner() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return hostname + "-" + primitive.NewObjectID().Hex()
+}
+
+// Fails the unfinished operations whose instance stopped renewing their lease, since their steps can't be resumed.
+// The operations of the other instances, such as one draining during a rolling deploy, are left running.
+func (s *OperationsService) FailInterruptedOperations(ctx context.Context) {
+	reason := repository.OperationError{
+		Code:    uint32(codes.Aborted),
+		Message: "operation was interrupted by a restart of the service",
+	}
+
+	count, err := s.repo.FailInterruptedOperations(ctx, reason)
+	if err != nil {
+		log.Printf("Failed to fail interrupted operations: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("Failed %d operations interrupted by a restart", count)
+	}
+}
+
+// Run renews the lease of the operations running here and fails the ones abandoned by the other instances,
+// until the context is done. It has to outlive Drain, so the operations finishing at shutdown keep their lease.
+func (s *OperationsService) Run(ctx context.Context) {
+	ticker := time.NewTicker(OPERATION_LEASE / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := s.repo.RenewLeases(ctx, s.owner, time.Now().Add(OPERATION_LEASE))
+		if err != nil {
+			log.Printf("Failed to renew leases of operations: %v", err)
+		}
+		s.FailInterruptedOperations(ctx)
+	}
+}
+
+// Waits for the running operations until the context is done, then cancels the remaining ones so
+// their state is stored before the service stops
+func (s *OperationsService) Drain(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	if len(s.cancels) > 0 {
+		log.Printf("Cancelling %d operations still running at shutdown", len(s.cancels))
+	}
+	for _, cancel := range s.cancels {
+		cancel()
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(CANCEL_GRACE_PERIOD):
+	}
+}
+
+// Persists a new operation and runs its steps in the background. The steps outlive the request, so
+// they only inherit the values of the request context.
+func (s *OperationsService) start(ctx context.Context, kind string, target string, steps []operationStep) (*controller.Operation, error) {
+	username, _ := ctx.Value(auth.CtxKey).(string)
+	now := time.Now()
+	operation := &repository.Operation{
+		ID:          primitive.NewObjectID().Hex(),
+		Kind:        kind,
+		Target:      target,
+		Username:    username,
+		State:       repository.OPERATION_PENDING,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Owner:       s.owner,
+		LeaseExpiry: now.Add(OPERATION_LEASE),
+	}
+	for _, step := range steps {
+		operation.Steps = append(operation.Steps, repository.OperationStep{Name: step.name, State: repository.OPERATION_PENDING})
+	}
+
+	err := s.repo.CreateOperation(ctx, operation)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Convert before running, the operation is owned by the background task from here on
+	response := operationToResponse(operation)
+
+	runCtx, cancel := context.WithCancel(context.WithValue(context.WithoutCancel(ctx), operationKey{}, operation.ID))
+	s.mu.Lock()
+	s.cancels[operation.ID] = cancel
+	s.mu.Unlock()
+
+	s.running.Add(1)
+	RunAsync(func() {
+		defer s.running.Done()
+		defer s.forget(operation.ID, cancel)
+		s.run(runCtx, operation, steps)
+	})
+
+	return response, nil
+}
+
+// Runs the steps in order, stopping at the first failure or when the operation is cancelled
+func (s *OperationsService) run(ctx context.Context, operation *repository.Operation, steps []operationStep) {
+	operation.State = repository.OPERATION_RUNNING
+	s.save(ctx, operation)
+
+	for i, step := range steps {
+		if ctx.Err() != nil || s.cancelRequested(ctx, operation.ID) {
+			s.cancelRemaining(ctx, operation, i)
+			return
+		}
+
+		startedAt := time.Now()
+		operation.Steps[i].State = repository.OPERATION_RUNNING
+		operation.Steps[i].StartedAt = &startedAt
+		s.save(ctx, operation)
+
+		err := step.run(ctx)
+
+		finishedAt := time.Now()
+		operation.Steps[i].FinishedAt = &finishedAt
+		if err != nil && ctx.Err() != nil {
+			// The step was interrupted by the cancellation rather than failing on its own
+			s.cancelRemaining(ctx, operation, i)
+			return
+		}
+		if err != nil {
+			log.Printf("Operation %s failed at step %s: %v", operation.ID, step.name, err)
+
+			stepStatus := status.Convert(err)
+			operation.Steps[i].State = repository.OPERATION_FAILED
+			operation.State = repository.OPERATION_FAILED
+			operation.Error = &repository.OperationError{Code: uint32(stepStatus.Code()), Message: stepStatus.Message()}
+			s.save(ctx, operation)
+			return
+		}
+		operation.Steps[i].State = repository.OPERATION_SUCCEEDED
+	}
+
+	operation.State = repository.OPERATION_SUCCEEDED
+	s.save(ctx, operation)
+}
+
+// Checks if the cancellation was requested, possibly through another instance which can't cancel the steps running here
+func (s *OperationsService) cancelRequested(ctx context.Context, id string) bool {
+	requested, err := s.repo.CancelRequested(ctx, id)
+	if err != nil {
+		log.Printf("Failed to check cancellation of operation %s: %v", id, err)
+		return false
+	}
+	return requested
+}
+
+// Marks the steps that did not run yet and the operation as cancelled
+func (s *OperationsService) cancelRemaining(ctx context.Context, operation *repository.Operation, from int) {
+	for i := from; i < len(operation.Steps); i++ {
+		operation.Steps[i].State = repository.OPERATION_CANCELLED
+	}
+	operation.State = repository.OPERATION_CANCELLED
+	operation.Error = &repository.OperationError{Code: uint32(codes.Canceled), Message: "operation was cancelled"}
+	s.save(ctx, operation)
+}
+
+// Stores the progress of the operation, even once it was cancelled. Failures are only logged, the steps keep running.
+func (s *OperationsService) save(ctx context.Context, operation *repository.Operation) {
+	operation.UpdatedAt = time.Now()
+
+	err := s.repo.UpdateOperation(context.WithoutCancel(ctx), operation)
+	if err != nil {
+		log.Printf("Failed to store progress of operation %s: %v", operation.ID, err)
+	}
+}
+
+func (s *OperationsService) forget(id string, cancel context.CancelFunc) {
+	s.mu.Lock()
+	delete(s.cancels, id)
+	s.mu.Unlock()
+
+	cancel()
+}
+
+// GetOperation returns the progress of an operation of the caller
+func (s *OperationsService) GetOperation(ctx context.Context, req *controller.GetOperationRequest) (*controller.Operation, error) {
+	operation, err := s.getAuthorizedOperation(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return operationToResponse(operation), nil
+}
+
+// ListOperations returns the operations of the caller, newest first
+func (s *OperationsService) ListOperations(ctx context.Context, req *controller.ListOperationsRequest) (*controller.ListOperationsResponse, error) {
+	pageSize := int64(req.PageSize)
+	if pageSize <= 0 {
+		pageSize = DEFAULT_OPERATION_PAGE_SIZE
+	}
+	pageSize = min(pageSize, MAX_OPERATION_PAGE_SIZE)
+
+	var offset int64
+	if req.PageToken != "" {
+		parsed, err := strconv.ParseInt(req.PageToken, 10, 64)
+		if err != nil || parsed < 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid page token")
+		}
+		offset = parsed
+	}
+
+	// Fetch one more operation to know if there is a next page
+	username, _ := ctx.Value(auth.CtxKey).(string)
+	operations, err := s.repo.ListOperations(ctx, username, req.Kind, req.Done, offset, pageSize+1)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &controller.ListOperationsResponse{}
+	if int64(len(operations)) > pageSize {
+		operations = operations[:pageSize]
+		response.NextPageToken = strconv.FormatInt(offset+pageSize, 10)
+	}
+
+	for i := range operations {
+		response.Operations = append(response.Operations, operationToResponse(&operations[i]))
+	}
+
+	return response, nil
+}
+
+// CancelOperation stops an unfinished operation of the caller. Steps that did not start yet are skipped, the
+// instance running the operation reading the request before each step.
+func (s *OperationsService) CancelOperation(ctx context.Context, req *controller.CancelOperationRequest) (*controller.Operation, error) {
+	operation, err := s.getAuthorizedOperation(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if operation.Done() {
+		return nil, status.Errorf(codes.FailedPrecondition, "operation %s is already done", req.Id)
+	}
+
+	err = s.repo.RequestCancel(ctx, req.Id)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	s.mu.Lock()
+	cancel, running := s.cancels[req.Id]
+	s.mu.Unlock()
+	if running {
+		cancel()
+	}
+
+	operation.CancelRequested = true
+	return operationToResponse(operation), nil
+}
+
+// Gets an operation of the caller
+func (s *OperationsService) getAuthorizedOperation(ctx context.Context, id string) (*repository.Operation, error) {
+	operation, err := s.repo.GetOperation(ctx, id)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if operation == nil {
+		return nil, status.Errorf(codes.NotFound, "operation %s not found", id)
+	}
+
+	username, _ := ctx.Value(auth.CtxKey).(string)
+	if operation.Username != username {
+		return nil, status.Error(codes.PermissionDenied, "user is unauthorized to perform this operation")
+	}
+
+	return operation, nil
+}
+
+func operationToResponse(operation *repository.Operation) *controller.Operation {
+	response := &controller.Operation{
+		Id:              operation.ID,
+		Kind:            operation.Kind,
+		Target:          operation.Target,
+		State:           operationStateToResponse(operation.State),
+		Done:            operation.Done(),
+		CreatedAt:       timestamppb.New(operation.CreatedAt),
+		UpdatedAt:       timestamppb.New(operation.UpdatedAt),
+		CancelRequested: operation.CancelRequested,
+	}
+
+	for _, step := range operation.Steps {
+		responseStep := &controller.OperationStep{
+			Name:  step.Name,
+			State: operationStateToResponse(step.State),
+		}
+		if step.StartedAt != nil {
+			responseStep.StartedAt = timestamppb.New(*step.StartedAt)
+		}
+		if step.FinishedAt != nil {
+			responseStep.FinishedAt = timestamppb.New(*step.FinishedAt)
+		}
+		response.Steps = append(response.Steps, responseStep)
+	}
+
+	if operation.Error != nil {
+		response.Error = &controller.OperationError{
+			Code:    int32(operation.Error.Code),
+			Message: operation.Error.Message,
+		}
+	}
+
+	return response
+}
+
+func operationStateToResponse(state string) controller.OperationState {
+	return controller.OperationState(controller.OperationState_value[state])
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"pvc-service/api/controller"
+	"pvc-service/internal/auth"
+	"pvc-service/mocks/mock_repository"
+	"pvc-service/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Creates an operations service whose repository accepts every progress update
+func newMockOperationsService() (*mock_repository.OperationRepositoryMock, *OperationsService) {
+	repo := &mock_repository.OperationRepositoryMock{}
+	repo.On("CreateOperation", mock.Anything).Return(nil)
+	repo.On("UpdateOperation", mock.Anything).Return(nil)
+	repo.On("CancelRequested", mock.Anything).Return(false, nil).Maybe()
+
+	return repo, GenerateOperationsService(repo)
+}
+
+// Returns the operation stored by the last progress update
+func lastOperation(t *testing.T, repo *mock_repository.OperationRepositoryMock) *repository.Operation {
+	for i := len(repo.Calls) - 1; i >= 0; i-- {
+		if repo.Calls[i].Method == "UpdateOperation" {
+			return repo.Calls[i].Arguments.Get(0).(*repository.Operation)
+		}
+	}
+
+	t.Fatal("no operation was updated")
+	return nil
+}
+
+func TestOperations_RunsStepsInOrder(t *testing.T) {
+	repo, operations := newMockOperationsService()
+	ctx := context.WithValue(context.Background(), auth.CtxKey, "user")
+
+	var executed []string
+	steps := []operationStep{
+		{name: "first", run: func(context.Context) error { executed = append(executed, "first"); return nil }},
+		{name: "second", run: func(context.Context) error { executed = append(executed, "second"); return nil }},
+	}
+
+	res, err := operations.start(ctx, "CreateVolume", "test-volume", steps)
+
+	assert.NoError(t, err)
+	assert.Equal(t, controller.OperationState_PENDING, res.State)
+	assert.Len(t, res.Steps, 2)
+	assert.Equal(t, []string{"first", "second"}, executed)
+
+	operation := lastOperation(t, repo)
+	assert.Equal(t, "user", operation.Username)
+	assert.Equal(t, repository.OPERATION_SUCCEEDED, operation.State)
+	for _, step := range operation.Steps {
+		assert.Equal(t, repository.OPERATION_SUCCEEDED, step.State)
+		assert.NotNil(t, step.StartedAt)
+		assert.NotNil(t, step.FinishedAt)
+	}
+}
+
+func TestOperations_StopsAtFailedStep(t *testing.T) {
+	repo, operations := newMockOperationsService()
+
+	steps := []operationStep{
+		{name: "first", run: func(context.Context) error { return status.Error(codes.ResourceExhausted, "quota exceeded") }},
+		{name: "second", run: func(context.Context) error { t.Error("step after a failure was executed"); return nil }},
+	}
+
+	_, err := operations.start(context.Background(), "CreateVolume", "test-volume", steps)
+	assert.NoError(t, err)
+
+	operation := lastOperation(t, repo)
+	assert.Equal(t, repository.OPERATION_FAILED, operation.State)
+	assert.Equal(t, repository.OPERATION_FAILED, operation.Steps[0].State)
+	assert.Equal(t, repository.OPERATION_PENDING, operation.Steps[1].State)
+	assert.Equal(t, &repository.OperationError{Code: uint32(codes.ResourceExhausted), Message: "quota exceeded"}, operation.Error)
+}
+
+func TestOperations_CancelBeforeRun(t *testing.T) {
+	repo, operations := newMockOperationsService()
+
+	// Hold the steps back until the operation was cancelled
+	var task func()
+	oldRunAsync := RunAsync
+	RunAsync = func(run func()) {
+		task = run
+	}
+	defer func() {
+		RunAsync = oldRunAsync
+	}()
+
+	steps := []operationStep{
+		{name: "delete-volume", run: func(context.Context) error { t.Error("cancelled step was executed"); return nil }},
+	}
+
+	res, err := operations.start(context.Background(), "DeletePvc", "test-volume", steps)
+	assert.NoError(t, err)
+
+	repo.On("GetOperation", res.Id).Return(&repository.Operation{ID: res.Id, State: repository.OPERATION_PENDING}, nil).Once()
+	repo.On("RequestCancel", res.Id).Return(nil).Once()
+
+	cancelled, err := operations.CancelOperation(context.Background(), &controller.CancelOperationRequest{Id: res.Id})
+	assert.NoError(t, err)
+	assert.True(t, cancelled.CancelRequested)
+
+	task()
+
+	operation := lastOperation(t, repo)
+	assert.Equal(t, repository.OPERATION_CANCELLED, operation.State)
+	assert.Equal(t, repository.OPERATION_CANCELLED, operation.Steps[0].State)
+	assert.Equal(t, uint32(codes.Canceled), operation.Error.Code)
+	repo.AssertExpectations(t)
+}
+
+func TestOperations_CancelThroughAnotherInstance(t *testing.T) {
+	repo := &mock_repository.OperationRepositoryMock{}
+	repo.On("CreateOperation", mock.Anything).Return(nil)
+	repo.On("UpdateOperation", mock.Anything).Return(nil)
+	// The cancellation is requested on another instance once the first step started
+	repo.On("CancelRequested", mock.Anything).Return(false, nil).Once()
+	repo.On("CancelRequested", mock.Anything).Return(true, nil)
+	operations := GenerateOperationsService(repo)
+
+	steps := []operationStep{
+		{name: "delete-volume", run: func(context.Context) error { return nil }},
+		{name: "unregister-volume", run: func(context.Context) error { t.Error("cancelled step was executed"); return nil }},
+	}
+
+	res, err := operations.start(context.Background(), "DeletePvc", "test-volume", steps)
+	assert.NoError(t, err)
+
+	created := repo.Calls[0].Arguments.Get(0).(*repository.Operation)
+	assert.NotEmpty(t, created.Owner)
+	assert.True(t, created.LeaseExpiry.After(time.Now()))
+
+	operation := lastOperation(t, repo)
+	assert.Equal(t, res.Id, operation.ID)
+	assert.Equal(t, repository.OPERATION_CANCELLED, operation.State)
+	assert.Equal(t, repository.OPERATION_SUCCEEDED, operation.Steps[0].State)
+	assert.Equal(t, repository.OPERATION_CANCELLED, operation.Steps[1].State)
+}
+
+func TestOperations_GetOperationOfOtherUser(t *testing.T) {
+	repo, operations := newMockOperationsService()
+	repo.On("GetOperation", "op-1").Return(&repository.Operation{ID: "op-1", Username: "other-user"}, nil).Once()
+
+	ctx := context.WithValue(context.Background(), auth.CtxKey, "user")
+	res, err := operations.GetOperation(ctx, &controller.GetOperationRequest{Id: "op-1"})
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestOperations_ListOperationsLastPage(t *testing.T) {
+	repo, operations := newMockOperationsService()
+	repo.On("ListOperations", "user", (*string)(nil), (*bool)(nil), int64(0), int64(DEFAULT_OPERATION_PAGE_SIZE+1)).Return([]repository.Operation{
+		{ID: "op-1", Username: "user", State: repository.OPERATION_FAILED, CreatedAt: time.Now()},
+	}, nil).Once()
+
+	ctx := context.WithValue(context.Background(), auth.CtxKey, "user")
+	res, err := operations.ListOperations(ctx, &controller.ListOperationsRequest{})
+
+	assert.NoError(t, err)
+	assert.Len(t, res.Operations, 1)
+	assert.Equal(t, controller.OperationState_FAILED, res.Operations[0].State)
+	assert.True(t, res.Operations[0].Done)
+	assert.Empty(t, res.NextPageToken)
+}
+
+func TestOperations_DrainCancelsRunningOperations(t *testing.T) {
+	repo, operations := newMockOperationsService()
+
+	// Run the steps in the background, as they do outside of tests
+	oldRunAsync := RunAsync
+	RunAsync = func(run func()) {
+		go run()
+	}
+	defer func() {
+		RunAsync = oldRunAsync
+	}()
+
+	started := make(chan struct{})
+	steps := []operationStep{
+		{name: "create-volume", run: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	}
+
+	_, err := operations.start(context.Background(), "CreateVolume", "test-volume", steps)
+	assert.NoError(t, err)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	operations.Drain(ctx)
+
+	operation := lastOperation(t, repo)
+	assert.Equal(t, repository.OPERATION_CANCELLED, operation.State)
+	assert.Equal(t, repository.OPERATION_CANCELLED, operation.Steps[0].State)
+}
+
+func TestOperations_DrainWithoutRunningOperations(t *testing.T) {
+	_, operations := newMockOperationsService()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Nothing is running, so it returns before the grace period
+	start := time.Now()
+	operations.Drain(ctx)
+	assert.Less(t, time.Since(start), CANCEL_GRACE_PERIOD)
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pvc-service/api/events"
+	"pvc-service/internal/config"
+	"pvc-service/internal/rabbitmq"
+	"pvc-service/mocks/mock_rbmq"
+	"pvc-service/mocks/mock_repository"
+	"pvc-service/repository"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/protobuf/proto"
+)
+
+// Creates an outbox relay accepting every recorded event
+func newMockOutboxRelay(rbmq rabbitmq.RabbitMQHandler) (*mock_repository.OutboxRepositoryMock, *OutboxRelay) {
+	repo := &mock_repository.OutboxRepositoryMock{}
+	repo.On("AddEvent", mock.Anything).Return(nil)
+
+	return repo, GenerateOutboxRelay(repo, rbmq, config.OutboxConfig{PollInterval: time.Second, BatchSize: 10})
+}
+
+// Returns the envelope of the last event recorded in the outbox
+func lastRecordedEvent(t *testing.T, repo *mock_repository.OutboxRepositoryMock) *events.Envelope {
+	for i := len(repo.Calls) - 1; i >= 0; i-- {
+		if repo.Calls[i].Method == "AddEvent" {
+			recorded := repo.Calls[i].Arguments.Get(0).(*repository.OutboxEvent)
+
+			event := &events.Envelope{}
+			assert.NoError(t, proto.Unmarshal(recorded.Envelope, event))
+			assert.Equal(t, recorded.ID, event.Id)
+			assert.Equal(t, recorded.Type, event.Type)
+			return event
+		}
+	}
+
+	t.Fatal("no event was recorded")
+	return nil
+}
+
+// Creates an event of the outbox as recorded by the relay
+func createOutboxEvent(t *testing.T, pvcName string) repository.OutboxEvent {
+	key := rabbitmq.GenerateRoutingKey(rabbitmq.PVC, rabbitmq.DELETE)
+	event, err := rabbitmq.NewEvent(key, "user", &events.PvcDeleted{PvcName: pvcName})
+	assert.NoError(t, err)
+
+	envelope, err := proto.Marshal(event)
+	assert.NoError(t, err)
+
+	return repository.OutboxEvent{ID: event.Id, Type: event.Type, Envelope: envelope, CreatedAt: event.Time.AsTime()}
+}
+
+func TestOutboxRecord(t *testing.T) {
+	repo, relay := newMockOutboxRelay(new(mock_rbmq.RabbitMQClientMock))
+
+	key := rabbitmq.GenerateRoutingKey(rabbitmq.PVC, rabbitmq.CREATE)
+	err := relay.Record(context.Background(), key, "user", &events.PvcCreated{PvcName: "test-pvc"})
+	assert.NoError(t, err)
+
+	event := lastRecordedEvent(t, repo)
+	assert.Equal(t, key, event.Type)
+	assert.Equal(t, "user", event.Actor)
+	assert.Equal(t, "test-pvc", event.Subject)
+}
+
+func TestOutboxRecordOnce(t *testing.T) {
+	repo, relay := newMockOutboxRelay(new(mock_rbmq.RabbitMQClientMock))
+
+	key := rabbitmq.GenerateRoutingKey(rabbitmq.PVC, rabbitmq.DELETE)
+	err := relay.RecordOnce(context.Background(), "test-pvc-workspace:uid", key, "user", &events.PvcDeleted{PvcName: "test-pvc-workspace"})
+	assert.NoError(t, err)
+	first := lastRecordedEvent(t, repo)
+
+	err = relay.RecordOnce(context.Background(), "test-pvc-workspace:uid", key, "user", &events.PvcDeleted{PvcName: "test-pvc-workspace"})
+	assert.NoError(t, err)
+
+	// Both records store the event under the same id, the repository keeping the first one
+	assert.Equal(t, uuid.NewSHA1(uuid.NameSpaceOID, []byte("test-pvc-workspace:uid")).String(), first.Id)
+	assert.Equal(t, first.Id, lastRecordedEvent(t, repo).Id)
+}
+
+func TestOutboxRecordFailed(t *testing.T) {
+	repo := &mock_repository.OutboxRepositoryMock{}
+	repo.On("AddEvent", mock.Anything).Return(errors.New("mongo down"))
+	relay := GenerateOutboxRelay(repo, new(mock_rbmq.RabbitMQClientMock), config.OutboxConfig{PollInterval: time.Second, BatchSize: 10})
+
+	key := rabbitmq.GenerateRoutingKey(rabbitmq.PVC, rabbitmq.CREATE)
+	err := relay.Record(context.Background(), key, "user", &events.PvcCreated{PvcName: "test-pvc"})
+	assert.Error(t, err)
+}
+
+func TestOutboxRelayPublishesAndMarksSent(t *testing.T) {
+	first := createOutboxEvent(t, "first")
+	second := createOutboxEvent(t, "second")
+
+	rbmq := new(mock_rbmq.RabbitMQClientMock)
+	rbmq.On("PublishConfirmed", mock.MatchedBy(func(event *events.Envelope) bool { return event.Id == first.ID })).Return(nil).Once()
+	rbmq.On("PublishConfirmed", mock.MatchedBy(func(event *events.Envelope) bool { return event.Id == second.ID })).Return(nil).Once()
+
+	repo := &mock_repository.OutboxRepositoryMock{}
+	relay := GenerateOutboxRelay(repo, rbmq, config.OutboxConfig{PollInterval: time.Second, BatchSize: 10})
+	repo.On("FindPendingEvents", int64(10)).Return([]repository.OutboxEvent{first, second}, nil).Once()
+	repo.On("MarkEventSent", first.ID).Return(nil).Once()
+	repo.On("MarkEventSent", second.ID).Return(nil).Once()
+
+	relay.Relay(context.Background())
+
+	repo.AssertExpectations(t)
+	rbmq.AssertExpectations(t)
+}
+
+func TestOutboxRelayStopsAtFailure(t *testing.T) {
+	first := createOutboxEvent(t, "first")
+	second := createOutboxEvent(t, "second")
+
+	rbmq := new(mock_rbmq.RabbitMQClientMock)
+	rbmq.On("PublishConfirmed", mock.Anything).Return(rabbitmq.ErrNotConnected).Once()
+
+	repo := &mock_repository.OutboxRepositoryMock{}
+	relay := GenerateOutboxRelay(repo, rbmq, config.OutboxConfig{PollInterval: time.Second, BatchSize: 10})
+	repo.On("FindPendingEvents", int64(10)).Return([]repository.OutboxEvent{first, second}, nil).Once()
+	repo.On("MarkEventFailed", first.ID, rabbitmq.ErrNotConnected.Error()).Return(nil).Once()
+
+	relay.Relay(context.Background())
+
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "MarkEventSent", mock.Anything)
+	rbmq.AssertNumberOfCalls(t, "PublishConfirmed", 1)
+}
+
+func TestOutboxRelayDiscardsAFullBatchOfUndecodableEvents(t *testing.T) {
+	var broken []repository.OutboxEvent
+	for i := 0; i < 10; i++ {
+		broken = append(broken, repository.OutboxEvent{ID: fmt.Sprintf("broken-%d", i), Envelope: []byte{0xff}})
+	}
+	valid := createOutboxEvent(t, "valid")
+
+	rbmq := new(mock_rbmq.RabbitMQClientMock)
+	rbmq.On("PublishConfirmed", mock.Anything).Return(nil).Once()
+
+	// The discarded events are no longer pending, so the next batch holds the events behind them
+	repo := &mock_repository.OutboxRepositoryMock{}
+	relay := GenerateOutboxRelay(repo, rbmq, config.OutboxConfig{PollInterval: time.Second, BatchSize: 10})
+	repo.On("FindPendingEvents", int64(10)).Return(broken, nil).Once()
+	repo.On("FindPendingEvents", int64(10)).Return([]repository.OutboxEvent{valid}, nil).Once()
+	repo.On("MarkEventDiscarded", mock.Anything, mock.Anything).Return(nil).Times(10)
+	repo.On("MarkEventSent", valid.ID).Return(nil).Once()
+
+	relay.Relay(context.Background())
+
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "MarkEventFailed", mock.Anything, mock.Anything)
+	rbmq.AssertExpectations(t)
+}
+
+func TestOutboxRelayPublishesToTheBus(t *testing.T) {
+	pending := createOutboxEvent(t, "test-pvc")
+
+	bus := rabbitmq.NewMemoryBus(config.Default().RabbitMQ, true)
+	repo := &mock_repository.OutboxRepositoryMock{}
+	repo.On("FindPendingEvents", int64(10)).Return([]repository.OutboxEvent{pending}, nil).Once()
+	repo.On("MarkEventSent", pending.ID).Return(nil).Once()
+
+	GenerateOutboxRelay(repo, bus, config.OutboxConfig{PollInterval: time.Second, BatchSize: 10}).Relay(context.Background())
+
+	repo.AssertExpectations(t)
+	published := bus.Published()
+	assert.Len(t, published, 1)
+	assert.Equal(t, pending.ID, published[0].Id)
+	assert.Equal(t, "test-pvc", published[0].Subject)
+}
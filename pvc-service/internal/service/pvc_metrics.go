@@ -0,0 +1,29 @@
+package service
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Name of the PVC cache in the cache metrics
+const PVC_CACHE = "pvcs"
+
+// CountPvcsByNamespace returns a count of the persistent volume claims of the namespace managed by the service.
+// The other namespaces are left out, so a scrape doesn't list every claim of the cluster.
+func CountPvcsByNamespace(clientset kubernetes.Interface) func(context.Context) (map[string]float64, error) {
+	return func(ctx context.Context) (map[string]float64, error) {
+		return countPvcsByNamespace(ctx, clientset)
+	}
+}
+
+func countPvcsByNamespace(ctx context.Context, clientset kubernetes.Interface) (map[string]float64, error) {
+	namespace := GetConfiguration().Namespace
+	pvcs, err := clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]float64{namespace: float64(len(pvcs.Items))}, nil
+}
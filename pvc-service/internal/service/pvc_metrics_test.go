@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+)
+
+// Makes the service manage the namespace of the mocked configuration until the end of the test
+func useMockConfiguration(t *testing.T) {
+	GetConfiguration = mockGetConfiguration
+	t.Cleanup(func() {
+		GetConfiguration = originalGetConfiguration
+	})
+}
+
+func TestCountPvcsByNamespace(t *testing.T) {
+	useMockConfiguration(t)
+	namespace := GetConfiguration().Namespace
+	pvcs := []*corev1.PersistentVolumeClaim{
+		{ObjectMeta: v1.ObjectMeta{Name: "workspace-a", Namespace: namespace}},
+		{ObjectMeta: v1.ObjectMeta{Name: "workspace-b", Namespace: namespace}},
+		{ObjectMeta: v1.ObjectMeta{Name: "workspace-c", Namespace: "other-namespace"}},
+	}
+
+	clientset := kubernetesfake.NewSimpleClientset(pvcs[0], pvcs[1], pvcs[2])
+
+	// Execute
+	counts, err := CountPvcsByNamespace(clientset)(context.Background())
+
+	// Verify, the claims of the other namespaces are not listed
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]float64{namespace: 2}, counts)
+}
+
+func TestCountPvcsByNamespaceWithoutClaims(t *testing.T) {
+	useMockConfiguration(t)
+	counts, err := CountPvcsByNamespace(kubernetesfake.NewSimpleClientset())(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]float64{GetConfiguration().Namespace: 0}, counts)
+}
@@ -5,20 +5,20 @@ import (
 	"fmt"
 	"log"
 	"pvc-service/api/controller"
+	"pvc-service/api/events"
+	"pvc-service/internal/auth"
 	"pvc-service/internal/rabbitmq" // Import the RabbitMQ handler
 
 	"pvc-service/internal"
+	"pvc-service/internal/metrics"
 
 	"gopkg.in/yaml.v2"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/rest"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 type VolumeSpec struct {
@@ -34,9 +34,9 @@ type VolMetadata struct {
 }
 
 type VolSpec struct {
-	AccessModes []string     `yaml:"accessModes"`
-	Resources   VolResources `yaml:"resources"`
-	StorageClassName string       `yaml:"storageClassName"`
+	AccessModes      []string     `yaml:"accessModes"`
+	Resources        VolResources `yaml:"resources"`
+	StorageClassName string       `yaml:"storageClassName,omitempty"`
 }
 
 type VolResources struct {
@@ -45,7 +45,6 @@ type VolResources struct {
 
 type VolRequests struct {
 	Storage string `yaml:"storage"`
-	
 }
 
 type Metadata struct {
@@ -56,22 +55,22 @@ type Metadata struct {
 	Namespace   string            `yaml:"namespace"`
 }
 
-func CreateVolumeBytes(NotebookName, VolumeSize string) ([]byte, error) {
+func CreateVolumeBytes(NotebookName, VolumeSize string, storage *VolumeStorage) ([]byte, error) {
 	volume := VolumeSpec{
 		APIVersion: "v1",
 		Kind:       "PersistentVolumeClaim",
 		Metadata: VolMetadata{
-			Name:      NotebookName + "-workspace",
+			Name:      NotebookName + WORKSPACE_SUFFIX,
 			Namespace: "kubeflow-user-example-com",
 		},
 		Spec: VolSpec{
-			AccessModes: []string{"ReadWriteOnce"},
+			AccessModes: []string{storage.AccessMode},
 			Resources: VolResources{
 				Requests: VolRequests{
 					Storage: VolumeSize + "Gi",
 				},
 			},
-			StorageClassName: "gp2", // Set your StorageClass
+			StorageClassName: storage.StorageClass,
 		},
 	}
 	yamlBytes, err := yaml.Marshal(volume)
@@ -82,11 +81,14 @@ func CreateVolumeBytes(NotebookName, VolumeSize string) ([]byte, error) {
 	return yamlBytes, nil
 }
 
-var dynamicNewForConfig = func(config *rest.Config) (dynamic.Interface, error) {
-	return dynamic.NewForConfig(config)
+// CreateVolume validates the request and starts an operation creating the volume
+func (s *PVCService) CreateVolume(ctx context.Context, request *controller.CreatePvcRequest) (*controller.Operation, error) {
+	return runIdempotent(ctx, s.idempotency, "CreateVolume", request.RequestId, request, func() (*controller.Operation, error) {
+		return s.createVolume(ctx, request)
+	})
 }
 
-func (s *PVCService) CreateVolume(ctx context.Context, request *controller.CreatePvcRequest) (*emptypb.Empty, error) {
+func (s *PVCService) createVolume(ctx context.Context, request *controller.CreatePvcRequest) (*controller.Operation, error) {
 
 	environmentConfig := GetConfiguration()
 
@@ -98,10 +100,12 @@ func (s *PVCService) CreateVolume(ctx context.Context, request *controller.Creat
 		return nil, nameError
 	}
 	// Check if the PVC already exists in the cache
-	exists, err := s.db.CheckPvcExistsInCache(volumeName)
+	exists, err := s.db.CheckPvcExistsInCache(ctx, volumeName)
 	if err != nil {
 		log.Printf("Warning: Error checking PVC existence in the database: %v", err)
 		exists = false
+	} else {
+		metrics.RecordCacheLookup(PVC_CACHE, exists)
 	}
 	if exists {
 		return nil, status.Errorf(codes.AlreadyExists, "PVC %s already exists", volumeName)
@@ -114,22 +118,16 @@ func (s *PVCService) CreateVolume(ctx context.Context, request *controller.Creat
 		return nil, status.Errorf(codes.InvalidArgument, "invalid volume size: %s", size)
 	}
 
-	config, err := getKubeConfigFunc()
-	if err != nil {
-		// Return an INTERNAL error with a description
-		return nil, status.Errorf(codes.Internal, "failed to get kubeconfig: %v", err)
-	}
-
-	// Create a dynamic client to interact with Kubernetes resources
-	dynamicClient, err := dynamicNewForConfig(config)
+	storage, err := resolveVolumeStorage(environmentConfig, request)
 	if err != nil {
-		// Return an INTERNAL error with a description
-		return nil, status.Errorf(codes.Internal, "failed to create dynamic client: %v", err)
+		return nil, err
 	}
 
+	dynamicClient := s.kube.Dynamic
 	namespace := environmentConfig.Namespace
+	username, _ := ctx.Value(auth.CtxKey).(string)
 
-	yamlFile, err := CreateVolumeBytes(volumeName, size)
+	yamlFile, err := CreateVolumeBytes(volumeName, size, storage)
 	if yamlFile == nil {
 		return nil, status.Errorf(codes.Internal, "Error marshalling yaml file: %v", err)
 	}
@@ -150,22 +148,26 @@ func (s *PVCService) CreateVolume(ctx context.Context, request *controller.Creat
 
 	// Set namespace and name in metadata
 	metadata := map[string]interface{}{
-		"name":      volumeName + "-workspace",
+		"name":      volumeName + WORKSPACE_SUFFIX,
 		"namespace": "kubeflow-user-example-com",
 	}
 	obj.SetNamespace("kubeflow-user-example-com")
-	obj.SetName(volumeName + "-workspace")
-	obj.SetUnstructuredContent(map[string]interface{}{
-		"metadata": metadata,
-		"spec": map[string]interface{}{
-			"accessModes": []interface{}{"ReadWriteOnce"},
-			"resources": map[string]interface{}{
-				"requests": map[string]interface{}{
-					"storage": size + "Gi",
-				},	
+	obj.SetName(volumeName + WORKSPACE_SUFFIX)
+	spec := map[string]interface{}{
+		"accessModes": []interface{}{storage.AccessMode},
+		"resources": map[string]interface{}{
+			"requests": map[string]interface{}{
+				"storage": size + "Gi",
 			},
-			"storageClassName": "gp2",
 		},
+	}
+	// Without a storage class the cluster default is used
+	if storage.StorageClass != "" {
+		spec["storageClassName"] = storage.StorageClass
+	}
+	obj.SetUnstructuredContent(map[string]interface{}{
+		"metadata": metadata,
+		"spec":     spec,
 	})
 
 	// Define GroupVersionResource for PVCs
@@ -175,30 +177,36 @@ func (s *PVCService) CreateVolume(ctx context.Context, request *controller.Creat
 		Resource: "persistentvolumeclaims",
 	}
 
-	// Apply the object to the cluster
-	_, err = dynamicClient.Resource(groupVersionResourcePvc).Namespace(namespace).Create(context.Background(), obj, v1.CreateOptions{})
-	if err != nil {
-		// Return an INTERNAL error with a description
-		return nil, status.Errorf(codes.Internal, "error applying YAML: %v", err)
-	}
-
-	fmt.Printf("PersistentVolumeClaim %s created successfully.\n", volumeName)
-
-	// Publish a message to RabbitMQ
-	message := fmt.Sprintf("{\"pvc_name\": \"%s\"}", volumeName)
-	key := rabbitmq.GenerateRoutingKey(rabbitmq.PVC, rabbitmq.CREATE)
-	log.Println(key)
-	err = s.rbmq.Publish(key, message)
-	if err != nil {
-		log.Printf("Failed to publish PVC creation message: %v", err)
-		return nil, status.Errorf(codes.Internal, "Error publishing RabbitMQ message: %v", err)
-	}
-
-	// create the PVC in the database
-	err = s.db.CreatePvc(volumeName)
-	if err != nil {
-		log.Printf("Warning: Failed to create PVC in the database: %v", err)
+	steps := []operationStep{
+		{name: "create-volume", run: func(ctx context.Context) error {
+			// Apply the object to the cluster
+			_, err := dynamicClient.Resource(groupVersionResourcePvc).Namespace(namespace).Create(ctx, obj, v1.CreateOptions{})
+			if err != nil {
+				// Return an INTERNAL error with a description
+				return status.Errorf(codes.Internal, "error applying YAML: %v", err)
+			}
+
+			fmt.Printf("PersistentVolumeClaim %s created successfully.\n", volumeName)
+
+			// Record the creation event in the same step, published to RabbitMQ by the outbox relay. It is keyed
+			// by the operation, so a retried step records it once.
+			key := rabbitmq.GenerateRoutingKey(rabbitmq.PVC, rabbitmq.CREATE)
+			err = s.outbox.RecordOnce(ctx, operationID(ctx)+":"+key, key, username, &events.PvcCreated{PvcName: volumeName})
+			if err != nil {
+				log.Printf("Failed to record PVC creation event: %v", err)
+				return status.Errorf(codes.Internal, "error recording PVC creation event: %v", err)
+			}
+			return nil
+		}},
+		{name: "register-volume", run: func(ctx context.Context) error {
+			// create the PVC in the database
+			err := s.db.CreatePvc(ctx, volumeName)
+			if err != nil {
+				log.Printf("Warning: Failed to create PVC in the database: %v", err)
+			}
+			return nil
+		}},
 	}
 
-	return &emptypb.Empty{}, nil
+	return s.operations.start(ctx, "CreateVolume", volumeName, steps)
 }
@@ -5,31 +5,24 @@ import (
 	"fmt"
 	"log"
 	"pvc-service/api/controller"
+	"pvc-service/api/events"
+	"pvc-service/internal/auth"
 	"pvc-service/internal/rabbitmq"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/types/known/emptypb"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
-// DeletePvc deletes a PVC in the specified namespace and publishes an event to RabbitMQ
-func (s *PVCService) DeletePvc(ctx context.Context, req *controller.DeletePvcRequest) (*emptypb.Empty, error) {
-	// Get Kubernetes configuration
-	config, err := getKubeConfigFunc()
-	if err != nil {
-		return nil, status.Error(codes.Internal, "failed getting kube config")
-	}
-
-	// Create a dynamic client to interact with Kubernetes resources
-	client, err := CreateDynamicClient(config)
-	if err != nil {
-		return nil, status.Error(codes.Internal, "failed creating dynamic client")
-	}
+// DeletePvc starts an operation deleting a PVC in the specified namespace and recording an event in the outbox
+func (s *PVCService) DeletePvc(ctx context.Context, req *controller.DeletePvcRequest) (*controller.Operation, error) {
+	client := s.kube.Dynamic
+	username, _ := ctx.Value(auth.CtxKey).(string)
 
 	// Extract the PVC name from the request
-	pvcName := req.Name + "-workspace"
+	pvcName := req.Name + WORKSPACE_SUFFIX
 
 	// Define the GroupVersionResource for PVCs
 	gvr := schema.GroupVersionResource{
@@ -42,32 +35,36 @@ func (s *PVCService) DeletePvc(ctx context.Context, req *controller.DeletePvcReq
 	environmentConfig := GetConfiguration()
 	namespace := environmentConfig.Namespace
 
-	// Delete the PVC by name in the specified namespace
-	err = client.Resource(gvr).Namespace(namespace).Delete(ctx, pvcName, metav1.DeleteOptions{})
-	if err != nil {
-		return nil, status.Errorf(codes.Internal, "error deleting PVC: %v", err)
-	}
-
-	// Publish a message to RabbitMQ
-	message := fmt.Sprintf("{\"pvc_name\": \"%s\"}", pvcName)
-	key := rabbitmq.GenerateRoutingKey(rabbitmq.PVC, rabbitmq.DELETE)
-	log.Println(key)
-	err = s.rbmq.Publish(key, message)
-	if err != nil {
-		log.Printf("Failed to publish PVC deletion message: %v", err)
-		return nil, status.Errorf(codes.Internal, "error publishing RabbitMQ message: %v", err)
-	}
+	steps := []operationStep{
+		{name: "delete-volume", run: func(ctx context.Context) error {
+			// Delete the PVC by name in the specified namespace. A PVC already gone was deleted by an earlier
+			// attempt interrupted before recording it, so its deletion is recorded now.
+			err := client.Resource(gvr).Namespace(namespace).Delete(ctx, pvcName, metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return status.Errorf(codes.Internal, "error deleting PVC: %v", err)
+			}
 
-	// Delete the PVC in the database
+			// Record the deletion event in the same step, published to RabbitMQ by the outbox relay. It is keyed
+			// by the operation, so a retried step records it once.
+			key := rabbitmq.GenerateRoutingKey(rabbitmq.PVC, rabbitmq.DELETE)
+			err = s.outbox.RecordOnce(ctx, operationID(ctx)+":"+key, key, username, &events.PvcDeleted{PvcName: pvcName})
+			if err != nil {
+				log.Printf("Failed to record PVC deletion event: %v", err)
+				return status.Errorf(codes.Internal, "error recording PVC deletion event: %v", err)
+			}
+			return nil
+		}},
+		{name: "unregister-volume", run: func(ctx context.Context) error {
+			// Delete the PVC in the database
+			err := s.db.DeletePvc(ctx, req.Name)
+			if err != nil {
+				log.Printf("Warning: error deleting PVC from database: %v", err)
+			}
 
-	//remove the workspace suffix
-	pvcName = pvcName[:len(pvcName)-len("-workspace")]
-	err = s.db.DeletePvc(pvcName)
-	if err != nil {
-		log.Printf("Failed to delete PVC from database: %v", err)
-		log.Printf("Warning: error deleting PVC from database: %v", err)
+			fmt.Printf("PVC '%s' deleted successfully.\n", req.Name)
+			return nil
+		}},
 	}
 
-	fmt.Printf("PVC '%s' deleted successfully.\n", pvcName)
-	return &emptypb.Empty{}, nil
+	return s.operations.start(ctx, "DeletePvc", req.Name, steps)
 }
@@ -6,29 +6,28 @@ import (
 	"testing"
 
 	"pvc-service/api/controller"
+	"pvc-service/api/events"
 	mock_dynamic "pvc-service/mocks"        // Generated mock for Kubernetes client
 	mock_rbmq "pvc-service/mocks/mock_rbmq" // Import your RabbitMQ mock package here
 
 	"pvc-service/mocks/mock_repository"
+	"pvc-service/repository"
 
 	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
-	"google.golang.org/protobuf/types/known/emptypb"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/rest"
 )
 
 func TestMain(m *testing.M) {
-	originalGetKubeConfig := getKubeConfig
-	getKubeConfigFunc = func() (*rest.Config, error) {
-		return &rest.Config{}, nil
+	// Run the operations synchronously, so tests can assert their outcome
+	RunAsync = func(task func()) {
+		task()
 	}
 
-	defer func() {
-		getKubeConfigFunc = originalGetKubeConfig
-	}()
-
 	code := m.Run()
 	os.Exit(code)
 }
@@ -64,19 +63,6 @@ func TestDeletePvc_Sucess(t *testing.T) {
 	mockResourceClient.EXPECT().Namespace(mockConfig.Namespace).Return(mockResourceClient).Times(1)
 	mockResourceClient.EXPECT().Delete(gomock.Any(), expectedPvcName, gomock.Any()).Return(nil).Times(1)
 
-	// Set expectations for RabbitMQ publish call with the corrected key
-	expectedMessage := "{\"pvc_name\": \"" + expectedPvcName + "\"}"
-	mockRabbitMQ.On("Publish", "PVC.DELETE", expectedMessage).Return(nil).Once()
-
-	// Override CreateDynamicClient to return mock dynamic client
-	oldCreateDynamicClient := CreateDynamicClient
-	CreateDynamicClient = func(*rest.Config) (dynamic.Interface, error) {
-		return mockDynamicClient, nil
-	}
-	defer func() {
-		CreateDynamicClient = oldCreateDynamicClient
-	}()
-
 	// Create PVCService instance with mock RabbitMQ client using the constructor
 
 	mockRepo := &mock_repository.PvcRepositoryMock{}
@@ -84,7 +70,9 @@ func TestDeletePvc_Sucess(t *testing.T) {
 
 	// Setup
 
-	pvcService := NewPVCService(mockRabbitMQ, mockRepo, context.Background())
+	operationRepo, operations := newMockOperationsService()
+	outboxRepo, outbox := newMockOutboxRelay(mockRabbitMQ)
+	pvcService := NewPVCService(mockRabbitMQ, mockRepo, nil, operations, &KubeClients{Dynamic: mockDynamicClient}, outbox, context.Background())
 
 	// Prepare request
 	req := &controller.DeletePvcRequest{
@@ -95,10 +83,16 @@ func TestDeletePvc_Sucess(t *testing.T) {
 	resp, err := pvcService.DeletePvc(context.Background(), req)
 
 	assert.NoError(t, err)
-	assert.Equal(t, &emptypb.Empty{}, resp)
-
-	// Assert all expectations were met
-	mockRabbitMQ.AssertExpectations(t)
+	assert.Equal(t, "DeletePvc", resp.Kind)
+	assert.Equal(t, repository.OPERATION_SUCCEEDED, lastOperation(t, operationRepo).State)
+
+	// The deletion event is recorded in the outbox with the corrected key, once for the operation
+	event := lastRecordedEvent(t, outboxRepo)
+	assert.Equal(t, "PVC.DELETE", event.Type)
+	assert.Equal(t, uuid.NewSHA1(uuid.NameSpaceOID, []byte(resp.Id+":PVC.DELETE")).String(), event.Id)
+	data, err := event.Data.UnmarshalNew()
+	assert.NoError(t, err)
+	assert.True(t, proto.Equal(&events.PvcDeleted{PvcName: expectedPvcName}, data))
 }
 
 func TestDeletePvc_InvalidName(t *testing.T) {
@@ -136,26 +130,87 @@ func TestDeletePvc_InvalidName(t *testing.T) {
 	mockResourceClient.EXPECT().Namespace(mockConfig.Namespace).Return(mockResourceClient).Times(1)
 	mockResourceClient.EXPECT().Delete(gomock.Any(), expectedPvcName, gomock.Any()).Return(assert.AnError).Times(1)
 
-	// Override CreateDynamicClient to return mock dynamic client
-	oldCreateDynamicClient := CreateDynamicClient
-	CreateDynamicClient = func(*rest.Config) (dynamic.Interface, error) {
-		return mockDynamicClient, nil
-	}
-	defer func() {
-		CreateDynamicClient = oldCreateDynamicClient
-	}()
-
 	// Initialize PVCService with the mock RabbitMQ client
-	pvcService := NewPVCService(mockRabbitMQ, nil, nil)
+	operationRepo, operations := newMockOperationsService()
+	outboxRepo, outbox := newMockOutboxRelay(mockRabbitMQ)
+	pvcService := NewPVCService(mockRabbitMQ, nil, nil, operations, &KubeClients{Dynamic: mockDynamicClient}, outbox, nil)
 
 	// Prepare the deletion request with the invalid PVC name
 	reqDelete := &controller.DeletePvcRequest{
 		Name: invalidPvcName,
 	}
 
-	// Execute the deletion and check that an error occurs
+	// Execute the deletion and check that the operation failed
 	_, err := pvcService.DeletePvc(context.Background(), reqDelete)
+	assert.NoError(t, err)
+
+	operation := lastOperation(t, operationRepo)
+	assert.Equal(t, repository.OPERATION_FAILED, operation.State)
+	assert.Equal(t, uint32(codes.Internal), operation.Error.Code)
+	assert.Equal(t, "delete-volume", operation.Steps[0].Name)
+	assert.Equal(t, repository.OPERATION_PENDING, operation.Steps[1].State)
+	outboxRepo.AssertNotCalled(t, "AddEvent", mock.Anything)
+}
+
+// PVC repository failing its writes on a cancelled context, as Redis does
+type contextAwarePvcRepository struct {
+	*mock_repository.PvcRepositoryMock
+}
+
+func (r contextAwarePvcRepository) CreatePvc(ctx context.Context, pvcName string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return r.PvcRepositoryMock.CreatePvc(ctx, pvcName)
+}
+
+func (r contextAwarePvcRepository) DeletePvc(ctx context.Context, pvcName string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return r.PvcRepositoryMock.DeletePvc(ctx, pvcName)
+}
+
+func TestDeletePvc_UnregistersVolumeAfterTheRequestEnded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDynamicClient := mock_dynamic.NewMockInterface(ctrl)
+	mockResourceClient := mock_dynamic.NewMockNamespaceableResourceInterface(ctrl)
+	mockRabbitMQ := new(mock_rbmq.RabbitMQClientMock)
+
+	GetConfiguration = func() Configuration {
+		return Configuration{Namespace: "test-namespace"}
+	}
+
+	mockDynamicClient.EXPECT().Resource(gomock.Any()).Return(mockResourceClient).Times(1)
+	mockResourceClient.EXPECT().Namespace("test-namespace").Return(mockResourceClient).Times(1)
+	mockResourceClient.EXPECT().Delete(gomock.Any(), "test-pvc-workspace", gomock.Any()).Return(nil).Times(1)
+
+	mockRepo := &mock_repository.PvcRepositoryMock{}
+	mockRepo.On("DeletePvc", "test-pvc").Return(nil).Once()
+
+	_, operations := newMockOperationsService()
+	_, outbox := newMockOutboxRelay(mockRabbitMQ)
+	pvcService := NewPVCService(mockRabbitMQ, contextAwarePvcRepository{mockRepo}, nil, operations, &KubeClients{Dynamic: mockDynamicClient}, outbox, context.Background())
+
+	// Hold the steps back until the request returned
+	var task func()
+	oldRunAsync := RunAsync
+	RunAsync = func(run func()) {
+		task = run
+	}
+	defer func() {
+		RunAsync = oldRunAsync
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := pvcService.DeletePvc(ctx, &controller.DeletePvcRequest{Name: "test-pvc"})
+	assert.NoError(t, err)
+	cancel()
+
+	task()
 
-	// Assert that an error was returned
-	assert.Error(t, err, "Expected error when deleting invalid PVC, but got none")
+	// The volume is unregistered although the request context was cancelled
+	mockRepo.AssertExpectations(t)
 }
@@ -12,6 +12,7 @@ import (
 	"pvc-service/internal"
 	"pvc-service/mocks/mock_rbmq"
 	"pvc-service/mocks/mock_repository"
+	"pvc-service/repository"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -19,11 +20,10 @@ import (
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
 
-	"k8s.io/client-go/rest"
 	k8stesting "k8s.io/client-go/testing"
 
 	"github.com/stretchr/testify/assert"
@@ -32,20 +32,14 @@ import (
 
 // Mock functions
 var (
-	originalGetKubeConfig         = getKubeConfig
 	originalIsValidKubernetesName = internal.IsValidKubernetesName
 	originalIsValidSize           = internal.IsValidSize
-	originalDynamicNewForConfig   = dynamicNewForConfig
 	originalGetConfiguration      = GetConfiguration
 )
 
 var PvcRepositoryMock mock_repository.PvcRepositoryMock
 
 // Mock implementations
-var mockGetKubeConfig = func() (*rest.Config, error) {
-	return &rest.Config{}, nil
-}
-
 var mockIsValidKubernetesName = func(name string) error {
 	return nil
 }
@@ -56,9 +50,8 @@ var mockIsValidSize = func(size *string) string {
 
 var mockGetConfiguration = func() Configuration {
 	return Configuration{
-		UrlBase:                   "http://localhost:8080",
-		Namespace:                 "kubeflow-user-example-com",
-		KubeflowKustomizationPath: "/path/to/kustomization",
+		UrlBase:   "http://localhost:8080",
+		Namespace: "kubeflow-user-example-com",
 	}
 }
 
@@ -69,14 +62,10 @@ func TestCreateVolume_Success(t *testing.T) {
 	corev1.AddToScheme(scheme)
 
 	// Mock dependencies
-	getKubeConfigFunc = mockGetKubeConfig
 	internal.IsValidKubernetesName = mockIsValidKubernetesName
 	internal.IsValidSize = mockIsValidSize
 	GetConfiguration = mockGetConfiguration
 	dynamicClient := fake.NewSimpleDynamicClient(scheme)
-	dynamicNewForConfig = func(config *rest.Config) (dynamic.Interface, error) {
-		return dynamicClient, nil
-	}
 
 	// Setup
 	rbmq := new(mock_rbmq.RabbitMQClientMock)
@@ -87,8 +76,9 @@ func TestCreateVolume_Success(t *testing.T) {
 	mockRepo.On("CreatePvc", "test-volume").Return(nil)
 	mockRepo.On("DeletePvc", "test-volume").Return(nil)
 
-	s := &PVCService{rbmq: rbmq, db: mockRepo, ctx: context.Background()}
-	rbmq.On("Publish", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
+	_, operations := newMockOperationsService()
+	outboxRepo, outbox := newMockOutboxRelay(rbmq)
+	s := &PVCService{rbmq: rbmq, db: mockRepo, operations: operations, kube: &KubeClients{Dynamic: dynamicClient}, outbox: outbox, ctx: context.Background()}
 	rbmq.On("ConsumeMessages", mock.Anything).Return()
 	ctx := context.Background()
 	size := "10"
@@ -119,21 +109,65 @@ func TestCreateVolume_Success(t *testing.T) {
 		t.Errorf("Expected PVC to be created, but it was not found")
 	}
 
+	// The creation event is recorded in the outbox
+	assert.Equal(t, "PVC.CREATE", lastRecordedEvent(t, outboxRepo).Type)
+
 	defer func() {
-		getKubeConfigFunc = originalGetKubeConfig
 		internal.IsValidKubernetesName = originalIsValidKubernetesName
 		internal.IsValidSize = originalIsValidSize
-		dynamicNewForConfig = originalDynamicNewForConfig
 		GetConfiguration = originalGetConfiguration
 	}()
 }
+func TestCreateVolume_RegistersVolumeAfterTheRequestEnded(t *testing.T) {
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+
+	internal.IsValidKubernetesName = mockIsValidKubernetesName
+	internal.IsValidSize = mockIsValidSize
+	GetConfiguration = mockGetConfiguration
+	defer func() {
+		internal.IsValidKubernetesName = originalIsValidKubernetesName
+		internal.IsValidSize = originalIsValidSize
+		GetConfiguration = originalGetConfiguration
+	}()
+
+	rbmq := new(mock_rbmq.RabbitMQClientMock)
+	mockRepo := &mock_repository.PvcRepositoryMock{}
+	mockRepo.On("CheckPvcExistsInCache", "test-volume").Return(false, nil)
+	mockRepo.On("CreatePvc", "test-volume").Return(nil).Once()
+
+	_, operations := newMockOperationsService()
+	_, outbox := newMockOutboxRelay(rbmq)
+	s := &PVCService{rbmq: rbmq, db: contextAwarePvcRepository{mockRepo}, operations: operations, kube: &KubeClients{Dynamic: fake.NewSimpleDynamicClient(scheme)}, outbox: outbox, ctx: context.Background()}
+
+	// Hold the steps back until the request returned
+	var task func()
+	oldRunAsync := RunAsync
+	RunAsync = func(run func()) {
+		task = run
+	}
+	defer func() {
+		RunAsync = oldRunAsync
+	}()
+
+	size := "10"
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := s.CreateVolume(ctx, &controller.CreatePvcRequest{Name: "test-volume", Size: &size})
+	assert.NoError(t, err)
+	cancel()
+
+	task()
+
+	// The volume is registered although the request context was cancelled
+	mockRepo.AssertExpectations(t)
+}
+
 func TestCreateVolume_InvalidName(t *testing.T) {
 	// Create a scheme and add corev1 to it
 	scheme := runtime.NewScheme()
 	corev1.AddToScheme(scheme)
 
 	// Mock dependencies
-	getKubeConfigFunc = mockGetKubeConfig
 	internal.IsValidKubernetesName = mockIsValidKubernetesName
 	internal.IsValidSize = mockIsValidSize
 	GetConfiguration = mockGetConfiguration
@@ -194,7 +228,6 @@ func TestCreateVolume_InvalidSize(t *testing.T) {
 	corev1.AddToScheme(scheme)
 
 	// Mock dependencies
-	getKubeConfigFunc = mockGetKubeConfig
 	internal.IsValidKubernetesName = mockIsValidKubernetesName
 	internal.IsValidSize = mockIsValidSize
 	GetConfiguration = mockGetConfiguration
@@ -249,136 +282,12 @@ func TestCreateVolume_InvalidSize(t *testing.T) {
 	}
 }
 
-func TestCreateVolume_KubeConfigError(t *testing.T) {
-	// Create a scheme and add corev1 to it
-	scheme := runtime.NewScheme()
-	corev1.AddToScheme(scheme)
-
-	// Mock dependencies
-	internal.IsValidKubernetesName = mockIsValidKubernetesName
-	internal.IsValidSize = mockIsValidSize
-	GetConfiguration = mockGetConfiguration
-
-	mockRepo := &mock_repository.PvcRepositoryMock{}
-	mockRepo.On("CachePvcList", mock.Anything).Return(nil)
-	mockRepo.On("CheckPvcExistsInCache", "test-volume").Return(false, nil)
-	mockRepo.On("CreatePvc", "test-volume").Return(nil)
-	mockRepo.On("DeletePvc", "test-volume").Return(nil)
-
-	// Setup
-	s := &PVCService{db: mockRepo, ctx: context.Background()}
-	ctx := context.Background()
-	size := "10"
-	req := &controller.CreatePvcRequest{
-		Name: "test-volume",
-		Size: &size,
-	}
-
-	// Mock dependency to return the expected error
-	expectedErrorMessage := fmt.Errorf("connection refused")
-	getKubeConfigFunc = func() (*rest.Config, error) {
-		return nil, expectedErrorMessage
-	}
-
-	defer func() {
-		getKubeConfigFunc = originalGetKubeConfig
-	}()
-
-	// Execute
-	_, err := s.CreateVolume(ctx, req)
-
-	// Verify
-	if err == nil {
-		t.Errorf("Expected error, but got nil")
-	} else {
-		// Check that the error code is Internal
-		if status.Code(err) != codes.Internal {
-			t.Errorf("Expected error code %v, but got %v", codes.Internal, status.Code(err))
-		}
-
-		// Extract the error status and message
-		st, ok := status.FromError(err)
-		if !ok {
-			t.Errorf("Expected gRPC error status, but got a different error type: %v", err)
-		}
-
-		// Verify the message part only
-		expectedMessage := fmt.Sprintf("failed to get kubeconfig: %v", expectedErrorMessage.Error())
-		if st.Message() != expectedMessage {
-			t.Errorf("Expected error message %q, but got %q", expectedMessage, st.Message())
-		}
-	}
-}
-func TestCreateVolume_DynamicClientError(t *testing.T) {
-	// Create a scheme and add corev1 to it
-	scheme := runtime.NewScheme()
-	corev1.AddToScheme(scheme)
-
-	// Mock dependencies
-	getKubeConfigFunc = mockGetKubeConfig
-	internal.IsValidKubernetesName = mockIsValidKubernetesName
-	internal.IsValidSize = mockIsValidSize
-	GetConfiguration = mockGetConfiguration
-
-	mockRepo := &mock_repository.PvcRepositoryMock{}
-	mockRepo.On("CachePvcList", mock.Anything).Return(nil)
-	mockRepo.On("CheckPvcExistsInCache", "test-volume").Return(false, nil)
-	mockRepo.On("CreatePvc", "test-volume").Return(nil)
-	mockRepo.On("DeletePvc", "test-volume").Return(nil)
-
-	// Setup
-	s := &PVCService{db: mockRepo, ctx: context.Background()}
-	ctx := context.Background()
-	size := "10"
-	req := &controller.CreatePvcRequest{
-		Name: "test-volume",
-		Size: &size,
-	}
-
-	// Define the expected error message
-	expectedErrorMessage := fmt.Errorf("failed to create dynamic client")
-
-	dynamicNewForConfig = func(config *rest.Config) (dynamic.Interface, error) {
-		return nil, expectedErrorMessage
-	}
-
-	defer func() {
-		// Restore the original dynamicNewForConfig after the test
-		dynamicNewForConfig = originalDynamicNewForConfig
-	}()
-
-	// Execute
-	_, err := s.CreateVolume(ctx, req)
-
-	// Verify
-	if err == nil {
-		t.Errorf("Expected error, but got nil")
-	} else {
-		// Check that the error code is Internal
-		if status.Code(err) != codes.Internal {
-			t.Errorf("Expected error code %v, but got %v", codes.Internal, status.Code(err))
-		}
-
-		// Extract the error status and message
-		st, ok := status.FromError(err)
-		if !ok {
-			t.Errorf("Expected gRPC error status, but got a different error type: %v", err)
-		}
-
-		// Verify the message part only
-		if st.Message() != fmt.Sprintf("failed to create dynamic client: %v", expectedErrorMessage.Error()) {
-			t.Errorf("Expected error message %q, but got %q", expectedErrorMessage.Error(), st.Message())
-		}
-	}
-}
-
 func TestCreateVolume_YamlApplicationError(t *testing.T) {
 	// Create a scheme and add corev1 to it
 	scheme := runtime.NewScheme()
 	corev1.AddToScheme(scheme)
 
 	// Mock dependencies
-	getKubeConfigFunc = mockGetKubeConfig
 	internal.IsValidKubernetesName = mockIsValidKubernetesName
 	internal.IsValidSize = mockIsValidSize
 	GetConfiguration = mockGetConfiguration
@@ -390,7 +299,9 @@ func TestCreateVolume_YamlApplicationError(t *testing.T) {
 	mockRepo.On("DeletePvc", "test-volume").Return(nil)
 
 	// Setup
-	s := &PVCService{db: mockRepo, ctx: context.Background()}
+	operationRepo, operations := newMockOperationsService()
+	dynamicClient := fake.NewSimpleDynamicClient(scheme)
+	s := &PVCService{db: mockRepo, operations: operations, kube: &KubeClients{Dynamic: dynamicClient}, ctx: context.Background()}
 	ctx := context.Background()
 	size := "10"
 	req := &controller.CreatePvcRequest{
@@ -401,43 +312,22 @@ func TestCreateVolume_YamlApplicationError(t *testing.T) {
 	// Define the expected error message
 	expectedErrorMessage := fmt.Errorf("failed to apply YAML")
 
-	// Create a fake dynamic client and add a reactor to simulate a YAML application error
-	dynamicClient := fake.NewSimpleDynamicClient(scheme)
-	dynamicNewForConfig = func(config *rest.Config) (dynamic.Interface, error) {
-		return dynamicClient, nil
-	}
+	// Add a reactor to the fake dynamic client to simulate a YAML application error
 	dynamicClient.PrependReactor("create", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
 		return true, nil, expectedErrorMessage
 	})
 
-	defer func() {
-		// Restore the original dynamicNewForConfig after the test
-		dynamicNewForConfig = originalDynamicNewForConfig
-	}()
-
 	// Execute
 	_, err := s.CreateVolume(ctx, req)
+	assert.NoError(t, err)
 
-	// Verify
-	if err == nil {
-		t.Errorf("Expected error, but got nil")
-	} else {
-		// Check that the error code is Internal
-		if status.Code(err) != codes.Internal {
-			t.Errorf("Expected error code %v, but got %v", codes.Internal, status.Code(err))
-		}
-
-		// Extract the error status and message
-		st, ok := status.FromError(err)
-		if !ok {
-			t.Errorf("Expected gRPC error status, but got a different error type: %v", err)
-		}
-
-		// Verify the message part only
-		if st.Message() != fmt.Sprintf("error applying YAML: %v", expectedErrorMessage.Error()) {
-			t.Errorf("Expected error message %q, but got %q", expectedErrorMessage, st.Message())
-		}
-	}
+	// Verify the operation failed with the error of the cluster
+	operation := lastOperation(t, operationRepo)
+	assert.Equal(t, repository.OPERATION_FAILED, operation.State)
+	assert.Equal(t, "create-volume", operation.Steps[0].Name)
+	assert.Equal(t, repository.OPERATION_FAILED, operation.Steps[0].State)
+	assert.Equal(t, uint32(codes.Internal), operation.Error.Code)
+	assert.Equal(t, fmt.Sprintf("error applying YAML: %v", expectedErrorMessage.Error()), operation.Error.Message)
 }
 
 // ----- LIST PVC TESTS ----- //
@@ -472,15 +362,6 @@ func (m *MockPVCService) List(ctx context.Context, opts v1.ListOptions) (*corev1
 	return args.Get(0).(*corev1.PersistentVolumeClaimList), args.Error(1)
 }
 
-type MockKubeConfig struct {
-	mock.Mock
-}
-
-func (m *MockKubeConfig) GetKubeConfig() (*rest.Config, error) {
-	args := m.Called()
-	return args.Get(0).(*rest.Config), args.Error(1)
-}
-
 // Test case for successful PVC listing// Test case for successful PVC listing
 func TestListPVCS_Success(t *testing.T) {
 	// Mock Kubernetes clientset
@@ -522,44 +403,25 @@ func TestListPVCS_Success(t *testing.T) {
 	pvcServiceMock.AssertExpectations(t)
 }
 
-type MockKubeConfigFunc struct {
-	mock.Mock
-}
-
-func (m *MockKubeConfigFunc) GetKubeConfig() (*rest.Config, error) {
-	args := m.Called()
-	// Handle nil safely
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	// Cast only if it's non-nil
-	config, ok := args.Get(0).(*rest.Config)
-	if !ok {
-		return nil, errors.New("failed to cast to *rest.Config")
-	}
-	return config, args.Error(1)
-}
-
-// Test case for error during kubeconfig loading
-func TestListPVCS_KubeConfigError(t *testing.T) {
-	kubeConfigMock := new(MockKubeConfigFunc)
-
-	// Set the expectation that getKubeConfigFunc will return an error
-	kubeConfigMock.On("GetKubeConfig").Return(nil, errors.New("failed to load kubeconfig"))
-
-	// Replace the global getKubeConfigFunc with the mocked version
-	getKubeConfigFunc = kubeConfigMock.GetKubeConfig
+// Test case for listing the PVCs of the namespace with the clients of the service
+func TestListPVCS_FromClientset(t *testing.T) {
+	GetConfiguration = mockGetConfiguration
+	defer func() {
+		GetConfiguration = originalGetConfiguration
+	}()
 
-	// Create a PVCService instance
-	pvcService := PVCService{}
+	clientset := kubernetesfake.NewSimpleClientset(
+		&corev1.PersistentVolumeClaim{ObjectMeta: v1.ObjectMeta{Name: "pvc1", Namespace: "kubeflow-user-example-com"}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: v1.ObjectMeta{Name: "pvc2", Namespace: "other-namespace"}},
+	)
+	pvcService := PVCService{kube: &KubeClients{Clientset: clientset}}
 
 	// Call the ListPVCS method
 	response, err := pvcService.ListPVCS(context.TODO(), &controller.ListPvcRequest{})
 
 	// Assertions
-	assert.Error(t, err)
-	assert.Nil(t, response)
-	assert.EqualError(t, err, "error building kubeconfig: failed to load kubeconfig")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pvc1"}, response.PvcNames)
 }
 
 func TestListPVCS_ListError(t *testing.T) {
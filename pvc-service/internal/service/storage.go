@@ -0,0 +1,55 @@
+package service
+
+import (
+	"pvc-service/api/controller"
+	"slices"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var accessModes = map[controller.VolumeAccessMode]string{
+	controller.VolumeAccessMode_READ_WRITE_ONCE:     "ReadWriteOnce",
+	controller.VolumeAccessMode_READ_WRITE_MANY:     "ReadWriteMany",
+	controller.VolumeAccessMode_READ_ONLY_MANY:      "ReadOnlyMany",
+	controller.VolumeAccessMode_READ_WRITE_ONCE_POD: "ReadWriteOncePod",
+}
+
+// VolumeStorage holds the storage class and access mode of a new volume
+type VolumeStorage struct {
+	StorageClass string // Empty uses the default storage class of the cluster
+	AccessMode   string
+}
+
+// Validates the requested storage class and access mode, falling back to the platform defaults
+func resolveVolumeStorage(config Configuration, request *controller.CreatePvcRequest) (*VolumeStorage, error) {
+	storage := &VolumeStorage{
+		StorageClass: config.DefaultStorageClass,
+		AccessMode:   config.DefaultAccessMode,
+	}
+	if storage.AccessMode == "" {
+		storage.AccessMode = accessModes[controller.VolumeAccessMode_READ_WRITE_ONCE]
+	}
+
+	if request.AccessMode != nil {
+		accessMode, exists := accessModes[*request.AccessMode]
+		if !exists {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid access mode: %v", *request.AccessMode)
+		}
+		storage.AccessMode = accessMode
+	}
+
+	if request.StorageClass != nil {
+		if !isStorageClassAllowed(config, *request.StorageClass) {
+			return nil, status.Errorf(codes.InvalidArgument, "storage class %s is not allowed", *request.StorageClass)
+		}
+		storage.StorageClass = *request.StorageClass
+	}
+
+	return storage, nil
+}
+
+// Checks if the storage class may be selected, an empty allow list permits every class
+func isStorageClassAllowed(config Configuration, storageClass string) bool {
+	return len(config.AllowedStorageClasses) == 0 || slices.Contains(config.AllowedStorageClasses, storageClass)
+}
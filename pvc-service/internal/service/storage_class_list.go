@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"log"
+	"pvc-service/api/controller"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const DEFAULT_STORAGE_CLASS_ANNOTATION = "storageclass.kubernetes.io/is-default-class"
+
+var volumeSnapshotClassResource = schema.GroupVersionResource{
+	Group:    "snapshot.storage.k8s.io",
+	Version:  "v1",
+	Resource: "volumesnapshotclasses",
+}
+
+// ListStorageClasses returns the storage classes of the cluster with their capabilities
+func (s *PVCService) ListStorageClasses(ctx context.Context, request *controller.ListStorageClassesRequest) (*controller.ListStorageClassesResponse, error) {
+	environmentConfig := GetConfiguration()
+
+	storageClasses, err := s.kube.Clientset.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list storage classes: %v", err)
+	}
+
+	snapshotDrivers, err := listSnapshotDrivers(ctx, s.kube.Dynamic)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list volume snapshot classes: %v", err)
+	}
+
+	response := &controller.ListStorageClassesResponse{}
+	for _, storageClass := range storageClasses.Items {
+		info := &controller.StorageClass{
+			Name:              storageClass.Name,
+			Provisioner:       storageClass.Provisioner,
+			SupportsSnapshots: snapshotDrivers[storageClass.Provisioner],
+			ClusterDefault:    storageClass.Annotations[DEFAULT_STORAGE_CLASS_ANNOTATION] == "true",
+			PlatformDefault:   storageClass.Name == environmentConfig.DefaultStorageClass,
+			Allowed:           isStorageClassAllowed(environmentConfig, storageClass.Name),
+		}
+		if storageClass.ReclaimPolicy != nil {
+			info.ReclaimPolicy = string(*storageClass.ReclaimPolicy)
+		}
+		if storageClass.VolumeBindingMode != nil {
+			info.VolumeBindingMode = string(*storageClass.VolumeBindingMode)
+		}
+		if storageClass.AllowVolumeExpansion != nil {
+			info.AllowVolumeExpansion = *storageClass.AllowVolumeExpansion
+		}
+
+		response.StorageClasses = append(response.StorageClasses, info)
+	}
+
+	return response, nil
+}
+
+// Returns the CSI drivers that have a volume snapshot class. Clusters without the snapshot CRDs have none, as
+// do the clusters where the service account may not read the snapshot classes.
+func listSnapshotDrivers(ctx context.Context, dynamicClient dynamic.Interface) (map[string]bool, error) {
+	drivers := map[string]bool{}
+
+	snapshotClasses, err := dynamicClient.Resource(volumeSnapshotClassResource).List(ctx, metav1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		return drivers, nil
+	}
+	if apierrors.IsForbidden(err) {
+		log.Printf("Not allowed to list volume snapshot classes, reporting no snapshot support: %v", err)
+		return drivers, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, snapshotClass := range snapshotClasses.Items {
+		driver, found, _ := unstructured.NestedString(snapshotClass.Object, "driver")
+		if found {
+			drivers[driver] = true
+		}
+	}
+
+	return drivers, nil
+}
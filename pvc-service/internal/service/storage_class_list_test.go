@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"pvc-service/api/controller"
+	"pvc-service/internal"
+	"pvc-service/mocks/mock_rbmq"
+	"pvc-service/mocks/mock_repository"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var mockStorageConfiguration = func() Configuration {
+	config := mockGetConfiguration()
+	config.DefaultStorageClass = "standard"
+	config.AllowedStorageClasses = []string{"standard", "nfs"}
+	return config
+}
+
+func TestListStorageClasses_Success(t *testing.T) {
+	expansion := true
+	reclaimPolicy := corev1.PersistentVolumeReclaimDelete
+	storageClasses := []runtime.Object{
+		&storagev1.StorageClass{
+			ObjectMeta: v1.ObjectMeta{
+				Name:        "standard",
+				Annotations: map[string]string{DEFAULT_STORAGE_CLASS_ANNOTATION: "true"},
+			},
+			Provisioner:          "ebs.csi.aws.com",
+			ReclaimPolicy:        &reclaimPolicy,
+			AllowVolumeExpansion: &expansion,
+		},
+		&storagev1.StorageClass{
+			ObjectMeta:  v1.ObjectMeta{Name: "gp2"},
+			Provisioner: "kubernetes.io/aws-ebs",
+		},
+	}
+
+	snapshotClass := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion":     "snapshot.storage.k8s.io/v1",
+		"kind":           "VolumeSnapshotClass",
+		"metadata":       map[string]interface{}{"name": "ebs-snapshots"},
+		"driver":         "ebs.csi.aws.com",
+		"deletionPolicy": "Delete",
+	}}
+
+	dynamicClient := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{volumeSnapshotClassResource: "VolumeSnapshotClassList"},
+		snapshotClass,
+	)
+
+	// Mock dependencies
+	GetConfiguration = mockStorageConfiguration
+
+	defer func() {
+		GetConfiguration = originalGetConfiguration
+	}()
+
+	kube := &KubeClients{Dynamic: dynamicClient, Clientset: kubernetesfake.NewSimpleClientset(storageClasses...)}
+	s := &PVCService{kube: kube, ctx: context.Background()}
+
+	// Execute
+	res, err := s.ListStorageClasses(context.Background(), &controller.ListStorageClassesRequest{})
+
+	// Verify
+	assert.Nil(t, err)
+	assert.Len(t, res.StorageClasses, 2)
+
+	classes := map[string]*controller.StorageClass{}
+	for _, storageClass := range res.StorageClasses {
+		classes[storageClass.Name] = storageClass
+	}
+
+	assert.True(t, classes["standard"].SupportsSnapshots)
+	assert.True(t, classes["standard"].AllowVolumeExpansion)
+	assert.True(t, classes["standard"].ClusterDefault)
+	assert.True(t, classes["standard"].PlatformDefault)
+	assert.True(t, classes["standard"].Allowed)
+	assert.Equal(t, "Delete", classes["standard"].ReclaimPolicy)
+
+	assert.False(t, classes["gp2"].SupportsSnapshots)
+	assert.False(t, classes["gp2"].AllowVolumeExpansion)
+	assert.False(t, classes["gp2"].Allowed)
+}
+
+func TestListStorageClasses_SnapshotClassesForbidden(t *testing.T) {
+	storageClass := &storagev1.StorageClass{ObjectMeta: v1.ObjectMeta{Name: "standard"}, Provisioner: "ebs.csi.aws.com"}
+
+	// The service account isn't allowed to read the cluster-wide snapshot classes
+	dynamicClient := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{volumeSnapshotClassResource: "VolumeSnapshotClassList"},
+	)
+	dynamicClient.PrependReactor("list", volumeSnapshotClassResource.Resource, func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(volumeSnapshotClassResource.GroupResource(), "", errors.New("no cluster role"))
+	})
+
+	// Mock dependencies
+	GetConfiguration = mockStorageConfiguration
+
+	defer func() {
+		GetConfiguration = originalGetConfiguration
+	}()
+
+	kube := &KubeClients{Dynamic: dynamicClient, Clientset: kubernetesfake.NewSimpleClientset(storageClass)}
+	s := &PVCService{kube: kube, ctx: context.Background()}
+
+	// Execute
+	res, err := s.ListStorageClasses(context.Background(), &controller.ListStorageClassesRequest{})
+
+	// Verify, the storage classes are listed without snapshot support
+	assert.Nil(t, err)
+	assert.Len(t, res.StorageClasses, 1)
+	assert.False(t, res.StorageClasses[0].SupportsSnapshots)
+}
+
+func TestCreateVolume_StorageClassNotAllowed(t *testing.T) {
+	// Mock dependencies
+	internal.IsValidKubernetesName = mockIsValidKubernetesName
+	internal.IsValidSize = mockIsValidSize
+	GetConfiguration = mockStorageConfiguration
+
+	defer func() {
+		internal.IsValidKubernetesName = originalIsValidKubernetesName
+		internal.IsValidSize = originalIsValidSize
+		GetConfiguration = originalGetConfiguration
+	}()
+
+	mockRepo := &mock_repository.PvcRepositoryMock{}
+	mockRepo.On("CheckPvcExistsInCache", "test-volume").Return(false, nil)
+
+	s := &PVCService{db: mockRepo, ctx: context.Background()}
+	size := "10"
+	storageClass := "gp2"
+	req := &controller.CreatePvcRequest{
+		Name:         "test-volume",
+		Size:         &size,
+		StorageClass: &storageClass,
+	}
+
+	// Execute
+	_, err := s.CreateVolume(context.Background(), req)
+
+	// Verify
+	assert.ErrorIs(t, err, status.Error(codes.InvalidArgument, "storage class gp2 is not allowed"))
+}
+
+func TestCreateVolume_SelectedStorage(t *testing.T) {
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	dynamicClient := fake.NewSimpleDynamicClient(scheme)
+
+	// Mock dependencies
+	internal.IsValidKubernetesName = mockIsValidKubernetesName
+	internal.IsValidSize = mockIsValidSize
+	GetConfiguration = mockStorageConfiguration
+
+	defer func() {
+		internal.IsValidKubernetesName = originalIsValidKubernetesName
+		internal.IsValidSize = originalIsValidSize
+		GetConfiguration = originalGetConfiguration
+	}()
+
+	rbmq := new(mock_rbmq.RabbitMQClientMock)
+
+	mockRepo := &mock_repository.PvcRepositoryMock{}
+	mockRepo.On("CheckPvcExistsInCache", "test-volume").Return(false, nil)
+	mockRepo.On("CreatePvc", "test-volume").Return(nil)
+
+	_, operations := newMockOperationsService()
+	_, outbox := newMockOutboxRelay(rbmq)
+	s := &PVCService{rbmq: rbmq, db: mockRepo, operations: operations, kube: &KubeClients{Dynamic: dynamicClient}, outbox: outbox, ctx: context.Background()}
+	size := "10"
+	storageClass := "nfs"
+	req := &controller.CreatePvcRequest{
+		Name:         "test-volume",
+		Size:         &size,
+		StorageClass: &storageClass,
+		AccessMode:   controller.VolumeAccessMode_READ_WRITE_MANY.Enum(),
+	}
+
+	// Execute
+	_, err := s.CreateVolume(context.Background(), req)
+
+	// Verify
+	assert.Nil(t, err)
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "persistentvolumeclaims"}
+	pvc, err := dynamicClient.Resource(gvr).Namespace("kubeflow-user-example-com").Get(context.Background(), "test-volume-workspace", v1.GetOptions{})
+	assert.Nil(t, err)
+
+	storageClassName, _, _ := unstructured.NestedString(pvc.Object, "spec", "storageClassName")
+	accessModes, _, _ := unstructured.NestedStringSlice(pvc.Object, "spec", "accessModes")
+	assert.Equal(t, "nfs", storageClassName)
+	assert.Equal(t, []string{"ReadWriteMany"}, accessModes)
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"pvc-service/api/events"
+	"pvc-service/internal/config"
+	"pvc-service/internal/rabbitmq"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	typedv1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// Suffix of the workspace volume of a notebook, named after it
+const WORKSPACE_SUFFIX = "-workspace"
+
+// Annotations of a workspace volume, set by notebook-service when it creates the volume
+const (
+	RETENTION_POLICY_ANNOTATION = "suedataplatform/retention-policy" // Unset uses the configured policy
+	RETENTION_DAYS_ANNOTATION   = "suedataplatform/retention-days"   // Unset uses the configured days
+	OWNER_ANNOTATION            = "suedataplatform/owner"            // User notified before the volume is removed
+)
+
+// Annotations set on a volume kept after the deletion of its notebook, so the retention survives restarts
+const (
+	DELETE_AT_ANNOTATION = "suedataplatform/delete-at"        // Time the volume is removed, in RFC 3339
+	NOTIFIED_ANNOTATION  = "suedataplatform/removal-notified" // Set once PVC.EXPIRE was published
+)
+
+// VolumeRetention applies the retention policy of the workspace volume of a deleted notebook. Volumes kept
+// for some days are removed by the sweeps, once their owner was notified.
+type VolumeRetention struct {
+	kube   *KubeClients
+	outbox *OutboxRelay
+	cfg    config.RetentionConfig
+}
+
+func GenerateVolumeRetention(kube *KubeClients, outbox *OutboxRelay, cfg config.RetentionConfig) *VolumeRetention {
+	return &VolumeRetention{
+		kube:   kube,
+		outbox: outbox,
+		cfg:    cfg,
+	}
+}
+
+// Apply applies the retention policy of a workspace volume. Applying it again leaves the volume as is,
+// so the event it follows can be retried.
+func (r *VolumeRetention) Apply(ctx context.Context, pvcName string, actor string) error {
+	pvc, err := r.client().Get(ctx, pvcName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		// The notebook mounted a volume of its own, or the volume was already removed
+		log.Printf("No workspace volume %s to apply a retention to", pvcName)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed getting PVC %s: %v", pvcName, err)
+	}
+
+	policy, days := r.retentionOf(pvc)
+	switch policy {
+	case config.RETENTION_RETAIN:
+		log.Printf("Keeping PVC %s", pvcName)
+		return nil
+	case config.RETENTION_DELETE:
+		return r.remove(ctx, pvc, actor)
+	}
+
+	if _, scheduled := pvc.Annotations[DELETE_AT_ANNOTATION]; scheduled {
+		return nil
+	}
+
+	deleteAt := time.Now().Add(time.Duration(days) * 24 * time.Hour).UTC()
+	err = r.annotate(ctx, pvcName, map[string]interface{}{DELETE_AT_ANNOTATION: deleteAt.Format(time.RFC3339)})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Keeping PVC %s until %s", pvcName, deleteAt.Format(time.RFC3339))
+	return nil
+}
+
+// Run sweeps the kept volumes until the context is done, at every sweep interval
+func (r *VolumeRetention) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Sweep(ctx); err != nil {
+			log.Printf("Failed sweeping the kept volumes: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep notifies the owners of the kept volumes removed within the notice, and removes the expired volumes.
+// A volume is only removed once its owner was notified, so a volume expired while the service was down is
+// removed on the sweep after the notification.
+func (r *VolumeRetention) Sweep(ctx context.Context) error {
+	pvcs, err := r.client().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed listing PVCs: %v", err)
+	}
+
+	now := time.Now()
+	var errs []error
+	for _, pvc := range pvcs.Items {
+		value, scheduled := pvc.Annotations[DELETE_AT_ANNOTATION]
+		if !scheduled {
+			continue
+		}
+
+		deleteAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid removal time of PVC %s: %v", pvc.Name, err))
+			continue
+		}
+
+		_, notified := pvc.Annotations[NOTIFIED_ANNOTATION]
+		switch {
+		case notified && !now.Before(deleteAt):
+			err = r.remove(ctx, &pvc, "")
+		case !notified && deleteAt.Sub(now) <= r.cfg.NotifyBefore:
+			err = r.notify(ctx, &pvc, deleteAt)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Returns the retention policy and days of a volume, the configured ones filling in the annotations left unset
+func (r *VolumeRetention) retentionOf(pvc *v1.PersistentVolumeClaim) (string, int) {
+	policy, days := r.cfg.Policy, r.cfg.Days
+
+	switch value := pvc.Annotations[RETENTION_POLICY_ANNOTATION]; value {
+	case "":
+	case config.RETENTION_DELETE, config.RETENTION_RETAIN, config.RETENTION_RETAIN_FOR:
+		policy = value
+	default:
+		log.Printf("Invalid retention policy %q of PVC %s, using %s", value, pvc.Name, policy)
+	}
+
+	if value, exists := pvc.Annotations[RETENTION_DAYS_ANNOTATION]; exists {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			log.Printf("Invalid retention days %q of PVC %s, using %d", value, pvc.Name, days)
+		} else {
+			days = parsed
+		}
+	}
+
+	return policy, days
+}
+
+// Publishes PVC.EXPIRE for a kept volume, then marks the volume notified
+func (r *VolumeRetention) notify(ctx context.Context, pvc *v1.PersistentVolumeClaim, deleteAt time.Time) error {
+	key := rabbitmq.GenerateRoutingKey(rabbitmq.PVC, rabbitmq.EXPIRE)
+	err := r.outbox.RecordOnce(ctx, eventKeyOf(key, pvc), key, "", &events.PvcExpiring{
+		PvcName:  pvc.Name,
+		Owner:    pvc.Annotations[OWNER_ANNOTATION],
+		DeleteAt: timestamppb.New(deleteAt),
+	})
+	if err != nil {
+		return fmt.Errorf("failed recording expiry of PVC %s: %v", pvc.Name, err)
+	}
+
+	// Failing here notifies the owner again on the next sweep
+	return r.annotate(ctx, pvc.Name, map[string]interface{}{NOTIFIED_ANNOTATION: time.Now().UTC().Format(time.RFC3339)})
+}
+
+// Records PVC.DELETE and deletes a volume, a volume already deleted is left as is. The event is recorded first,
+// so a failure is retried while the volume still exists rather than losing the event.
+func (r *VolumeRetention) remove(ctx context.Context, pvc *v1.PersistentVolumeClaim, actor string) error {
+	pvcName := pvc.Name
+	key := rabbitmq.GenerateRoutingKey(rabbitmq.PVC, rabbitmq.DELETE)
+	err := r.outbox.RecordOnce(ctx, eventKeyOf(key, pvc), key, actor, &events.PvcDeleted{PvcName: pvcName})
+	if err != nil {
+		return fmt.Errorf("failed recording deletion of PVC %s: %v", pvcName, err)
+	}
+
+	err = r.client().Delete(ctx, pvcName, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed deleting PVC %s: %v", pvcName, err)
+	}
+
+	log.Printf("PVC %s removed by its retention policy", pvcName)
+	return nil
+}
+
+// Returns the key recording an event of a volume once, whichever sweep, retry or replica records it. The UID tells
+// apart a volume created again under the same name.
+func eventKeyOf(eventType string, pvc *v1.PersistentVolumeClaim) string {
+	return eventType + ":" + pvc.Name + ":" + string(pvc.UID)
+}
+
+// Merges annotations into the ones of a volume
+func (r *VolumeRetention) annotate(ctx context.Context, pvcName string, annotations map[string]interface{}) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return fmt.Errorf("failed encoding patch: %v", err)
+	}
+
+	_, err = r.client().Patch(ctx, pvcName, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed annotating PVC %s: %v", pvcName, err)
+	}
+
+	return nil
+}
+
+func (r *VolumeRetention) client() typedv1.PersistentVolumeClaimInterface {
+	return r.kube.Clientset.CoreV1().PersistentVolumeClaims(GetConfiguration().Namespace)
+}
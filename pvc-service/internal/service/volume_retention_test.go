@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"pvc-service/api/events"
+	"pvc-service/internal/config"
+	"pvc-service/internal/rabbitmq"
+	"pvc-service/mocks/mock_rbmq"
+	"pvc-service/mocks/mock_repository"
+	"pvc-service/repository"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var retentionConfig = config.RetentionConfig{
+	Policy:        config.RETENTION_RETAIN_FOR,
+	Days:          7,
+	NotifyBefore:  24 * time.Hour,
+	SweepInterval: time.Minute,
+}
+
+// Creates a workspace volume of the test namespace with the given annotations
+func createWorkspaceVolume(pvcName string, annotations map[string]string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        pvcName,
+			Namespace:   mockGetConfiguration().Namespace,
+			Annotations: annotations,
+		},
+	}
+}
+
+// Creates the volume retention over a fake cluster holding the volumes, with an outbox accepting every event
+func newTestVolumeRetention(t *testing.T, volumes ...runtime.Object) (*VolumeRetention, kubernetes.Interface, *mock_repository.OutboxRepositoryMock) {
+	GetConfiguration = mockGetConfiguration
+	t.Cleanup(func() {
+		GetConfiguration = originalGetConfiguration
+	})
+
+	clientset := kubernetesfake.NewSimpleClientset(volumes...)
+	outboxRepo, outbox := newMockOutboxRelay(new(mock_rbmq.RabbitMQClientMock))
+
+	return GenerateVolumeRetention(&KubeClients{Clientset: clientset}, outbox, retentionConfig), clientset, outboxRepo
+}
+
+func getWorkspaceVolume(t *testing.T, clientset kubernetes.Interface, pvcName string) *corev1.PersistentVolumeClaim {
+	pvc, err := clientset.CoreV1().PersistentVolumeClaims(mockGetConfiguration().Namespace).Get(context.Background(), pvcName, v1.GetOptions{})
+	assert.NoError(t, err)
+	return pvc
+}
+
+func assertVolumeRemoved(t *testing.T, clientset kubernetes.Interface, pvcName string) {
+	_, err := clientset.CoreV1().PersistentVolumeClaims(mockGetConfiguration().Namespace).Get(context.Background(), pvcName, v1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestNotebookDeletedRemovesVolumeWithDeletePolicy(t *testing.T) {
+	retention, clientset, outboxRepo := newTestVolumeRetention(t, createWorkspaceVolume("notebook-a-workspace", map[string]string{
+		RETENTION_POLICY_ANNOTATION: config.RETENTION_DELETE,
+	}))
+
+	event, err := rabbitmq.NewEvent("NOTEBOOK.DELETE", "alice", &events.NotebookDeleted{NotebookName: "notebook-a"})
+	assert.NoError(t, err)
+
+	err = rabbitmq.OnEvent(retention.HandleNotebookDeleted)(context.Background(), event)
+
+	assert.NoError(t, err)
+	assertVolumeRemoved(t, clientset, "notebook-a-workspace")
+
+	recorded := lastRecordedEvent(t, outboxRepo)
+	assert.Equal(t, "PVC.DELETE", recorded.Type)
+	assert.Equal(t, "notebook-a-workspace", recorded.Subject)
+	assert.Equal(t, "alice", recorded.Actor)
+}
+
+func TestNotebookDeletedKeepsVolumeUntilItsDeletionIsRecorded(t *testing.T) {
+	retention, clientset, _ := newTestVolumeRetention(t, createWorkspaceVolume("notebook-a-workspace", map[string]string{
+		RETENTION_POLICY_ANNOTATION: config.RETENTION_DELETE,
+	}))
+	outboxRepo := &mock_repository.OutboxRepositoryMock{}
+	outboxRepo.On("AddEvent", mock.Anything).Return(errors.New("mongo down")).Once()
+	outboxRepo.On("AddEvent", mock.Anything).Return(nil).Once()
+	retention.outbox = GenerateOutboxRelay(outboxRepo, new(mock_rbmq.RabbitMQClientMock), config.OutboxConfig{PollInterval: time.Second, BatchSize: 10})
+
+	event, err := rabbitmq.NewEvent("NOTEBOOK.DELETE", "alice", &events.NotebookDeleted{NotebookName: "notebook-a"})
+	assert.NoError(t, err)
+
+	// The volume is kept when its deletion can't be recorded
+	err = rabbitmq.OnEvent(retention.HandleNotebookDeleted)(context.Background(), event)
+	assert.ErrorContains(t, err, "failed recording deletion of PVC notebook-a-workspace")
+	getWorkspaceVolume(t, clientset, "notebook-a-workspace")
+
+	// So the retry removes it and records its deletion
+	err = rabbitmq.OnEvent(retention.HandleNotebookDeleted)(context.Background(), event)
+	assert.NoError(t, err)
+	assertVolumeRemoved(t, clientset, "notebook-a-workspace")
+	assert.Equal(t, "PVC.DELETE", lastRecordedEvent(t, outboxRepo).Type)
+	outboxRepo.AssertExpectations(t)
+
+	// Both attempts record the deletion under the same id
+	attempted := outboxRepo.Calls[0].Arguments.Get(0).(*repository.OutboxEvent)
+	recorded := outboxRepo.Calls[1].Arguments.Get(0).(*repository.OutboxEvent)
+	assert.Equal(t, attempted.ID, recorded.ID)
+}
+
+func TestNotebookDeletedKeepsVolumeWithRetainPolicy(t *testing.T) {
+	retention, clientset, outboxRepo := newTestVolumeRetention(t, createWorkspaceVolume("notebook-a-workspace", map[string]string{
+		RETENTION_POLICY_ANNOTATION: config.RETENTION_RETAIN,
+	}))
+
+	err := retention.Apply(context.Background(), "notebook-a-workspace", "alice")
+
+	assert.NoError(t, err)
+	assert.NotContains(t, getWorkspaceVolume(t, clientset, "notebook-a-workspace").Annotations, DELETE_AT_ANNOTATION)
+	outboxRepo.AssertNotCalled(t, "AddEvent")
+}
+
+func TestNotebookDeletedSchedulesRemovalWithConfiguredPolicy(t *testing.T) {
+	retention, clientset, _ := newTestVolumeRetention(t, createWorkspaceVolume("notebook-a-workspace", nil))
+
+	err := retention.Apply(context.Background(), "notebook-a-workspace", "alice")
+	assert.NoError(t, err)
+
+	scheduled := getWorkspaceVolume(t, clientset, "notebook-a-workspace").Annotations[DELETE_AT_ANNOTATION]
+	deleteAt, err := time.Parse(time.RFC3339, scheduled)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(7*24*time.Hour), deleteAt, time.Minute)
+
+	// A retried event keeps the first removal time
+	err = retention.Apply(context.Background(), "notebook-a-workspace", "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, scheduled, getWorkspaceVolume(t, clientset, "notebook-a-workspace").Annotations[DELETE_AT_ANNOTATION])
+}
+
+func TestNotebookDeletedSchedulesRemovalWithDaysOfVolume(t *testing.T) {
+	retention, clientset, _ := newTestVolumeRetention(t, createWorkspaceVolume("notebook-a-workspace", map[string]string{
+		RETENTION_POLICY_ANNOTATION: config.RETENTION_RETAIN_FOR,
+		RETENTION_DAYS_ANNOTATION:   "30",
+	}))
+
+	err := retention.Apply(context.Background(), "notebook-a-workspace", "alice")
+	assert.NoError(t, err)
+
+	deleteAt, err := time.Parse(time.RFC3339, getWorkspaceVolume(t, clientset, "notebook-a-workspace").Annotations[DELETE_AT_ANNOTATION])
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), deleteAt, time.Minute)
+}
+
+func TestNotebookDeletedWithoutWorkspaceVolume(t *testing.T) {
+	retention, _, outboxRepo := newTestVolumeRetention(t)
+
+	err := retention.Apply(context.Background(), "notebook-a-workspace", "alice")
+
+	assert.NoError(t, err)
+	outboxRepo.AssertNotCalled(t, "AddEvent")
+}
+
+func TestSweepNotifiesBeforeRemovingVolumes(t *testing.T) {
+	now := time.Now().UTC()
+	retention, clientset, outboxRepo := newTestVolumeRetention(t,
+		createWorkspaceVolume("expiring-workspace", map[string]string{
+			DELETE_AT_ANNOTATION: now.Add(time.Hour).Format(time.RFC3339),
+			OWNER_ANNOTATION:     "alice",
+		}),
+		createWorkspaceVolume("kept-workspace", map[string]string{
+			DELETE_AT_ANNOTATION: now.Add(72 * time.Hour).Format(time.RFC3339),
+		}),
+		createWorkspaceVolume("expired-workspace", map[string]string{
+			DELETE_AT_ANNOTATION: now.Add(-time.Hour).Format(time.RFC3339),
+			NOTIFIED_ANNOTATION:  now.Add(-25 * time.Hour).Format(time.RFC3339),
+		}),
+		createWorkspaceVolume("unscheduled-workspace", nil),
+	)
+
+	err := retention.Sweep(context.Background())
+	assert.NoError(t, err)
+
+	// The volume removed within the notice is only notified
+	expiring := getWorkspaceVolume(t, clientset, "expiring-workspace")
+	assert.Contains(t, expiring.Annotations, NOTIFIED_ANNOTATION)
+	assert.NotContains(t, getWorkspaceVolume(t, clientset, "kept-workspace").Annotations, NOTIFIED_ANNOTATION)
+	assertVolumeRemoved(t, clientset, "expired-workspace")
+	getWorkspaceVolume(t, clientset, "unscheduled-workspace")
+
+	var recorded []string
+	for _, call := range outboxRepo.Calls {
+		if call.Method == "AddEvent" {
+			recorded = append(recorded, call.Arguments.Get(0).(*repository.OutboxEvent).Type)
+		}
+	}
+	assert.ElementsMatch(t, []string{"PVC.EXPIRE", "PVC.DELETE"}, recorded)
+}
+
+func TestSweepNotifiesExpiredVolumesBeforeRemovingThem(t *testing.T) {
+	deleteAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	retention, clientset, outboxRepo := newTestVolumeRetention(t, createWorkspaceVolume("expired-workspace", map[string]string{
+		DELETE_AT_ANNOTATION: deleteAt.Format(time.RFC3339),
+		OWNER_ANNOTATION:     "alice",
+	}))
+
+	// Expired while the service was down, the owner is notified first
+	err := retention.Sweep(context.Background())
+	assert.NoError(t, err)
+	getWorkspaceVolume(t, clientset, "expired-workspace")
+
+	recorded := lastRecordedEvent(t, outboxRepo)
+	assert.Equal(t, "PVC.EXPIRE", recorded.Type)
+	data := &events.PvcExpiring{}
+	assert.NoError(t, recorded.Data.UnmarshalTo(data))
+	assert.Equal(t, "alice", data.Owner)
+	assert.Equal(t, deleteAt, data.DeleteAt.AsTime())
+
+	err = retention.Sweep(context.Background())
+	assert.NoError(t, err)
+	assertVolumeRemoved(t, clientset, "expired-workspace")
+	assert.Equal(t, "PVC.DELETE", lastRecordedEvent(t, outboxRepo).Type)
+}
+
+func TestSweepRecordsTheExpiryOfAVolumeOnce(t *testing.T) {
+	volume := createWorkspaceVolume("expiring-workspace", map[string]string{
+		DELETE_AT_ANNOTATION: time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	})
+	volume.UID = "expiring-uid"
+	retention, clientset, outboxRepo := newTestVolumeRetention(t, volume)
+
+	// The volume can't be marked notified on the first sweep
+	failing := true
+	clientset.(*kubernetesfake.Clientset).PrependReactor("patch", "persistentvolumeclaims", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if failing {
+			failing = false
+			return true, nil, errors.New("api server down")
+		}
+		return false, nil, nil
+	})
+
+	err := retention.Sweep(context.Background())
+	assert.ErrorContains(t, err, "failed annotating PVC expiring-workspace")
+	first := lastRecordedEvent(t, outboxRepo)
+
+	// So the next sweep records the expiry again, under the same id
+	err = retention.Sweep(context.Background())
+	assert.NoError(t, err)
+	assert.Contains(t, getWorkspaceVolume(t, clientset, "expiring-workspace").Annotations, NOTIFIED_ANNOTATION)
+	assert.Equal(t, first.Id, lastRecordedEvent(t, outboxRepo).Id)
+	assert.Equal(t, uuid.NewSHA1(uuid.NameSpaceOID, []byte("PVC.EXPIRE:expiring-workspace:expiring-uid")).String(), first.Id)
+}
@@ -0,0 +1,61 @@
+// Package that sets up the OpenTelemetry tracing of the service
+package tracing
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"pvc-service/internal/config"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"k8s.io/client-go/rest"
+)
+
+// Method to setup the tracer provider of the configured exporter. The OTLP exporter is
+// configured by the standard OTEL_EXPORTER_OTLP_* variables. Without an exporter the trace context is
+// still propagated, but no spans are recorded. Returns a function flushing the remaining spans.
+func SetupTracing(serviceName string) func(context.Context) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch config.Get().Tracing.Exporter {
+	case "":
+		log.Println("Tracing is disabled, no exporter is set")
+		return func(context.Context) error { return nil }
+	case config.OTLP_EXPORTER:
+		exporter, err = otlptracegrpc.New(context.Background())
+	case config.STDOUT_EXPORTER:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		log.Fatalf("unknown tracing exporter %s", config.Get().Tracing.Exporter)
+	}
+	if err != nil {
+		log.Fatalf("failed creating tracing exporter: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown
+}
+
+// Method to trace the requests of every Kubernetes client created from the config
+func InstrumentKubernetes(config *rest.Config) {
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return otelhttp.NewTransport(rt, otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return "kubernetes " + r.Method
+		}))
+	})
+}
@@ -4,11 +4,17 @@ import (
 	"context"
 	"log"
 	"os"
+	"os/signal"
 	"pvc-service/db"
 	"pvc-service/grpc"
+	"pvc-service/internal/config"
+	"pvc-service/internal/health"
+	"pvc-service/internal/metrics"
 	"pvc-service/internal/rabbitmq"
 	"pvc-service/internal/service"
+	"pvc-service/internal/tracing"
 	"pvc-service/repository"
+	"syscall"
 
 	"fmt"
 
@@ -29,16 +35,60 @@ func main() {
 		}
 	}
 
-	rabbitMQ := rabbitmq.NewRabbitMQHandler()
-	defer rabbitMQ.Close()
+	// Load the configuration, the environment variables override the file
+	config.Setup()
 
-	context := context.Background()
-	db := db.Setup(context)
-	pvcRepository := GeneratePvcRepository(db, context)
+	// Stop gracefully on SIGTERM, sent by Kubernetes during rolling deploys, or on an interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	pvcService := service.CreatePVCService(rabbitMQ, pvcRepository, context)
+	// Setup the tracing, flushing the remaining spans on exit
+	shutdownTracing := tracing.SetupTracing("pvc-service")
+	defer shutdownTracing(context.Background())
 
-	listPvcResponse, err := pvcService.ListPVCS(context, &controller.ListPvcRequest{})
+	// Create the kubernetes clients once, they are shared by every request
+	kube, err := service.NewKubeClients(config.Get().Kubernetes)
+	if err != nil {
+		log.Fatalf("Failed setting up kubernetes clients: %v", err)
+	}
+
+	// Serve the metrics
+	metrics.RegisterKubernetesMetrics()
+	metrics.RegisterCountGauge("persistent_volume_claims", "Number of persistent volume claims in the namespace of the service, by namespace.", "namespace", service.CountPvcsByNamespace(kube.Clientset))
+	metrics.SetupMetricsServer()
+
+	rabbitMQ := rabbitmq.NewRabbitMQHandler(config.Get().RabbitMQ, rabbitmq.NewMemoryExchange())
+
+	// Create mongo connection for the operations
+	mongoDB := db.SetupMongoDB()
+
+	db := db.Setup(ctx)
+	pvcRepository := GeneratePvcRepository(db)
+
+	idempotencyRepository := repository.CreateIdempotencyRepository(db)
+	eventDedupRepository := repository.CreateEventDedupRepository(db, config.Get().RabbitMQ.DedupLease, config.Get().RabbitMQ.DedupTTL)
+
+	operationsService := service.GenerateOperationsService(repository.CreateOperationRepository(mongoDB))
+	deadLettersService := service.GenerateDeadLettersService(rabbitMQ)
+	operationsService.FailInterruptedOperations(ctx)
+
+	// Keep the leases of the operations until they were drained at shutdown
+	leaseCtx, stopLeases := context.WithCancel(context.Background())
+	defer stopLeases()
+	go operationsService.Run(leaseCtx)
+
+	// Publish the recorded events in the background, RabbitMQ being down does not fail the operations
+	outboxRepository := repository.CreateOutboxRepository(mongoDB, config.Get().Outbox.Retention)
+	outboxRelay := service.GenerateOutboxRelay(outboxRepository, rabbitMQ, config.Get().Outbox)
+	go outboxRelay.Run(ctx)
+
+	// Remove the kept workspace volumes of the deleted notebooks once expired
+	volumeRetention := service.GenerateVolumeRetention(kube, outboxRelay, config.Get().Retention)
+	go volumeRetention.Run(ctx)
+
+	pvcService := service.CreatePVCService(rabbitMQ, pvcRepository, idempotencyRepository, operationsService, kube, outboxRelay, volumeRetention, eventDedupRepository, ctx)
+
+	listPvcResponse, err := pvcService.ListPVCS(ctx, &controller.ListPvcRequest{})
 	if err != nil {
 		log.Fatalf("Failed to fetch PVCs from Kubernetes: %v", err)
 	}
@@ -48,16 +98,42 @@ func main() {
 	pvcList := listPvcResponse.PvcNames
 
 	// Cache the PVC list in Redis
-	err = pvcRepository.CachePvcList(pvcList)
+	err = pvcRepository.CachePvcList(ctx, pvcList)
 	if err != nil {
 		log.Fatalf("Failed to cache PVC list in Redis: %v", err)
 	}
 	fmt.Println("PVC list successfully cached in Redis.")
 
-	grpc.SetupGRPCServer(pvcService)
+	// Keep checking the dependencies, so the readiness follows them
+	checker := health.NewChecker()
+	checker.AddCheck(health.REDIS, health.Redis(db))
+	checker.AddCheck(health.MONGODB, health.MongoDB(mongoDB.Client()))
+	checker.AddCheck(health.RABBITMQ, health.RabbitMQ(rabbitMQ))
+	checker.AddCheck(health.KUBERNETES, health.Kubernetes(kube.Clientset))
+	go checker.Run(ctx)
+
+	grpc.SetupGRPCServer(ctx, pvcService, operationsService, deadLettersService, checker)
+
+	// Finish the work already accepted before closing the connections it uses
+	rabbitMQ.StopConsuming()
+	drainCtx, cancel := context.WithTimeout(context.Background(), grpc.SHUTDOWN_TIMEOUT)
+	defer cancel()
+	operationsService.Drain(drainCtx)
+	stopLeases()
+
+	// Close the connections in order
+	if err := db.Close(); err != nil {
+		log.Printf("Failed to close redis connection: %v", err)
+	}
+	if err := mongoDB.Client().Disconnect(context.Background()); err != nil {
+		log.Printf("Failed to close mongodb connection: %v", err)
+	}
+	rabbitMQ.Close()
+
+	log.Println("PVC service stopped")
 }
 
-func GeneratePvcRepository(db *redis.Client, context context.Context) repository.PvcRepository {
+func GeneratePvcRepository(db *redis.Client) repository.PvcRepository {
 
-	return repository.CreatePvcRepository(db, context)
+	return repository.CreatePvcRepository(db)
 }
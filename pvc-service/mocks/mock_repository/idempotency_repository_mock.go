@@ -0,0 +1,33 @@
+package mock_repository
+
+import (
+	"context"
+	"pvc-service/repository"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// Mocked idempotency repository
+type IdempotencyRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *IdempotencyRepositoryMock) Reserve(ctx context.Context, key string, payloadHash string, lease time.Duration) (*repository.IdempotencyRecord, bool, error) {
+	args := m.Called(key, payloadHash, lease)
+
+	if record, ok := args.Get(0).(*repository.IdempotencyRecord); ok {
+		return record, args.Bool(1), args.Error(2)
+	}
+	return nil, args.Bool(1), args.Error(2)
+}
+
+func (m *IdempotencyRepositoryMock) Complete(ctx context.Context, key string, record *repository.IdempotencyRecord, ttl time.Duration) error {
+	args := m.Called(key, record, ttl)
+	return args.Error(0)
+}
+
+func (m *IdempotencyRepositoryMock) Release(ctx context.Context, key string) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
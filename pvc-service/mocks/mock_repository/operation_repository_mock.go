@@ -0,0 +1,64 @@
+package mock_repository
+
+import (
+	"context"
+	"pvc-service/repository"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// Mocked operation repository
+type OperationRepositoryMock struct {
+	mock.Mock
+}
+
+func (r *OperationRepositoryMock) CreateOperation(ctx context.Context, operation *repository.Operation) error {
+	args := r.Called(operation)
+	return args.Error(0)
+}
+
+func (r *OperationRepositoryMock) UpdateOperation(ctx context.Context, operation *repository.Operation) error {
+	args := r.Called(operation)
+	return args.Error(0)
+}
+
+func (r *OperationRepositoryMock) GetOperation(ctx context.Context, id string) (*repository.Operation, error) {
+	args := r.Called(id)
+
+	if operation, ok := args.Get(0).(*repository.Operation); ok {
+		return operation, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (r *OperationRepositoryMock) ListOperations(ctx context.Context, username string, kind *string, done *bool, offset int64, limit int64) ([]repository.Operation, error) {
+	args := r.Called(username, kind, done, offset, limit)
+
+	if operations, ok := args.Get(0).([]repository.Operation); ok {
+		return operations, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (r *OperationRepositoryMock) RequestCancel(ctx context.Context, id string) error {
+	args := r.Called(id)
+	return args.Error(0)
+}
+
+func (r *OperationRepositoryMock) CancelRequested(ctx context.Context, id string) (bool, error) {
+	args := r.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (r *OperationRepositoryMock) RenewLeases(ctx context.Context, owner string, expiry time.Time) error {
+	args := r.Called(owner, expiry)
+	return args.Error(0)
+}
+
+func (r *OperationRepositoryMock) FailInterruptedOperations(ctx context.Context, reason repository.OperationError) (int64, error) {
+	args := r.Called(reason)
+	return args.Get(0).(int64), args.Error(1)
+}
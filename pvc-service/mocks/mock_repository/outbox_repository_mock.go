@@ -0,0 +1,44 @@
+package mock_repository
+
+import (
+	"context"
+	"pvc-service/repository"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// Mocked outbox repository
+type OutboxRepositoryMock struct {
+	mock.Mock
+}
+
+func (r *OutboxRepositoryMock) AddEvent(ctx context.Context, event *repository.OutboxEvent) error {
+	args := r.Called(event)
+	return args.Error(0)
+}
+
+func (r *OutboxRepositoryMock) FindPendingEvents(ctx context.Context, limit int64) ([]repository.OutboxEvent, error) {
+	args := r.Called(limit)
+
+	if events, ok := args.Get(0).([]repository.OutboxEvent); ok {
+		return events, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (r *OutboxRepositoryMock) MarkEventSent(ctx context.Context, id string, sentAt time.Time) error {
+	args := r.Called(id)
+	return args.Error(0)
+}
+
+func (r *OutboxRepositoryMock) MarkEventFailed(ctx context.Context, id string, cause string) error {
+	args := r.Called(id, cause)
+	return args.Error(0)
+}
+
+func (r *OutboxRepositoryMock) MarkEventDiscarded(ctx context.Context, id string, cause string, failedAt time.Time) error {
+	args := r.Called(id, cause)
+	return args.Error(0)
+}
@@ -1,6 +1,7 @@
 package mock_repository
 
 import (
+	"context"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -9,22 +10,22 @@ type PvcRepositoryMock struct {
 	mock.Mock
 }
 
-func (m *PvcRepositoryMock) CachePvcList(pvcList []string) error {
+func (m *PvcRepositoryMock) CachePvcList(ctx context.Context, pvcList []string) error {
 	args := m.Called(pvcList)
 	return args.Error(0)
 }
 
-func (m *PvcRepositoryMock) CheckPvcExistsInCache(pvcName string) (bool, error) {
+func (m *PvcRepositoryMock) CheckPvcExistsInCache(ctx context.Context, pvcName string) (bool, error) {
 	args := m.Called(pvcName)
 	return args.Bool(0), args.Error(1)
 }
 
-func (m *PvcRepositoryMock) CreatePvc(pvcName string) error {
+func (m *PvcRepositoryMock) CreatePvc(ctx context.Context, pvcName string) error {
 	args := m.Called(pvcName)
 	return args.Error(0)
 }
 
-func (m *PvcRepositoryMock) DeletePvc(pvcName string) error {
+func (m *PvcRepositoryMock) DeletePvc(ctx context.Context, pvcName string) error {
 	args := m.Called(pvcName)
 	return args.Error(0)
 }
@@ -0,0 +1,10 @@
+package repository
+
+import "context"
+
+// EventDedupRepository remembers the consumed events already handled, shared by every replica of the service
+type EventDedupRepository interface {
+	Claim(ctx context.Context, id string) (bool, bool, error)
+	Complete(ctx context.Context, id string) error
+	Release(ctx context.Context, id string) error
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Value of the key of an event leased to its handler, any other value marking it handled
+const EVENT_PROCESSING = "processing"
+
+type EventDedupRepositoryImpl struct {
+	DB    *redis.Client
+	Lease time.Duration
+	TTL   time.Duration
+}
+
+// Function to create a repository leasing the events for the lease while they are handled, then remembering
+// the handled events for the ttl
+func CreateEventDedupRepository(db *redis.Client, lease time.Duration, ttl time.Duration) EventDedupRepository {
+	return &EventDedupRepositoryImpl{DB: db, Lease: lease, TTL: ttl}
+}
+
+// Function to lease the event to the handler, returning whether it was handled when it is taken
+func (repo *EventDedupRepositoryImpl) Claim(ctx context.Context, id string) (bool, bool, error) {
+	key := fmt.Sprintf("event:processed:%s", id)
+	claimed, err := repo.DB.SetNX(ctx, key, EVENT_PROCESSING, repo.Lease).Result()
+	if err != nil {
+		return false, false, fmt.Errorf("failed to claim event %s: %w", id, err)
+	}
+	if claimed {
+		return true, false, nil
+	}
+
+	value, err := repo.DB.Get(ctx, key).Result()
+	if err == redis.Nil {
+		// The lease expired in between, so try again
+		return repo.Claim(ctx, id)
+	}
+	if err != nil {
+		return false, false, fmt.Errorf("failed to get event %s: %w", id, err)
+	}
+
+	return false, value != EVENT_PROCESSING, nil
+}
+
+// Function to mark the event handled for the ttl
+func (repo *EventDedupRepositoryImpl) Complete(ctx context.Context, id string) error {
+	if err := repo.DB.Set(ctx, fmt.Sprintf("event:processed:%s", id), time.Now().Unix(), repo.TTL).Err(); err != nil {
+		return fmt.Errorf("failed to complete event %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// Function to forget the event, so it is handled again on its retry
+func (repo *EventDedupRepositoryImpl) Release(ctx context.Context, id string) error {
+	if err := repo.DB.Del(ctx, fmt.Sprintf("event:processed:%s", id)).Err(); err != nil {
+		return fmt.Errorf("failed to release event %s: %w", id, err)
+	}
+
+	return nil
+}
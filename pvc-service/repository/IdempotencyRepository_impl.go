@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type IdempotencyRepositoryImpl struct {
+	DB *redis.Client
+}
+
+// Function to create an idempotency repository
+func CreateIdempotencyRepository(db *redis.Client) IdempotencyRepository {
+	return &IdempotencyRepositoryImpl{DB: db}
+}
+
+// Function to claim the key for a new request, returning the stored record if the key is already taken
+func (repo *IdempotencyRepositoryImpl) Reserve(ctx context.Context, key string, payloadHash string, lease time.Duration) (*IdempotencyRecord, bool, error) {
+	record, err := json.Marshal(&IdempotencyRecord{PayloadHash: payloadHash})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode idempotency record: %w", err)
+	}
+
+	reserved, err := repo.DB.SetNX(ctx, fmt.Sprintf("idempotency:%s", key), record, lease).Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if reserved {
+		return nil, true, nil
+	}
+
+	stored, err := repo.DB.Get(ctx, fmt.Sprintf("idempotency:%s", key)).Bytes()
+	if err == redis.Nil {
+		// The reservation expired in between, so try again
+		return repo.Reserve(ctx, key, payloadHash, lease)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get idempotency record: %w", err)
+	}
+
+	var existing IdempotencyRecord
+	if err := json.Unmarshal(stored, &existing); err != nil {
+		return nil, false, fmt.Errorf("failed to decode idempotency record: %w", err)
+	}
+	return &existing, false, nil
+}
+
+// Function to store the outcome of a request for the given time
+func (repo *IdempotencyRepositoryImpl) Complete(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode idempotency record: %w", err)
+	}
+
+	err = repo.DB.Set(ctx, fmt.Sprintf("idempotency:%s", key), value, ttl).Err()
+	if err != nil {
+		return fmt.Errorf("failed to store idempotency record: %w", err)
+	}
+	return nil
+}
+
+// Function to free the key so the request can be executed again
+func (repo *IdempotencyRepositoryImpl) Release(ctx context.Context, key string) error {
+	err := repo.DB.Del(ctx, fmt.Sprintf("idempotency:%s", key)).Err()
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// OutboxEvent is an event recorded with the state change it announces, kept until the relay published it
+type OutboxEvent struct {
+	ID        string     `bson:"_id"` // Id of the event envelope
+	Type      string     `bson:"type"`
+	Envelope  []byte     `bson:"envelope"` // Serialized event envelope, published as is
+	CreatedAt time.Time  `bson:"createdAt"`
+	SentAt    *time.Time `bson:"sentAt,omitempty"` // Unset until RabbitMQ confirmed the event
+	Attempts  int        `bson:"attempts"`         // Failed publications
+	LastError string     `bson:"lastError,omitempty"`
+	FailedAt  *time.Time `bson:"failedAt,omitempty"` // Set when the event can never be published, the relay skips it from then on
+}
+
+type OutboxRepository interface {
+	AddEvent(ctx context.Context, event *OutboxEvent) error
+	FindPendingEvents(ctx context.Context, limit int64) ([]OutboxEvent, error)
+	MarkEventSent(ctx context.Context, id string, sentAt time.Time) error
+	MarkEventFailed(ctx context.Context, id string, cause string) error
+	MarkEventDiscarded(ctx context.Context, id string, cause string, failedAt time.Time) error
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type OutboxRepositoryImpl struct {
+	DB *mongo.Collection
+}
+
+// Function to create an outbox repository, removing the sent events after the retention
+func CreateOutboxRepository(db *mongo.Database, retention time.Duration) OutboxRepository {
+	coll := db.Collection("outbox")
+
+	idxModels := []mongo.IndexModel{
+		// Unsent events are read oldest first
+		{Keys: bson.D{{Key: "sentAt", Value: 1}, {Key: "failedAt", Value: 1}, {Key: "createdAt", Value: 1}}},
+		// Events without sentAt never expire
+		{Keys: bson.D{{Key: "sentAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(retention.Seconds())).SetName("sentAt_ttl")},
+	}
+
+	_, err := coll.Indexes().CreateMany(context.Background(), idxModels)
+	if err != nil {
+		log.Fatalf("failed creating indexes for the outbox: %v", err)
+	}
+
+	return &OutboxRepositoryImpl{DB: coll}
+}
+
+// Function to store a new event in the outbox, an event already stored under its id being left as is
+func (repo *OutboxRepositoryImpl) AddEvent(ctx context.Context, event *OutboxEvent) error {
+	_, err := repo.DB.InsertOne(ctx, event)
+	if mongo.IsDuplicateKeyError(err) {
+		// Already recorded by an earlier attempt
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed storing event %s in the outbox: %w", event.ID, err)
+	}
+
+	return nil
+}
+
+// Function to get the events not sent yet, oldest first. The discarded events are left out.
+func (repo *OutboxRepositoryImpl) FindPendingEvents(ctx context.Context, limit int64) ([]OutboxEvent, error) {
+	filter := bson.M{"sentAt": bson.M{"$exists": false}, "failedAt": bson.M{"$exists": false}}
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(limit)
+
+	cursor, err := repo.DB.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing pending events: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var events []OutboxEvent
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, fmt.Errorf("failed decoding pending events: %w", err)
+	}
+
+	return events, nil
+}
+
+// Function to mark an event published
+func (repo *OutboxRepositoryImpl) MarkEventSent(ctx context.Context, id string, sentAt time.Time) error {
+	_, err := repo.DB.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"sentAt": sentAt}})
+	if err != nil {
+		return fmt.Errorf("failed marking event %s sent: %w", id, err)
+	}
+
+	return nil
+}
+
+// Function to count a failed publication, the event stays pending
+func (repo *OutboxRepositoryImpl) MarkEventFailed(ctx context.Context, id string, cause string) error {
+	update := bson.M{
+		"$inc": bson.M{"attempts": 1},
+		"$set": bson.M{"lastError": cause},
+	}
+
+	_, err := repo.DB.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return fmt.Errorf("failed recording the failure of event %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// Function to mark an event that can never be published, it is kept for inspection but no longer pending
+func (repo *OutboxRepositoryImpl) MarkEventDiscarded(ctx context.Context, id string, cause string, failedAt time.Time) error {
+	update := bson.M{
+		"$inc": bson.M{"attempts": 1},
+		"$set": bson.M{"lastError": cause, "failedAt": failedAt},
+	}
+
+	_, err := repo.DB.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return fmt.Errorf("failed discarding event %s: %w", id, err)
+	}
+
+	return nil
+}
@@ -1,8 +1,10 @@
 package repository
 
+import "context"
+
 type PvcRepository interface {
-	CachePvcList(pvcList []string) error
-	CheckPvcExistsInCache(pvcName string) (bool, error)
-	CreatePvc(pvcName string) error
-	DeletePvc(pvcName string) error
+	CachePvcList(ctx context.Context, pvcList []string) error
+	CheckPvcExistsInCache(ctx context.Context, pvcName string) (bool, error)
+	CreatePvc(ctx context.Context, pvcName string) error
+	DeletePvc(ctx context.Context, pvcName string) error
 }
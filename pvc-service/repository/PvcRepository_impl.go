@@ -8,20 +8,19 @@ import (
 )
 
 type PvcRepositoryImpl struct {
-	DB      *redis.Client
-	context context.Context
+	DB *redis.Client
 }
 
 // Function to create a PVC repository
-func CreatePvcRepository(db *redis.Client, context context.Context) PvcRepository {
-	return &PvcRepositoryImpl{DB: db, context: context}
+func CreatePvcRepository(db *redis.Client) PvcRepository {
+	return &PvcRepositoryImpl{DB: db}
 }
 
 // Function to cache the list of PVCs in the database
-func (pvcRepo *PvcRepositoryImpl) CachePvcList(pvcList []string) error {
+func (pvcRepo *PvcRepositoryImpl) CachePvcList(ctx context.Context, pvcList []string) error {
 	for _, pvcName := range pvcList {
 		// Store each PVC as an individual key
-		err := pvcRepo.DB.Set(pvcRepo.context, fmt.Sprintf("pvc:%s", pvcName), "true", 0).Err()
+		err := pvcRepo.DB.Set(ctx, fmt.Sprintf("pvc:%s", pvcName), "true", 0).Err()
 		if err != nil {
 			return fmt.Errorf("failed to cache PVC %s: %w", pvcName, err)
 		}
@@ -30,9 +29,9 @@ func (pvcRepo *PvcRepositoryImpl) CachePvcList(pvcList []string) error {
 }
 
 // Function to check if a PVC exists in the cache
-func (pvcRepo *PvcRepositoryImpl) CheckPvcExistsInCache(pvcName string) (bool, error) {
+func (pvcRepo *PvcRepositoryImpl) CheckPvcExistsInCache(ctx context.Context, pvcName string) (bool, error) {
 	// Check if the key exists in Redis
-	result, err := pvcRepo.DB.Exists(pvcRepo.context, fmt.Sprintf("pvc:%s", pvcName)).Result()
+	result, err := pvcRepo.DB.Exists(ctx, fmt.Sprintf("pvc:%s", pvcName)).Result()
 	if err != nil {
 		return false, fmt.Errorf("failed to check PVC existence: %w", err)
 	}
@@ -40,9 +39,9 @@ func (pvcRepo *PvcRepositoryImpl) CheckPvcExistsInCache(pvcName string) (bool, e
 }
 
 // Function to create a PVC in the database
-func (pvcRepo *PvcRepositoryImpl) CreatePvc(pvcName string) error {
+func (pvcRepo *PvcRepositoryImpl) CreatePvc(ctx context.Context, pvcName string) error {
 	// Add a new PVC to the Redis cache
-	err := pvcRepo.DB.Set(pvcRepo.context, fmt.Sprintf("pvc:%s", pvcName), "true", 0).Err()
+	err := pvcRepo.DB.Set(ctx, fmt.Sprintf("pvc:%s", pvcName), "true", 0).Err()
 	if err != nil {
 		return fmt.Errorf("failed to create PVC %s: %w", pvcName, err)
 	}
@@ -50,9 +49,9 @@ func (pvcRepo *PvcRepositoryImpl) CreatePvc(pvcName string) error {
 }
 
 // Function to delete a PVC in the database
-func (pvcRepo *PvcRepositoryImpl) DeletePvc(pvcName string) error {
+func (pvcRepo *PvcRepositoryImpl) DeletePvc(ctx context.Context, pvcName string) error {
 	// Remove the PVC from the Redis cache
-	err := pvcRepo.DB.Del(pvcRepo.context, fmt.Sprintf("pvc:%s", pvcName)).Err()
+	err := pvcRepo.DB.Del(ctx, fmt.Sprintf("pvc:%s", pvcName)).Err()
 	if err != nil {
 		return fmt.Errorf("failed to delete PVC %s: %w", pvcName, err)
 	}
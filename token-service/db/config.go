@@ -3,8 +3,9 @@ package db
 import (
 	"context"
 	"log"
-	"os"
+	"token-service/internal/config"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -12,16 +13,20 @@ var db *redis.Client
 
 // Method to setup the redis database
 func Setup(c context.Context) *redis.Client {
-	url := os.Getenv("REDIS_URL")
-	password := os.Getenv("REDIS_PASSWORD")
+	cfg := config.Get().Redis
 
 	// Connect to redis
 	db = redis.NewClient(&redis.Options{
-		Addr:     url,
-		Password: password,
+		Addr:     cfg.URL,
+		Password: cfg.Password,
 		DB:       0,
 	})
 
+	// Trace every redis command
+	if err := redisotel.InstrumentTracing(db); err != nil {
+		log.Printf("Failed to instrument redis tracing: %v", err)
+	}
+
 	// Check connectivity
 	err := db.Ping(c).Err()
 
@@ -1,30 +1,35 @@
 package grpc
 
 import (
+	"context"
 	"log"
 	"net"
-	"os"
 	"token-service/api/controller"
+	"token-service/internal/config"
+	"token-service/internal/health"
+	"token-service/internal/metrics"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 )
 
-func SetupGRPCServer(tokenService controller.TokenServer) {
+func SetupGRPCServer(ctx context.Context, tokenService controller.TokenServer, checker *health.Checker) {
 	server, lis, url := CreateGRPCServer()
 	// Register the service
 	controller.RegisterTokenServer(server, tokenService)
+	checker.Register(server)
 
 	// Run the server
 	log.Printf("gRPC server running on %s", url)
-	if err := server.Serve(lis); err != nil {
+	if err := serve(ctx, server, lis, checker); err != nil {
 		log.Fatalf("Unable to run gRPC server on %s", url)
 	}
-
+	log.Println("gRPC server stopped")
 }
 
 func CreateGRPCServer() (*grpc.Server, net.Listener, string) {
 	// Listen the tcp port for grpc server
-	url := os.Getenv("SERVER_URL")
+	url := config.Get().Server.URL
 
 	lis, err := net.Listen("tcp", url)
 	if err != nil {
@@ -32,7 +37,7 @@ func CreateGRPCServer() (*grpc.Server, net.Listener, string) {
 	}
 
 	// Create a new gRPC server
-	server := grpc.NewServer()
+	server := grpc.NewServer(grpc.UnaryInterceptor(metrics.UnaryServerInterceptor), grpc.StatsHandler(otelgrpc.NewServerHandler()))
 
 	return server, lis, url
 }
@@ -0,0 +1,73 @@
+// Package that loads the typed configuration of the service from a file and the environment
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Exporters selectable for the tracing
+const (
+	OTLP_EXPORTER   = "otlp"
+	STDOUT_EXPORTER = "stdout"
+)
+
+// Configuration of the service. Each field can be set in the YAML file and overridden by its
+// environment variable. Secrets are redacted when the configuration is printed.
+type Config struct {
+	Server  ServerConfig  `yaml:"server"`
+	Metrics MetricsConfig `yaml:"metrics"`
+	Tracing TracingConfig `yaml:"tracing"`
+	Auth    AuthConfig    `yaml:"auth"`
+	Redis   RedisConfig   `yaml:"redis"`
+	Kong    KongConfig    `yaml:"kong"`
+	Teams   TeamsConfig   `yaml:"teams"`
+}
+
+type ServerConfig struct {
+	URL string `yaml:"url" env:"SERVER_URL" required:"true"`
+}
+
+type MetricsConfig struct {
+	URL string `yaml:"url" env:"METRICS_URL" required:"true"`
+}
+
+type TracingConfig struct {
+	Exporter string `yaml:"exporter" env:"TRACING_EXPORTER"` // Empty disables the tracing
+}
+
+type AuthConfig struct {
+	SecretKey string `yaml:"secretKey" env:"SECRET_KEY" required:"true" secret:"true"` // Key signing the JWT of the users
+}
+
+type RedisConfig struct {
+	URL      string `yaml:"url" env:"REDIS_URL" required:"true"`
+	Password string `yaml:"password" env:"REDIS_PASSWORD" secret:"true"`
+}
+
+type KongConfig struct {
+	ConsumerAdminURI string `yaml:"consumerAdminURI" env:"KONG_CONSUMER_ADMIN_URI" required:"true"` // Admin endpoint registering the consumers
+}
+
+type TeamsConfig struct {
+	Members map[string]string `yaml:"members"` // Team of each user, issued in the team claim of their tokens. Only set by the administrators in the config file.
+}
+
+// Returns the configuration used for the settings left unset
+func Default() *Config {
+	return &Config{
+		Server:  ServerConfig{URL: ":50051"},
+		Metrics: MetricsConfig{URL: ":9090"},
+	}
+}
+
+// Checks the values that can't be described by the tags
+func (c *Config) validate() []error {
+	var errs []error
+
+	if !slices.Contains([]string{"", OTLP_EXPORTER, STDOUT_EXPORTER}, c.Tracing.Exporter) {
+		errs = append(errs, fmt.Errorf("tracing.exporter must be %s or %s, got %q", OTLP_EXPORTER, STDOUT_EXPORTER, c.Tracing.Exporter))
+	}
+
+	return errs
+}
@@ -0,0 +1,19 @@
+package health
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Names of the dependencies, under which their status is served
+const (
+	REDIS = "redis"
+)
+
+// Checks that redis answers a ping
+func Redis(client *redis.Client) Check {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}
+}
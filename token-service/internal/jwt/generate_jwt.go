@@ -1,15 +1,15 @@
 package jwt
 
 import (
-	"os"
 	"time"
+	"token-service/internal/config"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
-// Function to generate token
-var GenerateToken = func(key, role string) (string, error) {
-	secret := os.Getenv("SECRET_KEY")
+// Function to generate token, the team claim is only set for users with a team
+var GenerateToken = func(key, role, team string) (string, error) {
+	secret := config.Get().Auth.SecretKey
 
 	// Create claims
 	claims := jwt.MapClaims{
@@ -17,6 +17,9 @@ var GenerateToken = func(key, role string) (string, error) {
 		"role":         role,
 		"expired_time": time.Now().Add(time.Hour * 24).Unix(),
 	}
+	if team != "" {
+		claims["team"] = team
+	}
 
 	// Create the token
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"os"
+	"token-service/internal/config"
 
 	"github.com/go-resty/resty/v2"
 )
@@ -33,7 +33,7 @@ var RegisterConsumer = func(username string) error {
 	}
 
 	// Get kong admin's consumer url
-	url := os.Getenv("KONG_CONSUMER_ADMIN_URI")
+	url := config.Get().Kong.ConsumerAdminURI
 
 	// Create resty client
 	client := resty.New()
@@ -55,8 +55,8 @@ var RegisterConsumer = func(username string) error {
 		return err
 	}
 
-	// Get jwt secret from env
-	secret := os.Getenv("SECRET_KEY")
+	// Get jwt secret from the configuration
+	secret := config.Get().Auth.SecretKey
 
 	// Set the form data
 	formData := map[string]string{
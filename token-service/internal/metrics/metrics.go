@@ -0,0 +1,32 @@
+// Package that exposes the prometheus metrics of the service
+package metrics
+
+import (
+	"log"
+	"net/http"
+	"time"
+	"token-service/internal/config"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Method to serve the metrics on the configured url in the background
+func SetupMetricsServer() {
+	url := config.Get().Metrics.URL
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{
+		Addr:              url,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		log.Printf("Metrics server running on %s", url)
+		if err := server.ListenAndServe(); err != nil {
+			log.Printf("Unable to run metrics server on %s: %v", url, err)
+		}
+	}()
+}
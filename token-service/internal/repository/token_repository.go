@@ -1,8 +1,11 @@
 package repository
 
-import "token-service/internal/model"
+import (
+	"context"
+	"token-service/internal/model"
+)
 
 type TokenRepository interface {
-	CreateUser(username string, user *model.User) error
-	FindUserByUsername(username string) (*model.User, error)
+	CreateUser(ctx context.Context, username string, user *model.User) error
+	FindUserByUsername(ctx context.Context, username string) (*model.User, error)
 }
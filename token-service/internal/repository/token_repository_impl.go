@@ -9,30 +9,29 @@ import (
 )
 
 type TokenRepositoryImpl struct {
-	DB      *redis.Client
-	context context.Context
+	DB *redis.Client
 }
 
 // Function to create a token repository
-func CreateTokenRepository(db *redis.Client, context context.Context) TokenRepository {
-	return &TokenRepositoryImpl{DB: db, context: context}
+func CreateTokenRepository(db *redis.Client) TokenRepository {
+	return &TokenRepositoryImpl{DB: db}
 }
 
 // Function that create an entry of token in database
-func (tokenRepo *TokenRepositoryImpl) CreateUser(username string, user *model.User) error {
+func (tokenRepo *TokenRepositoryImpl) CreateUser(ctx context.Context, username string, user *model.User) error {
 	// Encode the user data
 	payload, err := json.Marshal(user)
 	if err != nil {
 		return err
 	}
 
-	return tokenRepo.DB.Set(tokenRepo.context, username, payload, 0).Err()
+	return tokenRepo.DB.Set(ctx, username, payload, 0).Err()
 }
 
 // Function that retrieve token based on username
-func (tokenRepo *TokenRepositoryImpl) FindUserByUsername(username string) (*model.User, error) {
+func (tokenRepo *TokenRepositoryImpl) FindUserByUsername(ctx context.Context, username string) (*model.User, error) {
 	// Get the payload
-	payload, err := tokenRepo.DB.Get(tokenRepo.context, username).Result()
+	payload, err := tokenRepo.DB.Get(ctx, username).Result()
 
 	if err != nil {
 		return nil, err
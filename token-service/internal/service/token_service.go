@@ -7,6 +7,7 @@ import (
 	"sync"
 	"token-service/api/controller"
 	"token-service/internal/bcrypt"
+	"token-service/internal/config"
 	"token-service/internal/jwt"
 	"token-service/internal/kong"
 	"token-service/internal/model"
@@ -30,7 +31,7 @@ func (tokenService *TokenService) Login(context context.Context, request *contro
 	username := request.Username
 
 	// Get token from database
-	user, err := tokenService.tokenRepo.FindUserByUsername(username)
+	user, err := tokenService.tokenRepo.FindUserByUsername(context, username)
 
 	if err == redis.Nil {
 		// hash the password
@@ -68,7 +69,7 @@ func (tokenService *TokenService) Login(context context.Context, request *contro
 				Password: hashedPassword,
 				Role:     request.Role,
 			}
-			redisErr := tokenService.tokenRepo.CreateUser(username, user)
+			redisErr := tokenService.tokenRepo.CreateUser(context, username, user)
 
 			if redisErr != nil {
 				errChan <- fmt.Errorf("failed storing the token: %v", redisErr)
@@ -98,8 +99,8 @@ func (tokenService *TokenService) Login(context context.Context, request *contro
 		return nil, errors.New("invalid role")
 	}
 
-	// Generate new token if username not found in database
-	token, err := jwt.GenerateToken(username, role)
+	// Generate new token if username not found in database, with the team the administrators gave the user
+	token, err := jwt.GenerateToken(username, role, config.Get().Teams.Members[username])
 
 	if err != nil {
 		return nil, fmt.Errorf("failed generating a token: %v", err)
@@ -4,11 +4,17 @@ import (
 	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 	"token-service/api/controller"
 	"token-service/db"
 	"token-service/grpc"
+	"token-service/internal/config"
+	"token-service/internal/health"
+	"token-service/internal/metrics"
 	"token-service/internal/repository"
 	"token-service/internal/service"
+	"token-service/internal/tracing"
 
 	"github.com/joho/godotenv"
 	"github.com/redis/go-redis/v9"
@@ -24,22 +30,46 @@ func main() {
 		}
 	}
 
+	// Load the configuration, the environment variables override the file
+	config.Setup()
+
+	// Stop gracefully on SIGTERM, sent by Kubernetes during rolling deploys, or on an interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Setup the tracing, flushing the remaining spans on exit
+	shutdownTracing := tracing.SetupTracing("token-service")
+	defer shutdownTracing(context.Background())
+
+	// Serve the metrics
+	metrics.SetupMetricsServer()
+
 	// Setup database
-	context := context.Background()
-	db := db.Setup(context)
+	db := db.Setup(ctx)
 
 	// Create the token service
-	tokenService := GenerateTokenService(db, context)
+	tokenService := GenerateTokenService(db)
+
+	// Keep checking the dependencies, so the readiness follows them
+	checker := health.NewChecker()
+	checker.AddCheck(health.REDIS, health.Redis(db))
+	go checker.Run(ctx)
 
 	// Setup grpc server
-	grpc.SetupGRPCServer(tokenService)
+	grpc.SetupGRPCServer(ctx, tokenService, checker)
+
+	// Close the connection once the running calls finished
+	if err := db.Close(); err != nil {
+		log.Printf("Failed to close redis connection: %v", err)
+	}
 
+	log.Println("Token service stopped")
 }
 
 // Method to generate the token service
-func GenerateTokenService(db *redis.Client, context context.Context) controller.TokenServer {
+func GenerateTokenService(db *redis.Client) controller.TokenServer {
 	// Create the token repository
-	tokenRepo := repository.CreateTokenRepository(db, context)
+	tokenRepo := repository.CreateTokenRepository(db)
 
 	// Create and return the token service
 	return service.CreateTokenService(tokenRepo)
@@ -1,6 +1,7 @@
 package mock_repository
 
 import (
+	"context"
 	"token-service/internal/model"
 
 	"github.com/stretchr/testify/mock"
@@ -12,14 +13,14 @@ type TokenRepositoryMock struct {
 }
 
 // Mock token creation in db method
-func (tokenRepoMock *TokenRepositoryMock) CreateUser(username string, user *model.User) error {
+func (tokenRepoMock *TokenRepositoryMock) CreateUser(ctx context.Context, username string, user *model.User) error {
 	args := tokenRepoMock.Called(username, user)
 
 	return args.Error(0)
 }
 
 // Mock the find token by username method
-func (tokenRepoMock *TokenRepositoryMock) FindUserByUsername(username string) (*model.User, error) {
+func (tokenRepoMock *TokenRepositoryMock) FindUserByUsername(ctx context.Context, username string) (*model.User, error) {
 	args := tokenRepoMock.Called(username)
 
 	// Mock the returned user model
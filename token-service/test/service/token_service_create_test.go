@@ -8,12 +8,14 @@ import (
 	"testing"
 	"token-service/api/controller"
 	"token-service/internal/bcrypt"
+	"token-service/internal/config"
 	"token-service/internal/jwt"
 	"token-service/internal/kong"
 	"token-service/internal/model"
 	"token-service/internal/service"
 	"token-service/test/mock/mock_repository"
 
+	gojwt "github.com/dgrijalva/jwt-go"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -283,7 +285,7 @@ func TestCreateTokenInvalidRole(t *testing.T) {
 // Method to mock jwt creation method
 func mockJWTGenerateToken(token string, err error) func() {
 	// Create the mock method
-	mockFunc := func(string, string) (string, error) {
+	mockFunc := func(string, string, string) (string, error) {
 		return token, err
 	}
 
@@ -365,3 +367,86 @@ func TestCreateToken_SuccessCreateToken(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, expectedResponse, actualResponse)
 }
+
+// Returns the claims of a token signed with the configured secret
+func parseToken(t *testing.T, token string) gojwt.MapClaims {
+	claims := gojwt.MapClaims{}
+	_, err := gojwt.ParseWithClaims(token, claims, func(*gojwt.Token) (interface{}, error) {
+		return []byte(config.Get().Auth.SecretKey), nil
+	})
+	assert.Nil(t, err)
+	return claims
+}
+
+// Method to assign teams to users as the administrators do in the config file
+func mockTeams(members map[string]string) func() {
+	oldMembers := config.Get().Teams.Members
+	config.Get().Teams.Members = members
+
+	return func() {
+		config.Get().Teams.Members = oldMembers
+	}
+}
+
+// Test the team the administrators assigned to a new user is issued in the token
+func TestCreateTokenIssuesTheTeamOfNewUsers(t *testing.T) {
+	restoreTeams := mockTeams(map[string]string{"new-user": "data-science"})
+	defer restoreTeams()
+
+	request := &controller.LoginRequest{
+		Username: "new-user",
+		Password: "user",
+		Role:     controller.Role_DS.Enum(),
+	}
+	tokenRepoMock.On("FindUserByUsername", request.Username).Return(nil, redis.Nil).Once()
+	tokenRepoMock.On("CreateUser", request.Username, mock.Anything).Return(nil).Once()
+
+	restoreHash := mockHash("hashed", nil)
+	defer restoreHash()
+	restoreConsumerFunc := mockRegisterConsumer(nil)
+	defer restoreConsumerFunc()
+
+	response, err := tokenService.Login(context.Background(), request)
+
+	assert.Nil(t, err)
+	claims := parseToken(t, response.Token)
+	assert.Equal(t, "new-user", claims["iss"])
+	assert.Equal(t, "DS", claims["role"])
+	assert.Equal(t, "data-science", claims["team"])
+}
+
+// Test the team of an existing user follows the one the administrators assign
+func TestCreateTokenIssuesTheTeamOfExistingUsers(t *testing.T) {
+	restoreCompare := mockCompare(true)
+	defer restoreCompare()
+
+	user := &model.User{Password: "password", Role: controller.Role_DS.Enum()}
+	request := &controller.LoginRequest{Username: "old-user", Password: "user"}
+	tokenRepoMock.On("FindUserByUsername", request.Username).Return(user, nil).Twice()
+
+	restoreTeams := mockTeams(map[string]string{"old-user": "platform"})
+	response, err := tokenService.Login(context.Background(), request)
+	restoreTeams()
+	assert.Nil(t, err)
+	assert.Equal(t, "platform", parseToken(t, response.Token)["team"])
+
+	restoreTeams = mockTeams(map[string]string{"old-user": "data-science"})
+	defer restoreTeams()
+	response, err = tokenService.Login(context.Background(), request)
+	assert.Nil(t, err)
+	assert.Equal(t, "data-science", parseToken(t, response.Token)["team"])
+}
+
+// Test users without a team get a token without the team claim
+func TestCreateTokenWithoutTeam(t *testing.T) {
+	restoreCompare := mockCompare(true)
+	defer restoreCompare()
+
+	user := &model.User{Password: "password", Role: controller.Role_DS.Enum()}
+	tokenRepoMock.On("FindUserByUsername", "no-team").Return(user, nil).Once()
+
+	response, err := tokenService.Login(context.Background(), &controller.LoginRequest{Username: "no-team", Password: "user"})
+
+	assert.Nil(t, err)
+	assert.NotContains(t, parseToken(t, response.Token), "team")
+}
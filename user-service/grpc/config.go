@@ -1,32 +1,37 @@
 package grpc
 
 import (
-	"fmt"
+	"context"
 	"log"
 	"net"
 	"user-service/api/controller"
+	"user-service/internal/config"
+	"user-service/internal/health"
+	"user-service/internal/metrics"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 )
 
-func SetupGRPCServer(pvcService controller.UserServiceServer) {
+func SetupGRPCServer(ctx context.Context, pvcService controller.UserServiceServer, checker *health.Checker) {
 	// Listen the tcp port for grpc server
-	port := "50051"
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
+	url := config.Get().Server.URL
+	lis, err := net.Listen("tcp", url)
 	if err != nil {
-		log.Fatalf("Failed listening to tcp port %s: %v", port, err)
+		log.Fatalf("Failed listening to tcp %s: %v", url, err)
 	}
 
 	// Create a new gRPC server
-	server := grpc.NewServer()
+	server := grpc.NewServer(grpc.UnaryInterceptor(metrics.UnaryServerInterceptor), grpc.StatsHandler(otelgrpc.NewServerHandler()))
 
 	// Register the service
 	controller.RegisterUserServiceServer(server, pvcService)
+	checker.Register(server)
 
 	// Run the server
-	log.Printf("gRPC server running on port %s", port)
-	if err := server.Serve(lis); err != nil {
-		log.Fatalf("Unable to run gRPC server on port %s", port)
+	log.Printf("gRPC server running on %s", url)
+	if err := serve(ctx, server, lis, checker); err != nil {
+		log.Fatalf("Unable to run gRPC server on %s", url)
 	}
-
+	log.Println("gRPC server stopped")
 }
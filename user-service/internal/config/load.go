@@ -0,0 +1,208 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Value printed in place of a secret
+const REDACTED = "<redacted>"
+
+var current = Default()
+
+// Returns the loaded configuration, or the defaults before it was loaded
+func Get() *Config {
+	return current
+}
+
+// Flags selecting the configuration
+var (
+	configFile  = flag.String("config", os.Getenv("CONFIG_FILE"), "path of the YAML configuration file, CONFIG_FILE by default")
+	printConfig = flag.Bool("print-config", false, "print the configuration with the secrets redacted and exit")
+)
+
+// Setup parses the flags and loads the configuration, exiting with every problem found when it is invalid.
+// With --print-config the configuration is printed, even an invalid one, and the service exits.
+func Setup() *Config {
+	flag.Parse()
+
+	config, err := Load(*configFile)
+	if *printConfig {
+		if printErr := Print(os.Stdout, config); printErr != nil {
+			log.Fatalf("failed printing configuration: %v", printErr)
+		}
+	}
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+	if *printConfig {
+		os.Exit(0)
+	}
+
+	return config
+}
+
+// Load reads the defaults, the optional file and the environment overrides in that order, then
+// validates the result. Every problem is reported at once, so a deploy can be fixed in one go.
+// The configuration is returned even when invalid, but only becomes the current one when valid.
+func Load(path string) (*Config, error) {
+	config := Default()
+	var errs []error
+
+	if path != "" {
+		if err := readFile(path, config); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, applyEnvironment(reflect.ValueOf(config).Elem())...)
+	errs = append(errs, checkRequired(reflect.ValueOf(config).Elem(), "")...)
+	errs = append(errs, config.validate()...)
+
+	if len(errs) > 0 {
+		return config, errors.Join(errs...)
+	}
+
+	current = config
+	return config, nil
+}
+
+// Print writes the configuration as YAML, with the secrets redacted
+func Print(w io.Writer, config *Config) error {
+	redacted := *config
+	redact(reflect.ValueOf(&redacted).Elem())
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	defer encoder.Close()
+	return encoder.Encode(&redacted)
+}
+
+func readFile(path string, config *Config) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed opening config file: %v", err)
+	}
+	defer file.Close()
+
+	// Reject unknown keys, a typo would otherwise be silently ignored
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && err != io.EOF {
+		return fmt.Errorf("failed decoding config file %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// Overrides the fields with an env tag by the environment variables that are set
+func applyEnvironment(v reflect.Value) []error {
+	var errs []error
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		tag := v.Type().Field(i).Tag
+
+		if field.Kind() == reflect.Struct {
+			errs = append(errs, applyEnvironment(field)...)
+			continue
+		}
+
+		name := tag.Get("env")
+		value, exists := os.LookupEnv(name)
+		if name == "" || !exists {
+			continue
+		}
+
+		if err := setField(field, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", name, err))
+		}
+	}
+
+	return errs
+}
+
+func setField(field reflect.Value, value string) error {
+	switch {
+	case field.Type() == reflect.TypeOf(time.Duration(0)):
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("expected a duration, got %q", value)
+		}
+		field.SetInt(int64(duration))
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	case field.Kind() == reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("expected a boolean, got %q", value)
+		}
+		field.SetBool(parsed)
+	case field.Kind() == reflect.Int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %q", value)
+		}
+		field.SetInt(int64(parsed))
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+		// Comma separated, ignoring empty entries
+		var values []string
+		for _, entry := range strings.Split(value, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				values = append(values, entry)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
+
+// Reports every field tagged as required that is still empty
+func checkRequired(v reflect.Value, prefix string) []error {
+	var errs []error
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		structField := v.Type().Field(i)
+		path := prefix + strings.Split(structField.Tag.Get("yaml"), ",")[0]
+
+		if field.Kind() == reflect.Struct {
+			errs = append(errs, checkRequired(field, path+".")...)
+			continue
+		}
+
+		if structField.Tag.Get("required") == "true" && field.IsZero() {
+			errs = append(errs, fmt.Errorf("%s is required, set it in the config file or with %s", path, structField.Tag.Get("env")))
+		}
+	}
+
+	return errs
+}
+
+// Replaces the secrets that are set with a placeholder
+func redact(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+
+		if field.Kind() == reflect.Struct {
+			redact(field)
+			continue
+		}
+
+		if v.Type().Field(i).Tag.Get("secret") == "true" && !field.IsZero() {
+			field.SetString(REDACTED)
+		}
+	}
+}
@@ -3,25 +3,30 @@ package service
 import (
 	"flag"
 	"path/filepath"
+	"user-service/internal/tracing"
 
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
 )
 
-var getKubeConfig = func() (*rest.Config, error) {
-	var kubeconfig *string
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
-	} else {
-		kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
-	}
-	flag.Parse()
+// Defined up front, so it is parsed along with the other flags of the service
+var kubeconfig = flag.String("kubeconfig", defaultKubeconfig(), "(optional) absolute path to the kubeconfig file")
 
+var getKubeConfig = func() (*rest.Config, error) {
 	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
 	if err != nil {
 		return nil, err
 	}
+	tracing.InstrumentKubernetes(config)
 
 	return config, nil
 }
+
+// Uses the kubeconfig of the home directory by default
+func defaultKubeconfig() string {
+	if home := homedir.HomeDir(); home != "" {
+		return filepath.Join(home, ".kube", "config")
+	}
+	return ""
+}
@@ -1,17 +1,43 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 	"user-service/grpc"
+	"user-service/internal/config"
+	"user-service/internal/health"
+	"user-service/internal/metrics"
 	"user-service/internal/service"
+	"user-service/internal/tracing"
 
 	"github.com/joho/godotenv"
 )
 
 func main() {
+	// Stop gracefully on SIGTERM, sent by Kubernetes during rolling deploys, or on an interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Load the configuration, the environment variables override the file
+	config.Setup()
+
+	// Setup the tracing, flushing the remaining spans on exit
+	shutdownTracing := tracing.SetupTracing("user-service")
+	defer shutdownTracing(context.Background())
+
+	metrics.SetupMetricsServer()
+
+	// The users are kept in memory, so there is no dependency to check
+	checker := health.NewChecker()
+	go checker.Run(ctx)
+
 	userService := service.CreateUserService()
-	grpc.SetupGRPCServer(userService)
+	grpc.SetupGRPCServer(ctx, userService, checker)
+
+	log.Println("User service stopped")
 }
 
 func init() {